@@ -0,0 +1,750 @@
+package api
+
+import (
+	"database/sql"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxSummaryWeeks is the most weeks an activity summary may cover
+const maxSummaryWeeks = 52
+
+type activity struct {
+	ID               uint      `json:"activity_id" example:"1"`
+	PetID            uint      `json:"pet_id" example:"1"`
+	UserID           uint      `json:"user_id" example:"1"`
+	Kind             string    `json:"kind" example:"walk"`
+	StartedAt        time.Time `json:"started_at" example:"2019-11-09T07:30:00Z"`
+	DurationSeconds  int64     `json:"duration_seconds" example:"2700"`
+	DistanceMeters   float64   `json:"distance_meters" example:"3200"`
+	PaceSecondsPerKm *int64    `json:"pace_seconds_per_km,omitempty" example:"843"`
+	ElevationGainM   *float64  `json:"elevation_gain_m,omitempty" example:"42"`
+	ElevationLossM   *float64  `json:"elevation_loss_m,omitempty" example:"40"`
+	HasRoute         bool      `json:"has_route" example:"true"`
+	Notes            string    `json:"notes" example:"Morning loop around the park"`
+	CreatedAt        time.Time `json:"created_at" example:"2019-11-09T21:21:46+00:00"`
+	UpdatedAt        time.Time `json:"updated_at" example:"2019-11-09T21:21:46+00:00"`
+}
+
+type activityRequest struct {
+	Kind            string    `json:"kind" example:"walk" validate:"required,oneof=walk run play training"`
+	StartedAt       time.Time `json:"started_at" example:"2019-11-09T07:30:00Z" validate:"required"`
+	DurationSeconds int64     `json:"duration_seconds" example:"2700" validate:"required,min=1,max=86400"`
+	DistanceMeters  float64   `json:"distance_meters" example:"3200" validate:"min=0,max=500000"`
+	Notes           string    `json:"notes" example:"Morning loop around the park" validate:"max=2000"`
+}
+
+type activities []activity
+
+// activityGoal is what a pet should do in a week, a zero target is no goal
+type activityGoal struct {
+	WeeklyMinutes        int64   `json:"weekly_minutes" example:"210" validate:"min=0"`
+	WeeklyDistanceMeters float64 `json:"weekly_distance_meters" example:"20000" validate:"min=0"`
+	WeeklySessions       int     `json:"weekly_sessions" example:"7" validate:"min=0"`
+}
+
+type activityWeek struct {
+	WeekStart           string         `json:"week_start" example:"2019-11-04"`
+	Sessions            int            `json:"sessions" example:"5"`
+	Minutes             int64          `json:"minutes" example:"180"`
+	DistanceMeters      float64        `json:"distance_meters" example:"15400"`
+	ByKind              map[string]int `json:"by_kind"`
+	MinutesGoalPercent  *int           `json:"minutes_goal_percent,omitempty" example:"86"`
+	DistanceGoalPercent *int           `json:"distance_goal_percent,omitempty" example:"77"`
+	SessionsGoalPercent *int           `json:"sessions_goal_percent,omitempty" example:"71"`
+	GoalMet             *bool          `json:"goal_met,omitempty" example:"false"`
+}
+
+type activitySummary struct {
+	Goal  activityGoal   `json:"goal"`
+	Weeks []activityWeek `json:"weeks"`
+}
+
+const activityColumns = "id, pet_id, user_id, kind, started_at, duration_seconds, distance_meters, elevation_gain_m, elevation_loss_m, has_route, notes, created_at, updated_at"
+
+//scanActivity scans a row selected with activityColumns
+func scanActivity(row interface{ Scan(...interface{}) error }) (activity, error) {
+	var a activity
+	var gain, loss sql.NullFloat64
+	var notes sql.NullString
+	err := row.Scan(&a.ID, &a.PetID, &a.UserID, &a.Kind, &a.StartedAt, &a.DurationSeconds, &a.DistanceMeters, &gain, &loss, &a.HasRoute, &notes, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return a, err
+	}
+	if gain.Valid {
+		a.ElevationGainM = &gain.Float64
+	}
+	if loss.Valid {
+		a.ElevationLossM = &loss.Float64
+	}
+	a.Notes = notes.String
+	a.computePace()
+	return a, nil
+}
+
+//computePace sets the pace from the duration and distance
+func (a *activity) computePace() {
+	a.PaceSecondsPerKm = nil
+	if a.DistanceMeters >= 1 && a.DurationSeconds > 0 {
+		pace := int64(math.Round(float64(a.DurationSeconds) / (a.DistanceMeters / 1000)))
+		a.PaceSecondsPerKm = &pace
+	}
+}
+
+//validateActivity normalizes and checks an activity request
+func validateActivity(req *activityRequest) error {
+	req.Kind = strings.ToLower(strings.TrimSpace(req.Kind))
+	req.Notes = strings.TrimSpace(req.Notes)
+	return validate(req)
+}
+
+//formatDistance formats a distance in meters as kilometers, e.g. 3240 is "3.2 km"
+func formatDistance(meters float64) string {
+	return strconv.FormatFloat(math.Round(meters/100)/10, 'f', -1, 64) + " km"
+}
+
+//percentOf returns how much of a target was reached, nil without a target
+func percentOf(done, target float64) *int {
+	if target <= 0 {
+		return nil
+	}
+	p := int(math.Floor(done / target * 100))
+	return &p
+}
+
+//weekStart returns the Monday, UTC, of the week t falls in
+func weekStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+//summarizeActivities buckets activities into weeks starting on Monday, UTC,
+//newest week first, and measures each against the goal
+func summarizeActivities(acts []activity, goal activityGoal, now time.Time, weeks int) []activityWeek {
+	current := weekStart(now)
+	res := make([]activityWeek, weeks)
+	seconds := make([]int64, weeks)
+	for i := range res {
+		res[i] = activityWeek{WeekStart: current.AddDate(0, 0, -7*i).Format("2006-01-02"), ByKind: map[string]int{}}
+	}
+	for _, a := range acts {
+		i := int(math.Round(current.Sub(weekStart(a.StartedAt)).Hours() / 24 / 7))
+		if i < 0 || i >= weeks {
+			continue
+		}
+		w := &res[i]
+		w.Sessions++
+		w.DistanceMeters += a.DistanceMeters
+		w.ByKind[a.Kind]++
+		seconds[i] += a.DurationSeconds
+	}
+	for i := range res {
+		w := &res[i]
+		w.Minutes = seconds[i] / 60
+		w.MinutesGoalPercent = percentOf(float64(w.Minutes), float64(goal.WeeklyMinutes))
+		w.DistanceGoalPercent = percentOf(w.DistanceMeters, goal.WeeklyDistanceMeters)
+		w.SessionsGoalPercent = percentOf(float64(w.Sessions), float64(goal.WeeklySessions))
+		set := false
+		met := true
+		for _, p := range []*int{w.MinutesGoalPercent, w.DistanceGoalPercent, w.SessionsGoalPercent} {
+			if p != nil {
+				set = true
+				met = met && *p >= 100
+			}
+		}
+		if set {
+			w.GoalMet = &met
+		}
+	}
+	return res
+}
+
+//activityIDFromRequest is a helper to extract the activity ID URL param
+func activityIDFromRequest(r *http.Request) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)["activityID"], 10, 64)
+}
+
+//dbActivitiesGetAll returns the activities of a pet owned by a user, newest first
+func (s *server) dbActivitiesGetAll(userID, petID int64, from, to time.Time) ([]activity, error) {
+	q := "SELECT " + activityColumns + " FROM activities WHERE user_id = $1 AND pet_id = $2 AND deleted_at IS NULL"
+	args := []interface{}{userID, petID}
+	if !from.IsZero() {
+		args = append(args, from)
+		q += " AND started_at >= $" + strconv.Itoa(len(args))
+	}
+	if !to.IsZero() {
+		args = append(args, to)
+		q += " AND started_at < $" + strconv.Itoa(len(args))
+	}
+	rows, err := s.db.Query(q+" ORDER BY started_at DESC, id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	acts := []activity{}
+	for rows.Next() {
+		a, err := scanActivity(rows)
+		if err != nil {
+			return nil, err
+		}
+		acts = append(acts, a)
+	}
+	return acts, rows.Err()
+}
+
+//dbActivitiesGetOne returns a single activity of a pet owned by a user
+func (s *server) dbActivitiesGetOne(userID, petID, activityID int64) (activity, error) {
+	row := s.db.QueryRow("SELECT "+activityColumns+" FROM activities WHERE user_id = $1 AND pet_id = $2 AND id = $3 AND deleted_at IS NULL", userID, petID, activityID)
+	return scanActivity(row)
+}
+
+//dbActivitiesCreate stores a new activity, with its route as GeoJSON when
+//it has one
+func (s *server) dbActivitiesCreate(a activity, route []byte) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow(`INSERT INTO activities(pet_id, user_id, kind, started_at, duration_seconds, distance_meters, elevation_gain_m, elevation_loss_m, has_route, notes, created_at, updated_at)
+		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12) RETURNING id`,
+		a.PetID, a.UserID, a.Kind, a.StartedAt, a.DurationSeconds, a.DistanceMeters, a.ElevationGainM, a.ElevationLossM, a.HasRoute, nullString(a.Notes), a.CreatedAt, a.UpdatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if route != nil {
+		_, err = tx.Exec("INSERT INTO activity_routes(activity_id, geojson) VALUES($1,$2)", id, string(route))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+//dbActivitiesUpdate updates an activity of a pet owned by a user
+func (s *server) dbActivitiesUpdate(a activity) (int64, error) {
+	res, err := s.db.Exec("UPDATE activities SET kind = $1, started_at = $2, duration_seconds = $3, distance_meters = $4, notes = $5, updated_at = $6 WHERE id = $7 AND user_id = $8 AND pet_id = $9 AND deleted_at IS NULL",
+		a.Kind, a.StartedAt, a.DurationSeconds, a.DistanceMeters, nullString(a.Notes), a.UpdatedAt, a.ID, a.UserID, a.PetID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbActivitiesDelete deletes an activity of a pet owned by a user, along with its route
+func (s *server) dbActivitiesDelete(userID, petID, activityID int64) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM activities WHERE user_id = $1 AND pet_id = $2 AND id = $3 AND deleted_at IS NULL", userID, petID, activityID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbActivitiesGetRoute returns the GeoJSON route of an activity of a pet owned by a user
+func (s *server) dbActivitiesGetRoute(userID, petID, activityID int64) (string, error) {
+	var route string
+	err := s.db.QueryRow(`SELECT r.geojson FROM activity_routes r JOIN activities a ON a.id = r.activity_id
+		WHERE a.user_id = $1 AND a.pet_id = $2 AND a.id = $3 AND a.deleted_at IS NULL`, userID, petID, activityID).Scan(&route)
+	return route, err
+}
+
+//dbActivityGoalGet returns the weekly activity goal of a pet, zero when it has none
+func (s *server) dbActivityGoalGet(petID int64) (activityGoal, error) {
+	var g activityGoal
+	err := s.db.QueryRow("SELECT weekly_minutes, weekly_distance_meters, weekly_sessions FROM activity_goals WHERE pet_id = $1", petID).
+		Scan(&g.WeeklyMinutes, &g.WeeklyDistanceMeters, &g.WeeklySessions)
+	if err == sql.ErrNoRows {
+		return g, nil
+	}
+	return g, err
+}
+
+//dbActivityGoalSet sets the weekly activity goal of a pet
+func (s *server) dbActivityGoalSet(petID int64, g activityGoal) error {
+	_, err := s.db.Exec(`INSERT INTO activity_goals(pet_id, weekly_minutes, weekly_distance_meters, weekly_sessions, updated_at) VALUES($1,$2,$3,$4,$5)
+		ON CONFLICT (pet_id) DO UPDATE SET weekly_minutes = excluded.weekly_minutes, weekly_distance_meters = excluded.weekly_distance_meters,
+		weekly_sessions = excluded.weekly_sessions, updated_at = excluded.updated_at`,
+		petID, g.WeeklyMinutes, g.WeeklyDistanceMeters, g.WeeklySessions, time.Now())
+	return err
+}
+
+// handlerActivitiesGetAll godoc
+// @Summary Get all activities of a pet
+// @Description Get the walks, runs, play and training sessions of a pet, newest first
+// @Tags Activities
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param from query string false "Earliest date, YYYY-MM-DD"
+// @Param to query string false "Date before which to stop, YYYY-MM-DD"
+// @Success 200 {array} activity
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/activities [get]
+func (s *server) handlerActivitiesGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		from, err := parseDateParam(r, "from")
+		if err != nil {
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+		to, err := parseDateParam(r, "to")
+		if err != nil {
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+		acts, err := s.dbActivitiesGetAll(userID, petID, from, to)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving activities from database")
+			s.respond(w, r, nil, "error retrieving activities", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, acts, "", http.StatusOK)
+	}
+}
+
+// handlerActivitiesGetOne godoc
+// @Summary Get one activity
+// @Description Get one activity of a pet
+// @Tags Activities
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param ActivityID path int true "Activity ID"
+// @Success 200 {object} activity
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/activities/{ActivityID} [get]
+func (s *server) handlerActivitiesGetOne() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		activityID, err := activityIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid activity id", http.StatusBadRequest)
+			return
+		}
+		a, err := s.dbActivitiesGetOne(userID, petID, activityID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "activity not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving activity from database")
+			s.respond(w, r, nil, "error retrieving activity", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, a, "", http.StatusOK)
+	}
+}
+
+// handlerActivitiesCreate godoc
+// @Summary Log an activity
+// @Description Log a walk, run, play or training session of a pet without a route
+// @Tags Activities
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param activity body activityRequest true "Create Activity"
+// @Success 201 {object} activity
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/activities [post]
+func (s *server) handlerActivitiesCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok || !s.requirePet(w, r, userID, petID) {
+			return
+		}
+
+		// Get JSON body, decode into an activity request and validate it
+		var req activityRequest
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateActivity(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking activity")
+			return
+		}
+
+		// Create the activity in the db
+		a := activity{
+			PetID:           uint(petID),
+			UserID:          uint(userID),
+			Kind:            req.Kind,
+			StartedAt:       req.StartedAt,
+			DurationSeconds: req.DurationSeconds,
+			DistanceMeters:  req.DistanceMeters,
+			Notes:           req.Notes,
+			CreatedAt:       ts,
+			UpdatedAt:       ts,
+		}
+		a.computePace()
+		id, err := s.dbActivitiesCreate(a, nil)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error creating activity in database")
+			s.respond(w, r, nil, "error creating activity", http.StatusInternalServerError)
+			return
+		}
+		a.ID = uint(id)
+		s.respond(w, r, a, "", http.StatusCreated)
+	}
+}
+
+// handlerActivitiesImport godoc
+// @Summary Import an activity from a GPX or GeoJSON file
+// @Description Import a route recorded by a phone or tracker as a GPX or GeoJSON file. Distance, elevation gain and loss, duration and pace are computed from the route. Routes without timestamps need started_at and duration_seconds form fields.
+// @Tags Activities
+// @Accept mpfd
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param file formData file true "GPX or GeoJSON file"
+// @Param kind formData string false "walk, run, play or training, defaults to walk"
+// @Param notes formData string false "Notes"
+// @Param started_at formData string false "Start time, RFC 3339, when the route has no timestamps"
+// @Param duration_seconds formData int false "Duration, when the route has no timestamps"
+// @Success 201 {object} activity
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/activities/import [post]
+func (s *server) handlerActivitiesImport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok || !s.requirePet(w, r, userID, petID) {
+			return
+		}
+
+		// Read the file and work out the route
+		up, err := s.readUpload(w, r, "file", routeAllowedTypes)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error reading upload")
+			return
+		}
+		points, err := parseRoute(up.Data)
+		if err != nil {
+			s.respondError(w, r, invalidField("file", err.Error()), "error reading route")
+			return
+		}
+		st, err := computeRouteStats(points)
+		if err != nil {
+			s.respondError(w, r, invalidField("file", err.Error()), "error reading route")
+			return
+		}
+
+		// Times in the route win over form fields
+		req := activityRequest{
+			Kind:           r.FormValue("kind"),
+			DistanceMeters: math.Round(st.DistanceMeters*10) / 10,
+			Notes:          r.FormValue("notes"),
+		}
+		if req.Kind == "" {
+			req.Kind = "walk"
+		}
+		if st.HasTimes && st.DurationSeconds > 0 {
+			req.StartedAt = st.StartedAt
+			req.DurationSeconds = st.DurationSeconds
+		} else {
+			req.StartedAt, _ = time.Parse(time.RFC3339, r.FormValue("started_at"))
+			req.DurationSeconds, _ = strconv.ParseInt(r.FormValue("duration_seconds"), 10, 64)
+		}
+		err = validateActivity(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking activity")
+			return
+		}
+		route, err := routeGeoJSON(st.SimplifiedPoints)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error encoding route")
+			s.respond(w, r, nil, "error importing activity", http.StatusInternalServerError)
+			return
+		}
+
+		// Create the activity in the db
+		gain := math.Round(st.ElevationGainM)
+		loss := math.Round(st.ElevationLossM)
+		a := activity{
+			PetID:           uint(petID),
+			UserID:          uint(userID),
+			Kind:            req.Kind,
+			StartedAt:       req.StartedAt,
+			DurationSeconds: req.DurationSeconds,
+			DistanceMeters:  req.DistanceMeters,
+			ElevationGainM:  &gain,
+			ElevationLossM:  &loss,
+			HasRoute:        true,
+			Notes:           req.Notes,
+			CreatedAt:       ts,
+			UpdatedAt:       ts,
+		}
+		a.computePace()
+		id, err := s.dbActivitiesCreate(a, route)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error creating activity in database")
+			s.respond(w, r, nil, "error importing activity", http.StatusInternalServerError)
+			return
+		}
+		a.ID = uint(id)
+		s.respond(w, r, a, "", http.StatusCreated)
+	}
+}
+
+// handlerActivitiesRoute godoc
+// @Summary Get the route of an activity
+// @Description Get the route of an imported activity as a GeoJSON LineString feature
+// @Tags Activities
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param ActivityID path int true "Activity ID"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/activities/{ActivityID}/route [get]
+func (s *server) handlerActivitiesRoute() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		activityID, err := activityIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid activity id", http.StatusBadRequest)
+			return
+		}
+		route, err := s.dbActivitiesGetRoute(userID, petID, activityID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "route not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving route from database")
+			s.respond(w, r, nil, "error retrieving route", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/geo+json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(route))
+	}
+}
+
+// handlerActivitiesUpdate godoc
+// @Summary Update an activity
+// @Description Update an activity of a pet. The route of an imported activity is kept as is.
+// @Tags Activities
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param ActivityID path int true "Activity ID"
+// @Param activity body activityRequest true "Updated Activity"
+// @Success 200 {object} activity
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/activities/{ActivityID} [put]
+func (s *server) handlerActivitiesUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		activityID, err := activityIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid activity id", http.StatusBadRequest)
+			return
+		}
+		a, err := s.dbActivitiesGetOne(userID, petID, activityID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "activity not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving activity from database")
+			s.respond(w, r, nil, "error updating activity", http.StatusInternalServerError)
+			return
+		}
+
+		// Get JSON body, decode into an activity request and validate it
+		var req activityRequest
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateActivity(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking activity")
+			return
+		}
+
+		// Update the activity in the db
+		a.Kind = req.Kind
+		a.StartedAt = req.StartedAt
+		a.DurationSeconds = req.DurationSeconds
+		a.DistanceMeters = req.DistanceMeters
+		a.Notes = req.Notes
+		a.UpdatedAt = ts
+		a.computePace()
+		_, err = s.dbActivitiesUpdate(a)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error updating activity in database")
+			s.respond(w, r, nil, "error updating activity", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, a, "", http.StatusOK)
+	}
+}
+
+// handlerActivitiesDelete godoc
+// @Summary Delete an activity
+// @Description Delete an activity of a pet along with its route
+// @Tags Activities
+// @Param PetID path int true "Pet ID"
+// @Param ActivityID path int true "Activity ID"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/activities/{ActivityID} [delete]
+func (s *server) handlerActivitiesDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		activityID, err := activityIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid activity id", http.StatusBadRequest)
+			return
+		}
+		rows, err := s.dbActivitiesDelete(userID, petID, activityID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error deleting activity from database")
+			s.respond(w, r, nil, "error deleting activity", http.StatusInternalServerError)
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "activity not found", http.StatusNotFound)
+			return
+		}
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
+
+// handlerActivityGoalGet godoc
+// @Summary Get the weekly activity goal of a pet
+// @Description Get the weekly activity goal of a pet. Targets of zero are not set.
+// @Tags Activities
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Success 200 {object} activityGoal
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/activities/goal [get]
+func (s *server) handlerActivityGoalGet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok || !s.requirePet(w, r, userID, petID) {
+			return
+		}
+		g, err := s.dbActivityGoalGet(petID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving activity goal from database")
+			s.respond(w, r, nil, "error retrieving activity goal", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, g, "", http.StatusOK)
+	}
+}
+
+// handlerActivityGoalUpdate godoc
+// @Summary Set the weekly activity goal of a pet
+// @Description Set how many minutes, meters and sessions of activity a pet should get each week. Set a target to zero to drop it.
+// @Tags Activities
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param goal body activityGoal true "Weekly Goal"
+// @Success 200 {object} activityGoal
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/activities/goal [put]
+func (s *server) handlerActivityGoalUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok || !s.requirePet(w, r, userID, petID) {
+			return
+		}
+		var g activityGoal
+		err := s.decode(w, r, &g)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validate(g)
+		if err != nil {
+			s.respondError(w, r, err, "error checking goal")
+			return
+		}
+		err = s.dbActivityGoalSet(petID, g)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error updating activity goal in database")
+			s.respond(w, r, nil, "error updating activity goal", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, g, "", http.StatusOK)
+	}
+}
+
+// handlerActivitySummary godoc
+// @Summary Get weekly activity summaries of a pet
+// @Description Get the sessions, minutes and distance of a pet per week, Monday to Sunday in UTC, newest week first, measured against its weekly goal
+// @Tags Activities
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param weeks query int false "Number of weeks including the current one, 1 to 52, defaults to 4"
+// @Success 200 {object} activitySummary
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/activities/summary [get]
+func (s *server) handlerActivitySummary() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		weeks := 4
+		if v := r.URL.Query().Get("weeks"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxSummaryWeeks {
+				s.respondProblem(w, r, invalidField("weeks", "weeks must be between 1 and 52"))
+				return
+			}
+			weeks = n
+		}
+		if !s.requirePet(w, r, userID, petID) {
+			return
+		}
+
+		g, err := s.dbActivityGoalGet(petID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving activity goal from database")
+			s.respond(w, r, nil, "error retrieving activity summary", http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		from := weekStart(now).AddDate(0, 0, -7*(weeks-1))
+		acts, err := s.dbActivitiesGetAll(userID, petID, from, time.Time{})
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving activities from database")
+			s.respond(w, r, nil, "error retrieving activity summary", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, activitySummary{Goal: g, Weeks: summarizeActivities(acts, g, now, weeks)}, "", http.StatusOK)
+	}
+}
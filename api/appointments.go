@@ -0,0 +1,445 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type appointment struct {
+	ID           uint       `json:"appointment_id" example:"1"`
+	PetID        uint       `json:"pet_id" example:"1"`
+	UserID       uint       `json:"user_id" example:"1"`
+	ProviderID   *uint      `json:"provider_id" example:"2"`
+	ProviderName string     `json:"provider_name,omitempty" example:"Riverside Animal Hospital"`
+	Title        string     `json:"title" example:"Annual checkup"`
+	StartsAt     time.Time  `json:"starts_at" example:"2019-11-20T09:30:00Z"`
+	EndsAt       *time.Time `json:"ends_at,omitempty" example:"2019-11-20T10:00:00Z"`
+	Notes        string     `json:"notes" example:"Bring the vaccination booklet"`
+	CreatedAt    time.Time  `json:"created_at" example:"2019-11-09T21:21:46+00:00"`
+	UpdatedAt    time.Time  `json:"updated_at" example:"2019-11-09T21:21:46+00:00"`
+}
+
+type appointmentRequest struct {
+	ProviderID *uint      `json:"provider_id" example:"2"`
+	Title      string     `json:"title" example:"Annual checkup" validate:"required,max=200"`
+	StartsAt   time.Time  `json:"starts_at" example:"2019-11-20T09:30:00Z" validate:"required"`
+	EndsAt     *time.Time `json:"ends_at" example:"2019-11-20T10:00:00Z"`
+	Notes      string     `json:"notes" example:"Bring the vaccination booklet" validate:"max=2000"`
+}
+
+//checkFields checks the rules spanning the fields of an appointment
+func (req appointmentRequest) checkFields() []fieldError {
+	errs := []fieldError{}
+	if req.EndsAt != nil && !req.StartsAt.IsZero() && !req.EndsAt.After(req.StartsAt) {
+		errs = append(errs, fieldError{Field: "ends_at", Code: codeOutOfRange, Message: "ends_at must be after starts_at"})
+	}
+	return errs
+}
+
+type appointments []appointment
+
+const appointmentColumns = "id, pet_id, user_id, provider_id, (SELECT name FROM providers WHERE providers.id = appointments.provider_id), title, starts_at, ends_at, notes, created_at, updated_at"
+
+//scanAppointment scans a row selected with appointmentColumns
+func scanAppointment(row interface{ Scan(...interface{}) error }) (appointment, error) {
+	var a appointment
+	var providerID sql.NullInt64
+	var providerName, notes sql.NullString
+	var ends sql.NullTime
+	err := row.Scan(&a.ID, &a.PetID, &a.UserID, &providerID, &providerName, &a.Title, &a.StartsAt, &ends, &notes, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return a, err
+	}
+	a.ProviderID = nullIDPtr(providerID)
+	a.ProviderName, a.Notes = providerName.String, notes.String
+	if ends.Valid {
+		a.EndsAt = &ends.Time
+	}
+	return a, nil
+}
+
+//validateAppointment normalizes and checks an appointment request
+func validateAppointment(req *appointmentRequest) error {
+	req.Title = strings.TrimSpace(req.Title)
+	req.Notes = strings.TrimSpace(req.Notes)
+	return validate(req)
+}
+
+//appointmentIDFromRequest is a helper to extract the appointment ID URL param
+func appointmentIDFromRequest(r *http.Request) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)["appointmentID"], 10, 64)
+}
+
+//dbAppointmentsQuery returns the appointments selected by a WHERE clause,
+//which also orders and limits them
+func (s *server) dbAppointmentsQuery(cond string, args ...interface{}) ([]appointment, error) {
+	rows, err := s.db.Query("SELECT "+appointmentColumns+" FROM appointments WHERE deleted_at IS NULL AND "+cond, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	as := []appointment{}
+	for rows.Next() {
+		a, err := scanAppointment(rows)
+		if err != nil {
+			return nil, err
+		}
+		as = append(as, a)
+	}
+	return as, rows.Err()
+}
+
+//dbAppointmentsGetAll returns the appointments of a pet owned by a user
+//between two optional dates, soonest first
+func (s *server) dbAppointmentsGetAll(userID, petID int64, from, to time.Time) ([]appointment, error) {
+	cond := "user_id = $1 AND pet_id = $2"
+	args := []interface{}{userID, petID}
+	if !from.IsZero() {
+		args = append(args, from)
+		cond += " AND starts_at >= $" + strconv.Itoa(len(args))
+	}
+	if !to.IsZero() {
+		args = append(args, to)
+		cond += " AND starts_at < $" + strconv.Itoa(len(args))
+	}
+	return s.dbAppointmentsQuery(cond+" ORDER BY starts_at, id", args...)
+}
+
+//dbAppointmentsGetOne returns a single appointment of a pet owned by a user
+func (s *server) dbAppointmentsGetOne(userID, petID, appointmentID int64) (appointment, error) {
+	row := s.db.QueryRow("SELECT "+appointmentColumns+" FROM appointments WHERE user_id = $1 AND pet_id = $2 AND id = $3 AND deleted_at IS NULL", userID, petID, appointmentID)
+	return scanAppointment(row)
+}
+
+//dbAppointmentsCreate stores a new appointment
+func (s *server) dbAppointmentsCreate(a appointment) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`INSERT INTO appointments(pet_id, user_id, provider_id, title, starts_at, ends_at, notes, created_at, updated_at)
+		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING id`,
+		a.PetID, a.UserID, a.ProviderID, a.Title, a.StartsAt, a.EndsAt, nullString(a.Notes), a.CreatedAt, a.UpdatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+//dbAppointmentsUpdate updates an appointment of a pet owned by a user
+func (s *server) dbAppointmentsUpdate(a appointment) (int64, error) {
+	res, err := s.db.Exec("UPDATE appointments SET provider_id = $1, title = $2, starts_at = $3, ends_at = $4, notes = $5, updated_at = $6 WHERE id = $7 AND user_id = $8 AND pet_id = $9 AND deleted_at IS NULL",
+		a.ProviderID, a.Title, a.StartsAt, a.EndsAt, nullString(a.Notes), a.UpdatedAt, a.ID, a.UserID, a.PetID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbAppointmentsDelete deletes an appointment of a pet owned by a user
+func (s *server) dbAppointmentsDelete(userID, petID, appointmentID int64) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM appointments WHERE user_id = $1 AND pet_id = $2 AND id = $3 AND deleted_at IS NULL", userID, petID, appointmentID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbAppointmentEvents returns the appointments of all pets of a user as
+//calendar events, located at the provider's address
+func (s *server) dbAppointmentEvents(userID int64) ([]calendarEvent, error) {
+	rows, err := s.db.Query(`SELECT a.id, a.title, a.starts_at, a.ends_at, a.notes, a.created_at, a.updated_at, pets.name, providers.name, providers.address
+		FROM appointments a JOIN pets ON pets.id = a.pet_id LEFT JOIN providers ON providers.id = a.provider_id
+		WHERE a.user_id = $1 AND a.deleted_at IS NULL AND pets.deleted_at IS NULL`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []calendarEvent
+	for rows.Next() {
+		var e calendarEvent
+		var id int64
+		var ends sql.NullTime
+		var notes, providerName, address sql.NullString
+		var petName string
+		err := rows.Scan(&id, &e.Summary, &e.Start, &ends, &notes, &e.Created, &e.LastModified, &petName, &providerName, &address)
+		if err != nil {
+			return nil, err
+		}
+		e.UID = fmt.Sprintf("appointment-%d@petkeep", id)
+		e.Summary = fmt.Sprintf("%s: %s", petName, e.Summary)
+		e.End = ends.Time
+		e.Location = address.String
+		e.Description = notes.String
+		if providerName.Valid {
+			e.Description = strings.TrimSpace("With " + providerName.String + ".\n" + notes.String)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// handlerAppointmentsGetAll godoc
+// @Summary Get all appointments of a pet
+// @Description Get the appointments of a pet, soonest first
+// @Tags Appointments
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param from query string false "Earliest date, YYYY-MM-DD"
+// @Param to query string false "Date before which to stop, YYYY-MM-DD"
+// @Success 200 {array} appointment
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/appointments [get]
+func (s *server) handlerAppointmentsGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		from, err := parseDateParam(r, "from")
+		if err != nil {
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+		to, err := parseDateParam(r, "to")
+		if err != nil {
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+		if !s.requirePet(w, r, userID, petID) {
+			return
+		}
+		as, err := s.dbAppointmentsGetAll(userID, petID, from, to)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving appointments from database")
+			s.respond(w, r, nil, "error retrieving appointments", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, as, "", http.StatusOK)
+	}
+}
+
+// handlerAppointmentsGetOne godoc
+// @Summary Get one appointment
+// @Description Get one appointment of a pet
+// @Tags Appointments
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param AppointmentID path int true "Appointment ID"
+// @Success 200 {object} appointment
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/appointments/{AppointmentID} [get]
+func (s *server) handlerAppointmentsGetOne() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		appointmentID, err := appointmentIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid appointment id", http.StatusBadRequest)
+			return
+		}
+		a, err := s.dbAppointmentsGetOne(userID, petID, appointmentID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "appointment not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving appointment from database")
+			s.respond(w, r, nil, "error retrieving appointment", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, a, "", http.StatusOK)
+	}
+}
+
+// handlerAppointmentsCreate godoc
+// @Summary Create an appointment
+// @Description Book an appointment for a pet, optionally with a provider from the user's directory
+// @Tags Appointments
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param appointment body appointmentRequest true "Create Appointment"
+// @Success 201 {object} appointment
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/appointments [post]
+func (s *server) handlerAppointmentsCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok || !s.requirePet(w, r, userID, petID) {
+			return
+		}
+
+		// Get JSON body, decode into an appointment request and validate it
+		var req appointmentRequest
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateAppointment(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking appointment")
+			return
+		}
+		ok, err = s.checkProvider(userID, req.ProviderID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving provider from database")
+			s.respond(w, r, nil, "error creating appointment", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			s.respondProblem(w, r, invalidField("provider_id", "provider not found"))
+			return
+		}
+
+		// Create the appointment in the db
+		a := appointment{
+			PetID:      uint(petID),
+			UserID:     uint(userID),
+			ProviderID: req.ProviderID,
+			Title:      req.Title,
+			StartsAt:   req.StartsAt,
+			EndsAt:     req.EndsAt,
+			Notes:      req.Notes,
+			CreatedAt:  ts,
+			UpdatedAt:  ts,
+		}
+		id, err := s.dbAppointmentsCreate(a)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error creating appointment in database")
+			s.respond(w, r, nil, "error creating appointment", http.StatusInternalServerError)
+			return
+		}
+		a, err = s.dbAppointmentsGetOne(userID, petID, id)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving appointment from database")
+			s.respond(w, r, nil, "error creating appointment", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, a, "", http.StatusCreated)
+	}
+}
+
+// handlerAppointmentsUpdate godoc
+// @Summary Update an appointment
+// @Description Update an appointment of a pet
+// @Tags Appointments
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param AppointmentID path int true "Appointment ID"
+// @Param appointment body appointmentRequest true "Updated Appointment"
+// @Success 200 {object} appointment
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/appointments/{AppointmentID} [put]
+func (s *server) handlerAppointmentsUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		appointmentID, err := appointmentIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid appointment id", http.StatusBadRequest)
+			return
+		}
+		a, err := s.dbAppointmentsGetOne(userID, petID, appointmentID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "appointment not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving appointment from database")
+			s.respond(w, r, nil, "error updating appointment", http.StatusInternalServerError)
+			return
+		}
+
+		// Get JSON body, decode into an appointment request and validate it
+		var req appointmentRequest
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateAppointment(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking appointment")
+			return
+		}
+		ok, err = s.checkProvider(userID, req.ProviderID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving provider from database")
+			s.respond(w, r, nil, "error updating appointment", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			s.respondProblem(w, r, invalidField("provider_id", "provider not found"))
+			return
+		}
+
+		// Update the appointment in the db
+		a.ProviderID = req.ProviderID
+		a.Title = req.Title
+		a.StartsAt = req.StartsAt
+		a.EndsAt = req.EndsAt
+		a.Notes = req.Notes
+		a.UpdatedAt = ts
+		_, err = s.dbAppointmentsUpdate(a)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error updating appointment in database")
+			s.respond(w, r, nil, "error updating appointment", http.StatusInternalServerError)
+			return
+		}
+		a, err = s.dbAppointmentsGetOne(userID, petID, appointmentID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving appointment from database")
+			s.respond(w, r, nil, "error updating appointment", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, a, "", http.StatusOK)
+	}
+}
+
+// handlerAppointmentsDelete godoc
+// @Summary Delete an appointment
+// @Description Delete an appointment of a pet
+// @Tags Appointments
+// @Param PetID path int true "Pet ID"
+// @Param AppointmentID path int true "Appointment ID"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/appointments/{AppointmentID} [delete]
+func (s *server) handlerAppointmentsDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		appointmentID, err := appointmentIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid appointment id", http.StatusBadRequest)
+			return
+		}
+		rows, err := s.dbAppointmentsDelete(userID, petID, appointmentID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error deleting appointment from database")
+			s.respond(w, r, nil, "error deleting appointment", http.StatusInternalServerError)
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "appointment not found", http.StatusNotFound)
+			return
+		}
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
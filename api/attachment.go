@@ -0,0 +1,952 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // register GIF decoding for thumbnails
+	"image/jpeg"
+	_ "image/png" // register PNG decoding for thumbnails
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang/gddo/httputil/header"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// attachmentKindAvatar is the single profile photo of a pet
+	attachmentKindAvatar = "avatar"
+	// attachmentKindDocument is any other file attached to a pet
+	attachmentKindDocument = "document"
+
+	// thumbnailSize is the longest edge of generated thumbnails in pixels
+	thumbnailSize = 256
+	// maxImagePixels bounds the images we decode to avoid decompression bombs
+	maxImagePixels = 50000000
+)
+
+// imageContentTypes are the sniffed types accepted for pet avatars, those
+// the standard library decodes so a thumbnail can be made. WebP is left out
+// since decoding it takes golang.org/x/image.
+var imageContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// documentContentTypes are the sniffed types accepted for document attachments
+var documentContentTypes = map[string]bool{
+	"image/jpeg":                true,
+	"image/png":                 true,
+	"image/gif":                 true,
+	"image/webp":                true,
+	"application/pdf":           true,
+	"text/plain; charset=utf-8": true,
+}
+
+type attachment struct {
+	ID           uint      `json:"attachment_id" example:"1"`
+	PetID        uint      `json:"pet_id" example:"1"`
+	UserID       uint      `json:"user_id" example:"1"`
+	Kind         string    `json:"kind" example:"document"`
+	Filename     string    `json:"filename" example:"invoice.pdf"`
+	ContentType  string    `json:"content_type" example:"application/pdf"`
+	Size         int64     `json:"size" example:"48213"`
+	Description  string    `json:"description" example:"Annual checkup invoice"`
+	HasThumbnail bool      `json:"has_thumbnail" example:"false"`
+	RecordID     *uint     `json:"record_id" example:"4"`
+	ClaimID      *uint     `json:"claim_id" example:"2"`
+	BlobKey      string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at" example:"2019-11-09T21:21:46+00:00"`
+}
+
+type attachments []attachment
+
+// upload is a file read from a multipart request body
+type upload struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+//readUpload reads a single file from a multipart/form-data request. The
+//content type is sniffed from the data rather than trusted from the client.
+//Like decode, it responds to the client itself when the upload is rejected.
+func (s *server) readUpload(w http.ResponseWriter, r *http.Request, field string, allowed map[string]bool) (upload, error) {
+	var up upload
+
+	// Uploads must be multipart forms
+	value, _ := header.ParseValueAndParams(r.Header, "Content-Type")
+	if value != "multipart/form-data" {
+		msg := "Content-Type header is not multipart/form-data"
+		s.respond(w, r, nil, msg, http.StatusUnsupportedMediaType)
+		return up, errors.New(msg)
+	}
+
+	// Leave some room for the multipart framing and other form fields
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUploadBytes+1048576)
+	err := r.ParseMultipartForm(1048576)
+	if err != nil {
+		if err.Error() == "http: request body too large" {
+			msg := fmt.Sprintf("Upload must not be larger than %d bytes", s.maxUploadBytes)
+			s.respond(w, r, nil, msg, http.StatusRequestEntityTooLarge)
+			return up, errors.New(msg)
+		}
+		msg := "Request body contains a malformed multipart form"
+		s.respond(w, r, nil, msg, http.StatusBadRequest)
+		return up, errors.New(msg)
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	f, fh, err := r.FormFile(field)
+	if err != nil {
+		msg := fmt.Sprintf("Request body must contain a %q file", field)
+		s.respond(w, r, nil, msg, http.StatusBadRequest)
+		return up, errors.New(msg)
+	}
+	defer f.Close()
+	if fh.Size > s.maxUploadBytes {
+		msg := fmt.Sprintf("Upload must not be larger than %d bytes", s.maxUploadBytes)
+		s.respond(w, r, nil, msg, http.StatusRequestEntityTooLarge)
+		return up, errors.New(msg)
+	}
+	up.Data, err = ioutil.ReadAll(f)
+	if err != nil {
+		return up, err
+	}
+	if len(up.Data) == 0 {
+		msg := "Upload must not be empty"
+		s.respond(w, r, nil, msg, http.StatusBadRequest)
+		return up, errors.New(msg)
+	}
+
+	// Sniff the content type and check it against the allowed ones
+	up.ContentType = http.DetectContentType(up.Data)
+	if !allowed[up.ContentType] {
+		msg := fmt.Sprintf("Upload content type %s is not allowed", up.ContentType)
+		s.respond(w, r, nil, msg, http.StatusUnsupportedMediaType)
+		return up, errors.New(msg)
+	}
+	up.Filename = fh.Filename
+	return up, nil
+}
+
+//makeThumbnail scales an image down to fit a thumbnailSize square and
+//encodes it as JPEG. ok is false when the data isn't a decodable image.
+func makeThumbnail(data []byte) (thumb []byte, ok bool, err error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width*cfg.Height > maxImagePixels {
+		return nil, false, nil
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, nil
+	}
+
+	var buf bytes.Buffer
+	err = jpeg.Encode(&buf, scaleImage(src, thumbnailSize), &jpeg.Options{Quality: 80})
+	if err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), true, nil
+}
+
+//scaleImage shrinks an image so its longest edge is at most size pixels,
+//averaging the source pixels covered by each destination pixel
+func scaleImage(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		size = w
+		if h > w {
+			size = h
+		}
+	}
+	dw, dh := size, size
+	if w > h {
+		dh = h * size / w
+	} else {
+		dw = w * size / h
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+(y+1)*h/dh
+		if y1 == y0 {
+			y1++
+		}
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+(x+1)*w/dw
+			if x1 == x0 {
+				x1++
+			}
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+//newBlobKey generates a unique blob key below a pet's prefix
+func newBlobKey(userID, petID int64) (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("users/%d/pets/%d/%s", userID, petID, hex.EncodeToString(b)), nil
+}
+
+//storeUpload puts an upload and its thumbnail, if any, in the blob store
+func (s *server) storeUpload(r *http.Request, userID, petID int64, up upload, kind, description string) (attachment, error) {
+	a := attachment{
+		PetID:       uint(petID),
+		UserID:      uint(userID),
+		Kind:        kind,
+		Filename:    up.Filename,
+		ContentType: up.ContentType,
+		Size:        int64(len(up.Data)),
+		Description: description,
+		CreatedAt:   time.Now(),
+	}
+	key, err := newBlobKey(userID, petID)
+	if err != nil {
+		return a, err
+	}
+	err = s.blobs.Put(r.Context(), key, bytes.NewReader(up.Data), a.Size, a.ContentType)
+	if err != nil {
+		return a, err
+	}
+	a.BlobKey = key
+
+	thumb, ok, err := makeThumbnail(up.Data)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error generating thumbnail")
+	}
+	if ok {
+		err = s.blobs.Put(r.Context(), key+"-thumb", bytes.NewReader(thumb), int64(len(thumb)), "image/jpeg")
+		if err != nil {
+			s.blobs.Delete(r.Context(), key)
+			return a, err
+		}
+		a.ThumbnailKey = key + "-thumb"
+		a.HasThumbnail = true
+	}
+	return a, nil
+}
+
+//deleteBlobs removes the blobs of attachments, logging any failures
+func (s *server) deleteBlobs(ctx context.Context, atts []attachment) {
+	for _, a := range atts {
+		keys := []string{a.BlobKey}
+		if a.ThumbnailKey != "" {
+			keys = append(keys, a.ThumbnailKey)
+		}
+		for _, k := range keys {
+			err := s.blobs.Delete(ctx, k)
+			if err != nil {
+				s.logger.Error().Err(err).Str("blob_key", k).Msg("error deleting blob")
+			}
+		}
+	}
+}
+
+//petIDFromRequest is a helper to extract the pet ID from the URL params
+func petIDFromRequest(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid pet id: %w", err)
+	}
+	return id, nil
+}
+
+const attachmentColumns = "id, pet_id, user_id, kind, filename, content_type, size_bytes, description, blob_key, thumbnail_key, record_id, claim_id, created_at"
+
+//scanAttachment scans a row selected with attachmentColumns
+func scanAttachment(row interface{ Scan(...interface{}) error }) (attachment, error) {
+	var a attachment
+	var thumb sql.NullString
+	var recordID, claimID sql.NullInt64
+	err := row.Scan(&a.ID, &a.PetID, &a.UserID, &a.Kind, &a.Filename, &a.ContentType, &a.Size, &a.Description, &a.BlobKey, &thumb, &recordID, &claimID, &a.CreatedAt)
+	a.ThumbnailKey = thumb.String
+	a.HasThumbnail = thumb.Valid && thumb.String != ""
+	a.RecordID, a.ClaimID = nullIDPtr(recordID), nullIDPtr(claimID)
+	return a, err
+}
+
+//dbAttachmentsCreate stores the metadata of an attachment
+func (s *server) dbAttachmentsCreate(a attachment) (int64, error) {
+	var thumb sql.NullString
+	if a.ThumbnailKey != "" {
+		thumb = sql.NullString{String: a.ThumbnailKey, Valid: true}
+	}
+	var id int64
+	err := s.db.QueryRow("INSERT INTO attachments(pet_id, user_id, kind, filename, content_type, size_bytes, description, blob_key, thumbnail_key, record_id, claim_id, created_at) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12) RETURNING id",
+		a.PetID, a.UserID, a.Kind, a.Filename, a.ContentType, a.Size, a.Description, a.BlobKey, thumb, a.RecordID, a.ClaimID, a.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+//dbAttachmentsQuery returns the attachments matching a where clause, oldest
+//first
+func (s *server) dbAttachmentsQuery(where string, args ...interface{}) ([]attachment, error) {
+	rows, err := s.db.Query("SELECT "+attachmentColumns+" FROM attachments WHERE "+where+" ORDER BY created_at, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	atts := []attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		atts = append(atts, a)
+	}
+	return atts, rows.Err()
+}
+
+//dbAttachmentsGetAll returns the attachments of a kind on a pet owned by a user
+func (s *server) dbAttachmentsGetAll(userID, petID int64, kind string) ([]attachment, error) {
+	return s.dbAttachmentsQuery("user_id = $1 AND pet_id = $2 AND kind = $3 AND deleted_at IS NULL", userID, petID, kind)
+}
+
+//dbAttachmentsGetPet returns every attachment on a pet owned by a user
+func (s *server) dbAttachmentsGetPet(userID, petID int64) ([]attachment, error) {
+	return s.dbAttachmentsQuery("user_id = $1 AND pet_id = $2", userID, petID)
+}
+
+//dbAttachmentsGetRecord returns the attachments of a medical record
+func (s *server) dbAttachmentsGetRecord(userID, petID, recordID int64) ([]attachment, error) {
+	return s.dbAttachmentsQuery("user_id = $1 AND pet_id = $2 AND record_id = $3 AND deleted_at IS NULL", userID, petID, recordID)
+}
+
+//dbAttachmentsGetClaim returns the attachments of an insurance claim
+func (s *server) dbAttachmentsGetClaim(userID, petID, claimID int64) ([]attachment, error) {
+	return s.dbAttachmentsQuery("user_id = $1 AND pet_id = $2 AND claim_id = $3 AND deleted_at IS NULL", userID, petID, claimID)
+}
+
+//dbAttachmentsGetPolicy returns the attachments of the claims of an
+//insurance policy
+func (s *server) dbAttachmentsGetPolicy(userID, petID, policyID int64) ([]attachment, error) {
+	return s.dbAttachmentsQuery("user_id = $1 AND pet_id = $2 AND claim_id IN (SELECT id FROM insurance_claims WHERE policy_id = $3) AND deleted_at IS NULL", userID, petID, policyID)
+}
+
+//dbAttachmentsGetOne returns a single attachment on a pet owned by a user
+func (s *server) dbAttachmentsGetOne(userID, petID, attachmentID int64) (attachment, error) {
+	row := s.db.QueryRow("SELECT "+attachmentColumns+" FROM attachments WHERE user_id = $1 AND pet_id = $2 AND id = $3 AND deleted_at IS NULL", userID, petID, attachmentID)
+	return scanAttachment(row)
+}
+
+//dbAttachmentsDelete deletes the metadata of an attachment
+func (s *server) dbAttachmentsDelete(userID, petID, attachmentID int64) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM attachments WHERE user_id = $1 AND pet_id = $2 AND id = $3 AND deleted_at IS NULL", userID, petID, attachmentID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//serveBlob streams a blob to the client
+func (s *server) serveBlob(w http.ResponseWriter, r *http.Request, key, contentType, filename string, size int64) {
+	blob, err := s.blobs.Get(r.Context(), key)
+	if err != nil {
+		s.logger.Error().Err(err).Str("blob_key", key).Msg("error retrieving blob")
+		if err == errBlobNotFound {
+			s.respond(w, r, nil, "file not found", http.StatusNotFound)
+			return
+		}
+		s.respond(w, r, nil, "error retrieving file", http.StatusInternalServerError)
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	if filename != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	}
+	w.WriteHeader(http.StatusOK)
+	_, err = io.Copy(w, blob)
+	if err != nil {
+		s.logger.Error().Err(err).Str("blob_key", key).Msg("error streaming blob")
+	}
+}
+
+//attachmentFromRequest loads the attachment addressed by the URL params,
+//responding to the client itself when it can't be found
+func (s *server) attachmentFromRequest(w http.ResponseWriter, r *http.Request) (attachment, bool) {
+
+	// Get user ID from the authenticated context
+	userID, err := userIDFromRequest(r)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+		s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+		return attachment{}, false
+	}
+
+	// Get URL params
+	petID, err := petIDFromRequest(r)
+	if err != nil {
+		s.respond(w, r, nil, "must provide a valid pet id", http.StatusBadRequest)
+		return attachment{}, false
+	}
+	attID, err := strconv.ParseInt(mux.Vars(r)["attachmentID"], 10, 64)
+	if err != nil {
+		s.respond(w, r, nil, "must provide a valid attachment id", http.StatusBadRequest)
+		return attachment{}, false
+	}
+
+	a, err := s.dbAttachmentsGetOne(userID, petID, attID)
+	if err == sql.ErrNoRows {
+		s.respond(w, r, nil, "attachment not found", http.StatusNotFound)
+		return a, false
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error retrieving attachment from database")
+		s.respond(w, r, nil, "error retrieving attachment", http.StatusInternalServerError)
+		return a, false
+	}
+	return a, true
+}
+
+//avatarFromRequest loads the avatar of the pet addressed by the URL params,
+//responding to the client itself when there is none
+func (s *server) avatarFromRequest(w http.ResponseWriter, r *http.Request) (attachment, bool) {
+
+	// Get user ID from the authenticated context
+	userID, err := userIDFromRequest(r)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+		s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+		return attachment{}, false
+	}
+
+	// Get URL params
+	petID, err := petIDFromRequest(r)
+	if err != nil {
+		s.respond(w, r, nil, "must provide a valid pet id", http.StatusBadRequest)
+		return attachment{}, false
+	}
+
+	atts, err := s.dbAttachmentsGetAll(userID, petID, attachmentKindAvatar)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error retrieving photo from database")
+		s.respond(w, r, nil, "error retrieving photo", http.StatusInternalServerError)
+		return attachment{}, false
+	}
+	if len(atts) == 0 {
+		s.respond(w, r, nil, "photo not found", http.StatusNotFound)
+		return attachment{}, false
+	}
+	return atts[len(atts)-1], true
+}
+
+// handlerPetsPhotoUpdate godoc
+// @Summary Upload a pet photo
+// @Description Upload a pet's profile photo as multipart/form-data, replacing any existing one
+// @Tags Attachments
+// @Accept mpfd
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param photo formData file true "Photo (JPEG, PNG or GIF)"
+// @Success 200 {object} attachment
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/photo [put]
+func (s *server) handlerPetsPhotoUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Get user ID from the authenticated context
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Get URL params and make sure the pet is the user's
+		petID, err := petIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid pet id", http.StatusBadRequest)
+			return
+		}
+		ok, err := s.pets.Exists(userID, petID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving pet from database")
+			s.respond(w, r, nil, "error uploading photo", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			s.respond(w, r, nil, "pet not found", http.StatusNotFound)
+			return
+		}
+
+		// Read the photo from the multipart body
+		up, err := s.readUpload(w, r, "photo", imageContentTypes)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error reading upload")
+			return
+		}
+
+		// Store the photo, then swap it in for the old one
+		old, err := s.dbAttachmentsGetAll(userID, petID, attachmentKindAvatar)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving photo from database")
+			s.respond(w, r, nil, "error uploading photo", http.StatusInternalServerError)
+			return
+		}
+		a, err := s.storeUpload(r, userID, petID, up, attachmentKindAvatar, "")
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error storing photo")
+			s.respond(w, r, nil, "error uploading photo", http.StatusInternalServerError)
+			return
+		}
+		id, err := s.dbAttachmentsCreate(a)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error creating photo in database")
+			s.deleteBlobs(r.Context(), []attachment{a})
+			s.respond(w, r, nil, "error uploading photo", http.StatusInternalServerError)
+			return
+		}
+		a.ID = uint(id)
+		for _, o := range old {
+			_, err := s.dbAttachmentsDelete(userID, petID, int64(o.ID))
+			if err != nil {
+				s.logger.Error().Err(err).Msg("error deleting old photo from database")
+			}
+		}
+		s.deleteBlobs(r.Context(), old)
+
+		s.respond(w, r, a, "", http.StatusOK)
+	}
+}
+
+// handlerPetsPhotoGet godoc
+// @Summary Get a pet photo
+// @Description Download a pet's profile photo
+// @Tags Attachments
+// @Produce image/jpeg,image/png,image/gif,image/webp
+// @Param PetID path int true "Pet ID"
+// @Success 200 {file} file
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/photo [get]
+func (s *server) handlerPetsPhotoGet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a, ok := s.avatarFromRequest(w, r)
+		if !ok {
+			return
+		}
+		s.serveBlob(w, r, a.BlobKey, a.ContentType, "", a.Size)
+	}
+}
+
+// handlerPetsPhotoThumbnail godoc
+// @Summary Get a pet photo thumbnail
+// @Description Download a thumbnail of a pet's profile photo
+// @Tags Attachments
+// @Produce image/jpeg
+// @Param PetID path int true "Pet ID"
+// @Success 200 {file} file
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/photo/thumbnail [get]
+func (s *server) handlerPetsPhotoThumbnail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a, ok := s.avatarFromRequest(w, r)
+		if !ok {
+			return
+		}
+		if !a.HasThumbnail {
+			s.respond(w, r, nil, "photo has no thumbnail", http.StatusNotFound)
+			return
+		}
+		s.serveBlob(w, r, a.ThumbnailKey, "image/jpeg", "", 0)
+	}
+}
+
+// handlerPetsPhotoDelete godoc
+// @Summary Delete a pet photo
+// @Description Delete a pet's profile photo
+// @Tags Attachments
+// @Param PetID path int true "Pet ID"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/photo [delete]
+func (s *server) handlerPetsPhotoDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a, ok := s.avatarFromRequest(w, r)
+		if !ok {
+			return
+		}
+		_, err := s.dbAttachmentsDelete(int64(a.UserID), int64(a.PetID), int64(a.ID))
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error deleting photo from database")
+			s.respond(w, r, nil, "error deleting photo", http.StatusInternalServerError)
+			return
+		}
+		s.deleteBlobs(r.Context(), []attachment{a})
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
+
+// handlerAttachmentsCreate godoc
+// @Summary Attach a document to a pet
+// @Description Upload a document such as a vet invoice or lab result as multipart/form-data
+// @Tags Attachments
+// @Accept mpfd
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param file formData file true "Document (PDF, plain text or image)"
+// @Param description formData string false "Description"
+// @Success 201 {object} attachment
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/attachments [post]
+func (s *server) handlerAttachmentsCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Get user ID from the authenticated context
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Get URL params and make sure the pet is the user's
+		petID, err := petIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid pet id", http.StatusBadRequest)
+			return
+		}
+		ok, err := s.pets.Exists(userID, petID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving pet from database")
+			s.respond(w, r, nil, "error creating attachment", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			s.respond(w, r, nil, "pet not found", http.StatusNotFound)
+			return
+		}
+
+		s.createDocument(w, r, userID, petID, nil, nil)
+	}
+}
+
+//createDocument stores a document uploaded for a pet, or for one of its
+//medical records or claims, and responds with its metadata
+func (s *server) createDocument(w http.ResponseWriter, r *http.Request, userID, petID int64, recordID, claimID *uint) {
+
+	// Read the document from the multipart body
+	up, err := s.readUpload(w, r, "file", documentContentTypes)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error reading upload")
+		return
+	}
+
+	// Store the document and its metadata
+	a, err := s.storeUpload(r, userID, petID, up, attachmentKindDocument, r.FormValue("description"))
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error storing attachment")
+		s.respond(w, r, nil, "error creating attachment", http.StatusInternalServerError)
+		return
+	}
+	a.RecordID, a.ClaimID = recordID, claimID
+	id, err := s.dbAttachmentsCreate(a)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error creating attachment in database")
+		s.deleteBlobs(r.Context(), []attachment{a})
+		s.respond(w, r, nil, "error creating attachment", http.StatusInternalServerError)
+		return
+	}
+	a.ID = uint(id)
+	s.respond(w, r, a, "", http.StatusCreated)
+}
+
+// handlerAttachmentsGetAll godoc
+// @Summary Get all attachments of a pet
+// @Description Get the metadata of all documents attached to a pet, including those of its medical records and claims
+// @Tags Attachments
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Success 200 {array} attachment
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/attachments [get]
+func (s *server) handlerAttachmentsGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Get user ID from the authenticated context
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Get URL params
+		petID, err := petIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid pet id", http.StatusBadRequest)
+			return
+		}
+
+		// Get attachments from the database and respond
+		atts, err := s.dbAttachmentsGetAll(userID, petID, attachmentKindDocument)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving attachments from database")
+			s.respond(w, r, nil, "error retrieving attachments", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, atts, "", http.StatusOK)
+	}
+}
+
+// handlerAttachmentsGetOne godoc
+// @Summary Download an attachment
+// @Description Download a document attached to a pet, one of its medical records or claims
+// @Tags Attachments
+// @Produce application/pdf,text/plain,image/jpeg,image/png,image/gif,image/webp
+// @Param PetID path int true "Pet ID"
+// @Param AttachmentID path int true "Attachment ID"
+// @Success 200 {file} file
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/attachments/{AttachmentID} [get]
+func (s *server) handlerAttachmentsGetOne() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a, ok := s.attachmentFromRequest(w, r)
+		if !ok {
+			return
+		}
+		s.serveBlob(w, r, a.BlobKey, a.ContentType, a.Filename, a.Size)
+	}
+}
+
+// handlerAttachmentsThumbnail godoc
+// @Summary Get an attachment thumbnail
+// @Description Download the thumbnail of an image attached to a pet
+// @Tags Attachments
+// @Produce image/jpeg
+// @Param PetID path int true "Pet ID"
+// @Param AttachmentID path int true "Attachment ID"
+// @Success 200 {file} file
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/attachments/{AttachmentID}/thumbnail [get]
+func (s *server) handlerAttachmentsThumbnail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a, ok := s.attachmentFromRequest(w, r)
+		if !ok {
+			return
+		}
+		if !a.HasThumbnail {
+			s.respond(w, r, nil, "attachment has no thumbnail", http.StatusNotFound)
+			return
+		}
+		s.serveBlob(w, r, a.ThumbnailKey, "image/jpeg", "", 0)
+	}
+}
+
+// handlerAttachmentsDelete godoc
+// @Summary Delete an attachment
+// @Description Delete a document attached to a pet, one of its medical records or claims
+// @Tags Attachments
+// @Param PetID path int true "Pet ID"
+// @Param AttachmentID path int true "Attachment ID"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/attachments/{AttachmentID} [delete]
+func (s *server) handlerAttachmentsDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a, ok := s.attachmentFromRequest(w, r)
+		if !ok {
+			return
+		}
+		_, err := s.dbAttachmentsDelete(int64(a.UserID), int64(a.PetID), int64(a.ID))
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error deleting attachment from database")
+			s.respond(w, r, nil, "error deleting attachment", http.StatusInternalServerError)
+			return
+		}
+		s.deleteBlobs(r.Context(), []attachment{a})
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
+
+// handlerRecordAttachmentsCreate godoc
+// @Summary Attach a document to a medical record
+// @Description Upload a document such as a lab result or vaccination certificate for a medical record as multipart/form-data. It is downloaded and deleted like the other attachments of the pet.
+// @Tags Attachments
+// @Accept mpfd
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param RecordID path int true "Record ID"
+// @Param file formData file true "Document (PDF, plain text or image)"
+// @Param description formData string false "Description"
+// @Success 201 {object} attachment
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/records/{RecordID}/attachments [post]
+func (s *server) handlerRecordAttachmentsCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, recordID, ok := s.recordFromRequest(w, r)
+		if !ok {
+			return
+		}
+		id := uint(recordID)
+		s.createDocument(w, r, userID, petID, &id, nil)
+	}
+}
+
+// handlerRecordAttachmentsGetAll godoc
+// @Summary Get all attachments of a medical record
+// @Description Get the metadata of the documents attached to a medical record
+// @Tags Attachments
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param RecordID path int true "Record ID"
+// @Success 200 {array} attachment
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/records/{RecordID}/attachments [get]
+func (s *server) handlerRecordAttachmentsGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, recordID, ok := s.recordFromRequest(w, r)
+		if !ok {
+			return
+		}
+		atts, err := s.dbAttachmentsGetRecord(userID, petID, recordID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving attachments from database")
+			s.respond(w, r, nil, "error retrieving attachments", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, atts, "", http.StatusOK)
+	}
+}
+
+// handlerClaimAttachmentsCreate godoc
+// @Summary Attach a document to an insurance claim
+// @Description Upload a document such as a claim form or an explanation of benefits for an insurance claim as multipart/form-data. It is downloaded and deleted like the other attachments of the pet.
+// @Tags Attachments
+// @Accept mpfd
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param ClaimID path int true "Claim ID"
+// @Param file formData file true "Document (PDF, plain text or image)"
+// @Param description formData string false "Description"
+// @Success 201 {object} attachment
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/insurance/claims/{ClaimID}/attachments [post]
+func (s *server) handlerClaimAttachmentsCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, claimID, ok := s.claimFromRequest(w, r)
+		if !ok {
+			return
+		}
+		id := uint(claimID)
+		s.createDocument(w, r, userID, petID, nil, &id)
+	}
+}
+
+// handlerClaimAttachmentsGetAll godoc
+// @Summary Get all attachments of an insurance claim
+// @Description Get the metadata of the documents attached to an insurance claim
+// @Tags Attachments
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param ClaimID path int true "Claim ID"
+// @Success 200 {array} attachment
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/insurance/claims/{ClaimID}/attachments [get]
+func (s *server) handlerClaimAttachmentsGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, claimID, ok := s.claimFromRequest(w, r)
+		if !ok {
+			return
+		}
+		atts, err := s.dbAttachmentsGetClaim(userID, petID, claimID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving attachments from database")
+			s.respond(w, r, nil, "error retrieving attachments", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, atts, "", http.StatusOK)
+	}
+}
+
+//recordFromRequest makes sure the medical record addressed by the URL
+//params is one of the user's, responding to the client itself when it isn't
+func (s *server) recordFromRequest(w http.ResponseWriter, r *http.Request) (userID, petID, recordID int64, ok bool) {
+	userID, petID, ok = s.userAndPetFromRequest(w, r)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	recordID, err := recordIDFromRequest(r)
+	if err != nil {
+		s.respond(w, r, nil, "must provide a valid record id", http.StatusBadRequest)
+		return 0, 0, 0, false
+	}
+	_, err = s.dbRecordsGetOne(userID, petID, recordID)
+	if err == sql.ErrNoRows {
+		s.respond(w, r, nil, "medical record not found", http.StatusNotFound)
+		return 0, 0, 0, false
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error retrieving medical record from database")
+		s.respond(w, r, nil, "error retrieving medical record", http.StatusInternalServerError)
+		return 0, 0, 0, false
+	}
+	return userID, petID, recordID, true
+}
+
+//claimFromRequest makes sure the insurance claim addressed by the URL
+//params is one of the user's, responding to the client itself when it isn't
+func (s *server) claimFromRequest(w http.ResponseWriter, r *http.Request) (userID, petID, claimID int64, ok bool) {
+	userID, petID, ok = s.userAndPetFromRequest(w, r)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	claimID, err := claimIDFromRequest(r)
+	if err != nil {
+		s.respond(w, r, nil, "must provide a valid claim id", http.StatusBadRequest)
+		return 0, 0, 0, false
+	}
+	_, err = s.dbClaimsGetOne(userID, petID, claimID)
+	if err == sql.ErrNoRows {
+		s.respond(w, r, nil, "claim not found", http.StatusNotFound)
+		return 0, 0, 0, false
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error retrieving claim from database")
+		s.respond(w, r, nil, "error retrieving claim", http.StatusInternalServerError)
+		return 0, 0, 0, false
+	}
+	return userID, petID, claimID, true
+}
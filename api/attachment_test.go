@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMakeThumbnailAvatarTypes(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 600, 300))
+	for x := 0; x < 600; x++ {
+		for y := 0; y < 300; y++ {
+			src.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	encoders := map[string]func(*bytes.Buffer) error{
+		"image/jpeg": func(b *bytes.Buffer) error { return jpeg.Encode(b, src, nil) },
+		"image/png":  func(b *bytes.Buffer) error { return png.Encode(b, src) },
+		"image/gif":  func(b *bytes.Buffer) error { return gif.Encode(b, src, nil) },
+	}
+
+	// Every type accepted for avatars must be one we can make a thumbnail of
+	for ct := range imageContentTypes {
+		encode, ok := encoders[ct]
+		if !ok {
+			t.Errorf("%s is accepted for avatars but has no decoder registered", ct)
+			continue
+		}
+		var b bytes.Buffer
+		if err := encode(&b); err != nil {
+			t.Fatal(err)
+		}
+		if sniffed := http.DetectContentType(b.Bytes()); sniffed != ct {
+			t.Errorf("encoded %s sniffed as %s", ct, sniffed)
+		}
+		thumb, ok, err := makeThumbnail(b.Bytes())
+		if err != nil || !ok {
+			t.Errorf("thumbnail of %s: got ok %v, error %v", ct, ok, err)
+			continue
+		}
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(thumb))
+		if err != nil || cfg.Width != thumbnailSize || cfg.Height != thumbnailSize/2 {
+			t.Errorf("thumbnail of %s: got %dx%d, error %v", ct, cfg.Width, cfg.Height, err)
+		}
+	}
+}
+
+//newSQLiteHandlerTest returns a handler test on a migrated sqlite database,
+//storing uploads in a temporary directory
+func newSQLiteHandlerTest(t *testing.T) *handlerTest {
+	t.Helper()
+	h := newHandlerTest(t)
+	db := newSQLiteTestServer(t)
+	h.s.db, h.s.users, h.s.pets, h.s.search, h.s.auditLog = db.db, db.users, db.pets, db.search, db.auditLog
+	blobs, err := newLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.s.blobs, h.s.maxUploadBytes = blobs, 1048576
+	ts := time.Now()
+	id, err := h.s.users.Create(user{Email: "jane@example.com", Password: "hash", CreatedAt: ts, UpdatedAt: ts})
+	if err != nil {
+		t.Fatalf("creating the user: %v", err)
+	}
+	h.token, err = generateToken(uint(id), ts.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("signing a token: %v", err)
+	}
+	return h
+}
+
+//upload posts a multipart form with a single file as the signed in user
+func (h *handlerTest) upload(path, field string, data []byte, out interface{}) *httptest.ResponseRecorder {
+	h.t.Helper()
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	fw, err := mw.CreateFormFile(field, "upload")
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+	return h.do("POST", path, &b, http.Header{"Content-Type": {mw.FormDataContentType()}}, out)
+}
+
+func TestHandlerRecordAndClaimAttachments(t *testing.T) {
+	h := newSQLiteHandlerTest(t)
+	day := time.Date(2019, 11, 9, 0, 0, 0, 0, time.UTC)
+	pdf := []byte("%PDF-1.4\n% test document\n")
+
+	var p pet
+	if w := h.do("POST", "/pets", petRequest{Name: "Fido", Type: "dog"}, nil, &p); w.Code != http.StatusCreated {
+		t.Fatalf("creating a pet: got %d %s", w.Code, w.Body.String())
+	}
+	base := fmt.Sprintf("/pets/%d", p.ID)
+	var rec medicalRecord
+	w := h.do("POST", base+"/records", medicalRecordRequest{Kind: "vaccination", Title: "Rabies booster", OccurredOn: day}, nil, &rec)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("creating a record: got %d %s", w.Code, w.Body.String())
+	}
+	var pol insurancePolicy
+	w = h.do("POST", base+"/insurance/policies", policyRequest{Insurer: "Healthy Paws", PolicyNumber: "HP-1", CoverageStart: day, Currency: "USD", ReimbursementPercent: 80}, nil, &pol)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("creating a policy: got %d %s", w.Code, w.Body.String())
+	}
+	var cl insuranceClaim
+	w = h.do("POST", base+"/insurance/claims", claimRequest{PolicyID: pol.ID, IncidentDate: day, ClaimedMinor: 12550}, nil, &cl)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("creating a claim: got %d %s", w.Code, w.Body.String())
+	}
+
+	var recAtt, claimAtt attachment
+	recPath := fmt.Sprintf("%s/records/%d/attachments", base, rec.ID)
+	if w := h.upload(recPath, "file", pdf, &recAtt); w.Code != http.StatusCreated || recAtt.RecordID == nil || *recAtt.RecordID != rec.ID {
+		t.Fatalf("attaching to a record: got %d %s", w.Code, w.Body.String())
+	}
+	claimPath := fmt.Sprintf("%s/insurance/claims/%d/attachments", base, cl.ID)
+	if w := h.upload(claimPath, "file", pdf, &claimAtt); w.Code != http.StatusCreated || claimAtt.ClaimID == nil || *claimAtt.ClaimID != cl.ID {
+		t.Fatalf("attaching to a claim: got %d %s", w.Code, w.Body.String())
+	}
+	if w := h.upload(fmt.Sprintf("%s/records/%d/attachments", base, rec.ID+100), "file", pdf, nil); w.Code != http.StatusNotFound {
+		t.Errorf("attaching to a missing record: got %d, want 404", w.Code)
+	}
+
+	// Each list holds its own documents, the pet's holds them all
+	for path, want := range map[string]int{recPath: 1, claimPath: 1, base + "/attachments": 2} {
+		var got []attachment
+		if w := h.do("GET", path, nil, nil, &got); w.Code != http.StatusOK || len(got) != want {
+			t.Errorf("listing %s: got %d %v, want %d attachments", path, w.Code, got, want)
+		}
+	}
+
+	// Deleting the record deletes its attachment and blob
+	atts, err := h.s.dbAttachmentsGetRecord(int64(rec.UserID), int64(p.ID), int64(rec.ID))
+	if err != nil || len(atts) != 1 {
+		t.Fatalf("getting the record's attachments: got %v, error %v", atts, err)
+	}
+	if w := h.do("DELETE", fmt.Sprintf("%s/records/%d", base, rec.ID), nil, nil, nil); w.Code >= 300 {
+		t.Fatalf("deleting the record: got %d %s", w.Code, w.Body.String())
+	}
+	if w := h.do("GET", fmt.Sprintf("%s/attachments/%d", base, recAtt.ID), nil, nil, nil); w.Code != http.StatusNotFound {
+		t.Errorf("getting the record's attachment after deleting it: got %d, want 404", w.Code)
+	}
+	if _, err := h.s.blobs.Get(context.Background(), atts[0].BlobKey); err != errBlobNotFound {
+		t.Errorf("getting the record's blob after deleting it: got %v, want %v", err, errBlobNotFound)
+	}
+}
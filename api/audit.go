@@ -0,0 +1,422 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// auditEntityPet and auditEntityUser are the kinds of entities whose
+	// changes are audited
+	auditEntityPet  = "pet"
+	auditEntityUser = "user"
+
+	auditCreate  = "create"
+	auditUpdate  = "update"
+	auditDelete  = "delete"
+	auditRestore = "restore"
+	auditPurge   = "purge"
+)
+
+// auditActions are the actions the audit log records
+var auditActions = map[string]bool{
+	auditCreate:  true,
+	auditUpdate:  true,
+	auditDelete:  true,
+	auditRestore: true,
+	auditPurge:   true,
+}
+
+// defaultAuditLimit and maxAuditLimit bound a page of audit log entries
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 200
+)
+
+// auditFieldsHidden are never written to the audit log
+var auditFieldsHidden = []string{"password"}
+
+// auditEntry is one change to an entity. Before and After only hold the
+// fields that changed, Before is null for a created entity and After for
+// a purged one. ActorID is null for changes made by the server itself.
+type auditEntry struct {
+	ID         uint            `json:"audit_id" example:"12"`
+	ActorID    *uint           `json:"actor_id" example:"1"`
+	OwnerID    uint            `json:"owner_id" example:"1"`
+	Action     string          `json:"action" example:"update"`
+	EntityType string          `json:"entity_type" example:"pet"`
+	EntityID   uint            `json:"entity_id" example:"3"`
+	Before     json.RawMessage `json:"before" swaggertype:"object"`
+	After      json.RawMessage `json:"after" swaggertype:"object"`
+	RequestID  string          `json:"request_id" example:"9f86d081884c7d659a2feaa0c55ad015"`
+	IP         string          `json:"ip" example:"203.0.113.7"`
+	CreatedAt  time.Time       `json:"created_at" example:"2019-11-09T21:21:46+00:00"`
+}
+
+type auditPage struct {
+	Entries    []auditEntry `json:"entries"`
+	NextCursor string       `json:"next_cursor,omitempty" example:"eyJpIjoxMn0"`
+}
+
+// auditCursor is the position of the last entry on a page, handed to
+// clients in an opaque form
+type auditCursor struct {
+	ID uint `json:"i"`
+}
+
+// auditFilter narrows down the entries of the audit log
+type auditFilter struct {
+	OwnerID    int64
+	ActorID    int64
+	EntityType string
+	EntityID   int64
+	Action     string
+	From       time.Time
+	To         time.Time
+	Cursor     *auditCursor
+	Limit      int
+}
+
+//encode returns the opaque form of a cursor
+func (c auditCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+//decodeAuditCursor parses a cursor from a previous page
+func decodeAuditCursor(s string) (*auditCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c auditCursor
+	err = json.Unmarshal(b, &c)
+	if err != nil || c.ID == 0 {
+		return nil, errors.New("invalid cursor")
+	}
+	return &c, nil
+}
+
+//auditFilterFromRequest reads the actor_id, entity_type, entity_id, action,
+//from, to, cursor and limit query params
+func auditFilterFromRequest(r *http.Request) (auditFilter, error) {
+	f := auditFilter{Limit: defaultAuditLimit}
+	q := r.URL.Query()
+	var err error
+	for name, dst := range map[string]*int64{"actor_id": &f.ActorID, "entity_id": &f.EntityID} {
+		if v := q.Get(name); v != "" {
+			*dst, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || *dst < 1 {
+				return f, invalidField(name, name+" must be a positive number")
+			}
+		}
+	}
+	f.EntityType = q.Get("entity_type")
+	if f.EntityType != "" && f.EntityType != auditEntityPet && f.EntityType != auditEntityUser {
+		return f, invalidField("entity_type", "entity_type must be pet or user")
+	}
+	f.Action = q.Get("action")
+	if f.Action != "" && !auditActions[f.Action] {
+		return f, invalidField("action", "action must be one of create, update, delete, restore or purge")
+	}
+	f.From, err = parseDateParam(r, "from")
+	if err != nil {
+		return f, err
+	}
+	f.To, err = parseDateParam(r, "to")
+	if err != nil {
+		return f, err
+	}
+	if c := q.Get("cursor"); c != "" {
+		f.Cursor, err = decodeAuditCursor(c)
+		if err != nil {
+			return f, invalidField("cursor", "invalid cursor")
+		}
+	}
+	if l := q.Get("limit"); l != "" {
+		f.Limit, err = strconv.Atoi(l)
+		if err != nil || f.Limit < 1 || f.Limit > maxAuditLimit {
+			return f, invalidField("limit", fmt.Sprintf("limit must be between 1 and %d", maxAuditLimit))
+		}
+	}
+	return f, nil
+}
+
+//auditDiff returns the fields of two states of an entity that differ, as
+//they are rendered in JSON. Either state may be nil.
+func auditDiff(before, after interface{}) (json.RawMessage, json.RawMessage, error) {
+	fields := func(v interface{}) (map[string]interface{}, error) {
+		if v == nil {
+			return nil, nil
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		m := map[string]interface{}{}
+		err = json.Unmarshal(b, &m)
+		for _, f := range auditFieldsHidden {
+			delete(m, f)
+		}
+		return m, err
+	}
+	old, err := fields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	cur, err := fields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for k, v := range old {
+		if w, ok := cur[k]; ok && reflect.DeepEqual(v, w) {
+			delete(old, k)
+			delete(cur, k)
+		}
+	}
+
+	// Fields left out when empty are null on the side they're missing from
+	if old != nil && cur != nil {
+		for k := range old {
+			if _, ok := cur[k]; !ok {
+				cur[k] = nil
+			}
+		}
+		for k := range cur {
+			if _, ok := old[k]; !ok {
+				old[k] = nil
+			}
+		}
+	}
+	encode := func(m map[string]interface{}) (json.RawMessage, error) {
+		if m == nil {
+			return nil, nil
+		}
+		return json.Marshal(m)
+	}
+	b, err := encode(old)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := encode(cur)
+	return b, a, err
+}
+
+//audit records a change to an entity in the audit log. The actor is the
+//authenticated user unless the entry names one, and r is nil for changes
+//made by the server itself. The change was already made, so failures are
+//only logged.
+func (s *server) audit(r *http.Request, e auditEntry, before, after interface{}) {
+	var err error
+	e.Before, e.After, err = auditDiff(before, after)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error computing audit diff")
+		return
+	}
+	if r != nil {
+		if e.ActorID == nil {
+			if id, err := userIDFromRequest(r); err == nil {
+				actor := uint(id)
+				e.ActorID = &actor
+			}
+		}
+		e.RequestID = requestIDFromRequest(r)
+		e.IP = s.clientIP(r)
+	}
+	e.CreatedAt = time.Now()
+	err = s.auditLog.Append(e)
+	if err != nil {
+		s.logger.Error().Err(err).Str("action", e.Action).Str("entity_type", e.EntityType).Uint("entity_id", e.EntityID).
+			Msg("error writing audit log to database")
+	}
+}
+
+// auditLog is where the audit entries of changes are appended
+type auditLog interface {
+	Append(e auditEntry) error
+}
+
+// sqlAuditLog keeps the audit log in the audit_log table
+type sqlAuditLog struct {
+	db *sql.DB
+}
+
+//Append adds an entry to the audit log. Entries are never changed or
+//removed.
+func (l *sqlAuditLog) Append(e auditEntry) error {
+	var actor interface{}
+	if e.ActorID != nil {
+		actor = int64(*e.ActorID)
+	}
+	_, err := l.db.Exec(`INSERT INTO audit_log(actor_id, owner_id, action, entity_type, entity_id, old_values, new_values, request_id, ip, created_at)
+		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)`,
+		actor, e.OwnerID, e.Action, e.EntityType, e.EntityID, nullString(string(e.Before)), nullString(string(e.After)),
+		nullString(e.RequestID), nullString(e.IP), e.CreatedAt)
+	return err
+}
+
+//dbAuditGetAll returns a page of the audit log, newest first. One more
+//entry than the limit is fetched to know whether there is a next page.
+func (s *server) dbAuditGetAll(f auditFilter) (auditPage, error) {
+	where := []string{"TRUE"}
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if f.OwnerID != 0 {
+		where = append(where, "owner_id = "+arg(f.OwnerID))
+	}
+	if f.ActorID != 0 {
+		where = append(where, "actor_id = "+arg(f.ActorID))
+	}
+	if f.EntityType != "" {
+		where = append(where, "entity_type = "+arg(f.EntityType))
+	}
+	if f.EntityID != 0 {
+		where = append(where, "entity_id = "+arg(f.EntityID))
+	}
+	if f.Action != "" {
+		where = append(where, "action = "+arg(f.Action))
+	}
+	if !f.From.IsZero() {
+		where = append(where, "created_at >= "+arg(f.From))
+	}
+	if !f.To.IsZero() {
+		where = append(where, "created_at < "+arg(f.To))
+	}
+	if f.Cursor != nil {
+		where = append(where, "id < "+arg(int64(f.Cursor.ID)))
+	}
+	q := `SELECT id, actor_id, owner_id, action, entity_type, entity_id, old_values, new_values, request_id, ip, created_at
+		FROM audit_log WHERE ` + strings.Join(where, " AND ") + " ORDER BY id DESC LIMIT " + arg(f.Limit+1)
+
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return auditPage{}, err
+	}
+	defer rows.Close()
+
+	page := auditPage{Entries: []auditEntry{}}
+	for rows.Next() {
+		var e auditEntry
+		var actor sql.NullInt64
+		var before, after, requestID, ip sql.NullString
+		err := rows.Scan(&e.ID, &actor, &e.OwnerID, &e.Action, &e.EntityType, &e.EntityID, &before, &after, &requestID, &ip, &e.CreatedAt)
+		if err != nil {
+			return auditPage{}, err
+		}
+		if actor.Valid {
+			id := uint(actor.Int64)
+			e.ActorID = &id
+		}
+		if before.Valid {
+			e.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			e.After = json.RawMessage(after.String)
+		}
+		e.RequestID = requestID.String
+		e.IP = ip.String
+		page.Entries = append(page.Entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return auditPage{}, err
+	}
+
+	if len(page.Entries) > f.Limit {
+		page.Entries = page.Entries[:f.Limit]
+		page.NextCursor = auditCursor{ID: page.Entries[f.Limit-1].ID}.encode()
+	}
+	return page, nil
+}
+
+// handlerAuditGetAll godoc
+// @Summary Get the audit log
+// @Description Get the changes made to the user's own account and pets, by anyone, newest first. Pass next_cursor back as cursor to get the next page.
+// @Tags Audit
+// @Produce json
+// @Param actor_id query int false "Only changes made by this user"
+// @Param entity_type query string false "pet or user"
+// @Param entity_id query int false "Only changes to this entity"
+// @Param action query string false "create, update, delete, restore or purge"
+// @Param from query string false "Earliest date, YYYY-MM-DD"
+// @Param to query string false "Date before which to stop, YYYY-MM-DD"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "Entries per page, 1 to 200, defaults to 50"
+// @Success 200 {object} auditPage
+// @Security ApiKeyAuth
+// @Router /audit [get]
+func (s *server) handlerAuditGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+		f, err := auditFilterFromRequest(r)
+		if err != nil {
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+		f.OwnerID = userID
+		page, err := s.dbAuditGetAll(f)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving audit log from database")
+			s.respond(w, r, nil, "error retrieving audit log", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, page, "", http.StatusOK)
+	}
+}
+
+// handlerAdminAuditGetAll godoc
+// @Summary Get the audit log of all users
+// @Description Get the changes made to any account or pet, newest first. Only admins may do this. Pass next_cursor back as cursor to get the next page.
+// @Tags Admin
+// @Produce json
+// @Param owner_id query int false "Only changes to this user's account and pets"
+// @Param actor_id query int false "Only changes made by this user"
+// @Param entity_type query string false "pet or user"
+// @Param entity_id query int false "Only changes to this entity"
+// @Param action query string false "create, update, delete, restore or purge"
+// @Param from query string false "Earliest date, YYYY-MM-DD"
+// @Param to query string false "Date before which to stop, YYYY-MM-DD"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "Entries per page, 1 to 200, defaults to 50"
+// @Success 200 {object} auditPage
+// @Security ApiKeyAuth
+// @Router /admin/audit [get]
+func (s *server) handlerAdminAuditGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, err := auditFilterFromRequest(r)
+		if err != nil {
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+		if v := r.URL.Query().Get("owner_id"); v != "" {
+			f.OwnerID, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || f.OwnerID < 1 {
+				s.respondProblem(w, r, invalidField("owner_id", "owner_id must be a positive number"))
+				return
+			}
+		}
+		page, err := s.dbAuditGetAll(f)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving audit log from database")
+			s.respond(w, r, nil, "error retrieving audit log", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, page, "", http.StatusOK)
+	}
+}
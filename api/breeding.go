@@ -0,0 +1,850 @@
+package api
+
+import (
+	"database/sql"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// The generations a pedigree or a coefficient of inbreeding goes back by
+// default and at most. Ten generations is up to 2046 ancestors.
+const (
+	defaultPedigreeGenerations = 4
+	defaultCOIGenerations      = 5
+	maxPedigreeGenerations     = 10
+)
+
+type litter struct {
+	ID                 uint      `json:"litter_id" example:"1"`
+	UserID             uint      `json:"user_id" example:"1"`
+	SireID             *uint     `json:"sire_id" example:"2"`
+	DamID              *uint     `json:"dam_id" example:"3"`
+	WhelpedOn          time.Time `json:"whelped_on" example:"2019-11-09T00:00:00Z"`
+	RegistrationNumber string    `json:"registration_number" example:"LR0012345"`
+	Notes              string    `json:"notes" example:"Six puppies, all healthy"`
+	PuppyCount         int       `json:"puppy_count" example:"6"`
+	Puppies            []pet     `json:"puppies,omitempty"`
+	CreatedAt          time.Time `json:"created_at" example:"2019-11-09T21:21:46+00:00"`
+	UpdatedAt          time.Time `json:"updated_at" example:"2019-11-09T21:21:46+00:00"`
+}
+
+// litterRequest holds a litter. The dam is required, the sire may be unknown.
+type litterRequest struct {
+	SireID             *uint     `json:"sire_id" example:"2" validate:"min=1"`
+	DamID              *uint     `json:"dam_id" example:"3" validate:"required,min=1"`
+	WhelpedOn          time.Time `json:"whelped_on" example:"2019-11-09T00:00:00Z" validate:"required,max=now"`
+	RegistrationNumber string    `json:"registration_number" example:"LR0012345" validate:"max=32"`
+	Notes              string    `json:"notes" example:"Six puppies, all healthy" validate:"max=2000"`
+}
+
+type litters []litter
+
+// pedigreeNode is a pet in a pedigree tree, with its own parents nested.
+// Parents that aren't known, or are past the last generation, are left out.
+type pedigreeNode struct {
+	PetID              uint          `json:"pet_id" example:"1"`
+	Name               string        `json:"name" example:"Fido"`
+	Type               string        `json:"type" example:"Dog"`
+	Breed              string        `json:"breed" example:"Labrador Retriever"`
+	Gender             string        `json:"gender" example:"Male"`
+	Birthday           time.Time     `json:"birthday" example:"2019-11-09T00:00:00Z"`
+	RegistrationNumber string        `json:"registration_number,omitempty" example:"SR12345678"`
+	Sire               *pedigreeNode `json:"sire,omitempty"`
+	Dam                *pedigreeNode `json:"dam,omitempty"`
+}
+
+// pedigreeAncestor is an ancestor shared by both sides of a pairing
+type pedigreeAncestor struct {
+	PetID uint   `json:"pet_id" example:"7"`
+	Name  string `json:"name" example:"Rex"`
+}
+
+// coiResult is the coefficient of inbreeding the offspring of a pairing
+// would have, as a fraction and as a percentage
+type coiResult struct {
+	SireID          uint               `json:"sire_id" example:"2"`
+	DamID           uint               `json:"dam_id" example:"3"`
+	Generations     int                `json:"generations" example:"5"`
+	Coefficient     float64            `json:"coefficient" example:"0.0625"`
+	Percent         float64            `json:"percent" example:"6.25"`
+	CommonAncestors []pedigreeAncestor `json:"common_ancestors"`
+}
+
+const litterColumns = "id, user_id, sire_id, dam_id, whelped_on, registration_number, notes, (SELECT count(*) FROM pets WHERE pets.litter_id = litters.id AND pets.deleted_at IS NULL), created_at, updated_at"
+
+//scanLitter scans a row selected with litterColumns
+func scanLitter(row interface{ Scan(...interface{}) error }) (litter, error) {
+	var l litter
+	var sireID, damID sql.NullInt64
+	var registration, notes sql.NullString
+	err := row.Scan(&l.ID, &l.UserID, &sireID, &damID, &l.WhelpedOn, &registration, &notes, &l.PuppyCount, &l.CreatedAt, &l.UpdatedAt)
+	if err != nil {
+		return l, err
+	}
+	l.SireID, l.DamID = nullIDPtr(sireID), nullIDPtr(damID)
+	l.RegistrationNumber, l.Notes = registration.String, notes.String
+	return l, nil
+}
+
+//validateLitter normalizes and checks a litter request
+func validateLitter(req *litterRequest) error {
+	req.RegistrationNumber = strings.TrimSpace(req.RegistrationNumber)
+	req.Notes = strings.TrimSpace(req.Notes)
+	return validate(req)
+}
+
+//litterIDFromRequest is a helper to extract the litter ID URL param
+func litterIDFromRequest(r *http.Request) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)["litterID"], 10, 64)
+}
+
+//generationsFromRequest reads the generations query param, between 1 and
+//maxPedigreeGenerations
+func generationsFromRequest(r *http.Request, def int) (int, error) {
+	g := r.URL.Query().Get("generations")
+	if g == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(g)
+	if err != nil || n < 1 || n > maxPedigreeGenerations {
+		return 0, invalidField("generations", "generations must be between 1 and 10")
+	}
+	return n, nil
+}
+
+//dbPetsAncestors loads pets owned by a user and their ancestors, going back
+//the given number of generations, or all the way when it is 0. Each pet is
+//only loaded once, so a cycle in existing data can't loop forever.
+func (s *server) dbPetsAncestors(userID int64, ids []uint, generations int) (map[uint]pet, error) {
+	found := map[uint]pet{}
+	frontier := ids
+	for g := 0; len(frontier) > 0 && (generations == 0 || g <= generations); g++ {
+		in, args := idList(frontier, 2)
+		rows, err := s.db.Query("SELECT "+petColumns+" FROM pets WHERE user_id = $1 AND deleted_at IS NULL AND id IN ("+in+")", append([]interface{}{userID}, args...)...)
+		if err != nil {
+			return nil, err
+		}
+		next := []uint{}
+		for rows.Next() {
+			p, err := scanPet(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			found[p.ID] = p
+			for _, parent := range []*uint{p.SireID, p.DamID} {
+				if parent == nil {
+					continue
+				}
+				if _, ok := found[*parent]; !ok {
+					next = append(next, *parent)
+				}
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		frontier = uniqueIDs(next)
+	}
+	return found, nil
+}
+
+//checkPetParents makes sure the sire and dam of a pet, or of a litter, are
+//different pets of the user of the same species, of the right gender, born
+//before their offspring and not descended from it, and that the litter of a
+//pet is the user's. It responds to the client itself when they aren't.
+func (s *server) checkPetParents(w http.ResponseWriter, r *http.Request, userID int64, p *pet) bool {
+	if p.LitterID != nil {
+		ok, err := s.dbLittersExists(userID, int64(*p.LitterID))
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving litter from database")
+			s.respond(w, r, nil, "error checking parents", http.StatusInternalServerError)
+			return false
+		}
+		if !ok {
+			s.respondProblem(w, r, invalidField("litter_id", "litter not found"))
+			return false
+		}
+	}
+	if p.SireID != nil && p.DamID != nil && *p.SireID == *p.DamID {
+		s.respondProblem(w, r, invalidField("dam_id", "sire and dam must be different pets"))
+		return false
+	}
+	species := p.Type
+	for _, parent := range []struct {
+		role   string
+		id     *uint
+		gender string
+	}{{"sire", p.SireID, "Male"}, {"dam", p.DamID, "Female"}} {
+		if parent.id == nil {
+			continue
+		}
+		if p.ID != 0 && *parent.id == p.ID {
+			s.respondProblem(w, r, invalidField(parent.role+"_id", "a pet can't be its own "+parent.role))
+			return false
+		}
+		pp, err := s.pets.GetOne(userID, int64(*parent.id))
+		if err == sql.ErrNoRows {
+			s.respondProblem(w, r, invalidField(parent.role+"_id", parent.role+" not found"))
+			return false
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving parent from database")
+			s.respond(w, r, nil, "error checking parents", http.StatusInternalServerError)
+			return false
+		}
+		if pp.Gender != "" && pp.Gender != "Unknown" && pp.Gender != parent.gender {
+			s.respondProblem(w, r, invalidField(parent.role+"_id", parent.role+" must be "+strings.ToLower(parent.gender)))
+			return false
+		}
+		if species != "" && pp.Type != "" && pp.Type != species {
+			s.respondProblem(w, r, invalidField(parent.role+"_id", "sire, dam and offspring must be the same species"))
+			return false
+		}
+		if species == "" {
+			species = pp.Type
+		}
+		if !p.Birthday.IsZero() && !pp.Birthday.IsZero() && !pp.Birthday.Before(p.Birthday) {
+			s.respondProblem(w, r, invalidField(parent.role+"_id", parent.role+" must be born before its offspring"))
+			return false
+		}
+	}
+
+	// A new pet has no descendants, an existing one mustn't be among the
+	// ancestors of its new parents
+	if p.ID == 0 || (p.SireID == nil && p.DamID == nil) {
+		return true
+	}
+	parents := []uint{}
+	for _, id := range []*uint{p.SireID, p.DamID} {
+		if id != nil {
+			parents = append(parents, *id)
+		}
+	}
+	ancestors, err := s.dbPetsAncestors(userID, parents, 0)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error retrieving ancestors from database")
+		s.respond(w, r, nil, "error checking parents", http.StatusInternalServerError)
+		return false
+	}
+	if _, ok := ancestors[p.ID]; ok {
+		s.respondProblem(w, r, invalidField("sire_id", "a pet can't be its own ancestor"))
+		return false
+	}
+	return true
+}
+
+//buildPedigree nests the ancestors of a pet up to a number of generations
+func buildPedigree(pets map[uint]pet, id uint, generations int) *pedigreeNode {
+	p, ok := pets[id]
+	if !ok {
+		return nil
+	}
+	n := &pedigreeNode{
+		PetID:              p.ID,
+		Name:               p.Name,
+		Type:               p.Type,
+		Breed:              p.Breed,
+		Gender:             p.Gender,
+		Birthday:           p.Birthday,
+		RegistrationNumber: p.RegistrationNumber,
+	}
+	if generations == 0 {
+		return n
+	}
+	if p.SireID != nil {
+		n.Sire = buildPedigree(pets, *p.SireID, generations-1)
+	}
+	if p.DamID != nil {
+		n.Dam = buildPedigree(pets, *p.DamID, generations-1)
+	}
+	return n
+}
+
+// kinship computes coefficients of kinship between pets of a loaded
+// pedigree. Parents that weren't loaded count as unknown and unrelated.
+type kinship struct {
+	pets map[uint]pet
+	memo map[[2]uint]float64
+}
+
+//parents returns the loaded sire and dam of a pet, 0 when unknown
+func (k *kinship) parents(id uint) (sire, dam uint) {
+	p := k.pets[id]
+	if p.SireID != nil {
+		if _, ok := k.pets[*p.SireID]; ok {
+			sire = *p.SireID
+		}
+	}
+	if p.DamID != nil {
+		if _, ok := k.pets[*p.DamID]; ok {
+			dam = *p.DamID
+		}
+	}
+	return sire, dam
+}
+
+//ancestors returns the loaded ancestors of a pet, including itself
+func (k *kinship) ancestors(id uint) map[uint]bool {
+	seen := map[uint]bool{}
+	stack := []uint{id}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		sire, dam := k.parents(id)
+		stack = append(stack, sire, dam)
+	}
+	return seen
+}
+
+//coefficient returns the coefficient of kinship of two pets, which is the
+//coefficient of inbreeding of their offspring. It always expands the pet
+//that isn't an ancestor of the other, so the recursion only goes back in
+//time.
+func (k *kinship) coefficient(a, b uint) float64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	key := [2]uint{a, b}
+	if a > b {
+		key = [2]uint{b, a}
+	}
+	if f, ok := k.memo[key]; ok {
+		return f
+	}
+	// Guards against cycles in existing data
+	k.memo[key] = 0
+
+	var f float64
+	if a == b {
+		sire, dam := k.parents(a)
+		f = (1 + k.coefficient(sire, dam)) / 2
+	} else {
+		if k.ancestors(b)[a] {
+			a, b = b, a
+		}
+		sire, dam := k.parents(a)
+		f = (k.coefficient(sire, b) + k.coefficient(dam, b)) / 2
+	}
+	k.memo[key] = f
+	return f
+}
+
+//computeCOI works out the coefficient of inbreeding of the offspring of a
+//sire and a dam from their loaded ancestors
+func computeCOI(pets map[uint]pet, sireID, damID uint, generations int) coiResult {
+	k := &kinship{pets: pets, memo: map[[2]uint]float64{}}
+	f := k.coefficient(sireID, damID)
+	res := coiResult{
+		SireID:          sireID,
+		DamID:           damID,
+		Generations:     generations,
+		Coefficient:     f,
+		Percent:         math.Round(f*10000) / 100,
+		CommonAncestors: []pedigreeAncestor{},
+	}
+	damSide := k.ancestors(damID)
+	for id := range k.ancestors(sireID) {
+		if damSide[id] {
+			res.CommonAncestors = append(res.CommonAncestors, pedigreeAncestor{PetID: id, Name: pets[id].Name})
+		}
+	}
+	sort.Slice(res.CommonAncestors, func(i, j int) bool {
+		return res.CommonAncestors[i].PetID < res.CommonAncestors[j].PetID
+	})
+	return res
+}
+
+//dbLittersGetAll returns the litters of a user, newest first
+func (s *server) dbLittersGetAll(userID int64) ([]litter, error) {
+	rows, err := s.db.Query("SELECT "+litterColumns+" FROM litters WHERE user_id = $1 ORDER BY whelped_on DESC, id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ls := []litter{}
+	for rows.Next() {
+		l, err := scanLitter(rows)
+		if err != nil {
+			return nil, err
+		}
+		ls = append(ls, l)
+	}
+	return ls, rows.Err()
+}
+
+//dbLittersGetOne returns a single litter of a user with its puppies
+func (s *server) dbLittersGetOne(userID, litterID int64) (litter, error) {
+	row := s.db.QueryRow("SELECT "+litterColumns+" FROM litters WHERE user_id = $1 AND id = $2", userID, litterID)
+	l, err := scanLitter(row)
+	if err != nil {
+		return l, err
+	}
+	rows, err := s.db.Query("SELECT "+petColumns+" FROM pets WHERE user_id = $1 AND litter_id = $2 AND deleted_at IS NULL ORDER BY id", userID, litterID)
+	if err != nil {
+		return l, err
+	}
+	defer rows.Close()
+
+	l.Puppies = []pet{}
+	for rows.Next() {
+		p, err := scanPet(rows)
+		if err != nil {
+			return l, err
+		}
+		l.Puppies = append(l.Puppies, p)
+	}
+	return l, rows.Err()
+}
+
+//dbLittersExists checks that a litter exists and is the user's
+func (s *server) dbLittersExists(userID, litterID int64) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM litters WHERE id = $1 AND user_id = $2)", litterID, userID).Scan(&exists)
+	return exists, err
+}
+
+//dbLittersCreate stores a new litter
+func (s *server) dbLittersCreate(l litter) (int64, error) {
+	var id int64
+	err := s.db.QueryRow("INSERT INTO litters(user_id, sire_id, dam_id, whelped_on, registration_number, notes, created_at, updated_at) VALUES($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id",
+		l.UserID, l.SireID, l.DamID, l.WhelpedOn, nullString(l.RegistrationNumber), nullString(l.Notes), l.CreatedAt, l.UpdatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+//dbLittersUpdate updates a litter of a user
+func (s *server) dbLittersUpdate(l litter) (int64, error) {
+	res, err := s.db.Exec("UPDATE litters SET sire_id = $1, dam_id = $2, whelped_on = $3, registration_number = $4, notes = $5, updated_at = $6 WHERE id = $7 AND user_id = $8",
+		l.SireID, l.DamID, l.WhelpedOn, nullString(l.RegistrationNumber), nullString(l.Notes), l.UpdatedAt, l.ID, l.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbLittersDelete deletes a litter of a user. Its puppies are kept.
+func (s *server) dbLittersDelete(userID, litterID int64) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM litters WHERE user_id = $1 AND id = $2", userID, litterID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// handlerPetsPedigree godoc
+// @Summary Get the pedigree of a pet
+// @Description Get the ancestors of a pet as a tree of sires and dams, going back a number of generations
+// @Tags Breeding
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param generations query int false "Generations, 1 to 10, defaults to 4"
+// @Success 200 {object} pedigreeNode
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/pedigree [get]
+func (s *server) handlerPetsPedigree() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		generations, err := generationsFromRequest(r, defaultPedigreeGenerations)
+		if err != nil {
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+		pets, err := s.dbPetsAncestors(userID, []uint{uint(petID)}, generations)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving pedigree from database")
+			s.respond(w, r, nil, "error retrieving pedigree", http.StatusInternalServerError)
+			return
+		}
+		tree := buildPedigree(pets, uint(petID), generations)
+		if tree == nil {
+			s.respond(w, r, nil, "pet not found", http.StatusNotFound)
+			return
+		}
+		s.respond(w, r, tree, "", http.StatusOK)
+	}
+}
+
+// handlerBreedingCOI godoc
+// @Summary Get the coefficient of inbreeding of a pairing
+// @Description Compute the coefficient of inbreeding (Wright) the offspring of a sire and a dam would have, from their pedigrees going back a number of generations. Ancestors past that are treated as unrelated.
+// @Tags Breeding
+// @Produce json
+// @Param sire_id query int true "Sire pet ID"
+// @Param dam_id query int true "Dam pet ID"
+// @Param generations query int false "Generations, 1 to 10, defaults to 5"
+// @Success 200 {object} coiResult
+// @Security ApiKeyAuth
+// @Router /breeding/coi [get]
+func (s *server) handlerBreedingCOI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+		sireID, err := strconv.ParseUint(r.URL.Query().Get("sire_id"), 10, 64)
+		if err != nil {
+			s.respondProblem(w, r, invalidField("sire_id", "must provide a valid sire_id"))
+			return
+		}
+		damID, err := strconv.ParseUint(r.URL.Query().Get("dam_id"), 10, 64)
+		if err != nil {
+			s.respondProblem(w, r, invalidField("dam_id", "must provide a valid dam_id"))
+			return
+		}
+		generations, err := generationsFromRequest(r, defaultCOIGenerations)
+		if err != nil {
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+		sire, dam := uint(sireID), uint(damID)
+		if !s.checkPetParents(w, r, userID, &pet{SireID: &sire, DamID: &dam}) {
+			return
+		}
+		pets, err := s.dbPetsAncestors(userID, []uint{sire, dam}, generations)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving pedigree from database")
+			s.respond(w, r, nil, "error computing coefficient of inbreeding", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, computeCOI(pets, sire, dam, generations), "", http.StatusOK)
+	}
+}
+
+// handlerLittersGetAll godoc
+// @Summary Get all litters
+// @Description Get all litters of the user, newest first
+// @Tags Breeding
+// @Produce json
+// @Success 200 {array} litter
+// @Security ApiKeyAuth
+// @Router /litters [get]
+func (s *server) handlerLittersGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+		ls, err := s.dbLittersGetAll(userID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving litters from database")
+			s.respond(w, r, nil, "error retrieving litters", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, ls, "", http.StatusOK)
+	}
+}
+
+// handlerLittersGetOne godoc
+// @Summary Get one litter
+// @Description Get one litter of the user with its puppies
+// @Tags Breeding
+// @Produce json
+// @Param LitterID path int true "Litter ID"
+// @Success 200 {object} litter
+// @Security ApiKeyAuth
+// @Router /litters/{LitterID} [get]
+func (s *server) handlerLittersGetOne() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+		litterID, err := litterIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid litter id", http.StatusBadRequest)
+			return
+		}
+		l, err := s.dbLittersGetOne(userID, litterID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "litter not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving litter from database")
+			s.respond(w, r, nil, "error retrieving litter", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, l, "", http.StatusOK)
+	}
+}
+
+// handlerLittersCreate godoc
+// @Summary Create a litter
+// @Description Record a litter with its sire, dam and whelping date. Add puppies to it afterwards.
+// @Tags Breeding
+// @Accept json
+// @Produce json
+// @Param litter body litterRequest true "Create Litter"
+// @Success 201 {object} litter
+// @Security ApiKeyAuth
+// @Router /litters [post]
+func (s *server) handlerLittersCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Get JSON body, decode into a litter request and validate it
+		var req litterRequest
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateLitter(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking litter")
+			return
+		}
+		if !s.checkPetParents(w, r, userID, &pet{SireID: req.SireID, DamID: req.DamID, Birthday: req.WhelpedOn}) {
+			return
+		}
+
+		// Create the litter in the db
+		l := litter{
+			UserID:             uint(userID),
+			SireID:             req.SireID,
+			DamID:              req.DamID,
+			WhelpedOn:          req.WhelpedOn,
+			RegistrationNumber: req.RegistrationNumber,
+			Notes:              req.Notes,
+			CreatedAt:          ts,
+			UpdatedAt:          ts,
+		}
+		id, err := s.dbLittersCreate(l)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error creating litter in database")
+			s.respond(w, r, nil, "error creating litter", http.StatusInternalServerError)
+			return
+		}
+		l.ID = uint(id)
+		s.respond(w, r, l, "", http.StatusCreated)
+	}
+}
+
+// handlerLittersUpdate godoc
+// @Summary Update a litter
+// @Description Update a litter of the user. Puppies already added keep their parents and birthday.
+// @Tags Breeding
+// @Accept json
+// @Produce json
+// @Param LitterID path int true "Litter ID"
+// @Param litter body litterRequest true "Updated Litter"
+// @Success 200 {object} litter
+// @Security ApiKeyAuth
+// @Router /litters/{LitterID} [put]
+func (s *server) handlerLittersUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+		litterID, err := litterIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid litter id", http.StatusBadRequest)
+			return
+		}
+		l, err := s.dbLittersGetOne(userID, litterID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "litter not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving litter from database")
+			s.respond(w, r, nil, "error updating litter", http.StatusInternalServerError)
+			return
+		}
+
+		// Get JSON body, decode into a litter request and validate it
+		var req litterRequest
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateLitter(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking litter")
+			return
+		}
+		if !s.checkPetParents(w, r, userID, &pet{SireID: req.SireID, DamID: req.DamID, Birthday: req.WhelpedOn}) {
+			return
+		}
+
+		// Update the litter in the db
+		l.SireID = req.SireID
+		l.DamID = req.DamID
+		l.WhelpedOn = req.WhelpedOn
+		l.RegistrationNumber = req.RegistrationNumber
+		l.Notes = req.Notes
+		l.UpdatedAt = ts
+		_, err = s.dbLittersUpdate(l)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error updating litter in database")
+			s.respond(w, r, nil, "error updating litter", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, l, "", http.StatusOK)
+	}
+}
+
+// handlerLittersDelete godoc
+// @Summary Delete a litter
+// @Description Delete a litter of the user. Its puppies are kept.
+// @Tags Breeding
+// @Param LitterID path int true "Litter ID"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /litters/{LitterID} [delete]
+func (s *server) handlerLittersDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+		litterID, err := litterIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid litter id", http.StatusBadRequest)
+			return
+		}
+		rows, err := s.dbLittersDelete(userID, litterID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error deleting litter from database")
+			s.respond(w, r, nil, "error deleting litter", http.StatusInternalServerError)
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "litter not found", http.StatusNotFound)
+			return
+		}
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
+
+// handlerLittersAddPuppy godoc
+// @Summary Add a puppy to a litter
+// @Description Create a pet born in a litter. Its sire, dam, birthday and litter come from the litter, its type from the dam and its breed from the parents when they share one.
+// @Tags Breeding
+// @Accept json
+// @Produce json
+// @Param LitterID path int true "Litter ID"
+// @Param pet body petRequest true "Create Puppy"
+// @Success 201 {object} pet
+// @Security ApiKeyAuth
+// @Router /litters/{LitterID}/puppies [post]
+func (s *server) handlerLittersAddPuppy() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+		litterID, err := litterIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid litter id", http.StatusBadRequest)
+			return
+		}
+		l, err := s.dbLittersGetOne(userID, litterID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "litter not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving litter from database")
+			s.respond(w, r, nil, "error creating puppy", http.StatusInternalServerError)
+			return
+		}
+
+		// Get JSON body and decode into a pet, taking its parents from the litter
+		var puppy pet
+		err = s.decode(w, r, &puppy)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		puppy.SireID, puppy.DamID, puppy.LitterID = l.SireID, l.DamID, &l.ID
+		puppy.Birthday = l.WhelpedOn
+		puppy.CreatedAt = ts
+		puppy.UpdatedAt = ts
+
+		// Default the type to the dam's, and the breed to the parents' when they share one
+		var sire, dam pet
+		for _, parent := range []struct {
+			id *uint
+			p  *pet
+		}{{l.SireID, &sire}, {l.DamID, &dam}} {
+			if parent.id == nil {
+				continue
+			}
+			*parent.p, err = s.pets.GetOne(userID, int64(*parent.id))
+			if err != nil && err != sql.ErrNoRows {
+				s.logger.Error().Err(err).Msg("error retrieving parent from database")
+				s.respond(w, r, nil, "error creating puppy", http.StatusInternalServerError)
+				return
+			}
+		}
+		if puppy.Type == "" {
+			puppy.Type = dam.Type
+		}
+		if puppy.Breed == "" && sire.Breed != "" && sire.Breed == dam.Breed {
+			puppy.Breed = dam.Breed
+		}
+
+		// Check the fields, identifiers and catalog like any other pet
+		err = validatePet(&puppy)
+		if err != nil {
+			s.respondError(w, r, err, "error checking pet")
+			return
+		}
+		err = s.catalog.snapshot().normalizePet(&puppy)
+		if err != nil {
+			s.respondError(w, r, err, "error checking pet")
+			return
+		}
+
+		id, err := s.pets.Create(puppy, userID)
+		if err != nil {
+			s.respondError(w, r, err, "error creating puppy")
+			return
+		}
+		puppy.UserID = uint(userID)
+		puppy.ID = uint(id)
+		s.respond(w, r, puppy, "", http.StatusCreated)
+	}
+}
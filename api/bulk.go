@@ -0,0 +1,433 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/httputil/header"
+)
+
+const (
+	// maxImportBytes is the largest import body accepted
+	maxImportBytes = 10 << 20
+	// maxImportRows is the most pets a single import may create
+	maxImportRows = 10000
+)
+
+// petImportFields sets the fields of a pet an import may fill from the
+// text of a column
+var petImportFields = map[string]func(p *pet, v string) error{
+	"name":                func(p *pet, v string) error { p.Name = v; return nil },
+	"type":                func(p *pet, v string) error { p.Type = v; return nil },
+	"gender":              func(p *pet, v string) error { p.Gender = v; return nil },
+	"breed":               func(p *pet, v string) error { p.Breed = v; return nil },
+	"microchip":           func(p *pet, v string) error { p.Microchip = v; return nil },
+	"tattoo":              func(p *pet, v string) error { p.Tattoo = v; return nil },
+	"license_tag":         func(p *pet, v string) error { p.LicenseTag = v; return nil },
+	"registration_number": func(p *pet, v string) error { p.RegistrationNumber = v; return nil },
+	"birthday": func(p *pet, v string) error {
+		if v == "" {
+			return nil
+		}
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			t, err = time.Parse(time.RFC3339, v)
+		}
+		if err != nil {
+			return errors.New("birthday must be a date (YYYY-MM-DD)")
+		}
+		p.Birthday = t
+		return nil
+	},
+}
+
+// petImportIgnored are the columns of an export an import skips, so an
+// export can be imported as it is. Parents and litters refer to pets of
+// the exporting account.
+var petImportIgnored = append([]string{"sire_id", "dam_id", "litter_id"}, petImmutableFields...)
+
+// petExportColumns are the columns of a CSV export, in order
+var petExportColumns = []string{"pet_id", "name", "type", "gender", "breed", "birthday", "microchip", "tattoo", "license_tag", "registration_number", "sire_id", "dam_id", "litter_id", "created_at", "updated_at", "version"}
+
+// importResult reports on an import that went through. Problems with rows
+// are reported as a validation problem instead, with the rows numbered
+// from 1, not counting the CSV header.
+type importResult struct {
+	DryRun bool   `json:"dry_run"`
+	Rows   int    `json:"rows" example:"2"`
+	PetIDs []uint `json:"pet_ids,omitempty"`
+}
+
+// importRow is a row of an import, its values by pet field
+type importRow map[string]string
+
+//importMapping reads the map query params, each a source column, a colon
+//and the pet field it fills, or - to skip the column
+func importMapping(r *http.Request) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, m := range r.URL.Query()["map"] {
+		i := strings.LastIndex(m, ":")
+		if i < 0 {
+			return nil, invalidField("map", fmt.Sprintf("map %q must be a column, a colon and a field", m))
+		}
+		source, field := strings.TrimSpace(m[:i]), strings.TrimSpace(m[i+1:])
+		if _, ok := petImportFields[field]; !ok && field != "-" {
+			return nil, invalidField("map", fmt.Sprintf("can't map %q to unknown field %q", source, field))
+		}
+		mapping[source] = field
+	}
+	return mapping, nil
+}
+
+//importField returns the field a source column fills, "" to skip it
+func importField(mapping map[string]string, column string) (string, error) {
+	field, ok := mapping[column]
+	if !ok {
+		field = column
+	}
+	if field == "-" {
+		return "", nil
+	}
+	if _, ok := petImportFields[field]; ok {
+		return field, nil
+	}
+	for _, ignored := range petImportIgnored {
+		if field == ignored {
+			return "", nil
+		}
+	}
+	return "", fmt.Errorf("unknown column %q, map it to a field or to - to skip it", column)
+}
+
+//readImportCSV reads the rows of a CSV import, the first line naming the
+//columns
+func readImportCSV(body io.Reader, mapping map[string]string) ([]importRow, error) {
+	cr := csv.NewReader(body)
+	cr.TrimLeadingSpace = true
+	columns, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV has no header")
+	}
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]string, len(columns))
+	for i, c := range columns {
+		// Spreadsheets like to start their CSV files with a byte order mark
+		if i == 0 {
+			c = strings.TrimPrefix(c, "\ufeff")
+		}
+		fields[i], err = importField(mapping, strings.TrimSpace(c))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows := []importRow{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("at most %d pets can be imported at once", maxImportRows)
+		}
+		row := importRow{}
+		for i, v := range record {
+			if fields[i] != "" {
+				row[fields[i]] = strings.TrimSpace(v)
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+//readImportJSON reads the rows of a JSON import, an array of objects
+func readImportJSON(body io.Reader, mapping map[string]string) ([]importRow, error) {
+	var objects []map[string]interface{}
+	dec := json.NewDecoder(body)
+	dec.UseNumber()
+	err := dec.Decode(&objects)
+	if err != nil {
+		return nil, errors.New("body must be a JSON array of objects")
+	}
+	if len(objects) > maxImportRows {
+		return nil, fmt.Errorf("at most %d pets can be imported at once", maxImportRows)
+	}
+	rows := make([]importRow, len(objects))
+	for i, o := range objects {
+		rows[i] = importRow{}
+		for k, v := range o {
+			field, err := importField(mapping, k)
+			if err != nil {
+				return nil, err
+			}
+			if field == "" || v == nil {
+				continue
+			}
+			switch v := v.(type) {
+			case string:
+				rows[i][field] = strings.TrimSpace(v)
+			case json.Number, bool:
+				rows[i][field] = fmt.Sprint(v)
+			default:
+				return nil, fmt.Errorf("%s must be a string", k)
+			}
+		}
+	}
+	return rows, nil
+}
+
+//validateImport turns the rows of an import into pets, checking them like
+//a create does, and returns the problems found per row
+func (s *server) validateImport(rows []importRow, ts time.Time) ([]pet, []fieldError, error) {
+	pets := make([]pet, len(rows))
+	errs := []fieldError{}
+	catalog := s.catalog.snapshot()
+	chips := map[string]int{}
+	for i, row := range rows {
+		p := pet{CreatedAt: ts, UpdatedAt: ts}
+		bad := false
+		for field, v := range row {
+			if err := petImportFields[field](&p, v); err != nil {
+				errs = append(errs, fieldError{Row: i + 1, Field: field, Code: codeInvalidField, Message: err.Error()})
+				bad = true
+			}
+		}
+		if bad {
+			continue
+		}
+		err := validatePet(&p)
+		if err == nil {
+			err = catalog.normalizePet(&p)
+		}
+		if e, ok := err.(*apiError); ok && len(e.Fields) > 0 {
+			for _, f := range e.Fields {
+				errs = append(errs, fieldError{Row: i + 1, Field: f.Field, Code: f.Code, Message: f.Message})
+			}
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fieldError{Row: i + 1, Code: codeInvalidField, Message: err.Error()})
+			continue
+		}
+		if p.Microchip != "" {
+			if first, ok := chips[p.Microchip]; ok {
+				errs = append(errs, fieldError{Row: i + 1, Field: "microchip", Code: codeMicrochipTaken, Message: fmt.Sprintf("microchip is on row %d too", first)})
+				continue
+			}
+			chips[p.Microchip] = i + 1
+		}
+		pets[i] = p
+	}
+
+	// Look the microchips up all at once
+	list := make([]string, 0, len(chips))
+	for chip := range chips {
+		list = append(list, chip)
+	}
+	taken, err := s.pets.TakenMicrochips(list)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, chip := range taken {
+		errs = append(errs, fieldError{Row: chips[chip], Field: "microchip", Code: codeMicrochipTaken, Message: "microchip is already registered"})
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Row < errs[j].Row })
+	return pets, errs, nil
+}
+
+//importError returns the validation problem reporting the rows of an
+//import with errors
+func importError(errs []fieldError) *apiError {
+	detail := fmt.Sprintf("row %d: %s", errs[0].Row, errs[0].Message)
+	if len(errs) > 1 {
+		detail = fmt.Sprintf("%s (and %d more)", detail, len(errs)-1)
+	}
+	return &apiError{Status: http.StatusUnprocessableEntity, Code: codeValidation, Detail: detail, Fields: errs}
+}
+
+// handlerPetsImport godoc
+// @Summary Import pets
+// @Description Create pets from a CSV file, whose first line names the columns, or a JSON array of objects. Columns are named after the fields of a pet, other names can be mapped to them with map, for instance map=Pet Name:name or map=Notes:- to skip a column. The columns of an export are accepted as they are. All rows are checked first and, when any has a problem, nothing is imported and a validation_failed problem with status 422 lists the problems, each with its row. With dry_run=true the rows are only checked.
+// @Tags Pets
+// @Accept text/csv,json
+// @Produce json
+// @Param map query []string false "Source column and the pet field it fills, separated by a colon" collectionFormat(multi)
+// @Param dry_run query bool false "Only check the rows"
+// @Success 200 {object} importResult
+// @Success 201 {object} importResult
+// @Security ApiKeyAuth
+// @Router /pets/import [post]
+func (s *server) handlerPetsImport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error importing pets", http.StatusUnauthorized)
+			return
+		}
+		mapping, err := importMapping(r)
+		if err != nil {
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+
+		// Read the rows in the format of the body
+		body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+		mediaType, _ := header.ParseValueAndParams(r.Header, "Content-Type")
+		var rows []importRow
+		switch mediaType {
+		case "text/csv":
+			rows, err = readImportCSV(body, mapping)
+		case "application/json":
+			rows, err = readImportJSON(body, mapping)
+		default:
+			s.respond(w, r, nil, "Content-Type header is not text/csv or application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+		if err != nil {
+			s.respondError(w, r, invalidField("body", err.Error()), "error reading import")
+			return
+		}
+
+		pets, errs, err := s.validateImport(rows, ts)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error checking microchips in database")
+			s.respond(w, r, nil, "error importing pets", http.StatusInternalServerError)
+			return
+		}
+		if len(errs) > 0 {
+			s.respondProblem(w, r, importError(errs))
+			return
+		}
+		result := importResult{DryRun: r.URL.Query().Get("dry_run") == "true", Rows: len(rows)}
+		if result.DryRun {
+			s.respond(w, r, result, "", http.StatusOK)
+			return
+		}
+
+		ids, err := s.pets.CreateMany(pets, userID)
+		if err != nil {
+			s.respondError(w, r, err, "error importing pets")
+			return
+		}
+		for i, id := range ids {
+			p := pets[i]
+			p.ID, p.UserID, p.Version = uint(id), uint(userID), 1
+			s.audit(r, auditEntry{OwnerID: p.UserID, Action: auditCreate, EntityType: auditEntityPet, EntityID: p.ID}, nil, p)
+			result.PetIDs = append(result.PetIDs, p.ID)
+		}
+		s.respond(w, r, result, "", http.StatusCreated)
+	}
+}
+
+//optionalID renders a nullable ID for a CSV cell
+func optionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
+
+//petCSVRecord renders a pet as the cells of petExportColumns
+func petCSVRecord(p pet) []string {
+	birthday := ""
+	if !p.Birthday.IsZero() {
+		birthday = p.Birthday.Format("2006-01-02")
+	}
+	return []string{
+		strconv.FormatUint(uint64(p.ID), 10), p.Name, p.Type, p.Gender, p.Breed, birthday,
+		p.Microchip, p.Tattoo, p.LicenseTag, p.RegistrationNumber,
+		optionalID(p.SireID), optionalID(p.DamID), optionalID(p.LitterID),
+		p.CreatedAt.Format(time.RFC3339), p.UpdatedAt.Format(time.RFC3339), strconv.FormatInt(p.Version, 10),
+	}
+}
+
+// handlerPetsExport godoc
+// @Summary Export pets
+// @Description Download all pets as a CSV file, a JSON array or newline delimited JSON. The export is streamed as it is read from the database.
+// @Tags Pets
+// @Produce text/csv,json,application/x-ndjson
+// @Param format query string false "csv, json (the default) or ndjson"
+// @Success 200 {array} pet
+// @Security ApiKeyAuth
+// @Router /pets/export [get]
+func (s *server) handlerPetsExport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error exporting pets", http.StatusUnauthorized)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "json"
+		}
+		contentTypes := map[string]string{
+			"csv":    "text/csv; charset=utf-8",
+			"json":   "application/json; charset=utf-8",
+			"ndjson": "application/x-ndjson",
+		}
+		if contentTypes[format] == "" {
+			s.respondProblem(w, r, invalidField("format", "format must be csv, json or ndjson"))
+			return
+		}
+		w.Header().Set("Content-Type", contentTypes[format])
+		w.Header().Set("Content-Disposition", `attachment; filename="pets.`+format+`"`)
+
+		// Write each pet as it is read. Once the first one is out the
+		// status can't change, a failure can only cut the export short.
+		cw := csv.NewWriter(w)
+		enc := json.NewEncoder(w)
+		n := 0
+		switch format {
+		case "csv":
+			err = cw.Write(petExportColumns)
+		case "json":
+			_, err = io.WriteString(w, "[")
+		}
+		if err == nil {
+			err = s.pets.Each(userID, func(p pet) error {
+				n++
+				switch format {
+				case "csv":
+					return cw.Write(petCSVRecord(p))
+				case "json":
+					if n > 1 {
+						if _, err := io.WriteString(w, ","); err != nil {
+							return err
+						}
+					}
+				}
+				return enc.Encode(p)
+			})
+		}
+		if err == nil {
+			switch format {
+			case "csv":
+				cw.Flush()
+				err = cw.Error()
+			case "json":
+				_, err = io.WriteString(w, "]\n")
+			}
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Int("pets", n).Msg("error exporting pets")
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// calendarProductID is the PRODID written at the top of every feed
+const calendarProductID = "-//Petkeep//Petkeep Calendar//EN"
+
+type calendarTokenResponse struct {
+	Token string `json:"token" example:"4f9c1f0c8b7d4e2a9d1c3b5a7e9f0d2c4b6a8e0f1d3c5b7a9e1f3d5c7b9a1e3f"`
+	URL   string `json:"url" example:"http://localhost:8080/api/v1/calendar.ics?token=4f9c1f0c8b7d4e2a9d1c3b5a7e9f0d2c4b6a8e0f1d3c5b7a9e1f3d5c7b9a1e3f"`
+}
+
+// calendarEvent is a single VEVENT in a user's feed. UID must be stable
+// across requests so calendar clients update events instead of duplicating them.
+type calendarEvent struct {
+	UID          string
+	Summary      string
+	Description  string
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+	Location     string
+	RRule        string
+	Created      time.Time
+	LastModified time.Time
+}
+
+//generateCalendarToken creates a random feed token and the hash stored for it
+func generateCalendarToken() (tkn string, hash string, err error) {
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", "", err
+	}
+	tkn = hex.EncodeToString(b)
+	return tkn, hashCalendarToken(tkn), nil
+}
+
+//hashCalendarToken hashes a feed token so the raw value is never stored
+func hashCalendarToken(tkn string) string {
+	sum := sha256.Sum256([]byte(tkn))
+	return hex.EncodeToString(sum[:])
+}
+
+//dbCalendarTokenSet creates or replaces the feed token of a user
+func (s *server) dbCalendarTokenSet(userID int64, hash string) error {
+	_, err := s.db.Exec(`INSERT INTO calendar_tokens(user_id, token_hash, created_at) VALUES($1,$2,$3)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = excluded.token_hash, created_at = excluded.created_at`,
+		userID, hash, time.Now())
+	return err
+}
+
+//dbCalendarTokenDelete revokes the feed token of a user
+func (s *server) dbCalendarTokenDelete(userID int64) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM calendar_tokens WHERE user_id = $1", userID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbCalendarTokenUser returns the ID of the user owning a feed token hash
+func (s *server) dbCalendarTokenUser(hash string) (int64, error) {
+	var id int64
+	err := s.db.QueryRow("SELECT user_id FROM calendar_tokens WHERE token_hash = $1", hash).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+//dbCalendarEvents collects every calendar event belonging to a user: pet
+//birthdays, appointments, medication doses and due dates of records
+func (s *server) dbCalendarEvents(userID int64) ([]calendarEvent, error) {
+	rows, err := s.db.Query("SELECT id, name, type, birthday, created_at, updated_at FROM pets WHERE user_id = $1 AND birthday IS NOT NULL AND deleted_at IS NULL", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []calendarEvent
+	for rows.Next() {
+		var p pet
+		var petType sql.NullString
+		err := rows.Scan(&p.ID, &p.Name, &petType, &p.Birthday, &p.CreatedAt, &p.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		// Pets without a known birthday have the zero time
+		if p.Birthday.IsZero() {
+			continue
+		}
+		p.Type = petType.String
+		events = append(events, birthdayEvent(p))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	appointments, err := s.dbAppointmentEvents(userID)
+	if err != nil {
+		return nil, err
+	}
+	records, err := s.dbRecordEvents(userID)
+	if err != nil {
+		return nil, err
+	}
+	events = append(events, appointments...)
+	return append(events, records...), nil
+}
+
+//birthdayEvent builds the yearly recurring birthday event of a pet
+func birthdayEvent(p pet) calendarEvent {
+	rrule := "FREQ=YEARLY"
+	if p.Birthday.Month() == time.February && p.Birthday.Day() == 29 {
+		// Leap day birthdays fall on the last day of February in common years
+		rrule = "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=-1"
+	}
+	desc := fmt.Sprintf("%s was born on %s.", p.Name, p.Birthday.Format("January 2, 2006"))
+	if p.Type != "" {
+		desc = fmt.Sprintf("%s the %s was born on %s.", p.Name, p.Type, p.Birthday.Format("January 2, 2006"))
+	}
+	return calendarEvent{
+		UID:          fmt.Sprintf("pet-%d-birthday@petkeep", p.ID),
+		Summary:      fmt.Sprintf("%s's birthday", p.Name),
+		Description:  desc,
+		Start:        p.Birthday,
+		AllDay:       true,
+		RRule:        rrule,
+		Created:      p.CreatedAt,
+		LastModified: p.UpdatedAt,
+	}
+}
+
+//writeCalendar encodes events as an RFC 5545 VCALENDAR
+func writeCalendar(w io.Writer, name string, events []calendarEvent, now time.Time) error {
+	cw := &calendarWriter{w: w}
+	cw.line("BEGIN:VCALENDAR")
+	cw.line("VERSION:2.0")
+	cw.line("PRODID:" + calendarProductID)
+	cw.line("CALSCALE:GREGORIAN")
+	cw.line("METHOD:PUBLISH")
+	cw.line("X-WR-CALNAME:" + escapeCalendarText(name))
+	for _, e := range events {
+		cw.line("BEGIN:VEVENT")
+		cw.line("UID:" + e.UID)
+		cw.line("DTSTAMP:" + calendarDateTime(now))
+		if e.AllDay {
+			cw.line("DTSTART;VALUE=DATE:" + e.Start.Format("20060102"))
+		} else {
+			cw.line("DTSTART:" + calendarDateTime(e.Start))
+			if !e.End.IsZero() {
+				cw.line("DTEND:" + calendarDateTime(e.End))
+			}
+		}
+		if e.RRule != "" {
+			cw.line("RRULE:" + e.RRule)
+		}
+		cw.line("SUMMARY:" + escapeCalendarText(e.Summary))
+		if e.Description != "" {
+			cw.line("DESCRIPTION:" + escapeCalendarText(e.Description))
+		}
+		if e.Location != "" {
+			cw.line("LOCATION:" + escapeCalendarText(e.Location))
+		}
+		if !e.Created.IsZero() {
+			cw.line("CREATED:" + calendarDateTime(e.Created))
+		}
+		if !e.LastModified.IsZero() {
+			cw.line("LAST-MODIFIED:" + calendarDateTime(e.LastModified))
+		}
+		// All day events like birthdays don't block time, appointments do
+		if e.AllDay {
+			cw.line("TRANSP:TRANSPARENT")
+		} else {
+			cw.line("TRANSP:OPAQUE")
+		}
+		cw.line("END:VEVENT")
+	}
+	cw.line("END:VCALENDAR")
+	return cw.err
+}
+
+//calendarDateTime formats a time as an RFC 5545 UTC DATE-TIME
+func calendarDateTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+//escapeCalendarText escapes a TEXT property value per RFC 5545 3.3.11
+func escapeCalendarText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// calendarWriter writes content lines terminated with CRLF and folded at
+// 75 octets, remembering the first write error.
+type calendarWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (cw *calendarWriter) line(l string) {
+	if cw.err != nil {
+		return
+	}
+	var b strings.Builder
+	limit := 75
+	for len(l) > limit {
+		// Never split a multi-byte UTF-8 sequence across lines
+		cut := limit
+		for cut > 0 && l[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(l[:cut])
+		b.WriteString("\r\n ")
+		l = l[cut:]
+		// Continuation lines start with a space which counts toward the limit
+		limit = 74
+	}
+	b.WriteString(l)
+	b.WriteString("\r\n")
+	_, cw.err = io.WriteString(cw.w, b.String())
+}
+
+// handlerCalendarFeed godoc
+// @Summary Get the calendar feed
+// @Description Get an iCalendar feed of a user's pet events, authenticated by a calendar feed token
+// @Tags Calendar
+// @Produce text/calendar
+// @Param token query string true "Calendar feed token"
+// @Success 200 {string} string
+// @Router /calendar.ics [get]
+func (s *server) handlerCalendarFeed() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Calendar apps can't send bearer headers, so the feed token
+		// is the only credential accepted here
+		tkn := r.URL.Query().Get("token")
+		if tkn == "" {
+			s.respond(w, r, nil, "must provide a calendar token", http.StatusUnauthorized)
+			return
+		}
+		userID, err := s.dbCalendarTokenUser(hashCalendarToken(tkn))
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving calendar token from database")
+			s.respond(w, r, nil, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		// Collect the user's events
+		events, err := s.dbCalendarEvents(userID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving calendar events from database")
+			s.respond(w, r, nil, "error retrieving calendar", http.StatusInternalServerError)
+			return
+		}
+
+		// Write the feed
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Content-Disposition", `inline; filename="petkeep.ics"`)
+		w.WriteHeader(http.StatusOK)
+		err = writeCalendar(w, "Petkeep", events, time.Now())
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error writing calendar")
+		}
+	}
+}
+
+// handlerCalendarTokenCreate godoc
+// @Summary Create a calendar feed token
+// @Description Create a calendar feed token, replacing and revoking any existing one
+// @Tags Calendar
+// @Produce json
+// @Success 201 {object} calendarTokenResponse
+// @Security ApiKeyAuth
+// @Router /users/calendar_token [post]
+func (s *server) handlerCalendarTokenCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Get user ID from the authenticated context
+		id, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Generate the token and store its hash
+		tkn, hash, err := generateCalendarToken()
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error generating calendar token")
+			s.respond(w, r, nil, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		err = s.dbCalendarTokenSet(id, hash)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error storing calendar token in database")
+			s.respond(w, r, nil, "error creating calendar token", http.StatusInternalServerError)
+			return
+		}
+
+		// Respond with the token and the subscription URL
+		resp := calendarTokenResponse{
+			Token: tkn,
+			URL:   s.publicURL("/api/" + version + "/calendar.ics?token=" + tkn),
+		}
+		s.respond(w, r, resp, "", http.StatusCreated)
+	}
+}
+
+// handlerCalendarTokenRevoke godoc
+// @Summary Revoke the calendar feed token
+// @Description Revoke the calendar feed token, disabling the feed URL
+// @Tags Calendar
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /users/calendar_token [delete]
+func (s *server) handlerCalendarTokenRevoke() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Get user ID from the authenticated context
+		id, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Delete the token
+		rows, err := s.dbCalendarTokenDelete(id)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error deleting calendar token from database")
+			s.respond(w, r, nil, "error revoking calendar token", http.StatusInternalServerError)
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "no calendar token to revoke", http.StatusNotFound)
+			return
+		}
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMedicationEvent(t *testing.T) {
+	day := time.Date(2019, 11, 9, 0, 0, 0, 0, time.UTC)
+	two, one, ten := 2, 1, 10
+	for _, c := range []struct {
+		name    string
+		m       medicalRecord
+		rrule   string
+		summary string
+	}{
+		{"a single dose", medicalRecord{}, "", "Fido: Amoxicillin"},
+		{"twice a day for ten days", medicalRecord{DoseFrequency: "daily", DosesPerDay: &two, CourseDays: &ten}, "FREQ=DAILY;UNTIL=20191118", "Fido: Amoxicillin, 2 doses"},
+		{"once a day for a day", medicalRecord{DoseFrequency: "daily", DosesPerDay: &one, CourseDays: &one}, "FREQ=DAILY;UNTIL=20191109", "Fido: Amoxicillin"},
+		{"monthly with no end", medicalRecord{DoseFrequency: "monthly"}, "FREQ=MONTHLY", "Fido: Amoxicillin"},
+	} {
+		c.m.ID, c.m.Kind, c.m.Title, c.m.OccurredOn = 4, "medication", "Amoxicillin", day
+		e := medicationEvent(c.m, "Fido")
+		if e.RRule != c.rrule || e.Summary != c.summary || !e.AllDay || !e.Start.Equal(day) {
+			t.Errorf("%s: got %+v", c.name, e)
+		}
+	}
+
+	// The schedule makes it into the feed as the event's RRULE
+	m := medicalRecord{ID: 4, Kind: "medication", Title: "Amoxicillin", OccurredOn: day, DoseFrequency: "daily", CourseDays: &ten}
+	var b bytes.Buffer
+	if err := writeCalendar(&b, "Pets", []calendarEvent{medicationEvent(m, "Fido")}, day); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"DTSTART;VALUE=DATE:20191109\r\n", "RRULE:FREQ=DAILY;UNTIL=20191118\r\n"} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("feed %q is missing %q", b.String(), line)
+		}
+	}
+}
@@ -0,0 +1,815 @@
+package api
+
+import (
+	"database/sql"
+	_ "embed" // for the catalog seed
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// catalogSeedJSON is the built-in list of species, breeds and genders.
+// Admins can add to it at runtime, those additions are kept in the database.
+//
+//go:embed catalog_seed.json
+var catalogSeedJSON []byte
+
+// maxMixedBreeds is the most breeds a mixed breed may name
+const maxMixedBreeds = 3
+
+// mixedBreed is stored for a mix of unknown breeds
+const mixedBreed = "Mixed Breed"
+
+// mixedBreedKeys are the ways people write down a mix of unknown breeds
+var mixedBreedKeys = map[string]bool{
+	"mix":         true,
+	"mixed":       true,
+	"mixed breed": true,
+	"mutt":        true,
+	"mongrel":     true,
+	"crossbreed":  true,
+	"cross breed": true,
+	"moggy":       true,
+}
+
+// mixedBreedSuffixes mark a breed as a mix of the breeds before them
+var mixedBreedSuffixes = []string{" mixed breed", " mix", " mixed", " crossbreed", " cross"}
+
+// breedSeparator splits a mixed breed such as "Lab/Terrier" or "Poodle x Beagle"
+var breedSeparator = regexp.MustCompile(`(?i)\s*(?:/|&|\+|,|\bx\b|\band\b)\s*`)
+
+// nonAlphanumeric is collapsed when comparing names and aliases
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+type catalogGender struct {
+	Name    string   `json:"name" example:"Female"`
+	Aliases []string `json:"aliases" example:"f,girl"`
+}
+
+type catalogBreed struct {
+	ID      uint     `json:"breed_id,omitempty" example:"1"`
+	Name    string   `json:"name" example:"Labrador Retriever"`
+	Aliases []string `json:"aliases" example:"lab,labrador"`
+	Custom  bool     `json:"custom" example:"false"`
+}
+
+type catalogSpecies struct {
+	Slug    string         `json:"slug" example:"dog"`
+	Name    string         `json:"name" example:"Dog"`
+	Aliases []string       `json:"aliases" example:"canine,puppy"`
+	Custom  bool           `json:"custom" example:"false"`
+	Breeds  []catalogBreed `json:"-"`
+}
+
+type catalogEntryRequest struct {
+	Name    string   `json:"name" example:"Axolotl" validate:"required,max=64"`
+	Aliases []string `json:"aliases" example:"mexican walking fish"`
+}
+
+//checkFields checks that the name and aliases of a catalog entry have
+//something to look them up by, and the number and length of the aliases
+func (req catalogEntryRequest) checkFields() []fieldError {
+	errs := []fieldError{}
+	if req.Name != "" && catalogKey(req.Name) == "" {
+		errs = append(errs, fieldError{Field: "name", Code: codeInvalidField, Message: "name must have a letter or a digit"})
+	}
+	if len(req.Aliases) > 20 {
+		errs = append(errs, fieldError{Field: "aliases", Code: codeTooLong, Message: "at most 20 aliases may be given"})
+	}
+	for _, a := range req.Aliases {
+		if catalogKey(a) == "" || utf8.RuneCountInString(a) > 64 {
+			errs = append(errs, fieldError{Field: "aliases", Code: codeInvalidField, Message: "aliases must be between 1 and 64 characters"})
+			break
+		}
+	}
+	return errs
+}
+
+type catalogSeed struct {
+	Genders []catalogGender  `json:"genders"`
+	Species []catalogSpecies `json:"species"`
+}
+
+// catalogSeedBreeds lets the seed file nest breeds under their species
+type catalogSeedBreeds struct {
+	catalogSpecies
+	Breeds []catalogBreed `json:"breeds"`
+}
+
+// catalogData is an immutable snapshot of the catalog with lookup indexes
+// by catalogKey of every name and alias
+type catalogData struct {
+	species      []catalogSpecies
+	speciesByKey map[string]int
+	breedsByKey  map[string]map[string]int
+	genders      []catalogGender
+	genderByKey  map[string]string
+}
+
+// catalog is the species and breed catalog pets are validated against. It
+// is read on every pet write, so it is kept in memory and rebuilt after
+// admins change it.
+type catalog struct {
+	mu   sync.RWMutex
+	data *catalogData
+}
+
+//catalogKey normalizes a name or alias for comparison
+func catalogKey(s string) string {
+	return strings.Trim(nonAlphanumeric.ReplaceAllString(strings.ToLower(s), " "), " ")
+}
+
+//parseCatalogSeed decodes the embedded seed file
+func parseCatalogSeed() (catalogSeed, error) {
+	var raw struct {
+		Genders []catalogGender     `json:"genders"`
+		Species []catalogSeedBreeds `json:"species"`
+	}
+	err := json.Unmarshal(catalogSeedJSON, &raw)
+	if err != nil {
+		return catalogSeed{}, err
+	}
+	seed := catalogSeed{Genders: raw.Genders}
+	for _, sp := range raw.Species {
+		sp.catalogSpecies.Breeds = sp.Breeds
+		seed.Species = append(seed.Species, sp.catalogSpecies)
+	}
+	return seed, nil
+}
+
+//buildCatalog indexes the seed plus admin additions. An addition whose
+//name or alias clashes with an existing entry is skipped, the seed wins.
+func buildCatalog(seed catalogSeed, custom []catalogSpecies, customBreeds map[string][]catalogBreed) *catalogData {
+	d := &catalogData{
+		speciesByKey: map[string]int{},
+		breedsByKey:  map[string]map[string]int{},
+		genders:      seed.Genders,
+		genderByKey:  map[string]string{},
+	}
+	for _, g := range seed.Genders {
+		d.genderByKey[catalogKey(g.Name)] = g.Name
+		for _, a := range g.Aliases {
+			d.genderByKey[catalogKey(a)] = g.Name
+		}
+	}
+
+	addSpecies := func(sp catalogSpecies) {
+		keys := append([]string{sp.Slug, sp.Name}, sp.Aliases...)
+		for _, k := range keys {
+			if _, ok := d.speciesByKey[catalogKey(k)]; ok {
+				return
+			}
+		}
+		i := len(d.species)
+		if sp.Aliases == nil {
+			sp.Aliases = []string{}
+		}
+		sp.Breeds = append([]catalogBreed(nil), sp.Breeds...)
+		d.species = append(d.species, sp)
+		for _, k := range keys {
+			d.speciesByKey[catalogKey(k)] = i
+		}
+		d.breedsByKey[sp.Slug] = map[string]int{}
+	}
+	addBreed := func(slug string, b catalogBreed) {
+		i, ok := d.speciesByKey[catalogKey(slug)]
+		if !ok {
+			return
+		}
+		sp := &d.species[i]
+		idx := d.breedsByKey[sp.Slug]
+		if b.Aliases == nil {
+			b.Aliases = []string{}
+		}
+		keys := append([]string{b.Name}, b.Aliases...)
+		for _, k := range keys {
+			if _, ok := idx[catalogKey(k)]; ok {
+				return
+			}
+		}
+		for _, k := range keys {
+			idx[catalogKey(k)] = len(sp.Breeds)
+		}
+		sp.Breeds = append(sp.Breeds, b)
+	}
+
+	for _, sp := range seed.Species {
+		breeds := sp.Breeds
+		sp.Breeds = nil
+		addSpecies(sp)
+		for _, b := range breeds {
+			addBreed(sp.Slug, b)
+		}
+	}
+	for _, sp := range custom {
+		addSpecies(sp)
+	}
+	for slug, breeds := range customBreeds {
+		for _, b := range breeds {
+			addBreed(slug, b)
+		}
+	}
+
+	sort.SliceStable(d.species, func(i, j int) bool { return d.species[i].Name < d.species[j].Name })
+	for i, sp := range d.species {
+		d.speciesByKey[catalogKey(sp.Slug)] = i
+		d.speciesByKey[catalogKey(sp.Name)] = i
+		for _, a := range sp.Aliases {
+			d.speciesByKey[catalogKey(a)] = i
+		}
+	}
+	return d
+}
+
+//loadCatalog reads the admin additions from the database and builds the
+//full catalog
+func loadCatalog(db *sql.DB) (*catalogData, error) {
+	seed, err := parseCatalogSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT slug, name, aliases FROM catalog_species ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	custom := []catalogSpecies{}
+	for rows.Next() {
+		sp := catalogSpecies{Custom: true}
+		err := rows.Scan(&sp.Slug, &sp.Name, pq.Array(&sp.Aliases))
+		if err != nil {
+			return nil, err
+		}
+		custom = append(custom, sp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query("SELECT id, species, name, aliases FROM catalog_breeds ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	customBreeds := map[string][]catalogBreed{}
+	for rows.Next() {
+		var slug string
+		b := catalogBreed{Custom: true}
+		err := rows.Scan(&b.ID, &slug, &b.Name, pq.Array(&b.Aliases))
+		if err != nil {
+			return nil, err
+		}
+		customBreeds[slug] = append(customBreeds[slug], b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return buildCatalog(seed, custom, customBreeds), nil
+}
+
+//newCatalog returns a catalog holding only the seed, until it is reloaded
+//from the database
+func newCatalog() *catalog {
+	seed, err := parseCatalogSeed()
+	if err != nil {
+		panic("invalid catalog seed: " + err.Error())
+	}
+	return &catalog{data: buildCatalog(seed, nil, nil)}
+}
+
+//snapshot returns the current catalog data
+func (c *catalog) snapshot() *catalogData {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.data
+}
+
+//reloadCatalog rebuilds the catalog from the seed and the database
+func (s *server) reloadCatalog() error {
+	d, err := loadCatalog(s.db)
+	if err != nil {
+		return err
+	}
+	s.catalog.mu.Lock()
+	s.catalog.data = d
+	s.catalog.mu.Unlock()
+	return nil
+}
+
+//lookupSpecies finds a species by slug, name or alias
+func (d *catalogData) lookupSpecies(name string) (catalogSpecies, bool) {
+	i, ok := d.speciesByKey[catalogKey(name)]
+	if !ok {
+		return catalogSpecies{}, false
+	}
+	return d.species[i], true
+}
+
+//lookupBreed finds a breed of a species by name or alias
+func (d *catalogData) lookupBreed(sp catalogSpecies, name string) (catalogBreed, bool) {
+	i, ok := d.breedsByKey[sp.Slug][catalogKey(name)]
+	if !ok {
+		return catalogBreed{}, false
+	}
+	return sp.Breeds[i], true
+}
+
+//normalizeBreed resolves a breed, or a mix of up to three breeds such as
+//"Lab/Terrier Mix", to catalog names. Species without a breed list accept
+//any breed.
+func (d *catalogData) normalizeBreed(sp catalogSpecies, breed string) (string, error) {
+	breed = strings.TrimSpace(breed)
+	if breed == "" {
+		return "", nil
+	}
+	if len(sp.Breeds) == 0 {
+		if len(breed) > 64 {
+			return "", errors.New("breed must not be longer than 64 characters")
+		}
+		return breed, nil
+	}
+	if mixedBreedKeys[catalogKey(breed)] {
+		return mixedBreed, nil
+	}
+
+	// A single known breed or alias wins over reading it as a mix
+	if b, ok := d.lookupBreed(sp, breed); ok {
+		return b.Name, nil
+	}
+
+	mix := false
+	lower := strings.ToLower(breed)
+	for _, suffix := range mixedBreedSuffixes {
+		if strings.HasSuffix(lower, suffix) {
+			breed = strings.TrimSpace(breed[:len(breed)-len(suffix)])
+			mix = true
+			break
+		}
+	}
+	names := []string{}
+	seen := map[string]bool{}
+	for _, part := range breedSeparator.Split(breed, -1) {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		b, ok := d.lookupBreed(sp, part)
+		if !ok {
+			return "", fmt.Errorf("unknown %s breed %q", strings.ToLower(sp.Name), strings.TrimSpace(part))
+		}
+		if !seen[b.Name] {
+			seen[b.Name] = true
+			names = append(names, b.Name)
+		}
+	}
+	switch {
+	case len(names) == 0:
+		return mixedBreed, nil
+	case len(names) > maxMixedBreeds:
+		return "", fmt.Errorf("a mixed breed may name at most %d breeds", maxMixedBreeds)
+	case len(names) > 1:
+		mix = true
+	}
+	if mix {
+		return strings.Join(names, "/") + " Mix", nil
+	}
+	return names[0], nil
+}
+
+//normalizePet resolves the type, breed and gender of a pet to catalog names.
+//The type may be left out, but then so must the breed.
+func (d *catalogData) normalizePet(p *pet) error {
+	if strings.TrimSpace(p.Type) == "" {
+		if strings.TrimSpace(p.Breed) != "" {
+			return invalidField("breed", "a breed needs a pet type")
+		}
+		p.Type, p.Breed = "", ""
+		return d.normalizeGender(p)
+	}
+	sp, ok := d.lookupSpecies(p.Type)
+	if !ok {
+		return invalidField("type", fmt.Sprintf("unknown pet type %q", strings.TrimSpace(p.Type)))
+	}
+	breed, err := d.normalizeBreed(sp, p.Breed)
+	if err != nil {
+		return invalidField("breed", err.Error())
+	}
+	p.Type = sp.Name
+	p.Breed = breed
+	return d.normalizeGender(p)
+}
+
+//normalizeGender rewrites the gender of a pet to its catalog name
+func (d *catalogData) normalizeGender(p *pet) error {
+	if strings.TrimSpace(p.Gender) == "" {
+		p.Gender = ""
+		return nil
+	}
+	gender, ok := d.genderByKey[catalogKey(p.Gender)]
+	if !ok {
+		return invalidField("gender", fmt.Sprintf("unknown gender %q", strings.TrimSpace(p.Gender)))
+	}
+	p.Gender = gender
+	return nil
+}
+
+//normalizePetCatalog rewrites the type, breed and gender of existing pets
+//to catalog names. Values that can't be resolved are left untouched so no
+//data is lost, they have to be fixed the next time the pet is updated.
+func normalizePetCatalog(db *sql.DB) error {
+	d, err := loadCatalog(db)
+	if err != nil {
+		return err
+	}
+	rows, err := db.Query("SELECT id, type, breed, gender FROM pets")
+	if err != nil {
+		return err
+	}
+	type petNames struct {
+		id                  int64
+		typ, breed, gender  string
+		ntyp, nbreed, ngndr string
+	}
+	changed := []petNames{}
+	for rows.Next() {
+		var p petNames
+		var typ, breed, gender sql.NullString
+		err := rows.Scan(&p.id, &typ, &breed, &gender)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		p.typ, p.breed, p.gender = typ.String, breed.String, gender.String
+		p.ntyp, p.nbreed, p.ngndr = p.typ, p.breed, p.gender
+		if sp, ok := d.lookupSpecies(p.typ); ok {
+			p.ntyp = sp.Name
+			if b, err := d.normalizeBreed(sp, p.breed); err == nil {
+				p.nbreed = b
+			}
+		}
+		if g, ok := d.genderByKey[catalogKey(p.gender)]; ok {
+			p.ngndr = g
+		}
+		if p.ntyp != p.typ || p.nbreed != p.breed || p.ngndr != p.gender {
+			changed = append(changed, p)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, p := range changed {
+		_, err := db.Exec("UPDATE pets SET type = $1, breed = $2, gender = $3, version = version + 1 WHERE id = $4",
+			p.ntyp, nullString(p.nbreed), nullString(p.ngndr), p.id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//catalogMatch ranks how well a query matches a name and its aliases for
+//autocompletion, 0 is no match
+func catalogMatch(q, name string, aliases []string) int {
+	if q == "" {
+		return 1
+	}
+	if strings.HasPrefix(catalogKey(name), q) {
+		return 4
+	}
+	for _, a := range aliases {
+		if strings.HasPrefix(catalogKey(a), q) {
+			return 3
+		}
+	}
+	for _, w := range strings.Fields(catalogKey(name)) {
+		if strings.HasPrefix(w, q) {
+			return 2
+		}
+	}
+	return 0
+}
+
+//autocompleteParams reads the q and limit query params
+func autocompleteParams(r *http.Request) (string, int, error) {
+	limit := 10
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 100 {
+			return "", 0, invalidField("limit", "limit must be between 1 and 100")
+		}
+		limit = n
+	}
+	return catalogKey(r.URL.Query().Get("q")), limit, nil
+}
+
+//validateCatalogEntry normalizes and checks a species or breed an admin adds
+func validateCatalogEntry(req *catalogEntryRequest) error {
+	req.Name = strings.Join(strings.Fields(req.Name), " ")
+	aliases := []string{}
+	for _, a := range req.Aliases {
+		aliases = append(aliases, strings.Join(strings.Fields(a), " "))
+	}
+	req.Aliases = aliases
+	return validate(req)
+}
+
+// handlerCatalogSpecies godoc
+// @Summary List species
+// @Description List the species pets may be, best matches first when searching by name or alias
+// @Tags Catalog
+// @Produce json
+// @Param q query string false "Name or alias prefix"
+// @Param limit query int false "Maximum number of results, 1 to 100, defaults to 10 when searching"
+// @Success 200 {array} catalogSpecies
+// @Security ApiKeyAuth
+// @Router /catalog/species [get]
+func (s *server) handlerCatalogSpecies() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, limit, err := autocompleteParams(r)
+		if err != nil {
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+		d := s.catalog.snapshot()
+		res := []catalogSpecies{}
+		scores := map[string]int{}
+		for _, sp := range d.species {
+			if score := catalogMatch(q, sp.Name, sp.Aliases); score > 0 {
+				scores[sp.Slug] = score
+				res = append(res, sp)
+			}
+		}
+		sort.SliceStable(res, func(i, j int) bool { return scores[res[i].Slug] > scores[res[j].Slug] })
+		if q != "" && len(res) > limit {
+			res = res[:limit]
+		}
+		s.respond(w, r, res, "", http.StatusOK)
+	}
+}
+
+// handlerCatalogBreeds godoc
+// @Summary Autocomplete breeds
+// @Description List the breeds of a species, best matches first when searching by name or alias. Mixes are written as breeds separated by a slash, e.g. "Labrador Retriever/Terrier Mix", or "Mixed Breed" when unknown.
+// @Tags Catalog
+// @Produce json
+// @Param Species path string true "Species slug, name or alias"
+// @Param q query string false "Name or alias prefix"
+// @Param limit query int false "Maximum number of results, 1 to 100, defaults to 10"
+// @Success 200 {array} catalogBreed
+// @Security ApiKeyAuth
+// @Router /catalog/species/{Species}/breeds [get]
+func (s *server) handlerCatalogBreeds() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, limit, err := autocompleteParams(r)
+		if err != nil {
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+		sp, ok := s.catalog.snapshot().lookupSpecies(mux.Vars(r)["species"])
+		if !ok {
+			s.respond(w, r, nil, "species not found", http.StatusNotFound)
+			return
+		}
+		res := []catalogBreed{}
+		scores := map[string]int{}
+		for _, b := range sp.Breeds {
+			if score := catalogMatch(q, b.Name, b.Aliases); score > 0 {
+				scores[b.Name] = score
+				res = append(res, b)
+			}
+		}
+		sort.SliceStable(res, func(i, j int) bool {
+			if scores[res[i].Name] != scores[res[j].Name] {
+				return scores[res[i].Name] > scores[res[j].Name]
+			}
+			return res[i].Name < res[j].Name
+		})
+		if len(res) > limit {
+			res = res[:limit]
+		}
+		s.respond(w, r, res, "", http.StatusOK)
+	}
+}
+
+// handlerCatalogGenders godoc
+// @Summary List genders
+// @Description List the genders a pet may have, with the aliases accepted for each
+// @Tags Catalog
+// @Produce json
+// @Success 200 {array} catalogGender
+// @Security ApiKeyAuth
+// @Router /catalog/genders [get]
+func (s *server) handlerCatalogGenders() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.respond(w, r, s.catalog.snapshot().genders, "", http.StatusOK)
+	}
+}
+
+// handlerCatalogSpeciesCreate godoc
+// @Summary Add a species
+// @Description Add a species to the catalog. Only admins may do this.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param species body catalogEntryRequest true "Species"
+// @Success 201 {object} catalogSpecies
+// @Security ApiKeyAuth
+// @Router /admin/catalog/species [post]
+func (s *server) handlerCatalogSpeciesCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req catalogEntryRequest
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateCatalogEntry(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking catalog entry")
+			return
+		}
+
+		// Names and aliases must not clash with any existing species
+		d := s.catalog.snapshot()
+		sp := catalogSpecies{
+			Slug:    strings.ReplaceAll(catalogKey(req.Name), " ", "-"),
+			Name:    req.Name,
+			Aliases: req.Aliases,
+			Custom:  true,
+		}
+		for _, k := range append([]string{sp.Slug, sp.Name}, sp.Aliases...) {
+			if _, ok := d.lookupSpecies(k); ok {
+				s.respond(w, r, nil, fmt.Sprintf("%q is already a species or alias", k), http.StatusConflict)
+				return
+			}
+		}
+
+		_, err = s.db.Exec("INSERT INTO catalog_species(slug, name, aliases, created_at) VALUES($1,$2,$3,$4)",
+			sp.Slug, sp.Name, pq.Array(sp.Aliases), time.Now())
+		if isUniqueViolation(err) {
+			s.respond(w, r, nil, "species already exists", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error creating species in database")
+			s.respond(w, r, nil, "error creating species", http.StatusInternalServerError)
+			return
+		}
+		err = s.reloadCatalog()
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error reloading catalog from database")
+		}
+		s.respond(w, r, sp, "", http.StatusCreated)
+	}
+}
+
+// handlerCatalogSpeciesDelete godoc
+// @Summary Remove a species
+// @Description Remove a species an admin added, along with its breeds. Built-in species can't be removed. Pets keep their type but must pick a new one when next updated.
+// @Tags Admin
+// @Param Species path string true "Species slug"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /admin/catalog/species/{Species} [delete]
+func (s *server) handlerCatalogSpeciesDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sp, ok := s.catalog.snapshot().lookupSpecies(mux.Vars(r)["species"])
+		if !ok {
+			s.respond(w, r, nil, "species not found", http.StatusNotFound)
+			return
+		}
+		if !sp.Custom {
+			s.respond(w, r, nil, "built-in species can't be removed", http.StatusConflict)
+			return
+		}
+		for _, q := range []string{"DELETE FROM catalog_breeds WHERE species = $1", "DELETE FROM catalog_species WHERE slug = $1"} {
+			_, err := s.db.Exec(q, sp.Slug)
+			if err != nil {
+				s.logger.Error().Err(err).Msg("error deleting species from database")
+				s.respond(w, r, nil, "error deleting species", http.StatusInternalServerError)
+				return
+			}
+		}
+		err := s.reloadCatalog()
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error reloading catalog from database")
+		}
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
+
+// handlerCatalogBreedsCreate godoc
+// @Summary Add a breed
+// @Description Add a breed to a species in the catalog. Only admins may do this.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param Species path string true "Species slug, name or alias"
+// @Param breed body catalogEntryRequest true "Breed"
+// @Success 201 {object} catalogBreed
+// @Security ApiKeyAuth
+// @Router /admin/catalog/species/{Species}/breeds [post]
+func (s *server) handlerCatalogBreedsCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d := s.catalog.snapshot()
+		sp, ok := d.lookupSpecies(mux.Vars(r)["species"])
+		if !ok {
+			s.respond(w, r, nil, "species not found", http.StatusNotFound)
+			return
+		}
+
+		var req catalogEntryRequest
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateCatalogEntry(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking catalog entry")
+			return
+		}
+
+		// Names and aliases must not clash with the species' breeds or read as a mix
+		b := catalogBreed{Name: req.Name, Aliases: req.Aliases, Custom: true}
+		for _, k := range append([]string{b.Name}, b.Aliases...) {
+			if _, ok := d.lookupBreed(sp, k); ok {
+				s.respond(w, r, nil, fmt.Sprintf("%q is already a breed or alias", k), http.StatusConflict)
+				return
+			}
+			if mixedBreedKeys[catalogKey(k)] || len(breedSeparator.Split(k, -1)) > 1 {
+				s.respondProblem(w, r, invalidField("name", fmt.Sprintf("%q would be read as a mixed breed", k)))
+				return
+			}
+		}
+
+		err = s.db.QueryRow("INSERT INTO catalog_breeds(species, name, aliases, created_at) VALUES($1,$2,$3,$4) RETURNING id",
+			sp.Slug, b.Name, pq.Array(b.Aliases), time.Now()).Scan(&b.ID)
+		if isUniqueViolation(err) {
+			s.respond(w, r, nil, "breed already exists", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error creating breed in database")
+			s.respond(w, r, nil, "error creating breed", http.StatusInternalServerError)
+			return
+		}
+		err = s.reloadCatalog()
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error reloading catalog from database")
+		}
+		s.respond(w, r, b, "", http.StatusCreated)
+	}
+}
+
+// handlerCatalogBreedsDelete godoc
+// @Summary Remove a breed
+// @Description Remove a breed an admin added. Built-in breeds can't be removed.
+// @Tags Admin
+// @Param Species path string true "Species slug"
+// @Param BreedID path int true "Breed ID"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /admin/catalog/species/{Species}/breeds/{BreedID} [delete]
+func (s *server) handlerCatalogBreedsDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sp, ok := s.catalog.snapshot().lookupSpecies(mux.Vars(r)["species"])
+		if !ok {
+			s.respond(w, r, nil, "species not found", http.StatusNotFound)
+			return
+		}
+		breedID, err := strconv.ParseInt(mux.Vars(r)["breedID"], 10, 64)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid breed id", http.StatusBadRequest)
+			return
+		}
+		res, err := s.db.Exec("DELETE FROM catalog_breeds WHERE species = $1 AND id = $2", sp.Slug, breedID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error deleting breed from database")
+			s.respond(w, r, nil, "error deleting breed", http.StatusInternalServerError)
+			return
+		}
+		rows, err := res.RowsAffected()
+		if err != nil || rows == 0 {
+			s.respond(w, r, nil, "breed not found", http.StatusNotFound)
+			return
+		}
+		err = s.reloadCatalog()
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error reloading catalog from database")
+		}
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
@@ -0,0 +1,262 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/golang/gddo/httputil/header"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	mediaJSON    = "application/json"
+	mediaMsgpack = "application/msgpack"
+	mediaCBOR    = "application/cbor"
+	mediaCSV     = "text/csv"
+)
+
+// errNotEncodable is returned by a codec that can't encode a value, like
+// CSV for anything but a list
+var errNotEncodable = errors.New("value can't be encoded in this format")
+
+// codec encodes response bodies in a media type and, when Decode is set,
+// decodes request bodies from it. The binary formats carry the very
+// document the JSON codec would, so every format has the same fields.
+type codec struct {
+	MediaTypes  []string
+	ContentType string
+	Encode      func(w io.Writer, v interface{}) error
+	Decode      func(r io.Reader) (interface{}, error)
+	// CanEncode reports whether a value can be encoded, nil for any value
+	CanEncode func(v interface{}) bool
+}
+
+// codecs are the formats the API speaks, JSON first as the default. More
+// can be added here.
+var codecs = []codec{
+	{
+		MediaTypes:  []string{mediaJSON},
+		ContentType: "application/json; charset=utf-8",
+		Encode:      func(w io.Writer, v interface{}) error { return json.NewEncoder(w).Encode(v) },
+	},
+	{
+		MediaTypes:  []string{mediaMsgpack, "application/x-msgpack", "application/vnd.msgpack"},
+		ContentType: mediaMsgpack,
+		Encode: func(w io.Writer, v interface{}) error {
+			doc, err := jsonDocument(v)
+			if err != nil {
+				return err
+			}
+			enc := msgpack.NewEncoder(w)
+			enc.SetSortMapKeys(true)
+			return enc.Encode(doc)
+		},
+		Decode: func(r io.Reader) (interface{}, error) {
+			var doc interface{}
+			err := msgpack.NewDecoder(r).Decode(&doc)
+			return doc, err
+		},
+	},
+	{
+		MediaTypes:  []string{mediaCBOR},
+		ContentType: mediaCBOR,
+		Encode: func(w io.Writer, v interface{}) error {
+			doc, err := jsonDocument(v)
+			if err != nil {
+				return err
+			}
+			return cborEncMode.NewEncoder(w).Encode(doc)
+		},
+		Decode: func(r io.Reader) (interface{}, error) {
+			var doc interface{}
+			err := cborDecMode.NewDecoder(r).Decode(&doc)
+			return doc, err
+		},
+	},
+	{
+		MediaTypes:  []string{mediaCSV},
+		ContentType: "text/csv; charset=utf-8",
+		Encode:      encodeCSV,
+		CanEncode:   func(v interface{}) bool { return csvColumns(v) != nil },
+	},
+}
+
+var (
+	cborEncMode, _ = cbor.EncOptions{Sort: cbor.SortCanonical}.EncMode()
+	// Maps decode with string keys, like JSON objects
+	cborDecMode, _ = cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}{})}.DecMode()
+)
+
+//jsonDocument returns the document v encodes to in JSON, with numbers as
+//int64 or float64
+func jsonDocument(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var doc interface{}
+	err = dec.Decode(&doc)
+	return jsonNumbers(doc), err
+}
+
+//jsonNumbers replaces the json.Numbers of a document by int64 or float64
+func jsonNumbers(doc interface{}) interface{} {
+	switch d := doc.(type) {
+	case json.Number:
+		if n, err := d.Int64(); err == nil {
+			return n
+		}
+		f, _ := d.Float64()
+		return f
+	case map[string]interface{}:
+		for k, v := range d {
+			d[k] = jsonNumbers(v)
+		}
+	case []interface{}:
+		for i, v := range d {
+			d[i] = jsonNumbers(v)
+		}
+	}
+	return doc
+}
+
+//csvColumns returns the JSON names of the fields of the elements of a
+//list of structs, in order, or nil when v isn't one
+func csvColumns(v interface{}) []string {
+	t := reflect.TypeOf(v)
+	if t == nil || (t.Kind() != reflect.Slice && t.Kind() != reflect.Array) {
+		return nil
+	}
+	t = t.Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return jsonFieldNames(t)
+}
+
+//jsonFieldNames returns the names the fields of a struct have in JSON,
+//with those of embedded structs in their place
+func jsonFieldNames(t reflect.Type) []string {
+	names := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			names = append(names, jsonFieldNames(f.Type)...)
+			continue
+		}
+		if f.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+//encodeCSV writes a list of structs as CSV, a row per element under a
+//header naming the fields like JSON does. Nested values are written as
+//JSON.
+func encodeCSV(w io.Writer, v interface{}) error {
+	columns := csvColumns(v)
+	if columns == nil {
+		return errNotEncodable
+	}
+	doc, err := jsonDocument(v)
+	if err != nil {
+		return err
+	}
+	rows, _ := doc.([]interface{})
+
+	cw := csv.NewWriter(w)
+	err = cw.Write(columns)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		fields, _ := row.(map[string]interface{})
+		record := make([]string, len(columns))
+		for i, c := range columns {
+			switch f := fields[c].(type) {
+			case nil:
+			case string:
+				record[i] = f
+			case int64, float64, bool:
+				record[i] = fmt.Sprint(f)
+			default:
+				b, _ := json.Marshal(f)
+				record[i] = string(b)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+//negotiate picks the codec of a response from the Accept header of the
+//request, JSON when the client takes anything. It returns false when no
+//format the client accepts can encode v.
+func negotiate(r *http.Request, v interface{}) (codec, bool) {
+	specs := header.ParseAccept(r.Header, "Accept")
+	if len(specs) == 0 {
+		return codecs[0], true
+	}
+	best, bestQ := codec{}, 0.0
+	for _, c := range codecs {
+		if v != nil && c.CanEncode != nil && !c.CanEncode(v) {
+			continue
+		}
+		for _, spec := range specs {
+			if spec.Q > bestQ && acceptsMediaType(spec.Value, c.MediaTypes) {
+				best, bestQ = c, spec.Q
+			}
+		}
+	}
+	return best, bestQ > 0
+}
+
+//acceptsMediaType reports whether a media range of an Accept header, like
+//application/*, covers one of the media types
+func acceptsMediaType(mediaRange string, mediaTypes []string) bool {
+	for _, mt := range mediaTypes {
+		if mediaRange == "*/*" || mediaRange == mt ||
+			(strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mt, strings.TrimSuffix(mediaRange, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+//decoderFor returns the codec decoding a request body of a media type
+func decoderFor(mediaType string) (codec, bool) {
+	for _, c := range codecs {
+		if c.Decode == nil {
+			continue
+		}
+		for _, mt := range c.MediaTypes {
+			if mt == mediaType {
+				return c, true
+			}
+		}
+	}
+	return codec{}, false
+}
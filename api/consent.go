@@ -0,0 +1,849 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	accessPending   = "pending"
+	accessGranted   = "granted"
+	accessDenied    = "denied"
+	accessRevoked   = "revoked"
+	accessCancelled = "cancelled"
+)
+
+// accessRequested is the consent audit trail action of a new access request.
+// The other actions are the statuses the request moves to.
+const accessRequested = "requested"
+
+// accessStatuses are the statuses an access request can be filtered by
+var accessStatuses = map[string]bool{
+	accessPending:   true,
+	accessGranted:   true,
+	accessDenied:    true,
+	accessRevoked:   true,
+	accessCancelled: true,
+}
+
+// ownerAccessTransitions lists the statuses a pet owner may move an access
+// request to from each status. Organizations can only cancel their own
+// pending or granted requests.
+var ownerAccessTransitions = map[string][]string{
+	accessPending: {accessGranted, accessDenied},
+	accessGranted: {accessRevoked},
+}
+
+// errAccessExists is returned when an organization already has a pending
+// or granted request for a pet
+var errAccessExists = errors.New("organization already has a pending or granted request for this pet")
+
+// petAccess is an organization's request to access a pet, and the owner's
+// consent once granted
+type petAccess struct {
+	ID          uint       `json:"access_id" example:"1"`
+	OrgID       uint       `json:"organization_id" example:"1"`
+	OrgName     string     `json:"organization_name" example:"Riverside Animal Hospital"`
+	PetID       uint       `json:"pet_id" example:"1"`
+	PetName     string     `json:"pet_name" example:"Fido"`
+	OwnerID     uint       `json:"owner_id" example:"1"`
+	Status      string     `json:"status" example:"pending"`
+	Message     string     `json:"message" example:"Fido is booked in for surgery on Friday"`
+	RequestedBy uint       `json:"requested_by" example:"4"`
+	RequestedAt time.Time  `json:"requested_at" example:"2019-11-09T21:21:46+00:00"`
+	DecidedAt   *time.Time `json:"decided_at,omitempty" example:"2019-11-10T08:00:00+00:00"`
+	UpdatedAt   time.Time  `json:"updated_at" example:"2019-11-10T08:00:00+00:00"`
+}
+
+// petAccessRequest asks an owner, by the email they signed up with, for
+// access to one of their pets
+type petAccessRequest struct {
+	OwnerEmail string `json:"owner_email" example:"jane@example.com" validate:"required,email"`
+	PetID      uint   `json:"pet_id" example:"1" validate:"required"`
+	Message    string `json:"message" example:"Fido is booked in for surgery on Friday" validate:"max=1000"`
+}
+
+type petAccessStatusRequest struct {
+	Status string `json:"status" example:"granted"`
+}
+
+type petAccesses []petAccess
+
+// accessEvent is an entry of the consent audit trail
+type accessEvent struct {
+	ID         uint      `json:"event_id" example:"1"`
+	AccessID   uint      `json:"access_id" example:"1"`
+	OrgID      uint      `json:"organization_id" example:"1"`
+	PetID      uint      `json:"pet_id" example:"1"`
+	ActorID    uint      `json:"actor_id" example:"1"`
+	ActorEmail string    `json:"actor_email" example:"jane@example.com"`
+	Action     string    `json:"action" example:"granted"`
+	CreatedAt  time.Time `json:"created_at" example:"2019-11-10T08:00:00+00:00"`
+}
+
+type accessEvents []accessEvent
+
+//canTransitionAccess reports whether an owner may move an access request
+//from one status to another
+func canTransitionAccess(from, to string) bool {
+	for _, s := range ownerAccessTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+//accessIDFromRequest is a helper to extract the access request ID URL param
+func accessIDFromRequest(r *http.Request) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)["accessID"], 10, 64)
+}
+
+//accessStatusFromRequest reads the optional status query param
+func accessStatusFromRequest(r *http.Request) (string, error) {
+	status := strings.ToLower(r.URL.Query().Get("status"))
+	if status != "" && !accessStatuses[status] {
+		return "", invalidField("status", "status must be one of pending, granted, denied, revoked or cancelled")
+	}
+	return status, nil
+}
+
+//dbAccessQuery returns the access requests matching a condition, newest first
+func (s *server) dbAccessQuery(cond string, args ...interface{}) ([]petAccess, error) {
+	rows, err := s.db.Query(`SELECT a.id, a.org_id, o.name, a.pet_id, p.name, a.owner_id, a.status, a.message, a.requested_by, a.requested_at, a.decided_at, a.updated_at
+		FROM pet_access a JOIN organizations o ON o.id = a.org_id JOIN pets p ON p.id = a.pet_id
+		WHERE p.deleted_at IS NULL AND `+cond+` ORDER BY a.requested_at DESC, a.id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	as := []petAccess{}
+	for rows.Next() {
+		var a petAccess
+		var message sql.NullString
+		var decided sql.NullTime
+		err := rows.Scan(&a.ID, &a.OrgID, &a.OrgName, &a.PetID, &a.PetName, &a.OwnerID, &a.Status, &message, &a.RequestedBy, &a.RequestedAt, &decided, &a.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		a.Message = message.String
+		if decided.Valid {
+			a.DecidedAt = &decided.Time
+		}
+		as = append(as, a)
+	}
+	return as, rows.Err()
+}
+
+//dbAccessGetOne returns a single access request matching a condition on top
+//of its ID
+func (s *server) dbAccessGetOne(accessID int64, cond string, args ...interface{}) (petAccess, error) {
+	as, err := s.dbAccessQuery("a.id = $1 AND "+cond, append([]interface{}{accessID}, args...)...)
+	if err != nil {
+		return petAccess{}, err
+	}
+	if len(as) == 0 {
+		return petAccess{}, sql.ErrNoRows
+	}
+	return as[0], nil
+}
+
+//dbAccessPetOfOwner returns the ID of the owner of a pet, when the pet
+//belongs to the user who signed up with an email
+func (s *server) dbAccessPetOfOwner(email string, petID uint) (int64, error) {
+	var ownerID int64
+	err := s.db.QueryRow("SELECT p.user_id FROM pets p JOIN users u ON u.id = p.user_id WHERE p.id = $1 AND p.deleted_at IS NULL AND lower(u.email) = lower($2)",
+		petID, email).Scan(&ownerID)
+	return ownerID, err
+}
+
+//dbAccessCreate stores a new pending access request and its audit trail
+//entry. The transaction is serializable, so two requests racing for the
+//same pet can't both get in.
+func (s *server) dbAccessCreate(a petAccess) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM pet_access WHERE org_id = $1 AND pet_id = $2 AND status IN ($3, $4))",
+		a.OrgID, a.PetID, accessPending, accessGranted).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, errAccessExists
+	}
+	var id int64
+	err = tx.QueryRow(`INSERT INTO pet_access(org_id, pet_id, owner_id, status, message, requested_by, requested_at, updated_at)
+		VALUES($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id`,
+		a.OrgID, a.PetID, a.OwnerID, a.Status, nullString(a.Message), a.RequestedBy, a.RequestedAt, a.UpdatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	err = insertAccessEvent(tx, id, a.OrgID, a.PetID, a.RequestedBy, accessRequested, a.RequestedAt)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+//dbAccessSetStatus moves an access request to a new status if it still has
+//the status it was read with, and records who did it in the audit trail
+func (s *server) dbAccessSetStatus(a petAccess, from string, actorID int64) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE pet_access SET status = $1, decided_at = $2, updated_at = $3 WHERE id = $4 AND status = $5",
+		a.Status, a.DecidedAt, a.UpdatedAt, a.ID, from)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil || rows == 0 {
+		return 0, err
+	}
+	err = insertAccessEvent(tx, int64(a.ID), a.OrgID, a.PetID, uint(actorID), a.Status, a.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return rows, tx.Commit()
+}
+
+//insertAccessEvent adds an entry to the consent audit trail
+func insertAccessEvent(tx *sql.Tx, accessID int64, orgID, petID, actorID uint, action string, ts time.Time) error {
+	_, err := tx.Exec("INSERT INTO pet_access_events(access_id, org_id, pet_id, actor_id, action, created_at) VALUES($1,$2,$3,$4,$5,$6)",
+		accessID, orgID, petID, actorID, action, ts)
+	return err
+}
+
+//dbAccessEvents returns the consent audit trail entries matching a
+//condition, newest first
+func (s *server) dbAccessEvents(cond string, args ...interface{}) ([]accessEvent, error) {
+	rows, err := s.db.Query(`SELECT e.id, e.access_id, e.org_id, e.pet_id, e.actor_id, u.email, e.action, e.created_at
+		FROM pet_access_events e JOIN users u ON u.id = e.actor_id
+		WHERE `+cond+` ORDER BY e.created_at DESC, e.id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []accessEvent{}
+	for rows.Next() {
+		var e accessEvent
+		err := rows.Scan(&e.ID, &e.AccessID, &e.OrgID, &e.PetID, &e.ActorID, &e.ActorEmail, &e.Action, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+//orgPetFromRequest reads the pet ID URL param of an organization route and
+//checks the organization has been granted access to it, responding to the
+//client itself when it hasn't
+func (s *server) orgPetFromRequest(w http.ResponseWriter, r *http.Request, orgID int64) (pet, bool) {
+	petID, err := strconv.ParseInt(mux.Vars(r)["petID"], 10, 64)
+	if err != nil {
+		s.respond(w, r, nil, "must provide a valid pet id", http.StatusBadRequest)
+		return pet{}, false
+	}
+	p, err := s.dbOrgPetsGetOne(orgID, petID)
+	if err == sql.ErrNoRows {
+		s.respond(w, r, nil, "pet not found", http.StatusNotFound)
+		return pet{}, false
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error retrieving pet from database")
+		s.respond(w, r, nil, "error retrieving pet", http.StatusInternalServerError)
+		return pet{}, false
+	}
+	return p, true
+}
+
+// handlerAccessGetAll godoc
+// @Summary Get access requests for my pets
+// @Description Get the requests organizations have made to access the user's pets, newest first
+// @Tags Consent
+// @Produce json
+// @Param status query string false "Only requests with this status: pending, granted, denied, revoked or cancelled"
+// @Success 200 {array} petAccess
+// @Security ApiKeyAuth
+// @Router /access-requests [get]
+func (s *server) handlerAccessGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+		status, err := accessStatusFromRequest(r)
+		if err != nil {
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+		var as []petAccess
+		if status != "" {
+			as, err = s.dbAccessQuery("a.owner_id = $1 AND a.status = $2", userID, status)
+		} else {
+			as, err = s.dbAccessQuery("a.owner_id = $1", userID)
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving access requests from database")
+			s.respond(w, r, nil, "error retrieving access requests", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, as, "", http.StatusOK)
+	}
+}
+
+// handlerPetAccessGetAll godoc
+// @Summary Get access requests for a pet
+// @Description Get the requests organizations have made to access a pet, newest first
+// @Tags Consent
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Success 200 {array} petAccess
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/access [get]
+func (s *server) handlerPetAccessGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok || !s.requirePet(w, r, userID, petID) {
+			return
+		}
+		as, err := s.dbAccessQuery("a.owner_id = $1 AND a.pet_id = $2", userID, petID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving access requests from database")
+			s.respond(w, r, nil, "error retrieving access requests", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, as, "", http.StatusOK)
+	}
+}
+
+// handlerPetAccessStatus godoc
+// @Summary Grant, deny or revoke access to a pet
+// @Description Decide on an organization's request to access a pet. Pending requests can be granted or denied, granted ones revoked. Every decision is kept in the consent audit trail.
+// @Tags Consent
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param AccessID path int true "Access request ID"
+// @Param status body petAccessStatusRequest true "New status (granted, denied or revoked)"
+// @Success 200 {object} petAccess
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/access/{AccessID}/status [put]
+func (s *server) handlerPetAccessStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		accessID, err := accessIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid access request id", http.StatusBadRequest)
+			return
+		}
+		a, err := s.dbAccessGetOne(accessID, "a.owner_id = $2 AND a.pet_id = $3", userID, petID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "access request not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving access request from database")
+			s.respond(w, r, nil, "error updating access request", http.StatusInternalServerError)
+			return
+		}
+
+		// Get JSON body and decode into a status request
+		var req petAccessStatusRequest
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		req.Status = strings.ToLower(strings.TrimSpace(req.Status))
+		if !canTransitionAccess(a.Status, req.Status) {
+			s.respond(w, r, nil, "a "+a.Status+" access request cannot be moved to "+req.Status, http.StatusConflict)
+			return
+		}
+
+		// Apply the transition
+		from := a.Status
+		a.Status = req.Status
+		a.DecidedAt = &ts
+		a.UpdatedAt = ts
+		rows, err := s.dbAccessSetStatus(a, from, userID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error updating access request in database")
+			s.respond(w, r, nil, "error updating access request", http.StatusInternalServerError)
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "access request was changed by someone else, try again", http.StatusConflict)
+			return
+		}
+		s.logger.Info().Uint("access_id", a.ID).Uint("org_id", a.OrgID).Int64("pet_id", petID).Str("status", a.Status).Msg("pet access changed")
+		s.respond(w, r, a, "", http.StatusOK)
+	}
+}
+
+// handlerPetAccessEvents godoc
+// @Summary Get the consent audit trail of a pet
+// @Description Get every access request, grant, denial, revocation and cancellation for a pet, newest first
+// @Tags Consent
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Success 200 {array} accessEvent
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/access/events [get]
+func (s *server) handlerPetAccessEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok || !s.requirePet(w, r, userID, petID) {
+			return
+		}
+		events, err := s.dbAccessEvents("e.pet_id = $1", petID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving access events from database")
+			s.respond(w, r, nil, "error retrieving access events", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, events, "", http.StatusOK)
+	}
+}
+
+// handlerOrgAccessCreate godoc
+// @Summary Request access to a client's pet
+// @Description Ask the owner of a pet for access to it. The owner is identified by the email they signed up with and has to grant the request before the organization can see the pet.
+// @Tags Consent
+// @Accept json
+// @Produce json
+// @Param OrgID path int true "Organization ID"
+// @Param request body petAccessRequest true "Access Request"
+// @Success 201 {object} petAccess
+// @Security ApiKeyAuth
+// @Router /organizations/{OrgID}/access-requests [post]
+func (s *server) handlerOrgAccessCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, orgID, ok := s.orgMemberFromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		// Get JSON body and decode into an access request
+		var req petAccessRequest
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		req.OwnerEmail = strings.TrimSpace(req.OwnerEmail)
+		req.Message = strings.TrimSpace(req.Message)
+		err = validate(req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking access request")
+			return
+		}
+
+		// The same answer whether the email or the pet is wrong, so the
+		// endpoint can't be used to find out who has an account
+		ownerID, err := s.dbAccessPetOfOwner(req.OwnerEmail, req.PetID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "no pet with that id belongs to that owner", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving pet from database")
+			s.respond(w, r, nil, "error requesting access", http.StatusInternalServerError)
+			return
+		}
+
+		a := petAccess{
+			OrgID:       uint(orgID),
+			PetID:       req.PetID,
+			OwnerID:     uint(ownerID),
+			Status:      accessPending,
+			Message:     req.Message,
+			RequestedBy: uint(userID),
+			RequestedAt: ts,
+			UpdatedAt:   ts,
+		}
+		id, err := s.dbAccessCreate(a)
+		if err == errAccessExists {
+			s.respond(w, r, nil, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error creating access request in database")
+			s.respond(w, r, nil, "error requesting access", http.StatusInternalServerError)
+			return
+		}
+		a, err = s.dbAccessGetOne(id, "a.org_id = $2", orgID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving access request from database")
+			s.respond(w, r, nil, "error requesting access", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, a, "", http.StatusCreated)
+	}
+}
+
+// handlerOrgAccessGetAll godoc
+// @Summary Get the access requests of an organization
+// @Description Get the requests an organization has made to access pets, newest first
+// @Tags Consent
+// @Produce json
+// @Param OrgID path int true "Organization ID"
+// @Param status query string false "Only requests with this status: pending, granted, denied, revoked or cancelled"
+// @Success 200 {array} petAccess
+// @Security ApiKeyAuth
+// @Router /organizations/{OrgID}/access-requests [get]
+func (s *server) handlerOrgAccessGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, orgID, ok := s.orgMemberFromRequest(w, r)
+		if !ok {
+			return
+		}
+		status, err := accessStatusFromRequest(r)
+		if err != nil {
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+		var as []petAccess
+		if status != "" {
+			as, err = s.dbAccessQuery("a.org_id = $1 AND a.status = $2", orgID, status)
+		} else {
+			as, err = s.dbAccessQuery("a.org_id = $1", orgID)
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving access requests from database")
+			s.respond(w, r, nil, "error retrieving access requests", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, as, "", http.StatusOK)
+	}
+}
+
+// handlerOrgAccessCancel godoc
+// @Summary Cancel an access request
+// @Description Withdraw a pending access request, or give up access an owner granted
+// @Tags Consent
+// @Param OrgID path int true "Organization ID"
+// @Param AccessID path int true "Access request ID"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /organizations/{OrgID}/access-requests/{AccessID} [delete]
+func (s *server) handlerOrgAccessCancel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, orgID, ok := s.orgMemberFromRequest(w, r)
+		if !ok {
+			return
+		}
+		accessID, err := accessIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid access request id", http.StatusBadRequest)
+			return
+		}
+		a, err := s.dbAccessGetOne(accessID, "a.org_id = $2", orgID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "access request not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving access request from database")
+			s.respond(w, r, nil, "error cancelling access request", http.StatusInternalServerError)
+			return
+		}
+		if a.Status != accessPending && a.Status != accessGranted {
+			s.respond(w, r, nil, "a "+a.Status+" access request cannot be cancelled", http.StatusConflict)
+			return
+		}
+
+		from := a.Status
+		a.Status = accessCancelled
+		a.UpdatedAt = ts
+		rows, err := s.dbAccessSetStatus(a, from, userID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error updating access request in database")
+			s.respond(w, r, nil, "error cancelling access request", http.StatusInternalServerError)
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "access request was changed by someone else, try again", http.StatusConflict)
+			return
+		}
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
+
+// handlerOrgAccessEvents godoc
+// @Summary Get the consent audit trail of an organization
+// @Description Get every access request, grant, denial, revocation and cancellation involving an organization, newest first
+// @Tags Consent
+// @Produce json
+// @Param OrgID path int true "Organization ID"
+// @Success 200 {array} accessEvent
+// @Security ApiKeyAuth
+// @Router /organizations/{OrgID}/access-events [get]
+func (s *server) handlerOrgAccessEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, orgID, ok := s.orgMemberFromRequest(w, r, orgRoleAdmin)
+		if !ok {
+			return
+		}
+		events, err := s.dbAccessEvents("e.org_id = $1", orgID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving access events from database")
+			s.respond(w, r, nil, "error retrieving access events", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, events, "", http.StatusOK)
+	}
+}
+
+// handlerOrgPetsGetAll godoc
+// @Summary Get the pets an organization may access
+// @Description Get the pets whose owners have granted the organization access
+// @Tags Consent
+// @Produce json
+// @Param OrgID path int true "Organization ID"
+// @Success 200 {array} pet
+// @Security ApiKeyAuth
+// @Router /organizations/{OrgID}/pets [get]
+func (s *server) handlerOrgPetsGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, orgID, ok := s.orgMemberFromRequest(w, r)
+		if !ok {
+			return
+		}
+		pets, err := s.dbOrgPetsGetAll(orgID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving pets from database")
+			s.respond(w, r, nil, "error retrieving pets", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, pets, "", http.StatusOK)
+	}
+}
+
+// handlerOrgPetsGetOne godoc
+// @Summary Get a pet an organization may access
+// @Description Get a pet whose owner has granted the organization access
+// @Tags Consent
+// @Produce json
+// @Param OrgID path int true "Organization ID"
+// @Param PetID path int true "Pet ID"
+// @Success 200 {object} pet
+// @Security ApiKeyAuth
+// @Router /organizations/{OrgID}/pets/{PetID} [get]
+func (s *server) handlerOrgPetsGetOne() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, orgID, ok := s.orgMemberFromRequest(w, r)
+		if !ok {
+			return
+		}
+		p, ok := s.orgPetFromRequest(w, r, orgID)
+		if !ok {
+			return
+		}
+		s.respond(w, r, p, "", http.StatusOK)
+	}
+}
+
+// handlerOrgRecordsGetAll godoc
+// @Summary Get the medical records of a client's pet
+// @Description Get all medical records of a pet whose owner has granted the organization access, including those the owner added
+// @Tags Consent
+// @Produce json
+// @Param OrgID path int true "Organization ID"
+// @Param PetID path int true "Pet ID"
+// @Success 200 {array} medicalRecord
+// @Security ApiKeyAuth
+// @Router /organizations/{OrgID}/pets/{PetID}/records [get]
+func (s *server) handlerOrgRecordsGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, orgID, ok := s.orgMemberFromRequest(w, r)
+		if !ok {
+			return
+		}
+		p, ok := s.orgPetFromRequest(w, r, orgID)
+		if !ok {
+			return
+		}
+		recs, err := s.dbRecordsGetAll(int64(p.UserID), int64(p.ID))
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving medical records from database")
+			s.respond(w, r, nil, "error retrieving medical records", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, recs, "", http.StatusOK)
+	}
+}
+
+// handlerOrgRecordsCreate godoc
+// @Summary Add a medical record to a client's pet
+// @Description Add a medical record to a pet whose owner has granted the organization access. It shows up in the owner's account. Only admins and vets of the organization may do this.
+// @Tags Consent
+// @Accept json
+// @Produce json
+// @Param OrgID path int true "Organization ID"
+// @Param PetID path int true "Pet ID"
+// @Param record body medicalRecordRequest true "Create Medical Record"
+// @Success 201 {object} medicalRecord
+// @Security ApiKeyAuth
+// @Router /organizations/{OrgID}/pets/{PetID}/records [post]
+func (s *server) handlerOrgRecordsCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, orgID, ok := s.orgMemberFromRequest(w, r, orgRoleAdmin, orgRoleVet)
+		if !ok {
+			return
+		}
+		p, ok := s.orgPetFromRequest(w, r, orgID)
+		if !ok {
+			return
+		}
+
+		// Get JSON body, decode into a record request and validate it
+		var req medicalRecordRequest
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateRecord(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking medical record")
+			return
+		}
+		if req.ProviderID != nil {
+			s.respondProblem(w, r, invalidField("provider_id", "provider_id refers to the owner's directory and can't be set by an organization"))
+			return
+		}
+
+		// Create the record in the owner's account
+		org, staff := uint(orgID), uint(userID)
+		m := medicalRecord{
+			PetID:         p.ID,
+			UserID:        p.UserID,
+			Kind:          req.Kind,
+			Title:         req.Title,
+			Notes:         req.Notes,
+			OccurredOn:    req.OccurredOn,
+			DueOn:         req.DueOn,
+			DoseFrequency: req.DoseFrequency,
+			DosesPerDay:   req.DosesPerDay,
+			CourseDays:    req.CourseDays,
+			OrgID:         &org,
+			CreatedBy:     &staff,
+			CreatedAt:     ts,
+			UpdatedAt:     ts,
+		}
+		id, err := s.dbRecordsCreate(m)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error creating medical record in database")
+			s.respond(w, r, nil, "error creating medical record", http.StatusInternalServerError)
+			return
+		}
+		m.ID = uint(id)
+		s.respond(w, r, m, "", http.StatusCreated)
+	}
+}
+
+// handlerOrgRecordsUpdate godoc
+// @Summary Update a medical record of a client's pet
+// @Description Update a medical record the organization added to a pet it still has access to. Only admins and vets of the organization may do this.
+// @Tags Consent
+// @Accept json
+// @Produce json
+// @Param OrgID path int true "Organization ID"
+// @Param PetID path int true "Pet ID"
+// @Param RecordID path int true "Record ID"
+// @Param record body medicalRecordRequest true "Updated Medical Record"
+// @Success 200 {object} medicalRecord
+// @Security ApiKeyAuth
+// @Router /organizations/{OrgID}/pets/{PetID}/records/{RecordID} [put]
+func (s *server) handlerOrgRecordsUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		_, orgID, ok := s.orgMemberFromRequest(w, r, orgRoleAdmin, orgRoleVet)
+		if !ok {
+			return
+		}
+		p, ok := s.orgPetFromRequest(w, r, orgID)
+		if !ok {
+			return
+		}
+		recordID, err := recordIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid record id", http.StatusBadRequest)
+			return
+		}
+		m, err := s.dbRecordsGetOne(int64(p.UserID), int64(p.ID), recordID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "medical record not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving medical record from database")
+			s.respond(w, r, nil, "error updating medical record", http.StatusInternalServerError)
+			return
+		}
+		if m.OrgID == nil || int64(*m.OrgID) != orgID {
+			s.respond(w, r, nil, "only records added by this organization can be changed", http.StatusForbidden)
+			return
+		}
+
+		// Get JSON body, decode into a record request and validate it
+		var req medicalRecordRequest
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateRecord(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking medical record")
+			return
+		}
+		if req.ProviderID != nil {
+			s.respondProblem(w, r, invalidField("provider_id", "provider_id refers to the owner's directory and can't be set by an organization"))
+			return
+		}
+
+		// Update the record in the db
+		m.Kind = req.Kind
+		m.Title = req.Title
+		m.Notes = req.Notes
+		m.OccurredOn = req.OccurredOn
+		m.DueOn = req.DueOn
+		m.DoseFrequency, m.DosesPerDay, m.CourseDays = req.DoseFrequency, req.DosesPerDay, req.CourseDays
+		m.UpdatedAt = ts
+		_, err = s.dbRecordsUpdate(m)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error updating medical record in database")
+			s.respond(w, r, nil, "error updating medical record", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, m, "", http.StatusOK)
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+)
+
+// currencyExponents maps active ISO 4217 currency codes to the number of
+// digits after the decimal separator of their minor unit
+var currencyExponents = map[string]int{}
+
+func init() {
+	// Codes grouped by exponent, most currencies have two decimals
+	groups := map[int]string{
+		0: "BIF CLP DJF GNF ISK JPY KMF KRW PYG RWF UGX UYI VND VUV XAF XOF XPF",
+		2: "AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BMD BND BOB BRL BSD BTN BWP BYN BZD " +
+			"CAD CDF CHF CNY COP CRC CUP CVE CZK DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD " +
+			"GTQ GYD HKD HNL HTG HUF IDR ILS INR IRR JMD KES KGS KHR KPW KYD KZT LAK LBP LKR LRD LSL MAD " +
+			"MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MYR MZN NAD NGN NIO NOK NPR NZD PAB PEN PGK PHP " +
+			"PKR PLN QAR RON RSD RUB SAR SBD SCR SDG SEK SGD SHP SLE SOS SRD SSP STN SVC SYP SZL THB TJS " +
+			"TMT TOP TRY TTD TWD TZS UAH USD UYU UZS VES WST XCD YER ZAR ZMW ZWL",
+		3: "BHD IQD JOD KWD LYD OMR TND",
+		4: "CLF UYW",
+	}
+	for exp, codes := range groups {
+		for _, c := range strings.Fields(codes) {
+			currencyExponents[c] = exp
+		}
+	}
+}
+
+//validCurrency reports whether code is an active ISO 4217 currency code
+func validCurrency(code string) bool {
+	_, ok := currencyExponents[code]
+	return ok
+}
+
+//formatMinorUnits formats an amount in minor units as a decimal string in
+//the major unit of its currency, e.g. 12550 USD is "125.50"
+func formatMinorUnits(amount int64, currency string) string {
+	exp := currencyExponents[currency]
+	neg := amount < 0
+	if neg {
+		amount = -amount
+	}
+	s := strconv.FormatInt(amount, 10)
+	if exp > 0 {
+		for len(s) <= exp {
+			s = "0" + s
+		}
+		s = s[:len(s)-exp] + "." + s[len(s)-exp:]
+	}
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
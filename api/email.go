@@ -1,6 +1,8 @@
 package api
 
 import (
+	"fmt"
+
 	"github.com/matcornic/hermes/v2"
 )
 
@@ -42,3 +44,31 @@ func generateEmail(userEmail string, resetToken string) string {
 	}
 	return emailBody
 }
+
+//generateLostPetContactEmail builds the email relaying a finder's message
+//to the owner of a lost pet
+func generateLostPetContactEmail(petName, senderName, senderContact, message string) (string, error) {
+	h := hermes.Hermes{
+		Product: hermes.Product{
+			Name: "Petkeep",
+			Link: "https://www.petkeep.com",
+		},
+	}
+	email := hermes.Email{
+		Body: hermes.Body{
+			Title: fmt.Sprintf("Someone may have found %s!", petName),
+			Intros: []string{
+				fmt.Sprintf("A visitor to %s's lost pet page sent you a message. Your email address has not been shared with them.", petName),
+			},
+			Dictionary: []hermes.Entry{
+				{Key: "Name", Value: senderName},
+				{Key: "Contact", Value: senderContact},
+				{Key: "Message", Value: message},
+			},
+			Outros: []string{
+				"Please be careful when arranging to meet someone you don't know.",
+			},
+		},
+	}
+	return h.GenerateHTML(email)
+}
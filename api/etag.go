@@ -0,0 +1,83 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//versionETag returns the strong entity tag of a version of a resource
+func versionETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+//listETag returns a weak entity tag for a list of resources, given their
+//IDs and versions in order. It changes whenever one is added, removed or
+//changed.
+func listETag(ids []uint, versions []int64) string {
+	h := sha256.New()
+	for i, id := range ids {
+		fmt.Fprintf(h, "%d:%d,", id, versions[i])
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+//etagList splits an If-Match or If-None-Match header into its entity tags
+func etagList(r *http.Request, name string) []string {
+	tags := []string{}
+	for _, v := range r.Header.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tags = append(tags, t)
+			}
+		}
+	}
+	return tags
+}
+
+//checkIfMatch checks the If-Match header of a write against the current
+//version of a resource, responding with 412 Precondition Failed itself
+//when it doesn't match. It returns the version the write has to be made
+//at so a change in between fails too, 0 when the write isn't conditional.
+func (s *server) checkIfMatch(w http.ResponseWriter, r *http.Request, version int64) (int64, bool) {
+	tags := etagList(r, "If-Match")
+	if len(tags) == 0 {
+		return 0, true
+	}
+	current := versionETag(version)
+	for _, t := range tags {
+		// Weak tags never match, If-Match uses the strong comparison
+		if t == "*" || t == current {
+			return version, true
+		}
+	}
+	s.respondPreconditionFailed(w, r, version)
+	return 0, false
+}
+
+//respondPreconditionFailed tells the client the resource changed since it
+//read it, along with the tag of the current version
+func (s *server) respondPreconditionFailed(w http.ResponseWriter, r *http.Request, version int64) {
+	if version != 0 {
+		w.Header().Set("ETag", versionETag(version))
+	}
+	s.respond(w, r, nil, "resource was changed since it was read", http.StatusPreconditionFailed)
+}
+
+//checkIfNoneMatch sets the ETag header of a read and responds with 304 Not
+//Modified when the client already has that version, in which case the
+//caller is done
+func checkIfNoneMatch(w http.ResponseWriter, r *http.Request, tag string) bool {
+	w.Header().Set("ETag", tag)
+	for _, t := range etagList(r, "If-None-Match") {
+		// If-None-Match uses the weak comparison
+		if t == "*" || strings.TrimPrefix(t, "W/") == strings.TrimPrefix(tag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
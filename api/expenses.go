@@ -0,0 +1,798 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// expenseCategories are the categories an expense may be filed under
+var expenseCategories = map[string]bool{
+	"vet":        true,
+	"medication": true,
+	"food":       true,
+	"insurance":  true,
+	"grooming":   true,
+	"supplies":   true,
+	"boarding":   true,
+	"training":   true,
+	"other":      true,
+}
+
+type expense struct {
+	ID                  uint      `json:"expense_id" example:"1"`
+	PetID               uint      `json:"pet_id" example:"1"`
+	UserID              uint      `json:"user_id" example:"1"`
+	AmountMinor         int64     `json:"amount_minor" example:"12550"`
+	Currency            string    `json:"currency" example:"USD"`
+	Category            string    `json:"category" example:"vet"`
+	Vendor              string    `json:"vendor" example:"Riverside Animal Hospital"`
+	Date                time.Time `json:"date" example:"2019-11-09T00:00:00Z"`
+	Notes               string    `json:"notes" example:"Annual checkup and rabies booster"`
+	ReceiptAttachmentID *uint     `json:"receipt_attachment_id,omitempty" example:"3"`
+	CreatedAt           time.Time `json:"created_at" example:"2019-11-09T21:21:46+00:00"`
+	UpdatedAt           time.Time `json:"updated_at" example:"2019-11-09T21:21:46+00:00"`
+}
+
+// expenseRequest holds an amount in integer minor units of the currency,
+// e.g. cents for USD, so no precision is lost to floating point
+type expenseRequest struct {
+	AmountMinor         int64     `json:"amount_minor" example:"12550" validate:"required,min=1"`
+	Currency            string    `json:"currency" example:"USD" validate:"required"`
+	Category            string    `json:"category" example:"vet" validate:"required,oneof=vet medication food insurance grooming supplies boarding training other"`
+	Vendor              string    `json:"vendor" example:"Riverside Animal Hospital" validate:"max=200"`
+	Date                time.Time `json:"date" example:"2019-11-09T00:00:00Z" validate:"required"`
+	Notes               string    `json:"notes" example:"Annual checkup and rabies booster"`
+	ReceiptAttachmentID *uint     `json:"receipt_attachment_id" example:"3"`
+}
+
+//checkFields checks the currency of an expense, which a tag can't express
+func (req expenseRequest) checkFields() []fieldError {
+	errs := []fieldError{}
+	if req.Currency != "" && !validCurrency(req.Currency) {
+		errs = append(errs, fieldError{Field: "currency", Code: codeInvalidField, Message: "currency must be an ISO 4217 currency code"})
+	}
+	return errs
+}
+
+type expenses []expense
+
+// expenseFilter narrows down the expenses listed or aggregated
+type expenseFilter struct {
+	PetID    int64
+	Category string
+	From     time.Time
+	To       time.Time
+}
+
+type expenseReportRow struct {
+	Month      string `json:"month,omitempty" example:"2019-11"`
+	Category   string `json:"category,omitempty" example:"vet"`
+	PetID      uint   `json:"pet_id,omitempty" example:"1"`
+	PetName    string `json:"pet_name,omitempty" example:"Fido"`
+	Currency   string `json:"currency" example:"USD"`
+	TotalMinor int64  `json:"total_minor" example:"12550"`
+	Total      string `json:"total" example:"125.50"`
+	Count      int    `json:"count" example:"1"`
+}
+
+type expenseReport struct {
+	GroupBy []string           `json:"group_by" example:"month,category"`
+	From    string             `json:"from,omitempty" example:"2019-01-01"`
+	To      string             `json:"to,omitempty" example:"2020-01-01"`
+	Rows    []expenseReportRow `json:"rows"`
+}
+
+// expenseReportDimensions whitelists what reports may be grouped by and the
+// SQL expressions selected for each. Months are bucketed from the days once
+// summed, since databases disagree on how to truncate dates.
+var expenseReportDimensions = map[string][]string{
+	"month":    {"e.spent_on"},
+	"category": {"e.category"},
+	"pet":      {"e.pet_id", "p.name"},
+}
+
+const expenseColumns = "id, pet_id, user_id, amount_minor, currency, category, vendor, spent_on, notes, receipt_attachment_id, created_at, updated_at"
+
+//scanExpense scans a row selected with expenseColumns
+func scanExpense(row interface{ Scan(...interface{}) error }) (expense, error) {
+	var e expense
+	var vendor, notes sql.NullString
+	var receipt sql.NullInt64
+	err := row.Scan(&e.ID, &e.PetID, &e.UserID, &e.AmountMinor, &e.Currency, &e.Category, &vendor, &e.Date, &notes, &receipt, &e.CreatedAt, &e.UpdatedAt)
+	if err != nil {
+		return e, err
+	}
+	e.Vendor, e.Notes = vendor.String, notes.String
+	if receipt.Valid {
+		id := uint(receipt.Int64)
+		e.ReceiptAttachmentID = &id
+	}
+	return e, nil
+}
+
+//parseDateParam parses an optional YYYY-MM-DD query parameter
+func parseDateParam(r *http.Request, name string) (time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return t, invalidField(name, name+" must be a date formatted as YYYY-MM-DD")
+	}
+	return t, nil
+}
+
+//expenseFilterFromRequest reads the from, to, category and pet_id query parameters
+func expenseFilterFromRequest(r *http.Request) (expenseFilter, error) {
+	var f expenseFilter
+	var err error
+	f.From, err = parseDateParam(r, "from")
+	if err != nil {
+		return f, err
+	}
+	f.To, err = parseDateParam(r, "to")
+	if err != nil {
+		return f, err
+	}
+	f.Category = r.URL.Query().Get("category")
+	if f.Category != "" && !expenseCategories[f.Category] {
+		return f, invalidField("category", fmt.Sprintf("unknown category %q", f.Category))
+	}
+	if v := r.URL.Query().Get("pet_id"); v != "" {
+		f.PetID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return f, invalidField("pet_id", "pet_id must be a number")
+		}
+	}
+	return f, nil
+}
+
+//where builds the WHERE clause of a filter on the expenses table aliased as
+//e, numbering its parameters after the user ID in $1
+func (f expenseFilter) where(userID int64) (string, []interface{}) {
+	conds := []string{"e.user_id = $1", "e.deleted_at IS NULL"}
+	args := []interface{}{userID}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.PetID != 0 {
+		add("e.pet_id = $%d", f.PetID)
+	}
+	if f.Category != "" {
+		add("e.category = $%d", f.Category)
+	}
+	if !f.From.IsZero() {
+		add("e.spent_on >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("e.spent_on < $%d", f.To)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+//validateExpense normalizes and checks an expense request
+func validateExpense(req *expenseRequest) error {
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	req.Category = strings.ToLower(strings.TrimSpace(req.Category))
+	req.Vendor = strings.TrimSpace(req.Vendor)
+	return validate(req)
+}
+
+//dbExpensesGetAll returns the expenses of a user matching a filter
+func (s *server) dbExpensesGetAll(userID int64, f expenseFilter) ([]expense, error) {
+	where, args := f.where(userID)
+	rows, err := s.db.Query("SELECT "+expenseColumns+" FROM expenses e WHERE "+where+" ORDER BY e.spent_on DESC, e.id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exps := []expense{}
+	for rows.Next() {
+		e, err := scanExpense(rows)
+		if err != nil {
+			return nil, err
+		}
+		exps = append(exps, e)
+	}
+	return exps, rows.Err()
+}
+
+//dbExpensesGetOne returns a single expense on a pet owned by a user
+func (s *server) dbExpensesGetOne(userID, petID, expenseID int64) (expense, error) {
+	row := s.db.QueryRow("SELECT "+expenseColumns+" FROM expenses WHERE user_id = $1 AND pet_id = $2 AND id = $3 AND deleted_at IS NULL", userID, petID, expenseID)
+	return scanExpense(row)
+}
+
+//dbExpensesCreate stores a new expense
+func (s *server) dbExpensesCreate(e expense) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`INSERT INTO expenses(pet_id, user_id, amount_minor, currency, category, vendor, spent_on, notes, receipt_attachment_id, created_at, updated_at)
+		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING id`,
+		e.PetID, e.UserID, e.AmountMinor, e.Currency, e.Category, e.Vendor, e.Date, e.Notes, e.ReceiptAttachmentID, e.CreatedAt, e.UpdatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+//dbExpensesUpdate updates an expense on a pet owned by a user
+func (s *server) dbExpensesUpdate(e expense) (int64, error) {
+	res, err := s.db.Exec(`UPDATE expenses SET amount_minor = $1, currency = $2, category = $3, vendor = $4, spent_on = $5, notes = $6,
+		receipt_attachment_id = $7, updated_at = $8 WHERE id = $9 AND user_id = $10 AND pet_id = $11 AND deleted_at IS NULL`,
+		e.AmountMinor, e.Currency, e.Category, e.Vendor, e.Date, e.Notes, e.ReceiptAttachmentID, e.UpdatedAt, e.ID, e.UserID, e.PetID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbExpensesDelete deletes an expense on a pet owned by a user
+func (s *server) dbExpensesDelete(userID, petID, expenseID int64) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM expenses WHERE user_id = $1 AND pet_id = $2 AND id = $3 AND deleted_at IS NULL", userID, petID, expenseID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbExpensesReport sums the expenses of a user matching a filter, grouped by
+//the given dimensions and always by currency since amounts in different
+//currencies can't be added up
+func (s *server) dbExpensesReport(userID int64, f expenseFilter, groupBy []string) ([]expenseReportRow, error) {
+	var exprs []string
+	for _, d := range groupBy {
+		exprs = append(exprs, expenseReportDimensions[d]...)
+	}
+	exprs = append(exprs, "e.currency")
+	cols := strings.Join(exprs, ", ")
+	where, args := f.where(userID)
+	q := "SELECT " + cols + ", sum(e.amount_minor), count(*) FROM expenses e JOIN pets p ON p.id = e.pet_id WHERE " + where +
+		" GROUP BY " + cols + " ORDER BY " + cols
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Rows of days in the same month are added up into one
+	report := []expenseReportRow{}
+	seen := map[expenseReportRow]int{}
+	for rows.Next() {
+		var row expenseReportRow
+		var day time.Time
+		var dest []interface{}
+		for _, d := range groupBy {
+			switch d {
+			case "month":
+				dest = append(dest, &day)
+			case "category":
+				dest = append(dest, &row.Category)
+			case "pet":
+				dest = append(dest, &row.PetID, &row.PetName)
+			}
+		}
+		var total int64
+		var count int
+		dest = append(dest, &row.Currency, &total, &count)
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+		if !day.IsZero() {
+			row.Month = day.Format("2006-01")
+		}
+		i, ok := seen[row]
+		if !ok {
+			i = len(report)
+			seen[row] = i
+			report = append(report, row)
+		}
+		report[i].TotalMinor += total
+		report[i].Count += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range report {
+		report[i].Total = formatMinorUnits(report[i].TotalMinor, report[i].Currency)
+	}
+	sort.SliceStable(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		for _, d := range groupBy {
+			switch {
+			case d == "month" && a.Month != b.Month:
+				return a.Month < b.Month
+			case d == "category" && a.Category != b.Category:
+				return a.Category < b.Category
+			case d == "pet" && a.PetID != b.PetID:
+				return a.PetID < b.PetID
+			}
+		}
+		return a.Currency < b.Currency
+	})
+	return report, nil
+}
+
+//wantsCSV reports whether the client asked for CSV output
+func wantsCSV(r *http.Request) bool {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f == "csv"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/csv")
+}
+
+//writeCSV writes records as a CSV attachment
+func (s *server) writeCSV(w http.ResponseWriter, filename string, records [][]string) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	cw := csv.NewWriter(w)
+	err := cw.WriteAll(records)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error writing CSV")
+	}
+}
+
+//expenseIDFromRequest is a helper to extract the expense ID from the URL params
+func expenseIDFromRequest(r *http.Request) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)["expenseID"], 10, 64)
+}
+
+//checkReceipt makes sure a receipt attachment belongs to the expense's pet
+func (s *server) checkReceipt(userID, petID int64, receiptID *uint) (bool, error) {
+	if receiptID == nil {
+		return true, nil
+	}
+	_, err := s.dbAttachmentsGetOne(userID, petID, int64(*receiptID))
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// handlerExpensesGetAll godoc
+// @Summary Get all expenses of a pet
+// @Description Get all expenses of a pet, newest first, optionally as CSV
+// @Tags Expenses
+// @Produce json,text/csv
+// @Param PetID path int true "Pet ID"
+// @Param from query string false "Earliest date, YYYY-MM-DD"
+// @Param to query string false "Date before which to stop, YYYY-MM-DD"
+// @Param category query string false "Category"
+// @Param format query string false "Set to csv for CSV output"
+// @Success 200 {array} expense
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/expenses [get]
+func (s *server) handlerExpensesGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Get user ID from the authenticated context
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Get URL and query params
+		petID, err := petIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid pet id", http.StatusBadRequest)
+			return
+		}
+		f, err := expenseFilterFromRequest(r)
+		if err != nil {
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+		f.PetID = petID
+
+		// Get expenses from the database and respond
+		exps, err := s.dbExpensesGetAll(userID, f)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving expenses from database")
+			s.respond(w, r, nil, "error retrieving expenses", http.StatusInternalServerError)
+			return
+		}
+		if wantsCSV(r) {
+			records := [][]string{{"expense_id", "pet_id", "date", "category", "vendor", "currency", "amount", "amount_minor", "notes", "receipt_attachment_id"}}
+			for _, e := range exps {
+				receipt := ""
+				if e.ReceiptAttachmentID != nil {
+					receipt = strconv.FormatUint(uint64(*e.ReceiptAttachmentID), 10)
+				}
+				records = append(records, []string{
+					strconv.FormatUint(uint64(e.ID), 10),
+					strconv.FormatUint(uint64(e.PetID), 10),
+					e.Date.Format("2006-01-02"),
+					e.Category,
+					e.Vendor,
+					e.Currency,
+					formatMinorUnits(e.AmountMinor, e.Currency),
+					strconv.FormatInt(e.AmountMinor, 10),
+					e.Notes,
+					receipt,
+				})
+			}
+			s.writeCSV(w, "expenses.csv", records)
+			return
+		}
+		s.respond(w, r, exps, "", http.StatusOK)
+	}
+}
+
+// handlerExpensesGetOne godoc
+// @Summary Get one expense
+// @Description Get one expense of a pet
+// @Tags Expenses
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param ExpenseID path int true "Expense ID"
+// @Success 200 {object} expense
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/expenses/{ExpenseID} [get]
+func (s *server) handlerExpensesGetOne() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Get user ID from the authenticated context
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Get URL params
+		petID, err := petIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid pet id", http.StatusBadRequest)
+			return
+		}
+		expenseID, err := expenseIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid expense id", http.StatusBadRequest)
+			return
+		}
+
+		e, err := s.dbExpensesGetOne(userID, petID, expenseID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "expense not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving expense from database")
+			s.respond(w, r, nil, "error retrieving expense", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, e, "", http.StatusOK)
+	}
+}
+
+// handlerExpensesCreate godoc
+// @Summary Create an expense
+// @Description Record an expense for a pet. Amounts are integer minor units of the currency, e.g. cents.
+// @Tags Expenses
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param expense body expenseRequest true "Create Expense"
+// @Success 201 {object} expense
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/expenses [post]
+func (s *server) handlerExpensesCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		ts := time.Now()
+
+		// Get user ID from the authenticated context
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Get URL params and make sure the pet is the user's
+		petID, err := petIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid pet id", http.StatusBadRequest)
+			return
+		}
+		ok, err := s.pets.Exists(userID, petID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving pet from database")
+			s.respond(w, r, nil, "error creating expense", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			s.respond(w, r, nil, "pet not found", http.StatusNotFound)
+			return
+		}
+
+		// Get JSON body, decode into an expense request and validate it
+		var req expenseRequest
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateExpense(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking expense")
+			return
+		}
+		ok, err = s.checkReceipt(userID, petID, req.ReceiptAttachmentID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving attachment from database")
+			s.respond(w, r, nil, "error creating expense", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			s.respondProblem(w, r, invalidField("receipt_attachment_id", "receipt must be an attachment of the same pet"))
+			return
+		}
+
+		// Create the expense in the db
+		e := expense{
+			PetID:               uint(petID),
+			UserID:              uint(userID),
+			AmountMinor:         req.AmountMinor,
+			Currency:            req.Currency,
+			Category:            req.Category,
+			Vendor:              req.Vendor,
+			Date:                req.Date,
+			Notes:               req.Notes,
+			ReceiptAttachmentID: req.ReceiptAttachmentID,
+			CreatedAt:           ts,
+			UpdatedAt:           ts,
+		}
+		id, err := s.dbExpensesCreate(e)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error creating expense in database")
+			s.respond(w, r, nil, "error creating expense", http.StatusInternalServerError)
+			return
+		}
+		e.ID = uint(id)
+		s.respond(w, r, e, "", http.StatusCreated)
+	}
+}
+
+// handlerExpensesUpdate godoc
+// @Summary Update an expense
+// @Description Update an expense of a pet
+// @Tags Expenses
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param ExpenseID path int true "Expense ID"
+// @Param expense body expenseRequest true "Updated Expense"
+// @Success 200 {object} expense
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/expenses/{ExpenseID} [put]
+func (s *server) handlerExpensesUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		ts := time.Now()
+
+		// Get user ID from the authenticated context
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Get URL params
+		petID, err := petIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid pet id", http.StatusBadRequest)
+			return
+		}
+		expenseID, err := expenseIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid expense id", http.StatusBadRequest)
+			return
+		}
+		e, err := s.dbExpensesGetOne(userID, petID, expenseID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "expense not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving expense from database")
+			s.respond(w, r, nil, "error updating expense", http.StatusInternalServerError)
+			return
+		}
+
+		// Get JSON body, decode into an expense request and validate it
+		var req expenseRequest
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateExpense(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking expense")
+			return
+		}
+		ok, err := s.checkReceipt(userID, petID, req.ReceiptAttachmentID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving attachment from database")
+			s.respond(w, r, nil, "error updating expense", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			s.respondProblem(w, r, invalidField("receipt_attachment_id", "receipt must be an attachment of the same pet"))
+			return
+		}
+
+		// Update the expense in the db
+		e.AmountMinor = req.AmountMinor
+		e.Currency = req.Currency
+		e.Category = req.Category
+		e.Vendor = req.Vendor
+		e.Date = req.Date
+		e.Notes = req.Notes
+		e.ReceiptAttachmentID = req.ReceiptAttachmentID
+		e.UpdatedAt = ts
+		_, err = s.dbExpensesUpdate(e)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error updating expense in database")
+			s.respond(w, r, nil, "error updating expense", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, e, "", http.StatusOK)
+	}
+}
+
+// handlerExpensesDelete godoc
+// @Summary Delete an expense
+// @Description Delete an expense of a pet
+// @Tags Expenses
+// @Param PetID path int true "Pet ID"
+// @Param ExpenseID path int true "Expense ID"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/expenses/{ExpenseID} [delete]
+func (s *server) handlerExpensesDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Get user ID from the authenticated context
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Get URL params
+		petID, err := petIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid pet id", http.StatusBadRequest)
+			return
+		}
+		expenseID, err := expenseIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid expense id", http.StatusBadRequest)
+			return
+		}
+
+		rows, err := s.dbExpensesDelete(userID, petID, expenseID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error deleting expense from database")
+			s.respond(w, r, nil, "error deleting expense", http.StatusInternalServerError)
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "expense not found", http.StatusNotFound)
+			return
+		}
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
+
+// handlerExpensesReport godoc
+// @Summary Get an expense report
+// @Description Sum a user's expenses grouped by month, category and/or pet, and always by currency. Optionally as CSV.
+// @Tags Expenses
+// @Produce json,text/csv
+// @Param group_by query string false "Comma separated dimensions: month, category, pet (default month)"
+// @Param from query string false "Earliest date, YYYY-MM-DD"
+// @Param to query string false "Date before which to stop, YYYY-MM-DD"
+// @Param category query string false "Category"
+// @Param pet_id query int false "Pet ID"
+// @Param format query string false "Set to csv for CSV output"
+// @Success 200 {object} expenseReport
+// @Security ApiKeyAuth
+// @Router /expenses/report [get]
+func (s *server) handlerExpensesReport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Get user ID from the authenticated context
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Get query params
+		f, err := expenseFilterFromRequest(r)
+		if err != nil {
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+		groupBy := []string{"month"}
+		if v := r.URL.Query().Get("group_by"); v != "" {
+			groupBy = nil
+			seen := map[string]bool{}
+			for _, d := range strings.Split(v, ",") {
+				d = strings.TrimSpace(d)
+				if _, ok := expenseReportDimensions[d]; !ok {
+					s.respondProblem(w, r, invalidField("group_by", fmt.Sprintf("cannot group by %q, use month, category or pet", d)))
+					return
+				}
+				if !seen[d] {
+					seen[d] = true
+					groupBy = append(groupBy, d)
+				}
+			}
+		}
+
+		// Build the report
+		rows, err := s.dbExpensesReport(userID, f, groupBy)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error building expense report from database")
+			s.respond(w, r, nil, "error building expense report", http.StatusInternalServerError)
+			return
+		}
+		if wantsCSV(r) {
+			var header []string
+			for _, d := range groupBy {
+				if d == "pet" {
+					header = append(header, "pet_id", "pet_name")
+					continue
+				}
+				header = append(header, d)
+			}
+			records := [][]string{append(header, "currency", "total", "total_minor", "count")}
+			for _, row := range rows {
+				var rec []string
+				for _, d := range groupBy {
+					switch d {
+					case "month":
+						rec = append(rec, row.Month)
+					case "category":
+						rec = append(rec, row.Category)
+					case "pet":
+						rec = append(rec, strconv.FormatUint(uint64(row.PetID), 10), row.PetName)
+					}
+				}
+				records = append(records, append(rec, row.Currency, row.Total, strconv.FormatInt(row.TotalMinor, 10), strconv.Itoa(row.Count)))
+			}
+			s.writeCSV(w, "expense-report.csv", records)
+			return
+		}
+
+		report := expenseReport{GroupBy: groupBy, Rows: rows}
+		if !f.From.IsZero() {
+			report.From = f.From.Format("2006-01-02")
+		}
+		if !f.To.IsZero() {
+			report.To = f.To.Format("2006-01-02")
+		}
+		s.respond(w, r, report, "", http.StatusOK)
+	}
+}
@@ -0,0 +1,305 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"math"
+	"time"
+)
+
+// maxRoutePoints is the most points kept of an imported route. Longer
+// tracks are thinned out evenly, distance and elevation are computed on
+// the full track first.
+const maxRoutePoints = 5000
+
+// elevationThreshold is the climb or drop in meters that counts towards
+// elevation gain and loss, so GPS noise doesn't add up on flat ground
+const elevationThreshold = 3.0
+
+// earthRadius is the mean radius of the earth in meters
+const earthRadius = 6371008.8
+
+// routeAllowedTypes are the sniffed types accepted for route imports, GPX
+// is XML and GeoJSON sniffs as plain text
+var routeAllowedTypes = map[string]bool{
+	"text/xml; charset=utf-8":   true,
+	"text/plain; charset=utf-8": true,
+}
+
+// routePoint is one point of a recorded route. Elevation and time are
+// optional in both GPX and GeoJSON.
+type routePoint struct {
+	Lat  float64
+	Lon  float64
+	Ele  *float64
+	Time *time.Time
+}
+
+// routeStats are computed from the points of a route
+type routeStats struct {
+	DistanceMeters   float64
+	ElevationGainM   float64
+	ElevationLossM   float64
+	StartedAt        time.Time
+	DurationSeconds  int64
+	HasTimes         bool
+	PointCount       int
+	SimplifiedPoints []routePoint
+}
+
+type gpxFile struct {
+	Tracks []struct {
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+	Routes []struct {
+		Points []gpxPoint `xml:"rtept"`
+	} `xml:"rte"`
+}
+
+type gpxPoint struct {
+	Lat  float64  `xml:"lat,attr"`
+	Lon  float64  `xml:"lon,attr"`
+	Ele  *float64 `xml:"ele"`
+	Time string   `xml:"time"`
+}
+
+type geoJSONObject struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geoJSONObject  `json:"geometry"`
+	Features    []geoJSONObject `json:"features"`
+	Properties  struct {
+		CoordTimes json.RawMessage `json:"coordTimes"`
+	} `json:"properties"`
+}
+
+//parseRoute reads the points of a GPX or GeoJSON file
+func parseRoute(data []byte) ([]routePoint, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseGeoJSON(trimmed)
+	}
+	return parseGPX(trimmed)
+}
+
+//parseGPX reads the track points of a GPX file, or its route points when
+//it has no tracks
+func parseGPX(data []byte) ([]routePoint, error) {
+	var g gpxFile
+	err := xml.Unmarshal(data, &g)
+	if err != nil {
+		return nil, errors.New("file is neither valid GPX nor GeoJSON")
+	}
+	var raw []gpxPoint
+	for _, t := range g.Tracks {
+		for _, s := range t.Segments {
+			raw = append(raw, s.Points...)
+		}
+	}
+	if len(raw) == 0 {
+		for _, r := range g.Routes {
+			raw = append(raw, r.Points...)
+		}
+	}
+	points := make([]routePoint, 0, len(raw))
+	for _, p := range raw {
+		rp := routePoint{Lat: p.Lat, Lon: p.Lon, Ele: p.Ele}
+		if p.Time != "" {
+			t, err := time.Parse(time.RFC3339, p.Time)
+			if err == nil {
+				rp.Time = &t
+			}
+		}
+		points = append(points, rp)
+	}
+	return points, nil
+}
+
+//parseGeoJSON reads the points of the LineString and MultiLineString
+//geometries in a GeoJSON object. Times are read from the coordTimes
+//property trackers and converters commonly write.
+func parseGeoJSON(data []byte) ([]routePoint, error) {
+	var obj geoJSONObject
+	err := json.Unmarshal(data, &obj)
+	if err != nil {
+		return nil, errors.New("file is neither valid GPX nor GeoJSON")
+	}
+	var points []routePoint
+	var walk func(o geoJSONObject, times json.RawMessage) error
+	walk = func(o geoJSONObject, times json.RawMessage) error {
+		switch o.Type {
+		case "FeatureCollection":
+			for _, f := range o.Features {
+				err := walk(f, nil)
+				if err != nil {
+					return err
+				}
+			}
+		case "Feature":
+			if o.Geometry != nil {
+				return walk(*o.Geometry, o.Properties.CoordTimes)
+			}
+		case "LineString":
+			var coords [][]float64
+			var ts []string
+			if err := json.Unmarshal(o.Coordinates, &coords); err != nil {
+				return errors.New("invalid LineString coordinates")
+			}
+			json.Unmarshal(times, &ts)
+			points = append(points, geoJSONPoints(coords, ts)...)
+		case "MultiLineString":
+			var lines [][][]float64
+			var ts [][]string
+			if err := json.Unmarshal(o.Coordinates, &lines); err != nil {
+				return errors.New("invalid MultiLineString coordinates")
+			}
+			json.Unmarshal(times, &ts)
+			for i, coords := range lines {
+				var lts []string
+				if i < len(ts) {
+					lts = ts[i]
+				}
+				points = append(points, geoJSONPoints(coords, lts)...)
+			}
+		}
+		return nil
+	}
+	err = walk(obj, obj.Properties.CoordTimes)
+	if err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+//geoJSONPoints converts GeoJSON positions, which are longitude first
+func geoJSONPoints(coords [][]float64, times []string) []routePoint {
+	points := make([]routePoint, 0, len(coords))
+	for i, c := range coords {
+		if len(c) < 2 {
+			continue
+		}
+		p := routePoint{Lon: c[0], Lat: c[1]}
+		if len(c) > 2 {
+			ele := c[2]
+			p.Ele = &ele
+		}
+		if i < len(times) {
+			t, err := time.Parse(time.RFC3339, times[i])
+			if err == nil {
+				p.Time = &t
+			}
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+//haversine returns the great-circle distance in meters between two points
+func haversine(a, b routePoint) float64 {
+	rad := math.Pi / 180
+	dLat := (b.Lat - a.Lat) * rad
+	dLon := (b.Lon - a.Lon) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+//computeRouteStats works out the distance, elevation and timing of a route
+func computeRouteStats(points []routePoint) (routeStats, error) {
+	var st routeStats
+	valid := points[:0:0]
+	for _, p := range points {
+		if p.Lat < -90 || p.Lat > 90 || p.Lon < -180 || p.Lon > 180 {
+			return st, errors.New("route has coordinates out of range")
+		}
+		valid = append(valid, p)
+	}
+	if len(valid) < 2 {
+		return st, errors.New("route must have at least two points")
+	}
+	st.PointCount = len(valid)
+
+	// Distance and elevation, only counting climbs and drops past the threshold
+	var ref *float64
+	for i, p := range valid {
+		if i > 0 {
+			st.DistanceMeters += haversine(valid[i-1], p)
+		}
+		if p.Ele == nil {
+			continue
+		}
+		if ref == nil {
+			e := *p.Ele
+			ref = &e
+			continue
+		}
+		diff := *p.Ele - *ref
+		if diff >= elevationThreshold {
+			st.ElevationGainM += diff
+			*ref = *p.Ele
+		} else if diff <= -elevationThreshold {
+			st.ElevationLossM -= diff
+			*ref = *p.Ele
+		}
+	}
+
+	// Timing from the first and last timestamped points
+	var first, last *time.Time
+	for _, p := range valid {
+		if p.Time == nil {
+			continue
+		}
+		if first == nil || p.Time.Before(*first) {
+			first = p.Time
+		}
+		if last == nil || p.Time.After(*last) {
+			last = p.Time
+		}
+	}
+	if first != nil {
+		st.HasTimes = true
+		st.StartedAt = *first
+		st.DurationSeconds = int64(last.Sub(*first) / time.Second)
+	}
+
+	st.SimplifiedPoints = valid
+	if len(valid) > maxRoutePoints {
+		st.SimplifiedPoints = make([]routePoint, 0, maxRoutePoints)
+		step := float64(len(valid)-1) / float64(maxRoutePoints-1)
+		for i := 0; i < maxRoutePoints; i++ {
+			st.SimplifiedPoints = append(st.SimplifiedPoints, valid[int(math.Round(float64(i)*step))])
+		}
+	}
+	return st, nil
+}
+
+//routeGeoJSON encodes route points as a GeoJSON LineString feature
+func routeGeoJSON(points []routePoint) ([]byte, error) {
+	coords := make([][]float64, 0, len(points))
+	times := []string{}
+	for _, p := range points {
+		c := []float64{p.Lon, p.Lat}
+		if p.Ele != nil {
+			c = append(c, *p.Ele)
+		}
+		coords = append(coords, c)
+		if p.Time != nil {
+			times = append(times, p.Time.UTC().Format(time.RFC3339))
+		}
+	}
+	props := map[string]interface{}{}
+	if len(times) == len(points) {
+		props["coordTimes"] = times
+	}
+	return json.Marshal(map[string]interface{}{
+		"type":       "Feature",
+		"properties": props,
+		"geometry": map[string]interface{}{
+			"type":        "LineString",
+			"coordinates": coords,
+		},
+	})
+}
@@ -1,49 +1,74 @@
 package api
 
 import (
+	"bytes"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/badoux/checkmail"
 	"github.com/golang/gddo/httputil/header"
 	"github.com/gorilla/context"
-	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
 )
 
 func (s *server) respond(w http.ResponseWriter, r *http.Request, data interface{}, errMsg string, status int) {
 
-	// This could really be useful for a singular place to do something like
-	// check the "accepts" header in the request and respond appropriately.
-	// For now it's a simple helper.
+	// The format of the response is negotiated from the Accept header of
+	// the request, see codecs. Errors are always sent as problem details.
 	if errMsg != "" {
-		http.Error(w, errMsg, status)
+		s.respondProblem(w, r, &apiError{Status: status, Detail: errMsg})
 		return
 	}
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Add("Vary", "Accept")
+	if data == nil {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		return
+	}
+	c, ok := negotiate(r, data)
+	if !ok {
+		s.respondProblem(w, r, &apiError{
+			Status: http.StatusNotAcceptable,
+			Detail: "none of the media types in the Accept header can be sent for this resource",
+		})
+		return
+	}
+	w.Header().Set("Content-Type", c.ContentType)
 	w.WriteHeader(status)
-	if data != nil {
-		err := json.NewEncoder(w).Encode(data)
-		if err != nil {
-			s.logger.Error().Err(err).Msg("error encoding data")
-			return
-		}
+	err := c.Encode(w, data)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error encoding data")
+		return
 	}
 }
 
 func (s *server) decode(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	return s.decodeAs(w, r, v, "application/json")
+}
+
+//decodeAs decodes a JSON request body sent as the given media type, such
+//as application/merge-patch+json. A body without a Content-Type is taken
+//to be of that type.
+func (s *server) decodeAs(w http.ResponseWriter, r *http.Request, v interface{}, mediaType string) error {
 
-	// First check 'Content-Type' header and make sure we're receiving JSON
+	// First check 'Content-Type' header and make sure we're receiving JSON,
+	// or a format JSON bodies can also be sent in
+	var c codec
 	if r.Header.Get("Content-Type") != "" {
 		value, _ := header.ParseValueAndParams(r.Header, "Content-Type")
-		if value != "application/json" {
-			msg := "Content-Type header is not application/json"
+		ok := value == mediaType
+		if !ok && mediaType == mediaJSON {
+			c, ok = decoderFor(value)
+		}
+		if !ok {
+			msg := "Content-Type header is not " + mediaType
 			s.respond(w, r, nil, msg, http.StatusUnsupportedMediaType)
 			return errors.New(msg)
 		}
@@ -51,6 +76,32 @@ func (s *server) decode(w http.ResponseWriter, r *http.Request, v interface{}) e
 	// Set the max request Body size
 	r.Body = http.MaxBytesReader(w, r.Body, 1048576)
 
+	// Bodies in other formats are turned into JSON, so they are checked
+	// just like JSON ones
+	if c.Decode != nil {
+		doc, err := c.Decode(r.Body)
+		if err == nil {
+			var b []byte
+			b, err = json.Marshal(doc)
+			r.Body = io.NopCloser(bytes.NewReader(b))
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				msg := "Request body must not be empty"
+				s.respond(w, r, nil, msg, http.StatusBadRequest)
+				return errors.New(msg)
+			}
+			if err.Error() == "http: request body too large" {
+				msg := "Request body must not be larger than 1MB"
+				s.respond(w, r, nil, msg, http.StatusRequestEntityTooLarge)
+				return errors.New(msg)
+			}
+			msg := "Request body is not valid " + c.MediaTypes[0]
+			s.respond(w, r, nil, msg, http.StatusBadRequest)
+			return errors.New(msg)
+		}
+	}
+
 	// Create the decoder and attempt to decode the received JSON,
 	// returning the corresponding error if there's a failure.
 	dec := json.NewDecoder(r.Body)
@@ -72,13 +123,18 @@ func (s *server) decode(w http.ResponseWriter, r *http.Request, v interface{}) e
 
 		case errors.As(err, &unmarshalTypeError):
 			msg := fmt.Sprintf("Request body contains an invalid value for the %q field (at position %d)", unmarshalTypeError.Field, unmarshalTypeError.Offset)
-			s.respond(w, r, nil, msg, http.StatusBadRequest)
+			s.respondProblem(w, r, invalidField(unmarshalTypeError.Field, msg))
 			return errors.New(msg)
 
 		case strings.HasPrefix(err.Error(), "json: unknown field "):
 			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
 			msg := fmt.Sprintf("Request body contains unknown field %s", fieldName)
-			s.respond(w, r, nil, msg, http.StatusBadRequest)
+			s.respondProblem(w, r, &apiError{
+				Status: http.StatusBadRequest,
+				Code:   codeValidation,
+				Detail: msg,
+				Fields: []fieldError{{Field: strings.Trim(fieldName, `"`), Code: codeUnknownField, Message: "unknown field"}},
+			})
 			return errors.New(msg)
 
 		case errors.Is(err, io.EOF):
@@ -92,6 +148,7 @@ func (s *server) decode(w http.ResponseWriter, r *http.Request, v interface{}) e
 			return errors.New(msg)
 
 		default:
+			s.respond(w, r, nil, "Request body is invalid", http.StatusBadRequest)
 			return err
 		}
 	}
@@ -122,7 +179,7 @@ func (s *server) handlerLogin() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		// Create user and decode into it
-		var usr user
+		var usr userRequest
 		err := s.decode(w, r, &usr)
 		if err != nil {
 			s.logger.Error().Err(err).Msg("error decoding")
@@ -130,13 +187,14 @@ func (s *server) handlerLogin() http.HandlerFunc {
 		}
 
 		// Check for email and password
-		if usr.Email == "" || usr.Password == "" {
-			s.respond(w, r, nil, "must provide username and password", http.StatusBadRequest)
+		err = validate(usr)
+		if err != nil {
+			s.respondError(w, r, err, "error checking login")
 			return
 		}
 
 		// Check DB for user and compare passwords
-		id, err := s.dbLogin(usr.Email, usr.Password)
+		id, err := s.dbLogin(strings.TrimSpace(usr.Email), usr.Password)
 		if err != nil {
 			s.logger.Error().Err(err).Msg("error retrieving user from database")
 			s.respond(w, r, nil, "incorrect password", http.StatusUnauthorized)
@@ -174,29 +232,22 @@ func (s *server) handlerLogin() http.HandlerFunc {
 func (s *server) handlerUsersCreate() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ts := time.Now()
-		var usr user
-		err := s.decode(w, r, &usr)
+		var req userRequest
+		err := s.decode(w, r, &req)
 		if err != nil {
 			s.logger.Error().Err(err).Msg("error decoding JSON")
 			return
 		}
 
-		// Check for email and password
-		if usr.Email == "" || usr.Password == "" {
-			s.respond(w, r, nil, "must provide username and password", http.StatusBadRequest)
-			return
-		}
-
-		// Validate the email address
-		err = checkmail.ValidateFormat(usr.Email)
+		// Check the email address and password
+		err = validate(req)
 		if err != nil {
-			s.logger.Error().Err(err).Msg("error validating email")
-			s.respond(w, r, nil, "email format is invalid", http.StatusBadRequest)
+			s.respondError(w, r, err, "error checking user")
 			return
 		}
 
 		//Create hashed version of password
-		hashedPass, err := bcrypt.GenerateFromPassword([]byte(usr.Password), bcrypt.DefaultCost)
+		hashedPass, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 		if err != nil {
 			s.logger.Error().Err(err).Msg("error hashing password")
 			s.respond(w, r, nil, "internal server error", http.StatusInternalServerError)
@@ -204,15 +255,17 @@ func (s *server) handlerUsersCreate() http.HandlerFunc {
 		}
 
 		// Fill the user struct to send to the DB
-		usr.Password = string(hashedPass)
-		usr.CreatedAt = ts
-		usr.UpdatedAt = ts
+		usr := user{
+			Email:     strings.TrimSpace(req.Email),
+			Password:  string(hashedPass),
+			CreatedAt: ts,
+			UpdatedAt: ts,
+		}
 
 		//Create the user in the DB
-		id, err := s.dbUsersCreate(usr)
+		id, err := s.users.Create(usr)
 		if err != nil {
-			s.logger.Error().Err(err).Msg("error creating user in database")
-			s.respond(w, r, nil, err.Error(), http.StatusInternalServerError)
+			s.respondError(w, r, err, "error creating user")
 			return
 		}
 
@@ -222,7 +275,10 @@ func (s *server) handlerUsersCreate() http.HandlerFunc {
 			Email:     usr.Email,
 			CreatedAt: ts,
 			UpdatedAt: ts,
+			Role:      roleOwner,
 		}
+		actor := uint(id)
+		s.audit(r, auditEntry{ActorID: &actor, OwnerID: uint(id), Action: auditCreate, EntityType: auditEntityUser, EntityID: uint(id)}, nil, usrResp)
 		s.respond(w, r, usrResp, "", http.StatusCreated)
 	}
 }
@@ -243,13 +299,14 @@ func (s *server) handlerUsersGetOne() http.HandlerFunc {
 		if err != nil {
 			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
 			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
 		}
 
 		// Get user from database and respond
-		u, err := s.dbUsersGetOne(int64(id))
+		u, err := s.users.GetOne(int64(id))
 		if err != nil {
-			s.logger.Error().Err(err).Msg("error retrieving user from database")
-			s.respond(w, r, nil, err.Error(), http.StatusInternalServerError)
+			s.respondError(w, r, err, "error retrieving user")
+			return
 		}
 		s.respond(w, r, u, "", http.StatusOK)
 	}
@@ -266,12 +323,48 @@ func (s *server) handlerResetPassword() http.HandlerFunc {
 	}
 }
 
+// petListSchema is what the list of pets can be filtered and sorted by
+var petListSchema = &listSchema{
+	Fields: map[string]listField{
+		"id":         {Column: "id", Kind: listInt, Value: func(p interface{}) interface{} { return int64(p.(pet).ID) }},
+		"name":       {Column: "COALESCE(name, '')", Value: func(p interface{}) interface{} { return p.(pet).Name }},
+		"type":       {Column: "COALESCE(type, '')", Value: func(p interface{}) interface{} { return p.(pet).Type }},
+		"breed":      {Column: "COALESCE(breed, '')", Value: func(p interface{}) interface{} { return p.(pet).Breed }},
+		"gender":     {Column: "COALESCE(gender, '')", Value: func(p interface{}) interface{} { return p.(pet).Gender }},
+		"birthday":   {Column: "COALESCE(birthday, '0001-01-01')", Kind: listTime, Value: func(p interface{}) interface{} { return p.(pet).Birthday }},
+		"created_at": {Column: "created_at", Kind: listTime, Value: func(p interface{}) interface{} { return p.(pet).CreatedAt }},
+	},
+	Filters: map[string]listFilterParam{
+		"type":          {Field: "type", Op: "="},
+		"breed":         {Field: "breed", Op: "="},
+		"gender":        {Field: "gender", Op: "="},
+		"birthday_from": {Field: "birthday", Op: ">="},
+		"birthday_to":   {Field: "birthday", Op: "<"},
+	},
+	DefaultLimit: 100,
+	MaxLimit:     500,
+}
+
 // handlerPetsGetAll godoc
 // @Summary Get all pets
-// @Description Get all pets
+// @Description Get a page of the pets, oldest first unless sorted otherwise. The Link header points to the next page when there is one. Send Accept: text/csv for the page as CSV.
 // @Tags Pets
-// @Produce json
+// @Produce json,text/csv,application/msgpack,application/cbor
+// @Param type query string false "Only pets of this type, ignoring case"
+// @Param breed query string false "Only pets of this breed, ignoring case"
+// @Param gender query string false "Only pets of this gender, ignoring case"
+// @Param birthday_from query string false "Only pets born on or after this day (YYYY-MM-DD)"
+// @Param birthday_to query string false "Only pets born before this day (YYYY-MM-DD)"
+// @Param sort query string false "Comma separated fields to sort by, prefixed with - for descending: name, type, breed, gender, birthday, created_at"
+// @Param limit query int false "Pets per page, 100 by default and at most 500"
+// @Param cursor query string false "Cursor of the page, from the Link header of the previous one"
+// @Param count query bool false "Return the total number of matching pets in X-Total-Count"
+// @Param If-None-Match header string false "ETag of the list the client has"
 // @Success 200 {array} pet
+// @Success 304 {object} emptyBody
+// @Header 200 {string} ETag "Changes whenever a pet on the page is added, removed or changed"
+// @Header 200 {string} Link "URL of the next page as rel=\"next\""
+// @Header 200 {int} X-Total-Count "Number of matching pets on all pages, when asked for"
 // @Security ApiKeyAuth
 // @Router /pets [get]
 func (s *server) handlerPetsGetAll() http.HandlerFunc {
@@ -282,13 +375,38 @@ func (s *server) handlerPetsGetAll() http.HandlerFunc {
 		if err != nil {
 			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
 			s.respond(w, r, nil, "error retrieving pets", http.StatusUnauthorized)
+			return
 		}
 
-		// Get pets from the database and respond
-		pets, err := s.dbPetsGetAll(int64(id))
+		q, err := petListSchema.listQueryFromRequest(r)
 		if err != nil {
-			s.logger.Error().Err(err).Msg("error retrieving pets from database")
-			s.respond(w, r, nil, err.Error(), http.StatusInternalServerError)
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+
+		// Get a page of pets from the database and respond
+		pets, total, err := s.pets.List(int64(id), q)
+		if err != nil {
+			s.respondError(w, r, err, "error retrieving pets")
+			return
+		}
+		n := len(pets)
+		if n > q.Limit {
+			pets = pets[:q.Limit]
+		}
+		var last interface{}
+		if len(pets) > 0 {
+			last = pets[len(pets)-1]
+		}
+		q.setPageHeaders(w, r, n, last, total)
+
+		// Let clients that have the list already skip it
+		ids, versions := make([]uint, len(pets)), make([]int64, len(pets))
+		for i, p := range pets {
+			ids[i], versions[i] = p.ID, p.Version
+		}
+		if checkIfNoneMatch(w, r, listETag(ids, versions)) {
+			return
 		}
 		s.respond(w, r, pets, "", http.StatusOK)
 	}
@@ -300,34 +418,31 @@ func (s *server) handlerPetsGetAll() http.HandlerFunc {
 // @Tags Pets
 // @Produce json
 // @Param PetID path int true "Get Pet"
+// @Param If-None-Match header string false "ETag of the version the client has"
 // @Success 200 {object} pet
+// @Success 304 {object} emptyBody
+// @Header 200 {string} ETag "Version of the pet"
 // @Security ApiKeyAuth
 // @Router /pets/{PetID} [get]
 func (s *server) handlerPetsGetOne() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		// Get user ID from the authenticated context
-		id, err := userIDFromRequest(r)
-		if err != nil {
-			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
-			s.respond(w, r, nil, "error retrieving pet", http.StatusUnauthorized)
-		}
-
-		// Get URL params
-		params := mux.Vars(r)
-		petIDStr := params["id"]
-		if petIDStr == "" {
-			s.respond(w, r, nil, "must provide a pet id", http.StatusBadRequest)
+		// Get the user and pet IDs from the request
+		id, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
 		}
-		petIDInt, _ := strconv.Atoi(petIDStr)
 
 		// Get pet from db
-		pet, err := s.dbPetsGetOne(id, int64(petIDInt))
+		pet, err := s.pets.GetOne(id, petID)
 		if err != nil {
-			s.logger.Error().Err(err).Msg("error retrieving pet from database")
-			s.respond(w, r, nil, err.Error(), http.StatusInternalServerError)
+			s.respondError(w, r, err, "error retrieving pet")
+			return
+		}
+		// Respond with pet record, unless the client has this version
+		if checkIfNoneMatch(w, r, versionETag(pet.Version)) {
+			return
 		}
-		// Respond with pet record
 		s.respond(w, r, pet, "", http.StatusOK)
 	}
 }
@@ -352,6 +467,7 @@ func (s *server) handlerPetsCreate() http.HandlerFunc {
 		if err != nil {
 			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
 			s.respond(w, r, nil, "error creating pet", http.StatusUnauthorized)
+			return
 		}
 
 		var pet pet
@@ -360,34 +476,58 @@ func (s *server) handlerPetsCreate() http.HandlerFunc {
 		err = s.decode(w, r, &pet)
 		if err != nil {
 			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
 		}
 		pet.CreatedAt = ts
 		pet.UpdatedAt = ts
 
+		// Check the fields, microchip, tattoo and license tag
+		err = validatePet(&pet)
+		if err != nil {
+			s.respondError(w, r, err, "error checking pet")
+			return
+		}
+
+		// Check the type, breed and gender against the catalog
+		err = s.catalog.snapshot().normalizePet(&pet)
+		if err != nil {
+			s.respondError(w, r, err, "error checking pet")
+			return
+		}
+
+		// Check the sire, dam and litter
+		if !s.checkPetParents(w, r, userID, &pet) {
+			return
+		}
+
 		// Create pet in the db
-		id, err := s.dbPetsCreate(pet, userID)
+		id, err := s.pets.Create(pet, userID)
 		if err != nil {
-			s.logger.Error().Err(err).Msg("error creating pet in databse")
-			s.respond(w, r, nil, "error creating pet", http.StatusInternalServerError)
+			s.respondError(w, r, err, "error creating pet")
 			return
 		}
 
 		// Set ID's and respond
 		pet.UserID = uint(userID)
 		pet.ID = uint(id)
+		pet.Version = 1
+		w.Header().Set("ETag", versionETag(pet.Version))
+		s.audit(r, auditEntry{OwnerID: pet.UserID, Action: auditCreate, EntityType: auditEntityPet, EntityID: pet.ID}, nil, pet)
 		s.respond(w, r, pet, "", http.StatusCreated)
 	}
 }
 
 // handlerPetsUpdate godoc
 // @Summary Update a pet
-// @Description Update a pet
+// @Description Update a pet. Pass the ETag of the pet as If-Match to only update it if nobody changed it since.
 // @Tags Pets
 // @Accept json
 // @Produce json
 // @Param PetID path int true "Pet ID"
+// @Param If-Match header string false "ETag of the version the update is based on"
 // @Param pet body pet true "Updated Pet"
 // @Success 200 {object} pet
+// @Header 200 {string} ETag "New version of the pet"
 // @Security ApiKeyAuth
 // @Router /pets/{PetID} [put]
 func (s *server) handlerPetsUpdate() http.HandlerFunc {
@@ -395,75 +535,254 @@ func (s *server) handlerPetsUpdate() http.HandlerFunc {
 
 		ts := time.Now()
 
-		// Get user ID from the authenticated context
-		id, err := userIDFromRequest(r)
-		if err != nil {
-			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
-			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
-		}
-
-		// Get URL params
-		params := mux.Vars(r)
-		petIDStr := params["id"]
-		if petIDStr == "" {
-			s.respond(w, r, nil, "must provide a pet id", http.StatusBadRequest)
+		// Get the user and pet IDs from the request
+		id, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
 		}
-		petIDInt, _ := strconv.Atoi(petIDStr)
 
 		var pet pet
 
 		//Get JSON body and decode into pet
-		err = s.decode(w, r, pet)
+		err := s.decode(w, r, &pet)
 		if err != nil {
 			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
 		}
 		pet.UpdatedAt = ts
-		pet.ID = uint(petIDInt)
+		pet.ID = uint(petID)
 
-		//Update pet in the db
-		err = s.dbPetsUpdate(pet, id)
+		// Keep the pet as it was for the audit log
+		old, err := s.pets.GetOne(id, petID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "pet not found", http.StatusNotFound)
+			return
+		}
 		if err != nil {
-			s.logger.Error().Err(err).Msg("error updating pet in databse")
+			s.logger.Error().Err(err).Msg("error retrieving pet from database")
 			s.respond(w, r, nil, "error updating pet", http.StatusInternalServerError)
 			return
 		}
 
-		//Respond with the updated pet record
-		s.respond(w, r, pet, "", http.StatusOK)
+		// Only overwrite the version the client read when it says which
+		version, ok := s.checkIfMatch(w, r, old.Version)
+		if !ok {
+			return
+		}
+		pet.Version = version
+
+		s.savePet(w, r, id, old, pet)
+	}
+}
+
+// petImmutableFields are the fields of a pet a patch can't change
+var petImmutableFields = []string{"pet_id", "user_id", "created_at", "updated_at", "deleted_at", "version"}
+
+// handlerPetsPatch godoc
+// @Summary Patch a pet
+// @Description Change some fields of a pet with a JSON Merge Patch (application/merge-patch+json) or a JSON Patch (application/json-patch+json). pet_id, user_id, created_at, updated_at and version can't be changed. Pass the ETag of the pet as If-Match to only patch it if nobody changed it since.
+// @Tags Pets
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param If-Match header string false "ETag of the version the patch is based on"
+// @Param patch body object true "Merge patch or JSON Patch operations"
+// @Success 200 {object} pet
+// @Header 200 {string} ETag "New version of the pet"
+// @Security ApiKeyAuth
+// @Router /pets/{PetID} [patch]
+func (s *server) handlerPetsPatch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		old, err := s.pets.GetOne(userID, petID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "pet not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving pet from database")
+			s.respond(w, r, nil, "error updating pet", http.StatusInternalServerError)
+			return
+		}
+		if _, ok := s.checkIfMatch(w, r, old.Version); !ok {
+			return
+		}
+
+		// Apply the patch to the pet as clients see it
+		b, err := json.Marshal(old)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error encoding pet")
+			s.respond(w, r, nil, "error updating pet", http.StatusInternalServerError)
+			return
+		}
+		var doc, patched interface{}
+		err = json.Unmarshal(b, &doc)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding pet")
+			s.respond(w, r, nil, "error updating pet", http.StatusInternalServerError)
+			return
+		}
+		mediaType, _ := header.ParseValueAndParams(r.Header, "Content-Type")
+		switch mediaType {
+		case mediaMergePatch:
+			var patch interface{}
+			if err := s.decodeAs(w, r, &patch, mediaMergePatch); err != nil {
+				s.logger.Error().Err(err).Msg("error decoding JSON")
+				return
+			}
+			patched = mergePatch(doc, patch)
+		case mediaJSONPatch:
+			var ops []jsonPatchOp
+			if err := s.decodeAs(w, r, &ops, mediaJSONPatch); err != nil {
+				s.logger.Error().Err(err).Msg("error decoding JSON")
+				return
+			}
+			patched, err = applyJSONPatch(doc, ops)
+			if err == errPatchTestFailed {
+				s.respondProblem(w, r, &apiError{Status: http.StatusConflict, Code: codePatchTestFailed, Detail: err.Error()})
+				return
+			}
+			if err != nil {
+				s.respondProblem(w, r, &apiError{Status: http.StatusBadRequest, Code: codeInvalidPatch, Detail: err.Error()})
+				return
+			}
+		default:
+			s.respond(w, r, nil, "Content-Type header is not "+mediaMergePatch+" or "+mediaJSONPatch, http.StatusUnsupportedMediaType)
+			return
+		}
+
+		// The patched pet must still be a pet, with its identity intact
+		fields, ok := patched.(map[string]interface{})
+		if !ok {
+			s.respond(w, r, nil, "patched pet must be an object", http.StatusBadRequest)
+			return
+		}
+		var before map[string]interface{}
+		err = json.Unmarshal(b, &before)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding pet")
+			s.respond(w, r, nil, "error updating pet", http.StatusInternalServerError)
+			return
+		}
+		for _, f := range petImmutableFields {
+			if !reflect.DeepEqual(before[f], fields[f]) {
+				s.respond(w, r, nil, f+" can't be changed", http.StatusBadRequest)
+				return
+			}
+		}
+		b, err = json.Marshal(patched)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error encoding patched pet")
+			s.respond(w, r, nil, "error updating pet", http.StatusInternalServerError)
+			return
+		}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		var p pet
+		err = dec.Decode(&p)
+		if err != nil {
+			s.respond(w, r, nil, "patched pet is invalid: "+strings.TrimPrefix(err.Error(), "json: "), http.StatusBadRequest)
+			return
+		}
+
+		// The patch was made to this version, so it is only saved at it
+		p.UpdatedAt = time.Now()
+		p.Version = old.Version
+		s.savePet(w, r, userID, old, p)
 	}
 }
 
+//savePet checks the changes to a pet and saves them, responding with the
+//updated pet. The update is only made at p.Version when it is set.
+func (s *server) savePet(w http.ResponseWriter, r *http.Request, userID int64, old, p pet) {
+
+	// Check the fields, microchip, tattoo and license tag
+	err := validatePet(&p)
+	if err != nil {
+		s.respondError(w, r, err, "error checking pet")
+		return
+	}
+
+	// Check the type, breed and gender against the catalog
+	err = s.catalog.snapshot().normalizePet(&p)
+	if err != nil {
+		s.respondError(w, r, err, "error checking pet")
+		return
+	}
+
+	// Check the sire, dam and litter
+	if !s.checkPetParents(w, r, userID, &p) {
+		return
+	}
+
+	//Update pet in the db
+	p.Version, err = s.pets.Update(p, userID)
+	if err == errVersionConflict {
+		s.respondPreconditionFailed(w, r, 0)
+		return
+	}
+	if err != nil {
+		s.respondError(w, r, err, "error updating pet")
+		return
+	}
+
+	//Respond with the updated pet record
+	p.UserID = old.UserID
+	p.CreatedAt = old.CreatedAt
+	w.Header().Set("ETag", versionETag(p.Version))
+	s.audit(r, auditEntry{OwnerID: old.UserID, Action: auditUpdate, EntityType: auditEntityPet, EntityID: old.ID}, old, p)
+	s.respond(w, r, p, "", http.StatusOK)
+}
+
 // handlerPetsDelete godoc
 // @Summary Delete a pet
-// @Description Delete a pet
+// @Description Move a pet and its records to the trash, they are purged for good once the retention period is over. Pass the ETag of the pet as If-Match to only delete it if nobody changed it since.
 // @Tags Pets
 // @Param PetID path int true "Deleted Pet"
+// @Param If-Match header string false "ETag of the version the client means to delete"
 // @Success 200 {object} emptyBody
 // @Security ApiKeyAuth
 // @Router /pets/{PetID} [delete]
 func (s *server) handlerPetsDelete() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		// Get user ID from the authenticated context
-		id, err := userIDFromRequest(r)
-		if err != nil {
-			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
-			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+		// Get the user and pet IDs from the request
+		id, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
 		}
 
-		// Get URL params
-		params := mux.Vars(r)
-		petIDStr := params["id"]
-		if petIDStr == "" {
-			s.respond(w, r, nil, "must provide a pet id", http.StatusBadRequest)
+		// Keep the pet as it was for the audit log
+		old, err := s.pets.GetOne(id, petID)
+		if err != nil {
+			s.respondError(w, r, err, "error deleting pet")
+			return
+		}
+		version, ok := s.checkIfMatch(w, r, old.Version)
+		if !ok {
+			return
 		}
-		petIDInt, _ := strconv.Atoi(petIDStr)
 
-		//Delete the pet from the db
-		rows, err := s.dbPetsDelete(int64(petIDInt), id)
-		if err != nil || rows == 0 {
-			s.respond(w, r, nil, "could not delete pet", http.StatusBadRequest)
+		//Move the pet to the trash, its files stay until it is purged
+		ts := time.Now()
+		rows, err := s.pets.Trash(petID, id, version, ts)
+		if err == errVersionConflict {
+			s.respondPreconditionFailed(w, r, 0)
+			return
+		}
+		if err == nil && rows == 0 {
+			err = sql.ErrNoRows
+		}
+		if err != nil {
+			s.respondError(w, r, err, "error deleting pet")
+			return
 		}
+		trashed := old
+		trashed.DeletedAt = &ts
+		s.audit(r, auditEntry{OwnerID: old.UserID, Action: auditDelete, EntityType: auditEntityPet, EntityID: old.ID}, old, trashed)
 
 		s.respond(w, r, nil, "", http.StatusOK)
 	}
@@ -0,0 +1,1216 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	claimDraft     = "draft"
+	claimSubmitted = "submitted"
+	claimApproved  = "approved"
+	claimPaid      = "paid"
+	claimDenied    = "denied"
+)
+
+// claimTransitions lists the statuses a claim may move to from each status.
+// A denied claim may be resubmitted, e.g. on appeal.
+var claimTransitions = map[string][]string{
+	claimDraft:     {claimSubmitted},
+	claimSubmitted: {claimApproved, claimDenied},
+	claimApproved:  {claimPaid},
+	claimDenied:    {claimSubmitted},
+}
+
+type insurancePolicy struct {
+	ID                   uint       `json:"policy_id" example:"1"`
+	PetID                uint       `json:"pet_id" example:"1"`
+	UserID               uint       `json:"user_id" example:"1"`
+	Insurer              string     `json:"insurer" example:"Healthy Paws"`
+	PolicyNumber         string     `json:"policy_number" example:"HP-1234567"`
+	CoverageStart        time.Time  `json:"coverage_start" example:"2019-06-01T00:00:00Z"`
+	CoverageEnd          *time.Time `json:"coverage_end,omitempty" example:"2022-06-01T00:00:00Z"`
+	Currency             string     `json:"currency" example:"USD"`
+	DeductibleMinor      int64      `json:"deductible_minor" example:"25000"`
+	ReimbursementPercent int        `json:"reimbursement_percent" example:"80"`
+	AnnualLimitMinor     *int64     `json:"annual_limit_minor,omitempty" example:"500000"`
+	CreatedAt            time.Time  `json:"created_at" example:"2019-11-09T21:21:46+00:00"`
+	UpdatedAt            time.Time  `json:"updated_at" example:"2019-11-09T21:21:46+00:00"`
+}
+
+// policyRequest holds amounts in integer minor units of the currency. The
+// deductible and annual limit apply per policy year, counted from the
+// coverage start. Leave annual_limit_minor out for an unlimited policy and
+// coverage_end out for a policy that renews indefinitely.
+type policyRequest struct {
+	Insurer              string     `json:"insurer" example:"Healthy Paws" validate:"required,max=100"`
+	PolicyNumber         string     `json:"policy_number" example:"HP-1234567" validate:"required,max=64"`
+	CoverageStart        time.Time  `json:"coverage_start" example:"2019-06-01T00:00:00Z" validate:"required"`
+	CoverageEnd          *time.Time `json:"coverage_end" example:"2022-06-01T00:00:00Z"`
+	Currency             string     `json:"currency" example:"USD" validate:"required"`
+	DeductibleMinor      int64      `json:"deductible_minor" example:"25000" validate:"min=0"`
+	ReimbursementPercent int        `json:"reimbursement_percent" example:"80" validate:"required,min=1,max=100"`
+	AnnualLimitMinor     *int64     `json:"annual_limit_minor" example:"500000"`
+}
+
+//checkFields checks the rules of a policy a tag can't express: the
+//coverage period, the currency and an annual limit given as zero
+func (req policyRequest) checkFields() []fieldError {
+	errs := []fieldError{}
+	if req.CoverageEnd != nil && !req.CoverageStart.IsZero() && !req.CoverageEnd.After(req.CoverageStart) {
+		errs = append(errs, fieldError{Field: "coverage_end", Code: codeOutOfRange, Message: "coverage_end must be after coverage_start"})
+	}
+	if req.Currency != "" && !validCurrency(req.Currency) {
+		errs = append(errs, fieldError{Field: "currency", Code: codeInvalidField, Message: "currency must be an ISO 4217 currency code"})
+	}
+	if req.AnnualLimitMinor != nil && *req.AnnualLimitMinor <= 0 {
+		errs = append(errs, fieldError{Field: "annual_limit_minor", Code: codeOutOfRange, Message: "annual_limit_minor must be positive"})
+	}
+	return errs
+}
+
+type insurancePolicies []insurancePolicy
+
+type insuranceClaim struct {
+	ID               uint       `json:"claim_id" example:"1"`
+	PolicyID         uint       `json:"policy_id" example:"1"`
+	PetID            uint       `json:"pet_id" example:"1"`
+	UserID           uint       `json:"user_id" example:"1"`
+	Status           string     `json:"status" example:"submitted"`
+	IncidentDate     time.Time  `json:"incident_date" example:"2019-11-09T00:00:00Z"`
+	ClaimedMinor     int64      `json:"claimed_minor" example:"12550"`
+	ApprovedMinor    *int64     `json:"approved_minor,omitempty" example:"12000"`
+	PaidMinor        *int64     `json:"paid_minor,omitempty" example:"9600"`
+	InsurerReference string     `json:"insurer_reference,omitempty" example:"CLM-998877"`
+	Notes            string     `json:"notes" example:"Ear infection"`
+	ExpenseIDs       []uint     `json:"expense_ids" example:"1,2"`
+	RecordIDs        []uint     `json:"record_ids" example:"4"`
+	SubmittedAt      *time.Time `json:"submitted_at,omitempty" example:"2019-11-10T09:00:00+00:00"`
+	DecidedAt        *time.Time `json:"decided_at,omitempty" example:"2019-11-20T09:00:00+00:00"`
+	PaidAt           *time.Time `json:"paid_at,omitempty" example:"2019-11-25T09:00:00+00:00"`
+	CreatedAt        time.Time  `json:"created_at" example:"2019-11-09T21:21:46+00:00"`
+	UpdatedAt        time.Time  `json:"updated_at" example:"2019-11-09T21:21:46+00:00"`
+}
+
+// claimRequest creates or edits a draft claim. When claimed_minor is left
+// out it is the sum of the linked expenses, which must then all be in the
+// currency of the policy.
+type claimRequest struct {
+	PolicyID     uint      `json:"policy_id" example:"1" validate:"required"`
+	IncidentDate time.Time `json:"incident_date" example:"2019-11-09T00:00:00Z" validate:"required"`
+	ClaimedMinor int64     `json:"claimed_minor" example:"12550" validate:"min=0"`
+	Notes        string    `json:"notes" example:"Ear infection" validate:"max=2000"`
+	ExpenseIDs   []uint    `json:"expense_ids" example:"1,2"`
+	RecordIDs    []uint    `json:"record_ids" example:"4"`
+}
+
+//checkFields checks that a claim has an amount or an expense to claim
+func (req claimRequest) checkFields() []fieldError {
+	errs := []fieldError{}
+	if req.ClaimedMinor == 0 && len(req.ExpenseIDs) == 0 {
+		errs = append(errs, fieldError{Field: "claimed_minor", Code: codeRequired, Message: "must provide claimed_minor or link at least one expense"})
+	}
+	return errs
+}
+
+// claimStatusRequest moves a claim along its workflow. approved_minor is the
+// amount the insurer accepted as eligible before the deductible and
+// reimbursement percentage, and is required to approve. paid_minor is the
+// amount actually reimbursed, and is required to mark a claim paid.
+type claimStatusRequest struct {
+	Status           string `json:"status" example:"approved"`
+	ApprovedMinor    *int64 `json:"approved_minor" example:"12000"`
+	PaidMinor        *int64 `json:"paid_minor" example:"9600"`
+	InsurerReference string `json:"insurer_reference" example:"CLM-998877"`
+}
+
+type insuranceClaims []insuranceClaim
+
+// policyYearSummary shows how much of the deductible and annual limit of a
+// policy year are used. Approved claims count with their expected
+// reimbursement, paid claims with what was actually paid.
+type policyYearSummary struct {
+	PolicyID                 uint   `json:"policy_id" example:"1"`
+	YearStart                string `json:"year_start" example:"2019-06-01"`
+	YearEnd                  string `json:"year_end" example:"2020-06-01"`
+	Currency                 string `json:"currency" example:"USD"`
+	DeductibleMinor          int64  `json:"deductible_minor" example:"25000"`
+	DeductibleMetMinor       int64  `json:"deductible_met_minor" example:"12000"`
+	DeductibleRemainingMinor int64  `json:"deductible_remaining_minor" example:"13000"`
+	AnnualLimitMinor         *int64 `json:"annual_limit_minor,omitempty" example:"500000"`
+	ReimbursedMinor          int64  `json:"reimbursed_minor" example:"0"`
+	LimitRemainingMinor      *int64 `json:"limit_remaining_minor,omitempty" example:"500000"`
+	PendingMinor             int64  `json:"pending_minor" example:"0"`
+	Claims                   int    `json:"claims" example:"1"`
+}
+
+const policyColumns = "id, pet_id, user_id, insurer, policy_number, coverage_start, coverage_end, currency, deductible_minor, reimbursement_percent, annual_limit_minor, created_at, updated_at"
+
+const claimColumns = "id, policy_id, pet_id, user_id, status, incident_date, claimed_minor, approved_minor, paid_minor, insurer_reference, notes, submitted_at, decided_at, paid_at, created_at, updated_at"
+
+//scanPolicy scans a row selected with policyColumns
+func scanPolicy(row interface{ Scan(...interface{}) error }) (insurancePolicy, error) {
+	var p insurancePolicy
+	var end sql.NullTime
+	var limit sql.NullInt64
+	err := row.Scan(&p.ID, &p.PetID, &p.UserID, &p.Insurer, &p.PolicyNumber, &p.CoverageStart, &end, &p.Currency,
+		&p.DeductibleMinor, &p.ReimbursementPercent, &limit, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return p, err
+	}
+	if end.Valid {
+		p.CoverageEnd = &end.Time
+	}
+	if limit.Valid {
+		p.AnnualLimitMinor = &limit.Int64
+	}
+	return p, nil
+}
+
+//scanClaim scans a row selected with claimColumns
+func scanClaim(row interface{ Scan(...interface{}) error }) (insuranceClaim, error) {
+	var c insuranceClaim
+	var approved, paid sql.NullInt64
+	var ref, notes sql.NullString
+	var submitted, decided, paidAt sql.NullTime
+	err := row.Scan(&c.ID, &c.PolicyID, &c.PetID, &c.UserID, &c.Status, &c.IncidentDate, &c.ClaimedMinor, &approved, &paid,
+		&ref, &notes, &submitted, &decided, &paidAt, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return c, err
+	}
+	if approved.Valid {
+		c.ApprovedMinor = &approved.Int64
+	}
+	if paid.Valid {
+		c.PaidMinor = &paid.Int64
+	}
+	if submitted.Valid {
+		c.SubmittedAt = &submitted.Time
+	}
+	if decided.Valid {
+		c.DecidedAt = &decided.Time
+	}
+	if paidAt.Valid {
+		c.PaidAt = &paidAt.Time
+	}
+	c.InsurerReference = ref.String
+	c.Notes = notes.String
+	c.ExpenseIDs = []uint{}
+	c.RecordIDs = []uint{}
+	return c, nil
+}
+
+//validatePolicy normalizes and checks a policy request
+func validatePolicy(req *policyRequest) error {
+	req.Insurer = strings.TrimSpace(req.Insurer)
+	req.PolicyNumber = strings.TrimSpace(req.PolicyNumber)
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	return validate(req)
+}
+
+//validateClaim normalizes and checks a claim request
+func validateClaim(req *claimRequest) error {
+	req.Notes = strings.TrimSpace(req.Notes)
+	req.ExpenseIDs = uniqueIDs(req.ExpenseIDs)
+	req.RecordIDs = uniqueIDs(req.RecordIDs)
+	return validate(req)
+}
+
+//uniqueIDs sorts IDs and drops duplicates
+func uniqueIDs(ids []uint) []uint {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	out := []uint{}
+	for i, id := range ids {
+		if i == 0 || id != ids[i-1] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+//idList builds numbered placeholders for IDs, starting at $first, for an IN
+//list. Unlike an array parameter it works with sqlite too.
+func idList(ids []uint, first int) (string, []interface{}) {
+	ph := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		ph[i] = "$" + strconv.Itoa(first+i)
+		args[i] = int64(id)
+	}
+	return strings.Join(ph, ","), args
+}
+
+//canTransition reports whether a claim may move from one status to another
+func canTransition(from, to string) bool {
+	for _, s := range claimTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+//policyYear returns the policy year containing t, counted in whole years
+//from the coverage start
+func policyYear(p insurancePolicy, t time.Time) (start, end time.Time) {
+	cs := p.CoverageStart
+	years := t.Year() - cs.Year()
+	start = cs.AddDate(years, 0, 0)
+	if start.After(t) {
+		start = cs.AddDate(years-1, 0, 0)
+	}
+	if start.Before(cs) {
+		start = cs
+	}
+	return start, start.AddDate(1, 0, 0)
+}
+
+//summarizePolicyYear applies the deductible, reimbursement percentage and
+//annual limit to the claims of a policy year in incident date order
+func summarizePolicyYear(p insurancePolicy, start, end time.Time, claims []insuranceClaim) policyYearSummary {
+	sum := policyYearSummary{
+		PolicyID:         p.ID,
+		YearStart:        start.Format("2006-01-02"),
+		YearEnd:          end.Format("2006-01-02"),
+		Currency:         p.Currency,
+		DeductibleMinor:  p.DeductibleMinor,
+		AnnualLimitMinor: p.AnnualLimitMinor,
+	}
+	sort.Slice(claims, func(i, j int) bool {
+		if claims[i].IncidentDate.Equal(claims[j].IncidentDate) {
+			return claims[i].ID < claims[j].ID
+		}
+		return claims[i].IncidentDate.Before(claims[j].IncidentDate)
+	})
+	for _, c := range claims {
+		switch c.Status {
+		case claimSubmitted:
+			sum.PendingMinor += c.ClaimedMinor
+		case claimApproved, claimPaid:
+			eligible := c.ClaimedMinor
+			if c.ApprovedMinor != nil {
+				eligible = *c.ApprovedMinor
+			}
+			applied := p.DeductibleMinor - sum.DeductibleMetMinor
+			if applied > eligible {
+				applied = eligible
+			}
+			sum.DeductibleMetMinor += applied
+			reimbursed := (eligible - applied) * int64(p.ReimbursementPercent) / 100
+			if c.Status == claimPaid && c.PaidMinor != nil {
+				reimbursed = *c.PaidMinor
+			}
+			if p.AnnualLimitMinor != nil && sum.ReimbursedMinor+reimbursed > *p.AnnualLimitMinor {
+				reimbursed = *p.AnnualLimitMinor - sum.ReimbursedMinor
+			}
+			if reimbursed < 0 {
+				reimbursed = 0
+			}
+			sum.ReimbursedMinor += reimbursed
+		default:
+			continue
+		}
+		sum.Claims++
+	}
+	sum.DeductibleRemainingMinor = p.DeductibleMinor - sum.DeductibleMetMinor
+	if p.AnnualLimitMinor != nil {
+		remaining := *p.AnnualLimitMinor - sum.ReimbursedMinor
+		sum.LimitRemainingMinor = &remaining
+	}
+	return sum
+}
+
+//dbPoliciesGetAll returns the insurance policies of a pet owned by a user
+func (s *server) dbPoliciesGetAll(userID, petID int64) ([]insurancePolicy, error) {
+	rows, err := s.db.Query("SELECT "+policyColumns+" FROM insurance_policies WHERE user_id = $1 AND pet_id = $2 AND deleted_at IS NULL ORDER BY coverage_start DESC, id DESC", userID, petID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []insurancePolicy{}
+	for rows.Next() {
+		p, err := scanPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+//dbPoliciesGetOne returns a single insurance policy of a pet owned by a user
+func (s *server) dbPoliciesGetOne(userID, petID, policyID int64) (insurancePolicy, error) {
+	row := s.db.QueryRow("SELECT "+policyColumns+" FROM insurance_policies WHERE user_id = $1 AND pet_id = $2 AND id = $3 AND deleted_at IS NULL", userID, petID, policyID)
+	return scanPolicy(row)
+}
+
+//dbPoliciesCreate stores a new insurance policy
+func (s *server) dbPoliciesCreate(p insurancePolicy) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`INSERT INTO insurance_policies(pet_id, user_id, insurer, policy_number, coverage_start, coverage_end, currency,
+		deductible_minor, reimbursement_percent, annual_limit_minor, created_at, updated_at)
+		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12) RETURNING id`,
+		p.PetID, p.UserID, p.Insurer, p.PolicyNumber, p.CoverageStart, p.CoverageEnd, p.Currency,
+		p.DeductibleMinor, p.ReimbursementPercent, p.AnnualLimitMinor, p.CreatedAt, p.UpdatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+//dbPoliciesUpdate updates an insurance policy of a pet owned by a user
+func (s *server) dbPoliciesUpdate(p insurancePolicy) (int64, error) {
+	res, err := s.db.Exec(`UPDATE insurance_policies SET insurer = $1, policy_number = $2, coverage_start = $3, coverage_end = $4, currency = $5,
+		deductible_minor = $6, reimbursement_percent = $7, annual_limit_minor = $8, updated_at = $9 WHERE id = $10 AND user_id = $11 AND pet_id = $12 AND deleted_at IS NULL`,
+		p.Insurer, p.PolicyNumber, p.CoverageStart, p.CoverageEnd, p.Currency,
+		p.DeductibleMinor, p.ReimbursementPercent, p.AnnualLimitMinor, p.UpdatedAt, p.ID, p.UserID, p.PetID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbPoliciesDelete deletes an insurance policy of a pet owned by a user,
+//along with its claims
+func (s *server) dbPoliciesDelete(userID, petID, policyID int64) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM insurance_policies WHERE user_id = $1 AND pet_id = $2 AND id = $3 AND deleted_at IS NULL", userID, petID, policyID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbClaimsQuery returns the claims matching a where clause, with their links
+func (s *server) dbClaimsQuery(where string, args ...interface{}) ([]insuranceClaim, error) {
+	rows, err := s.db.Query("SELECT "+claimColumns+" FROM insurance_claims WHERE deleted_at IS NULL AND "+where+" ORDER BY incident_date DESC, id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	claims := []insuranceClaim{}
+	byID := map[uint]int{}
+	for rows.Next() {
+		c, err := scanClaim(rows)
+		if err != nil {
+			return nil, err
+		}
+		byID[c.ID] = len(claims)
+		claims = append(claims, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(claims) == 0 {
+		return claims, nil
+	}
+
+	// Fetch the links of every claim at once
+	ids := make([]uint, 0, len(claims))
+	for _, c := range claims {
+		ids = append(ids, c.ID)
+	}
+	in, args := idList(ids, 1)
+	links, err := s.db.Query(`SELECT claim_id, expense_id, NULL FROM insurance_claim_expenses WHERE claim_id IN (`+in+`)
+		UNION ALL SELECT claim_id, NULL, record_id FROM insurance_claim_records WHERE claim_id IN (`+in+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer links.Close()
+	for links.Next() {
+		var claimID uint
+		var expenseID, recordID sql.NullInt64
+		err := links.Scan(&claimID, &expenseID, &recordID)
+		if err != nil {
+			return nil, err
+		}
+		c := &claims[byID[claimID]]
+		if expenseID.Valid {
+			c.ExpenseIDs = append(c.ExpenseIDs, uint(expenseID.Int64))
+		}
+		if recordID.Valid {
+			c.RecordIDs = append(c.RecordIDs, uint(recordID.Int64))
+		}
+	}
+	return claims, links.Err()
+}
+
+//dbClaimsGetAll returns the insurance claims of a pet owned by a user
+func (s *server) dbClaimsGetAll(userID, petID int64) ([]insuranceClaim, error) {
+	return s.dbClaimsQuery("user_id = $1 AND pet_id = $2", userID, petID)
+}
+
+//dbClaimsGetOne returns a single insurance claim of a pet owned by a user
+func (s *server) dbClaimsGetOne(userID, petID, claimID int64) (insuranceClaim, error) {
+	claims, err := s.dbClaimsQuery("user_id = $1 AND pet_id = $2 AND id = $3", userID, petID, claimID)
+	if err != nil {
+		return insuranceClaim{}, err
+	}
+	if len(claims) == 0 {
+		return insuranceClaim{}, sql.ErrNoRows
+	}
+	return claims[0], nil
+}
+
+//dbClaimsGetPolicyYear returns the claims of a policy with an incident
+//date in the given policy year
+func (s *server) dbClaimsGetPolicyYear(policyID int64, start, end time.Time) ([]insuranceClaim, error) {
+	return s.dbClaimsQuery("policy_id = $1 AND incident_date >= $2 AND incident_date < $3", policyID, start, end)
+}
+
+//dbClaimsCheckLinks checks that expenses and records belong to a pet
+//owned by a user, returning the sum of the expenses in the given currency
+//and whether all of them are in that currency
+func (s *server) dbClaimsCheckLinks(userID, petID int64, currency string, expenseIDs, recordIDs []uint) (total int64, sameCurrency, ok bool, err error) {
+	var n int
+	var mismatched int
+	if len(expenseIDs) > 0 {
+		in, ids := idList(expenseIDs, 4)
+		err = s.db.QueryRow(`SELECT count(*), COALESCE(sum(CASE WHEN currency = $3 THEN amount_minor ELSE 0 END), 0),
+			COALESCE(sum(CASE WHEN currency != $3 THEN 1 ELSE 0 END), 0)
+			FROM expenses WHERE user_id = $1 AND pet_id = $2 AND id IN (`+in+`) AND deleted_at IS NULL`,
+			append([]interface{}{userID, petID, currency}, ids...)...).Scan(&n, &total, &mismatched)
+		if err != nil || n != len(expenseIDs) {
+			return 0, false, false, err
+		}
+	}
+	if len(recordIDs) > 0 {
+		in, ids := idList(recordIDs, 3)
+		err = s.db.QueryRow("SELECT count(*) FROM medical_records WHERE user_id = $1 AND pet_id = $2 AND id IN ("+in+") AND deleted_at IS NULL",
+			append([]interface{}{userID, petID}, ids...)...).Scan(&n)
+		if err != nil || n != len(recordIDs) {
+			return 0, false, false, err
+		}
+	}
+	return total, mismatched == 0, true, nil
+}
+
+//dbClaimsSave creates a claim, or updates a draft one when it has an ID,
+//and replaces its links in a single transaction
+func (s *server) dbClaimsSave(c insuranceClaim) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id := int64(c.ID)
+	if id == 0 {
+		err = tx.QueryRow(`INSERT INTO insurance_claims(policy_id, pet_id, user_id, status, incident_date, claimed_minor, notes, created_at, updated_at)
+			VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING id`,
+			c.PolicyID, c.PetID, c.UserID, c.Status, c.IncidentDate, c.ClaimedMinor, c.Notes, c.CreatedAt, c.UpdatedAt).Scan(&id)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		res, err := tx.Exec(`UPDATE insurance_claims SET policy_id = $1, incident_date = $2, claimed_minor = $3, notes = $4, updated_at = $5
+			WHERE id = $6 AND user_id = $7 AND pet_id = $8 AND status = $9 AND deleted_at IS NULL`,
+			c.PolicyID, c.IncidentDate, c.ClaimedMinor, c.Notes, c.UpdatedAt, id, c.UserID, c.PetID, claimDraft)
+		if err != nil {
+			return 0, err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil || rows == 0 {
+			return 0, err
+		}
+		for _, q := range []string{"DELETE FROM insurance_claim_expenses WHERE claim_id = $1", "DELETE FROM insurance_claim_records WHERE claim_id = $1"} {
+			_, err = tx.Exec(q, id)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	for _, e := range c.ExpenseIDs {
+		_, err = tx.Exec("INSERT INTO insurance_claim_expenses(claim_id, expense_id) VALUES($1,$2)", id, e)
+		if err != nil {
+			return 0, err
+		}
+	}
+	for _, m := range c.RecordIDs {
+		_, err = tx.Exec("INSERT INTO insurance_claim_records(claim_id, record_id) VALUES($1,$2)", id, m)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+//dbClaimsSetStatus moves a claim to a new status, as long as nobody else
+//changed its status in the meantime
+func (s *server) dbClaimsSetStatus(c insuranceClaim, from string) (int64, error) {
+	res, err := s.db.Exec(`UPDATE insurance_claims SET status = $1, approved_minor = $2, paid_minor = $3, insurer_reference = $4,
+		submitted_at = $5, decided_at = $6, paid_at = $7, updated_at = $8 WHERE id = $9 AND user_id = $10 AND status = $11 AND deleted_at IS NULL`,
+		c.Status, c.ApprovedMinor, c.PaidMinor, nullString(c.InsurerReference), c.SubmittedAt, c.DecidedAt, c.PaidAt, c.UpdatedAt, c.ID, c.UserID, from)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbClaimsDelete deletes a draft claim of a pet owned by a user
+func (s *server) dbClaimsDelete(userID, petID, claimID int64) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM insurance_claims WHERE user_id = $1 AND pet_id = $2 AND id = $3 AND status = $4", userID, petID, claimID, claimDraft)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//policyIDFromRequest is a helper to extract the policy ID URL param
+func policyIDFromRequest(r *http.Request) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)["policyID"], 10, 64)
+}
+
+//claimIDFromRequest is a helper to extract the claim ID URL param
+func claimIDFromRequest(r *http.Request) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)["claimID"], 10, 64)
+}
+
+//claimFromRequestBody decodes and checks a claim request against the
+//user's policies, expenses and medical records, responding to the client
+//itself when it is invalid
+func (s *server) claimFromRequestBody(w http.ResponseWriter, r *http.Request, userID, petID int64) (claimRequest, bool) {
+	var req claimRequest
+	err := s.decode(w, r, &req)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error decoding JSON")
+		return req, false
+	}
+	err = validateClaim(&req)
+	if err != nil {
+		s.respondError(w, r, err, "error checking claim")
+		return req, false
+	}
+	p, err := s.dbPoliciesGetOne(userID, petID, int64(req.PolicyID))
+	if err == sql.ErrNoRows {
+		s.respondProblem(w, r, invalidField("policy_id", "policy must be a policy of the same pet"))
+		return req, false
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error retrieving insurance policy from database")
+		s.respond(w, r, nil, "error saving claim", http.StatusInternalServerError)
+		return req, false
+	}
+	if req.IncidentDate.Before(p.CoverageStart) || (p.CoverageEnd != nil && !req.IncidentDate.Before(*p.CoverageEnd)) {
+		s.respondProblem(w, r, invalidField("incident_date", "incident date must be within the coverage period of the policy"))
+		return req, false
+	}
+	total, sameCurrency, ok, err := s.dbClaimsCheckLinks(userID, petID, p.Currency, req.ExpenseIDs, req.RecordIDs)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error retrieving claim links from database")
+		s.respond(w, r, nil, "error saving claim", http.StatusInternalServerError)
+		return req, false
+	}
+	if !ok {
+		s.respondProblem(w, r, invalidField("expense_ids", "expenses and medical records must belong to the same pet"))
+		return req, false
+	}
+	if req.ClaimedMinor == 0 {
+		if !sameCurrency {
+			s.respondProblem(w, r, invalidField("claimed_minor", "must provide claimed_minor when expenses are not in the currency of the policy"))
+			return req, false
+		}
+		req.ClaimedMinor = total
+	}
+	return req, true
+}
+
+// handlerPoliciesGetAll godoc
+// @Summary Get all insurance policies of a pet
+// @Description Get all insurance policies of a pet, newest coverage first
+// @Tags Insurance
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Success 200 {array} insurancePolicy
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/insurance/policies [get]
+func (s *server) handlerPoliciesGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		policies, err := s.dbPoliciesGetAll(userID, petID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving insurance policies from database")
+			s.respond(w, r, nil, "error retrieving policies", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, policies, "", http.StatusOK)
+	}
+}
+
+// handlerPoliciesGetOne godoc
+// @Summary Get one insurance policy
+// @Description Get one insurance policy of a pet
+// @Tags Insurance
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param PolicyID path int true "Policy ID"
+// @Success 200 {object} insurancePolicy
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/insurance/policies/{PolicyID} [get]
+func (s *server) handlerPoliciesGetOne() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		policyID, err := policyIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid policy id", http.StatusBadRequest)
+			return
+		}
+		p, err := s.dbPoliciesGetOne(userID, petID, policyID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "policy not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving insurance policy from database")
+			s.respond(w, r, nil, "error retrieving policy", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, p, "", http.StatusOK)
+	}
+}
+
+// handlerPoliciesCreate godoc
+// @Summary Create an insurance policy
+// @Description Add an insurance policy to a pet. Amounts are in integer minor units of the currency.
+// @Tags Insurance
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param policy body policyRequest true "Create Policy"
+// @Success 201 {object} insurancePolicy
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/insurance/policies [post]
+func (s *server) handlerPoliciesCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok || !s.requirePet(w, r, userID, petID) {
+			return
+		}
+
+		// Get JSON body, decode into a policy request and validate it
+		var req policyRequest
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validatePolicy(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking policy")
+			return
+		}
+
+		// Create the policy in the db
+		p := insurancePolicy{
+			PetID:                uint(petID),
+			UserID:               uint(userID),
+			Insurer:              req.Insurer,
+			PolicyNumber:         req.PolicyNumber,
+			CoverageStart:        req.CoverageStart,
+			CoverageEnd:          req.CoverageEnd,
+			Currency:             req.Currency,
+			DeductibleMinor:      req.DeductibleMinor,
+			ReimbursementPercent: req.ReimbursementPercent,
+			AnnualLimitMinor:     req.AnnualLimitMinor,
+			CreatedAt:            ts,
+			UpdatedAt:            ts,
+		}
+		id, err := s.dbPoliciesCreate(p)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error creating insurance policy in database")
+			s.respond(w, r, nil, "error creating policy", http.StatusInternalServerError)
+			return
+		}
+		p.ID = uint(id)
+		s.respond(w, r, p, "", http.StatusCreated)
+	}
+}
+
+// handlerPoliciesUpdate godoc
+// @Summary Update an insurance policy
+// @Description Update an insurance policy of a pet
+// @Tags Insurance
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param PolicyID path int true "Policy ID"
+// @Param policy body policyRequest true "Updated Policy"
+// @Success 200 {object} insurancePolicy
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/insurance/policies/{PolicyID} [put]
+func (s *server) handlerPoliciesUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		policyID, err := policyIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid policy id", http.StatusBadRequest)
+			return
+		}
+		p, err := s.dbPoliciesGetOne(userID, petID, policyID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "policy not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving insurance policy from database")
+			s.respond(w, r, nil, "error updating policy", http.StatusInternalServerError)
+			return
+		}
+
+		// Get JSON body, decode into a policy request and validate it
+		var req policyRequest
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validatePolicy(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking policy")
+			return
+		}
+
+		// Update the policy in the db
+		p.Insurer = req.Insurer
+		p.PolicyNumber = req.PolicyNumber
+		p.CoverageStart = req.CoverageStart
+		p.CoverageEnd = req.CoverageEnd
+		p.Currency = req.Currency
+		p.DeductibleMinor = req.DeductibleMinor
+		p.ReimbursementPercent = req.ReimbursementPercent
+		p.AnnualLimitMinor = req.AnnualLimitMinor
+		p.UpdatedAt = ts
+		_, err = s.dbPoliciesUpdate(p)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error updating insurance policy in database")
+			s.respond(w, r, nil, "error updating policy", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, p, "", http.StatusOK)
+	}
+}
+
+// handlerPoliciesDelete godoc
+// @Summary Delete an insurance policy
+// @Description Delete an insurance policy of a pet along with all of its claims
+// @Tags Insurance
+// @Param PetID path int true "Pet ID"
+// @Param PolicyID path int true "Policy ID"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/insurance/policies/{PolicyID} [delete]
+func (s *server) handlerPoliciesDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		policyID, err := policyIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid policy id", http.StatusBadRequest)
+			return
+		}
+		// Look up the attachments first, their blobs go with the rows
+		atts, err := s.dbAttachmentsGetPolicy(userID, petID, policyID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving attachments from database")
+			s.respond(w, r, nil, "error deleting policy", http.StatusInternalServerError)
+			return
+		}
+		rows, err := s.dbPoliciesDelete(userID, petID, policyID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error deleting insurance policy from database")
+			s.respond(w, r, nil, "error deleting policy", http.StatusInternalServerError)
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "policy not found", http.StatusNotFound)
+			return
+		}
+		s.deleteBlobs(r.Context(), atts)
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
+
+// handlerPoliciesSummary godoc
+// @Summary Get the deductible and limit left on a policy
+// @Description Get how much of the deductible and annual limit are used and remaining in a policy year. Approved claims count with their expected reimbursement, paid claims with the amount paid, and submitted claims are shown as pending.
+// @Tags Insurance
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param PolicyID path int true "Policy ID"
+// @Param date query string false "A date in the policy year, YYYY-MM-DD, defaults to today"
+// @Success 200 {object} policyYearSummary
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/insurance/policies/{PolicyID}/summary [get]
+func (s *server) handlerPoliciesSummary() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		policyID, err := policyIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid policy id", http.StatusBadRequest)
+			return
+		}
+		date, err := parseDateParam(r, "date")
+		if err != nil {
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+		if date.IsZero() {
+			date = time.Now().UTC()
+		}
+
+		p, err := s.dbPoliciesGetOne(userID, petID, policyID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "policy not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving insurance policy from database")
+			s.respond(w, r, nil, "error retrieving policy", http.StatusInternalServerError)
+			return
+		}
+		if date.Before(p.CoverageStart) || (p.CoverageEnd != nil && !date.Before(*p.CoverageEnd)) {
+			s.respondProblem(w, r, invalidField("date", "date must be within the coverage period of the policy"))
+			return
+		}
+
+		start, end := policyYear(p, date)
+		claims, err := s.dbClaimsGetPolicyYear(policyID, start, end)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving insurance claims from database")
+			s.respond(w, r, nil, "error retrieving policy", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, summarizePolicyYear(p, start, end, claims), "", http.StatusOK)
+	}
+}
+
+// handlerClaimsGetAll godoc
+// @Summary Get all insurance claims of a pet
+// @Description Get all insurance claims of a pet, newest incident first
+// @Tags Insurance
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Success 200 {array} insuranceClaim
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/insurance/claims [get]
+func (s *server) handlerClaimsGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		claims, err := s.dbClaimsGetAll(userID, petID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving insurance claims from database")
+			s.respond(w, r, nil, "error retrieving claims", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, claims, "", http.StatusOK)
+	}
+}
+
+// handlerClaimsGetOne godoc
+// @Summary Get one insurance claim
+// @Description Get one insurance claim of a pet
+// @Tags Insurance
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param ClaimID path int true "Claim ID"
+// @Success 200 {object} insuranceClaim
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/insurance/claims/{ClaimID} [get]
+func (s *server) handlerClaimsGetOne() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		claimID, err := claimIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid claim id", http.StatusBadRequest)
+			return
+		}
+		c, err := s.dbClaimsGetOne(userID, petID, claimID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "claim not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving insurance claim from database")
+			s.respond(w, r, nil, "error retrieving claim", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, c, "", http.StatusOK)
+	}
+}
+
+// handlerClaimsCreate godoc
+// @Summary Create an insurance claim
+// @Description Draft an insurance claim against a policy of the pet, linked to its expenses and medical records
+// @Tags Insurance
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param claim body claimRequest true "Create Claim"
+// @Success 201 {object} insuranceClaim
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/insurance/claims [post]
+func (s *server) handlerClaimsCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok || !s.requirePet(w, r, userID, petID) {
+			return
+		}
+		req, ok := s.claimFromRequestBody(w, r, userID, petID)
+		if !ok {
+			return
+		}
+
+		// Create the claim in the db
+		c := insuranceClaim{
+			PolicyID:     req.PolicyID,
+			PetID:        uint(petID),
+			UserID:       uint(userID),
+			Status:       claimDraft,
+			IncidentDate: req.IncidentDate,
+			ClaimedMinor: req.ClaimedMinor,
+			Notes:        req.Notes,
+			ExpenseIDs:   req.ExpenseIDs,
+			RecordIDs:    req.RecordIDs,
+			CreatedAt:    ts,
+			UpdatedAt:    ts,
+		}
+		id, err := s.dbClaimsSave(c)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error creating insurance claim in database")
+			s.respond(w, r, nil, "error creating claim", http.StatusInternalServerError)
+			return
+		}
+		c.ID = uint(id)
+		s.respond(w, r, c, "", http.StatusCreated)
+	}
+}
+
+// handlerClaimsUpdate godoc
+// @Summary Update a draft insurance claim
+// @Description Update an insurance claim of a pet. Only draft claims can be edited.
+// @Tags Insurance
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param ClaimID path int true "Claim ID"
+// @Param claim body claimRequest true "Updated Claim"
+// @Success 200 {object} insuranceClaim
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/insurance/claims/{ClaimID} [put]
+func (s *server) handlerClaimsUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		claimID, err := claimIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid claim id", http.StatusBadRequest)
+			return
+		}
+		c, err := s.dbClaimsGetOne(userID, petID, claimID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "claim not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving insurance claim from database")
+			s.respond(w, r, nil, "error updating claim", http.StatusInternalServerError)
+			return
+		}
+		if c.Status != claimDraft {
+			s.respond(w, r, nil, "only draft claims can be edited", http.StatusConflict)
+			return
+		}
+		req, ok := s.claimFromRequestBody(w, r, userID, petID)
+		if !ok {
+			return
+		}
+
+		// Update the claim in the db
+		c.PolicyID = req.PolicyID
+		c.IncidentDate = req.IncidentDate
+		c.ClaimedMinor = req.ClaimedMinor
+		c.Notes = req.Notes
+		c.ExpenseIDs = req.ExpenseIDs
+		c.RecordIDs = req.RecordIDs
+		c.UpdatedAt = ts
+		id, err := s.dbClaimsSave(c)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error updating insurance claim in database")
+			s.respond(w, r, nil, "error updating claim", http.StatusInternalServerError)
+			return
+		}
+		if id == 0 {
+			s.respond(w, r, nil, "only draft claims can be edited", http.StatusConflict)
+			return
+		}
+		s.respond(w, r, c, "", http.StatusOK)
+	}
+}
+
+// handlerClaimsStatus godoc
+// @Summary Move an insurance claim along its workflow
+// @Description Change the status of a claim. Claims go from draft to submitted, then approved or denied, and approved claims to paid. Denied claims may be resubmitted. approved_minor is required to approve and paid_minor to mark paid.
+// @Tags Insurance
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param ClaimID path int true "Claim ID"
+// @Param status body claimStatusRequest true "New Status"
+// @Success 200 {object} insuranceClaim
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/insurance/claims/{ClaimID}/status [put]
+func (s *server) handlerClaimsStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		claimID, err := claimIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid claim id", http.StatusBadRequest)
+			return
+		}
+		c, err := s.dbClaimsGetOne(userID, petID, claimID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "claim not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving insurance claim from database")
+			s.respond(w, r, nil, "error updating claim", http.StatusInternalServerError)
+			return
+		}
+
+		// Get JSON body and decode into a status request
+		var req claimStatusRequest
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		req.Status = strings.ToLower(strings.TrimSpace(req.Status))
+		if !canTransition(c.Status, req.Status) {
+			s.respond(w, r, nil, "a "+c.Status+" claim cannot be moved to "+req.Status, http.StatusConflict)
+			return
+		}
+
+		// Apply the transition
+		from := c.Status
+		c.Status = req.Status
+		c.UpdatedAt = ts
+		if ref := strings.TrimSpace(req.InsurerReference); ref != "" {
+			c.InsurerReference = ref
+		}
+		switch req.Status {
+		case claimSubmitted:
+			c.SubmittedAt = &ts
+			c.DecidedAt = nil
+			c.ApprovedMinor = nil
+		case claimApproved:
+			if req.ApprovedMinor == nil || *req.ApprovedMinor < 0 || *req.ApprovedMinor > c.ClaimedMinor {
+				s.respondProblem(w, r, invalidField("approved_minor", "must provide an approved_minor between 0 and the claimed amount"))
+				return
+			}
+			c.ApprovedMinor = req.ApprovedMinor
+			c.DecidedAt = &ts
+		case claimDenied:
+			c.DecidedAt = &ts
+		case claimPaid:
+			if req.PaidMinor == nil || *req.PaidMinor < 0 || *req.PaidMinor > *c.ApprovedMinor {
+				s.respondProblem(w, r, invalidField("paid_minor", "must provide a paid_minor between 0 and the approved amount"))
+				return
+			}
+			c.PaidMinor = req.PaidMinor
+			c.PaidAt = &ts
+		}
+
+		rows, err := s.dbClaimsSetStatus(c, from)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error updating insurance claim in database")
+			s.respond(w, r, nil, "error updating claim", http.StatusInternalServerError)
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "claim was changed by another request, please retry", http.StatusConflict)
+			return
+		}
+		s.respond(w, r, c, "", http.StatusOK)
+	}
+}
+
+// handlerClaimsDelete godoc
+// @Summary Delete a draft insurance claim
+// @Description Delete an insurance claim of a pet. Only draft claims can be deleted.
+// @Tags Insurance
+// @Param PetID path int true "Pet ID"
+// @Param ClaimID path int true "Claim ID"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/insurance/claims/{ClaimID} [delete]
+func (s *server) handlerClaimsDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		claimID, err := claimIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid claim id", http.StatusBadRequest)
+			return
+		}
+		// Look up the attachments first, their blobs go with the rows
+		atts, err := s.dbAttachmentsGetClaim(userID, petID, claimID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving attachments from database")
+			s.respond(w, r, nil, "error deleting claim", http.StatusInternalServerError)
+			return
+		}
+		rows, err := s.dbClaimsDelete(userID, petID, claimID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error deleting insurance claim from database")
+			s.respond(w, r, nil, "error deleting claim", http.StatusInternalServerError)
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "draft claim not found", http.StatusNotFound)
+			return
+		}
+		s.deleteBlobs(r.Context(), atts)
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
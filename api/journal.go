@@ -0,0 +1,548 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yuin/goldmark"
+)
+
+// maxNoteBytes is the longest markdown body a journal note may have
+const maxNoteBytes = 20000
+
+
+// markdown renders note bodies. Raw HTML in the source is dropped, so the
+// output is safe to show as is.
+var markdown = goldmark.New()
+
+type journalNote struct {
+	ID         uint      `json:"note_id" example:"1"`
+	PetID      uint      `json:"pet_id" example:"1"`
+	UserID     uint      `json:"user_id" example:"1"`
+	Title      string    `json:"title" example:"First day at the beach"`
+	Body       string    `json:"body" example:"Loved the **waves**, hated the sand."`
+	BodyHTML   string    `json:"body_html" example:"<p>Loved the <strong>waves</strong>, hated the sand.</p>"`
+	OccurredAt time.Time `json:"occurred_at" example:"2019-11-09T15:00:00Z"`
+	CreatedAt  time.Time `json:"created_at" example:"2019-11-09T21:21:46+00:00"`
+	UpdatedAt  time.Time `json:"updated_at" example:"2019-11-09T21:21:46+00:00"`
+}
+
+// noteRequest holds a note body in markdown. occurred_at defaults to now.
+type noteRequest struct {
+	Title      string    `json:"title" example:"First day at the beach" validate:"max=200"`
+	Body       string    `json:"body" example:"Loved the **waves**, hated the sand." validate:"required"`
+	OccurredAt time.Time `json:"occurred_at" example:"2019-11-09T15:00:00Z"`
+}
+
+//checkFields checks the size of a note body, which is bound in bytes rather
+//than characters
+func (req noteRequest) checkFields() []fieldError {
+	errs := []fieldError{}
+	if len(req.Body) > maxNoteBytes {
+		errs = append(errs, fieldError{Field: "body", Code: codeTooLong, Message: "body must not be longer than 20000 bytes"})
+	}
+	return errs
+}
+
+type journalNotes []journalNote
+
+type weightEntry struct {
+	ID          uint      `json:"weight_id" example:"1"`
+	PetID       uint      `json:"pet_id" example:"1"`
+	UserID      uint      `json:"user_id" example:"1"`
+	WeightGrams int64     `json:"weight_grams" example:"12500"`
+	MeasuredAt  time.Time `json:"measured_at" example:"2019-11-09T09:00:00Z"`
+	Notes       string    `json:"notes" example:"Weighed at the vet"`
+	CreatedAt   time.Time `json:"created_at" example:"2019-11-09T21:21:46+00:00"`
+}
+
+// weightRequest holds a weight in whole grams, up to a little over a draft
+// horse. measured_at defaults to now.
+type weightRequest struct {
+	WeightGrams int64     `json:"weight_grams" example:"12500" validate:"required,min=1,max=1500000"`
+	MeasuredAt  time.Time `json:"measured_at" example:"2019-11-09T09:00:00Z"`
+	Notes       string    `json:"notes" example:"Weighed at the vet"`
+}
+
+type weightEntries []weightEntry
+
+const noteColumns = "id, pet_id, user_id, title, body, occurred_at, created_at, updated_at"
+
+//renderMarkdown renders a note body to HTML
+func renderMarkdown(src string) string {
+	var buf bytes.Buffer
+	err := markdown.Convert([]byte(src), &buf)
+	if err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+//formatWeight formats a weight in grams as kilograms, e.g. 12500 is "12.5 kg"
+func formatWeight(grams int64) string {
+	return strconv.FormatFloat(float64(grams)/1000, 'f', -1, 64) + " kg"
+}
+
+//scanNote scans a row selected with noteColumns
+func scanNote(row interface{ Scan(...interface{}) error }) (journalNote, error) {
+	var n journalNote
+	var title sql.NullString
+	err := row.Scan(&n.ID, &n.PetID, &n.UserID, &title, &n.Body, &n.OccurredAt, &n.CreatedAt, &n.UpdatedAt)
+	if err != nil {
+		return n, err
+	}
+	n.Title = title.String
+	n.BodyHTML = renderMarkdown(n.Body)
+	return n, nil
+}
+
+//validateNote normalizes and checks a note request
+func validateNote(req *noteRequest, ts time.Time) error {
+	req.Title = strings.TrimSpace(req.Title)
+	req.Body = strings.TrimSpace(req.Body)
+	if req.OccurredAt.IsZero() {
+		req.OccurredAt = ts
+	}
+	return validate(req)
+}
+
+//noteIDFromRequest is a helper to extract the note ID URL param
+func noteIDFromRequest(r *http.Request) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)["noteID"], 10, 64)
+}
+
+//weightIDFromRequest is a helper to extract the weight ID URL param
+func weightIDFromRequest(r *http.Request) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)["weightID"], 10, 64)
+}
+
+//dbNotesGetAll returns the journal notes of a pet owned by a user
+func (s *server) dbNotesGetAll(userID, petID int64) ([]journalNote, error) {
+	rows, err := s.db.Query("SELECT "+noteColumns+" FROM pet_notes WHERE user_id = $1 AND pet_id = $2 AND deleted_at IS NULL ORDER BY occurred_at DESC, id DESC", userID, petID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []journalNote{}
+	for rows.Next() {
+		n, err := scanNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+//dbNotesGetOne returns a single journal note of a pet owned by a user
+func (s *server) dbNotesGetOne(userID, petID, noteID int64) (journalNote, error) {
+	row := s.db.QueryRow("SELECT "+noteColumns+" FROM pet_notes WHERE user_id = $1 AND pet_id = $2 AND id = $3 AND deleted_at IS NULL", userID, petID, noteID)
+	return scanNote(row)
+}
+
+//dbNotesCreate stores a new journal note
+func (s *server) dbNotesCreate(n journalNote) (int64, error) {
+	var id int64
+	err := s.db.QueryRow("INSERT INTO pet_notes(pet_id, user_id, title, body, occurred_at, created_at, updated_at) VALUES($1,$2,$3,$4,$5,$6,$7) RETURNING id",
+		n.PetID, n.UserID, nullString(n.Title), n.Body, n.OccurredAt, n.CreatedAt, n.UpdatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+//dbNotesUpdate updates a journal note of a pet owned by a user
+func (s *server) dbNotesUpdate(n journalNote) (int64, error) {
+	res, err := s.db.Exec("UPDATE pet_notes SET title = $1, body = $2, occurred_at = $3, updated_at = $4 WHERE id = $5 AND user_id = $6 AND pet_id = $7 AND deleted_at IS NULL",
+		nullString(n.Title), n.Body, n.OccurredAt, n.UpdatedAt, n.ID, n.UserID, n.PetID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbNotesDelete deletes a journal note of a pet owned by a user
+func (s *server) dbNotesDelete(userID, petID, noteID int64) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM pet_notes WHERE user_id = $1 AND pet_id = $2 AND id = $3 AND deleted_at IS NULL", userID, petID, noteID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbWeightsGetAll returns the weight log of a pet owned by a user
+func (s *server) dbWeightsGetAll(userID, petID int64) ([]weightEntry, error) {
+	rows, err := s.db.Query("SELECT id, pet_id, user_id, weight_grams, measured_at, notes, created_at FROM pet_weights WHERE user_id = $1 AND pet_id = $2 AND deleted_at IS NULL ORDER BY measured_at DESC, id DESC", userID, petID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	weights := []weightEntry{}
+	for rows.Next() {
+		var e weightEntry
+		var notes sql.NullString
+		err := rows.Scan(&e.ID, &e.PetID, &e.UserID, &e.WeightGrams, &e.MeasuredAt, &notes, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		e.Notes = notes.String
+		weights = append(weights, e)
+	}
+	return weights, rows.Err()
+}
+
+//dbWeightsCreate stores a new weight entry
+func (s *server) dbWeightsCreate(e weightEntry) (int64, error) {
+	var id int64
+	err := s.db.QueryRow("INSERT INTO pet_weights(pet_id, user_id, weight_grams, measured_at, notes, created_at) VALUES($1,$2,$3,$4,$5,$6) RETURNING id",
+		e.PetID, e.UserID, e.WeightGrams, e.MeasuredAt, nullString(e.Notes), e.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+//dbWeightsDelete deletes a weight entry of a pet owned by a user
+func (s *server) dbWeightsDelete(userID, petID, weightID int64) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM pet_weights WHERE user_id = $1 AND pet_id = $2 AND id = $3", userID, petID, weightID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// handlerNotesGetAll godoc
+// @Summary Get all journal notes of a pet
+// @Description Get all journal notes of a pet, newest first, with their markdown rendered to HTML
+// @Tags Journal
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Success 200 {array} journalNote
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/notes [get]
+func (s *server) handlerNotesGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		notes, err := s.dbNotesGetAll(userID, petID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving notes from database")
+			s.respond(w, r, nil, "error retrieving notes", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, notes, "", http.StatusOK)
+	}
+}
+
+// handlerNotesGetOne godoc
+// @Summary Get one journal note
+// @Description Get one journal note of a pet, with its markdown rendered to HTML
+// @Tags Journal
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param NoteID path int true "Note ID"
+// @Success 200 {object} journalNote
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/notes/{NoteID} [get]
+func (s *server) handlerNotesGetOne() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		noteID, err := noteIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid note id", http.StatusBadRequest)
+			return
+		}
+		n, err := s.dbNotesGetOne(userID, petID, noteID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "note not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving note from database")
+			s.respond(w, r, nil, "error retrieving note", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, n, "", http.StatusOK)
+	}
+}
+
+// handlerNotesCreate godoc
+// @Summary Create a journal note
+// @Description Write a free-text note about a pet. The body is markdown, raw HTML in it is not rendered.
+// @Tags Journal
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param note body noteRequest true "Create Note"
+// @Success 201 {object} journalNote
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/notes [post]
+func (s *server) handlerNotesCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok || !s.requirePet(w, r, userID, petID) {
+			return
+		}
+
+		// Get JSON body, decode into a note request and validate it
+		var req noteRequest
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateNote(&req, ts)
+		if err != nil {
+			s.respondError(w, r, err, "error checking note")
+			return
+		}
+
+		// Create the note in the db
+		n := journalNote{
+			PetID:      uint(petID),
+			UserID:     uint(userID),
+			Title:      req.Title,
+			Body:       req.Body,
+			BodyHTML:   renderMarkdown(req.Body),
+			OccurredAt: req.OccurredAt,
+			CreatedAt:  ts,
+			UpdatedAt:  ts,
+		}
+		id, err := s.dbNotesCreate(n)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error creating note in database")
+			s.respond(w, r, nil, "error creating note", http.StatusInternalServerError)
+			return
+		}
+		n.ID = uint(id)
+		s.respond(w, r, n, "", http.StatusCreated)
+	}
+}
+
+// handlerNotesUpdate godoc
+// @Summary Update a journal note
+// @Description Update a journal note of a pet
+// @Tags Journal
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param NoteID path int true "Note ID"
+// @Param note body noteRequest true "Updated Note"
+// @Success 200 {object} journalNote
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/notes/{NoteID} [put]
+func (s *server) handlerNotesUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		noteID, err := noteIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid note id", http.StatusBadRequest)
+			return
+		}
+		n, err := s.dbNotesGetOne(userID, petID, noteID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "note not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving note from database")
+			s.respond(w, r, nil, "error updating note", http.StatusInternalServerError)
+			return
+		}
+
+		// Get JSON body, decode into a note request and validate it
+		var req noteRequest
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		if req.OccurredAt.IsZero() {
+			req.OccurredAt = n.OccurredAt
+		}
+		err = validateNote(&req, ts)
+		if err != nil {
+			s.respondError(w, r, err, "error checking note")
+			return
+		}
+
+		// Update the note in the db
+		n.Title = req.Title
+		n.Body = req.Body
+		n.BodyHTML = renderMarkdown(req.Body)
+		n.OccurredAt = req.OccurredAt
+		n.UpdatedAt = ts
+		_, err = s.dbNotesUpdate(n)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error updating note in database")
+			s.respond(w, r, nil, "error updating note", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, n, "", http.StatusOK)
+	}
+}
+
+// handlerNotesDelete godoc
+// @Summary Delete a journal note
+// @Description Delete a journal note of a pet
+// @Tags Journal
+// @Param PetID path int true "Pet ID"
+// @Param NoteID path int true "Note ID"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/notes/{NoteID} [delete]
+func (s *server) handlerNotesDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		noteID, err := noteIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid note id", http.StatusBadRequest)
+			return
+		}
+		rows, err := s.dbNotesDelete(userID, petID, noteID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error deleting note from database")
+			s.respond(w, r, nil, "error deleting note", http.StatusInternalServerError)
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "note not found", http.StatusNotFound)
+			return
+		}
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
+
+// handlerWeightsGetAll godoc
+// @Summary Get the weight log of a pet
+// @Description Get all weight entries of a pet, newest first
+// @Tags Journal
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Success 200 {array} weightEntry
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/weights [get]
+func (s *server) handlerWeightsGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		weights, err := s.dbWeightsGetAll(userID, petID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving weights from database")
+			s.respond(w, r, nil, "error retrieving weights", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, weights, "", http.StatusOK)
+	}
+}
+
+// handlerWeightsCreate godoc
+// @Summary Log a weight
+// @Description Log the weight of a pet in whole grams
+// @Tags Journal
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param weight body weightRequest true "Create Weight Entry"
+// @Success 201 {object} weightEntry
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/weights [post]
+func (s *server) handlerWeightsCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok || !s.requirePet(w, r, userID, petID) {
+			return
+		}
+
+		// Get JSON body and decode into a weight request
+		var req weightRequest
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validate(req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking weight")
+			return
+		}
+		if req.MeasuredAt.IsZero() {
+			req.MeasuredAt = ts
+		}
+
+		// Create the weight entry in the db
+		e := weightEntry{
+			PetID:       uint(petID),
+			UserID:      uint(userID),
+			WeightGrams: req.WeightGrams,
+			MeasuredAt:  req.MeasuredAt,
+			Notes:       strings.TrimSpace(req.Notes),
+			CreatedAt:   ts,
+		}
+		id, err := s.dbWeightsCreate(e)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error creating weight in database")
+			s.respond(w, r, nil, "error creating weight", http.StatusInternalServerError)
+			return
+		}
+		e.ID = uint(id)
+		s.respond(w, r, e, "", http.StatusCreated)
+	}
+}
+
+// handlerWeightsDelete godoc
+// @Summary Delete a weight entry
+// @Description Delete a weight entry of a pet
+// @Tags Journal
+// @Param PetID path int true "Pet ID"
+// @Param WeightID path int true "Weight ID"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/weights/{WeightID} [delete]
+func (s *server) handlerWeightsDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		weightID, err := weightIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid weight id", http.StatusBadRequest)
+			return
+		}
+		rows, err := s.dbWeightsDelete(userID, petID, weightID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error deleting weight from database")
+			s.respond(w, r, nil, "error deleting weight", http.StatusInternalServerError)
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "weight not found", http.StatusNotFound)
+			return
+		}
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
@@ -0,0 +1,319 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listKind is the type of a field a list can be filtered or sorted by
+type listKind int
+
+const (
+	listString listKind = iota
+	listTime
+	listInt
+)
+
+// listField is a field of the items of a list. Column is its SQL
+// expression, which must not be NULL for a field that can be sorted by,
+// and Value reads it from an item for stores that don't speak SQL and to
+// build cursors.
+type listField struct {
+	Column string
+	Kind   listKind
+	Value  func(item interface{}) interface{}
+}
+
+// listFilterParam is a query param filtering a list by comparing a field
+// to its value with =, >= or <. Strings are compared ignoring case.
+type listFilterParam struct {
+	Field string
+	Op    string
+}
+
+// listSchema describes what a list endpoint can be filtered and sorted by.
+// Every list is sorted by "id" last so pages never overlap.
+type listSchema struct {
+	Fields       map[string]listField
+	Filters      map[string]listFilterParam
+	DefaultSort  []listSort
+	DefaultLimit int
+	MaxLimit     int
+}
+
+type listFilter struct {
+	Field string
+	Op    string
+	Value interface{}
+}
+
+type listSort struct {
+	Field string
+	Desc  bool
+}
+
+// listQuery asks for a page of a list: the items matching the filters in
+// the sort order, starting after the item the cursor points at
+type listQuery struct {
+	schema  *listSchema
+	Filters []listFilter
+	Sort    []listSort
+	After   []interface{}
+	Limit   int
+	Count   bool
+}
+
+// listCursor is the position of the last item on a page, handed to clients
+// in an opaque form. It holds the sort order so it can't be used with
+// another one.
+type listCursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+//sortString renders a sort order the way the sort query param takes it
+func sortString(sort []listSort) string {
+	keys := make([]string, len(sort))
+	for i, k := range sort {
+		keys[i] = k.Field
+		if k.Desc {
+			keys[i] = "-" + k.Field
+		}
+	}
+	return strings.Join(keys, ",")
+}
+
+//parseListValue parses a query param or cursor value of a field
+func parseListValue(kind listKind, v string) (interface{}, error) {
+	switch kind {
+	case listTime:
+		if t, err := time.Parse("2006-01-02", v); err == nil {
+			return t, nil
+		}
+		return time.Parse(time.RFC3339Nano, v)
+	case listInt:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return v, nil
+}
+
+//listQueryFromRequest reads the filter params of a schema and the sort,
+//cursor, limit and count query params
+func (sc *listSchema) listQueryFromRequest(r *http.Request) (listQuery, error) {
+	q := listQuery{schema: sc, Limit: sc.DefaultLimit}
+	params := r.URL.Query()
+
+	for name, fp := range sc.Filters {
+		v := params.Get(name)
+		if v == "" {
+			continue
+		}
+		value, err := parseListValue(sc.Fields[fp.Field].Kind, v)
+		if err != nil {
+			return q, invalidField(name, "invalid "+name)
+		}
+		q.Filters = append(q.Filters, listFilter{Field: fp.Field, Op: fp.Op, Value: value})
+	}
+
+	q.Sort = sc.DefaultSort
+	if s := params.Get("sort"); s != "" {
+		q.Sort = nil
+		for _, key := range strings.Split(s, ",") {
+			k := listSort{Field: strings.TrimSpace(key)}
+			if strings.HasPrefix(k.Field, "-") {
+				k.Field, k.Desc = k.Field[1:], true
+			}
+			if _, ok := sc.Fields[k.Field]; !ok || k.Field == "id" {
+				return q, invalidField("sort", fmt.Sprintf("can't sort by %q", k.Field))
+			}
+			q.Sort = append(q.Sort, k)
+		}
+	}
+	q.Sort = append(q.Sort[:len(q.Sort):len(q.Sort)], listSort{Field: "id"})
+
+	if c := params.Get("cursor"); c != "" {
+		b, err := base64.RawURLEncoding.DecodeString(c)
+		var cur listCursor
+		if err == nil {
+			// Keep numbers as they were written, ids go past what a
+			// float64 holds exactly
+			dec := json.NewDecoder(bytes.NewReader(b))
+			dec.UseNumber()
+			err = dec.Decode(&cur)
+		}
+		if err != nil || cur.Sort != sortString(q.Sort) || len(cur.Values) != len(q.Sort) {
+			return q, invalidField("cursor", "invalid cursor")
+		}
+		for i, k := range q.Sort {
+			v, err := parseListValue(sc.Fields[k.Field].Kind, fmt.Sprint(cur.Values[i]))
+			if err != nil {
+				return q, invalidField("cursor", "invalid cursor")
+			}
+			q.After = append(q.After, v)
+		}
+	}
+
+	if l := params.Get("limit"); l != "" {
+		var err error
+		q.Limit, err = strconv.Atoi(l)
+		if err != nil || q.Limit < 1 || q.Limit > sc.MaxLimit {
+			return q, invalidField("limit", fmt.Sprintf("limit must be between 1 and %d", sc.MaxLimit))
+		}
+	}
+	q.Count = params.Get("count") == "true"
+	return q, nil
+}
+
+//where returns the SQL conditions of the filters and the cursor, adding
+//their values to the args with arg
+func (q listQuery) where(arg func(v interface{}) string) []string {
+	conds := []string{}
+	for _, f := range q.Filters {
+		col := q.schema.Fields[f.Field].Column
+		if _, ok := f.Value.(string); ok {
+			conds = append(conds, fmt.Sprintf("lower(%s) %s lower(%s)", col, f.Op, arg(f.Value)))
+		} else {
+			conds = append(conds, fmt.Sprintf("%s %s %s", col, f.Op, arg(f.Value)))
+		}
+	}
+	if q.After != nil {
+		// (a, b, id) comes after the cursor when a is past it, or a is
+		// equal and b is past it, and so on, each in its own direction
+		ors := []string{}
+		for i, k := range q.Sort {
+			and := []string{}
+			for j := 0; j < i; j++ {
+				and = append(and, fmt.Sprintf("%s = %s", q.schema.Fields[q.Sort[j].Field].Column, arg(q.After[j])))
+			}
+			op := ">"
+			if k.Desc {
+				op = "<"
+			}
+			and = append(and, fmt.Sprintf("%s %s %s", q.schema.Fields[k.Field].Column, op, arg(q.After[i])))
+			ors = append(ors, "("+strings.Join(and, " AND ")+")")
+		}
+		conds = append(conds, "("+strings.Join(ors, " OR ")+")")
+	}
+	return conds
+}
+
+//orderBy returns the SQL ORDER BY and LIMIT clauses, fetching one more
+//item than the limit to know whether there is a next page
+func (q listQuery) orderBy(arg func(v interface{}) string) string {
+	keys := make([]string, len(q.Sort))
+	for i, k := range q.Sort {
+		keys[i] = q.schema.Fields[k.Field].Column
+		if k.Desc {
+			keys[i] += " DESC"
+		}
+	}
+	return " ORDER BY " + strings.Join(keys, ", ") + " LIMIT " + arg(q.Limit+1)
+}
+
+//compareListValues compares two values of a field like the database does
+func compareListValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case time.Time:
+		switch {
+		case a.Before(b.(time.Time)):
+			return -1
+		case a.After(b.(time.Time)):
+			return 1
+		}
+	case int64:
+		switch {
+		case a < b.(int64):
+			return -1
+		case a > b.(int64):
+			return 1
+		}
+	}
+	return 0
+}
+
+//matches reports whether an item passes the filters and comes after the
+//cursor, for stores that don't speak SQL
+func (q listQuery) matches(item interface{}) bool {
+	for _, f := range q.Filters {
+		v := q.schema.Fields[f.Field].Value(item)
+		c := compareListValues(v, f.Value)
+		if s, ok := v.(string); ok {
+			c = strings.Compare(strings.ToLower(s), strings.ToLower(f.Value.(string)))
+		}
+		if (f.Op == "=" && c != 0) || (f.Op == ">=" && c < 0) || (f.Op == "<" && c >= 0) {
+			return false
+		}
+	}
+	if q.After == nil {
+		return true
+	}
+	for i, k := range q.Sort {
+		c := compareListValues(q.schema.Fields[k.Field].Value(item), q.After[i])
+		if k.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c > 0
+		}
+	}
+	return false
+}
+
+//less reports whether an item comes before another in the sort order, for
+//stores that don't speak SQL
+func (q listQuery) less(a, b interface{}) bool {
+	for _, k := range q.Sort {
+		f := q.schema.Fields[k.Field]
+		c := compareListValues(f.Value(a), f.Value(b))
+		if k.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+	}
+	return false
+}
+
+//cursorAfter returns the cursor of the page following an item
+func (q listQuery) cursorAfter(item interface{}) string {
+	cur := listCursor{Sort: sortString(q.Sort)}
+	for _, k := range q.Sort {
+		v := q.schema.Fields[k.Field].Value(item)
+		if t, ok := v.(time.Time); ok {
+			v = t.Format(time.RFC3339Nano)
+		}
+		cur.Values = append(cur.Values, v)
+	}
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+//setPageHeaders links to the next page of a list and sets its total
+//count when it was asked for. n is the number of items fetched, one more
+//than the limit when there is a next page, and last the last item of the
+//page.
+func (q listQuery) setPageHeaders(w http.ResponseWriter, r *http.Request, n int, last interface{}, total int64) {
+	if q.Count {
+		w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	}
+	if n <= q.Limit {
+		return
+	}
+	params := url.Values{}
+	for k, v := range r.URL.Query() {
+		params[k] = v
+	}
+	params.Set("cursor", q.cursorAfter(last))
+	next := url.URL{Path: r.URL.Path, RawQuery: params.Encode()}
+	w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.String()))
+}
@@ -0,0 +1,646 @@
+package api
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"html/template"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// lostMessagesPerSlugPerDay caps how many messages a lost pet page relays
+	lostMessagesPerSlugPerDay = 30
+	// lostMessageMaxLength is the longest message a finder may send
+	lostMessageMaxLength = 2000
+	// lostMessageMaxLinks is the most links a message may contain
+	lostMessageMaxLinks = 2
+)
+
+// linkPattern matches anything that looks like a link in a relayed message
+var linkPattern = regexp.MustCompile(`(?i)(https?://|www\.)`)
+
+// lostSlugEncoding encodes slugs as lowercase unpadded base32
+var lostSlugEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+type lostReport struct {
+	PetID             uint      `json:"pet_id" example:"1"`
+	Slug              string    `json:"slug" example:"mfrggzdfmztwq2lknnwg23tpob"`
+	PublicURL         string    `json:"public_url" example:"http://localhost:8080/lost/mfrggzdfmztwq2lknnwg23tpob"`
+	Description       string    `json:"description" example:"Black lab with a red collar, very friendly"`
+	LastSeenLocation  string    `json:"last_seen_location" example:"Riverside Park near the north entrance"`
+	LastSeenLatitude  *float64  `json:"last_seen_latitude,omitempty" example:"40.8010"`
+	LastSeenLongitude *float64  `json:"last_seen_longitude,omitempty" example:"-73.9723"`
+	LastSeenAt        time.Time `json:"last_seen_at" example:"2019-11-09T21:21:46+00:00"`
+	LostAt            time.Time `json:"lost_at" example:"2019-11-09T21:21:46+00:00"`
+}
+
+type lostReportRequest struct {
+	Description       string    `json:"description" example:"Black lab with a red collar, very friendly"`
+	LastSeenLocation  string    `json:"last_seen_location" example:"Riverside Park near the north entrance"`
+	LastSeenLatitude  *float64  `json:"last_seen_latitude" example:"40.8010"`
+	LastSeenLongitude *float64  `json:"last_seen_longitude" example:"-73.9723"`
+	LastSeenAt        time.Time `json:"last_seen_at" example:"2019-11-09T21:21:46+00:00"`
+}
+
+// lostPetProfile is the public view of a lost pet, free of owner details
+type lostPetProfile struct {
+	Name              string    `json:"name" example:"Fido"`
+	Type              string    `json:"type" example:"Dog"`
+	Breed             string    `json:"breed" example:"Lab/Terrier Mix"`
+	Gender            string    `json:"gender" example:"Female"`
+	Description       string    `json:"description" example:"Black lab with a red collar, very friendly"`
+	LastSeenLocation  string    `json:"last_seen_location" example:"Riverside Park near the north entrance"`
+	LastSeenLatitude  *float64  `json:"last_seen_latitude,omitempty" example:"40.8010"`
+	LastSeenLongitude *float64  `json:"last_seen_longitude,omitempty" example:"-73.9723"`
+	LastSeenAt        time.Time `json:"last_seen_at" example:"2019-11-09T21:21:46+00:00"`
+	LostAt            time.Time `json:"lost_at" example:"2019-11-09T21:21:46+00:00"`
+	PhotoURL          string    `json:"photo_url,omitempty" example:"http://localhost:8080/api/v1/lost/mfrggzdfmztwq2lknnwg23tpob/photo"`
+
+	slug   string
+	petID  int64
+	userID int64
+}
+
+type lostMessageRequest struct {
+	Name    string `json:"name" example:"Jane"`
+	Contact string `json:"contact" example:"jane@email.com"`
+	Message string `json:"message" example:"I think I saw your dog near the bakery on 5th street."`
+	// Website is a honeypot, people never see the field but bots fill it in
+	Website string `json:"website"`
+}
+
+type lostMessage struct {
+	ID        uint      `json:"message_id" example:"1"`
+	PetID     uint      `json:"pet_id" example:"1"`
+	Name      string    `json:"name" example:"Jane"`
+	Contact   string    `json:"contact" example:"jane@email.com"`
+	Message   string    `json:"message" example:"I think I saw your dog near the bakery on 5th street."`
+	CreatedAt time.Time `json:"created_at" example:"2019-11-09T21:21:46+00:00"`
+}
+
+//generateLostSlug creates the unguessable slug of a public lost pet page
+func generateLostSlug() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return lostSlugEncoding.EncodeToString(b), nil
+}
+
+//publicURL builds an absolute URL on this server for a path
+func (s *server) publicURL(path string) string {
+	return "http://" + s.serverHost + ":" + s.listenPort + path
+}
+
+//dbLostGetOne returns the active lost report of a pet owned by a user
+func (s *server) dbLostGetOne(userID, petID int64) (lostReport, error) {
+	var l lostReport
+	var lat, lng sql.NullFloat64
+	row := s.db.QueryRow(`SELECT pet_id, slug, description, last_seen_location, last_seen_latitude, last_seen_longitude, last_seen_at, lost_at
+		FROM lost_pets WHERE user_id = $1 AND pet_id = $2 AND found_at IS NULL AND deleted_at IS NULL`, userID, petID)
+	err := row.Scan(&l.PetID, &l.Slug, &l.Description, &l.LastSeenLocation, &lat, &lng, &l.LastSeenAt, &l.LostAt)
+	if err != nil {
+		return l, err
+	}
+	if lat.Valid && lng.Valid {
+		l.LastSeenLatitude, l.LastSeenLongitude = &lat.Float64, &lng.Float64
+	}
+	return l, nil
+}
+
+//dbLostSet marks a pet as lost, or updates the details of an active report
+func (s *server) dbLostSet(userID int64, l lostReport) error {
+	_, err := s.db.Exec(`INSERT INTO lost_pets(pet_id, user_id, slug, description, last_seen_location, last_seen_latitude, last_seen_longitude, last_seen_at, lost_at, found_at)
+		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,NULL)
+		ON CONFLICT (pet_id) DO UPDATE SET slug = excluded.slug, description = excluded.description,
+			last_seen_location = excluded.last_seen_location, last_seen_latitude = excluded.last_seen_latitude,
+			last_seen_longitude = excluded.last_seen_longitude, last_seen_at = excluded.last_seen_at,
+			lost_at = excluded.lost_at, found_at = NULL`,
+		l.PetID, userID, l.Slug, l.Description, l.LastSeenLocation, l.LastSeenLatitude, l.LastSeenLongitude, l.LastSeenAt, l.LostAt)
+	return err
+}
+
+//dbLostFound marks a lost pet as found, taking its public page down
+func (s *server) dbLostFound(userID, petID int64) (int64, error) {
+	res, err := s.db.Exec("UPDATE lost_pets SET found_at = $1 WHERE user_id = $2 AND pet_id = $3 AND found_at IS NULL AND deleted_at IS NULL", time.Now(), userID, petID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbLostProfile returns the public profile of a lost pet by slug
+func (s *server) dbLostProfile(slug string) (lostPetProfile, error) {
+	p := lostPetProfile{slug: slug}
+	var petType, breed, gender sql.NullString
+	var lat, lng sql.NullFloat64
+	row := s.db.QueryRow(`SELECT p.id, p.user_id, p.name, p.type, p.breed, p.gender, l.description, l.last_seen_location,
+			l.last_seen_latitude, l.last_seen_longitude, l.last_seen_at, l.lost_at
+		FROM lost_pets l JOIN pets p ON p.id = l.pet_id
+		WHERE l.slug = $1 AND l.found_at IS NULL AND l.deleted_at IS NULL AND p.deleted_at IS NULL`, slug)
+	err := row.Scan(&p.petID, &p.userID, &p.Name, &petType, &breed, &gender, &p.Description, &p.LastSeenLocation, &lat, &lng, &p.LastSeenAt, &p.LostAt)
+	if err != nil {
+		return p, err
+	}
+	p.Type, p.Breed, p.Gender = petType.String, breed.String, gender.String
+	if lat.Valid && lng.Valid {
+		p.LastSeenLatitude, p.LastSeenLongitude = &lat.Float64, &lng.Float64
+	}
+	return p, nil
+}
+
+//dbLostMessagesCreate stores a message relayed to the owner of a lost pet
+func (s *server) dbLostMessagesCreate(petID int64, slug, ip string, m lostMessageRequest) error {
+	_, err := s.db.Exec("INSERT INTO lost_pet_messages(pet_id, slug, sender_name, sender_contact, message, ip, created_at) VALUES($1,$2,$3,$4,$5,$6,$7)",
+		petID, slug, m.Name, m.Contact, m.Message, ip, time.Now())
+	return err
+}
+
+//dbLostMessagesCount counts the messages relayed through a slug since a time
+func (s *server) dbLostMessagesCount(slug string, since time.Time) (int, error) {
+	var n int
+	err := s.db.QueryRow("SELECT count(*) FROM lost_pet_messages WHERE slug = $1 AND created_at > $2", slug, since).Scan(&n)
+	return n, err
+}
+
+//dbLostMessagesGetAll returns the messages relayed about a pet owned by a user
+func (s *server) dbLostMessagesGetAll(userID, petID int64) ([]lostMessage, error) {
+	rows, err := s.db.Query(`SELECT m.id, m.pet_id, m.sender_name, m.sender_contact, m.message, m.created_at
+		FROM lost_pet_messages m JOIN pets p ON p.id = m.pet_id
+		WHERE p.user_id = $1 AND m.pet_id = $2 AND p.deleted_at IS NULL ORDER BY m.created_at DESC`, userID, petID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	msgs := []lostMessage{}
+	for rows.Next() {
+		var m lostMessage
+		err := rows.Scan(&m.ID, &m.PetID, &m.Name, &m.Contact, &m.Message, &m.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
+
+//lostProfileFromRequest loads the public profile addressed by the slug URL
+//param, responding to the client itself when there is no such lost pet
+func (s *server) lostProfileFromRequest(w http.ResponseWriter, r *http.Request) (lostPetProfile, bool) {
+	p, err := s.dbLostProfile(mux.Vars(r)["slug"])
+	if err == sql.ErrNoRows {
+		s.respond(w, r, nil, "lost pet not found", http.StatusNotFound)
+		return p, false
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error retrieving lost pet from database")
+		s.respond(w, r, nil, "error retrieving lost pet", http.StatusInternalServerError)
+		return p, false
+	}
+
+	// Only link the photo when there is one
+	atts, err := s.dbAttachmentsGetAll(p.userID, p.petID, attachmentKindAvatar)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error retrieving photo from database")
+	}
+	if len(atts) > 0 {
+		p.PhotoURL = s.publicURL("/api/" + version + "/lost/" + p.slug + "/photo")
+	}
+	return p, true
+}
+
+//relayLostMessage checks a finder's message for spam, stores it and emails
+//it to the owner. It returns the status and error message for the client.
+func (s *server) relayLostMessage(r *http.Request, p lostPetProfile, m lostMessageRequest) (int, string) {
+	m.Name = strings.TrimSpace(m.Name)
+	m.Contact = strings.TrimSpace(m.Contact)
+	m.Message = strings.TrimSpace(m.Message)
+
+	// Bots fill in the hidden field, pretend everything went fine
+	if m.Website != "" {
+		s.logger.Info().Str("slug", p.slug).Msg("dropping lost pet message caught by honeypot")
+		return http.StatusAccepted, ""
+	}
+
+	// Validate the message
+	switch {
+	case m.Contact == "" || m.Message == "":
+		return http.StatusBadRequest, "must provide contact details and a message"
+	case len(m.Name) > 100 || len(m.Contact) > 200:
+		return http.StatusBadRequest, "name or contact details are too long"
+	case len(m.Message) > lostMessageMaxLength:
+		return http.StatusBadRequest, "message is too long"
+	case len(linkPattern.FindAllStringIndex(m.Message, -1)) > lostMessageMaxLinks:
+		return http.StatusBadRequest, "message contains too many links"
+	}
+
+	// Rate limit per sender and per pet
+	ip := s.clientIP(r)
+	if !s.contactLimiter.allow(ip) {
+		return http.StatusTooManyRequests, "too many messages, please try again later"
+	}
+	n, err := s.dbLostMessagesCount(p.slug, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error counting lost pet messages in database")
+		return http.StatusInternalServerError, "error sending message"
+	}
+	if n >= lostMessagesPerSlugPerDay {
+		return http.StatusTooManyRequests, "too many messages, please try again later"
+	}
+
+	// Store the message so the owner can always read it in the app
+	err = s.dbLostMessagesCreate(p.petID, p.slug, ip, m)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error creating lost pet message in database")
+		return http.StatusInternalServerError, "error sending message"
+	}
+
+	// Email the owner when a mailer is set up
+	if s.mailer != nil {
+		owner, err := s.users.GetOne(p.userID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user from database")
+			return http.StatusAccepted, ""
+		}
+		body, err := generateLostPetContactEmail(p.Name, m.Name, m.Contact, m.Message)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error generating email")
+			return http.StatusAccepted, ""
+		}
+		err = s.mailer.Send(owner.Email, "", "Someone may have found "+p.Name, body)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error sending lost pet message email")
+		}
+	}
+	return http.StatusAccepted, ""
+}
+
+// handlerLostUpdate godoc
+// @Summary Mark a pet as lost
+// @Description Mark a pet as lost, publishing its profile at an unguessable URL, or update an active report
+// @Tags Lost Pets
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param report body lostReportRequest true "Lost Report"
+// @Success 200 {object} lostReport
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/lost [put]
+func (s *server) handlerLostUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Get user ID from the authenticated context
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Get URL params and make sure the pet is the user's
+		petID, err := petIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid pet id", http.StatusBadRequest)
+			return
+		}
+		ok, err := s.pets.Exists(userID, petID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving pet from database")
+			s.respond(w, r, nil, "error reporting lost pet", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			s.respond(w, r, nil, "pet not found", http.StatusNotFound)
+			return
+		}
+
+		// Get JSON body and decode into a report request
+		var req lostReportRequest
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		if (req.LastSeenLatitude == nil) != (req.LastSeenLongitude == nil) {
+			s.respond(w, r, nil, "must provide both latitude and longitude", http.StatusBadRequest)
+			return
+		}
+
+		// Keep the slug of an active report so shared links keep working
+		l, err := s.dbLostGetOne(userID, petID)
+		if err == sql.ErrNoRows {
+			l = lostReport{PetID: uint(petID), LostAt: time.Now()}
+			l.Slug, err = generateLostSlug()
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving lost report")
+			s.respond(w, r, nil, "error reporting lost pet", http.StatusInternalServerError)
+			return
+		}
+		l.Description = req.Description
+		l.LastSeenLocation = req.LastSeenLocation
+		l.LastSeenLatitude = req.LastSeenLatitude
+		l.LastSeenLongitude = req.LastSeenLongitude
+		l.LastSeenAt = req.LastSeenAt
+		if l.LastSeenAt.IsZero() {
+			l.LastSeenAt = l.LostAt
+		}
+
+		err = s.dbLostSet(userID, l)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error storing lost report in database")
+			s.respond(w, r, nil, "error reporting lost pet", http.StatusInternalServerError)
+			return
+		}
+		l.PublicURL = s.publicURL("/lost/" + l.Slug)
+		s.respond(w, r, l, "", http.StatusOK)
+	}
+}
+
+// handlerLostGetOne godoc
+// @Summary Get a lost report
+// @Description Get the active lost report of a pet
+// @Tags Lost Pets
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Success 200 {object} lostReport
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/lost [get]
+func (s *server) handlerLostGetOne() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Get user ID from the authenticated context
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Get URL params
+		petID, err := petIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid pet id", http.StatusBadRequest)
+			return
+		}
+
+		l, err := s.dbLostGetOne(userID, petID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "pet is not lost", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving lost report from database")
+			s.respond(w, r, nil, "error retrieving lost report", http.StatusInternalServerError)
+			return
+		}
+		l.PublicURL = s.publicURL("/lost/" + l.Slug)
+		s.respond(w, r, l, "", http.StatusOK)
+	}
+}
+
+// handlerLostFound godoc
+// @Summary Mark a pet as found
+// @Description Mark a lost pet as found, making its public profile private again
+// @Tags Lost Pets
+// @Param PetID path int true "Pet ID"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/lost [delete]
+func (s *server) handlerLostFound() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Get user ID from the authenticated context
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Get URL params
+		petID, err := petIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid pet id", http.StatusBadRequest)
+			return
+		}
+
+		rows, err := s.dbLostFound(userID, petID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error updating lost report in database")
+			s.respond(w, r, nil, "error marking pet as found", http.StatusInternalServerError)
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "pet is not lost", http.StatusNotFound)
+			return
+		}
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
+
+// handlerLostMessagesGetAll godoc
+// @Summary Get lost pet messages
+// @Description Get the messages finders sent through a pet's lost pet page
+// @Tags Lost Pets
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Success 200 {array} lostMessage
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/lost/messages [get]
+func (s *server) handlerLostMessagesGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Get user ID from the authenticated context
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Get URL params
+		petID, err := petIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid pet id", http.StatusBadRequest)
+			return
+		}
+
+		msgs, err := s.dbLostMessagesGetAll(userID, petID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving lost pet messages from database")
+			s.respond(w, r, nil, "error retrieving messages", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, msgs, "", http.StatusOK)
+	}
+}
+
+// handlerLostProfile godoc
+// @Summary Get a lost pet profile
+// @Description Get the public profile of a lost pet. No authentication is needed.
+// @Tags Lost Pets
+// @Produce json
+// @Param Slug path string true "Lost pet slug"
+// @Success 200 {object} lostPetProfile
+// @Router /lost/{Slug} [get]
+func (s *server) handlerLostProfile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := s.lostProfileFromRequest(w, r)
+		if !ok {
+			return
+		}
+		s.respond(w, r, p, "", http.StatusOK)
+	}
+}
+
+// handlerLostPhoto godoc
+// @Summary Get a lost pet photo
+// @Description Get the photo of a lost pet. No authentication is needed.
+// @Tags Lost Pets
+// @Produce image/jpeg,image/png,image/gif,image/webp
+// @Param Slug path string true "Lost pet slug"
+// @Success 200 {file} file
+// @Router /lost/{Slug}/photo [get]
+func (s *server) handlerLostPhoto() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := s.lostProfileFromRequest(w, r)
+		if !ok {
+			return
+		}
+		atts, err := s.dbAttachmentsGetAll(p.userID, p.petID, attachmentKindAvatar)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving photo from database")
+			s.respond(w, r, nil, "error retrieving photo", http.StatusInternalServerError)
+			return
+		}
+		if len(atts) == 0 {
+			s.respond(w, r, nil, "photo not found", http.StatusNotFound)
+			return
+		}
+		a := atts[len(atts)-1]
+		s.serveBlob(w, r, a.BlobKey, a.ContentType, "", a.Size)
+	}
+}
+
+// handlerLostContact godoc
+// @Summary Contact the owner of a lost pet
+// @Description Send a message to the owner of a lost pet without seeing their contact details. No authentication is needed.
+// @Tags Lost Pets
+// @Accept json
+// @Param Slug path string true "Lost pet slug"
+// @Param message body lostMessageRequest true "Message"
+// @Success 202 {object} emptyBody
+// @Router /lost/{Slug}/contact [post]
+func (s *server) handlerLostContact() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := s.lostProfileFromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		var m lostMessageRequest
+		err := s.decode(w, r, &m)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+
+		status, errMsg := s.relayLostMessage(r, p, m)
+		s.respond(w, r, nil, errMsg, status)
+	}
+}
+
+// lostPageTemplate renders the public lost pet page and its contact form.
+// The website field is a honeypot hidden from people.
+var lostPageTemplate = template.Must(template.New("lost").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Lost: {{.Profile.Name}}</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; padding: 0 1em; color: #333; }
+img { max-width: 100%; border-radius: 8px; }
+label { display: block; margin-top: 1em; }
+input, textarea { width: 100%; padding: .5em; box-sizing: border-box; }
+button { margin-top: 1em; padding: .6em 1.2em; background: #4A9FFA; color: #fff; border: 0; border-radius: 4px; }
+.hp { position: absolute; left: -10000px; }
+.notice { padding: 1em; background: #eef6ff; border-radius: 4px; }
+.error { background: #ffeeee; }
+</style>
+</head>
+<body>
+<h1>Lost: {{.Profile.Name}}</h1>
+{{with .Profile.PhotoURL}}<img src="{{.}}" alt="Photo of {{$.Profile.Name}}">{{end}}
+<p>{{.Profile.Type}}{{with .Profile.Breed}}, {{.}}{{end}}{{with .Profile.Gender}}, {{.}}{{end}}</p>
+{{with .Profile.Description}}<p>{{.}}</p>{{end}}
+<p><strong>Last seen:</strong> {{.Profile.LastSeenLocation}} on {{.Profile.LastSeenAt.Format "January 2, 2006 at 3:04 PM MST"}}</p>
+{{if .Sent}}<p class="notice">Thank you! Your message has been passed on to {{.Profile.Name}}'s owner.</p>{{else}}
+{{with .Error}}<p class="notice error">{{.}}</p>{{end}}
+<h2>Have you seen {{.Profile.Name}}?</h2>
+<p>Your message will be forwarded to the owner. Their contact details stay private.</p>
+<form method="post">
+<label>Your name <input name="name" maxlength="100"></label>
+<label>How can the owner reach you? <input name="contact" maxlength="200" required></label>
+<label>Message <textarea name="message" rows="5" maxlength="2000" required></textarea></label>
+<label class="hp" aria-hidden="true">Website <input name="website" tabindex="-1" autocomplete="off"></label>
+<button type="submit">Send message</button>
+</form>{{end}}
+</body>
+</html>
+`))
+
+//renderLostPage writes the public lost pet page
+func (s *server) renderLostPage(w http.ResponseWriter, p lostPetProfile, sent bool, errMsg string, status int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.WriteHeader(status)
+	err := lostPageTemplate.Execute(w, struct {
+		Profile lostPetProfile
+		Sent    bool
+		Error   string
+	}{p, sent, errMsg})
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error rendering lost pet page")
+	}
+}
+
+//handlerLostPage serves the public lost pet page and handles its contact form
+func (s *server) handlerLostPage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := s.lostProfileFromRequest(w, r)
+		if !ok {
+			return
+		}
+		if r.Method != http.MethodPost {
+			s.renderLostPage(w, p, false, "", http.StatusOK)
+			return
+		}
+
+		// Handle the contact form
+		r.Body = http.MaxBytesReader(w, r.Body, 65536)
+		err := r.ParseForm()
+		if err != nil {
+			s.renderLostPage(w, p, false, "Your message could not be read, please try again.", http.StatusBadRequest)
+			return
+		}
+		m := lostMessageRequest{
+			Name:    r.PostFormValue("name"),
+			Contact: r.PostFormValue("contact"),
+			Message: r.PostFormValue("message"),
+			Website: r.PostFormValue("website"),
+		}
+		status, errMsg := s.relayLostMessage(r, p, m)
+		s.renderLostPage(w, p, errMsg == "", errMsg, status)
+	}
+}
@@ -0,0 +1,367 @@
+package api
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memUserStore keeps users in memory. It is meant for tests and behaves
+// like sqlUserStore, including the unique email.
+type memUserStore struct {
+	mu     sync.Mutex
+	nextID int64
+	users  map[int64]user
+}
+
+// memPetStore keeps pets in memory. It is meant for tests and behaves like
+// sqlPetStore, including the unique microchip.
+type memPetStore struct {
+	mu     sync.Mutex
+	nextID int64
+	pets   map[int64]pet
+}
+
+func newMemUserStore() *memUserStore {
+	return &memUserStore{users: map[int64]user{}}
+}
+
+func newMemPetStore() *memPetStore {
+	return &memPetStore{pets: map[int64]pet{}}
+}
+
+//Create stores a user, its password already hashed
+func (st *memUserStore) Create(u user) (int64, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, other := range st.users {
+		if other.Email == u.Email {
+			return 0, errEmailTaken
+		}
+	}
+	st.nextID++
+	u.ID = uint(st.nextID)
+	if u.Role == "" {
+		u.Role = roleOwner
+	}
+	st.users[st.nextID] = u
+	return st.nextID, nil
+}
+
+//GetOne returns a user by ID, without its password
+func (st *memUserStore) GetOne(id int64) (user, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	u, ok := st.users[id]
+	if !ok {
+		return user{}, sql.ErrNoRows
+	}
+	u.Password = ""
+	return u, nil
+}
+
+//GetByEmail returns a user by email, with its password hash
+func (st *memUserStore) GetByEmail(email string) (user, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, u := range st.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return user{}, sql.ErrNoRows
+}
+
+//SetLastLogin records when a user last logged in
+func (st *memUserStore) SetLastLogin(id int64, ts time.Time) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if u, ok := st.users[id]; ok {
+		u.LastLogin = ts
+		st.users[id] = u
+	}
+	return nil
+}
+
+//GetRole returns the role of a user
+func (st *memUserStore) GetRole(id int64) (string, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	u, ok := st.users[id]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return u.Role, nil
+}
+
+//SetRole changes the role of a user
+func (st *memUserStore) SetRole(id int64, role string) (int64, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	u, ok := st.users[id]
+	if !ok {
+		return 0, nil
+	}
+	u.Role = role
+	st.users[id] = u
+	return 1, nil
+}
+
+//copyPet copies a pet so callers can't change a stored one through its
+//ID pointers
+func copyPet(p pet) pet {
+	p.SireID, p.DamID, p.LitterID = copyID(p.SireID), copyID(p.DamID), copyID(p.LitterID)
+	if p.DeletedAt != nil {
+		ts := *p.DeletedAt
+		p.DeletedAt = &ts
+	}
+	return p
+}
+
+func copyID(id *uint) *uint {
+	if id == nil {
+		return nil
+	}
+	v := *id
+	return &v
+}
+
+//microchipTaken reports whether another pet has the microchip. The caller
+//holds the lock.
+func (st *memPetStore) microchipTaken(chip string, petID int64) bool {
+	if chip == "" {
+		return false
+	}
+	for id, other := range st.pets {
+		if id != petID && other.Microchip == chip {
+			return true
+		}
+	}
+	return false
+}
+
+//GetAll returns all pets owned by a user by ID
+func (st *memPetStore) GetAll(userID int64) ([]pet, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	pets := []pet{}
+	for _, p := range st.pets {
+		if int64(p.UserID) == userID && p.DeletedAt == nil {
+			pets = append(pets, copyPet(p))
+		}
+	}
+	sort.Slice(pets, func(i, j int) bool { return pets[i].ID < pets[j].ID })
+	return pets, nil
+}
+
+//List returns a page of the pets owned by a user
+func (st *memPetStore) List(userID int64, q listQuery) ([]pet, int64, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	pets := []pet{}
+	var total int64
+	filters := q
+	filters.After = nil
+	for _, p := range st.pets {
+		if int64(p.UserID) != userID || p.DeletedAt != nil {
+			continue
+		}
+		if filters.matches(p) {
+			total++
+		}
+		if q.matches(p) {
+			pets = append(pets, copyPet(p))
+		}
+	}
+	sort.Slice(pets, func(i, j int) bool { return q.less(pets[i], pets[j]) })
+	if len(pets) > q.Limit+1 {
+		pets = pets[:q.Limit+1]
+	}
+	if !q.Count {
+		total = 0
+	}
+	return pets, total, nil
+}
+
+//GetOne returns a single pet by ID owned by a user by ID
+func (st *memPetStore) GetOne(userID, petID int64) (pet, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	p, ok := st.pets[petID]
+	if !ok || int64(p.UserID) != userID || p.DeletedAt != nil {
+		return pet{}, sql.ErrNoRows
+	}
+	return copyPet(p), nil
+}
+
+//Exists checks whether a pet exists and is owned by a user
+func (st *memPetStore) Exists(userID, petID int64) (bool, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	p, ok := st.pets[petID]
+	return ok && int64(p.UserID) == userID && p.DeletedAt == nil, nil
+}
+
+//Create stores a pet for a user
+func (st *memPetStore) Create(p pet, userID int64) (int64, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.microchipTaken(p.Microchip, 0) {
+		return 0, errMicrochipTaken
+	}
+	st.nextID++
+	p.ID = uint(st.nextID)
+	p.UserID = uint(userID)
+	p.Version = 1
+	st.pets[st.nextID] = copyPet(p)
+	return st.nextID, nil
+}
+
+//CreateMany stores pets for a user, none of them when a microchip is taken
+func (st *memPetStore) CreateMany(ps []pet, userID int64) ([]int64, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	chips := map[string]bool{}
+	for _, p := range ps {
+		if st.microchipTaken(p.Microchip, 0) || (p.Microchip != "" && chips[p.Microchip]) {
+			return nil, errMicrochipTaken
+		}
+		chips[p.Microchip] = true
+	}
+	ids := make([]int64, len(ps))
+	for i, p := range ps {
+		st.nextID++
+		p.ID = uint(st.nextID)
+		p.UserID = uint(userID)
+		p.Version = 1
+		st.pets[st.nextID] = copyPet(p)
+		ids[i] = st.nextID
+	}
+	return ids, nil
+}
+
+//TakenMicrochips returns which of the microchips are registered already
+func (st *memPetStore) TakenMicrochips(chips []string) ([]string, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	taken := []string{}
+	for _, chip := range chips {
+		if st.microchipTaken(chip, 0) {
+			taken = append(taken, chip)
+		}
+	}
+	return taken, nil
+}
+
+//Each calls fn with every pet of a user. The pets are copied first so fn
+//can use the store.
+func (st *memPetStore) Each(userID int64, fn func(p pet) error) error {
+	pets, _ := st.GetAll(userID)
+	for _, p := range pets {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Update saves the changes to a pet owned by a user, bumping its version.
+//Like the database, the owner and creation time are kept.
+func (st *memPetStore) Update(p pet, userID int64) (int64, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	old, ok := st.pets[int64(p.ID)]
+	if !ok || int64(old.UserID) != userID || old.DeletedAt != nil {
+		return 0, nil
+	}
+	if p.Version != 0 && p.Version != old.Version {
+		return 0, errVersionConflict
+	}
+	if st.microchipTaken(p.Microchip, int64(p.ID)) {
+		return 0, errMicrochipTaken
+	}
+	p.UserID = old.UserID
+	p.CreatedAt = old.CreatedAt
+	p.DeletedAt = nil
+	p.Version = old.Version + 1
+	st.pets[int64(p.ID)] = copyPet(p)
+	return p.Version, nil
+}
+
+//Delete removes a pet owned by a user for good, returning the number of
+//pets removed
+func (st *memPetStore) Delete(petID, userID int64) (int64, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	p, ok := st.pets[petID]
+	if !ok || int64(p.UserID) != userID {
+		return 0, nil
+	}
+	delete(st.pets, petID)
+	return 1, nil
+}
+
+//Trash moves a pet to the trash
+func (st *memPetStore) Trash(petID, userID, version int64, ts time.Time) (int64, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	p, ok := st.pets[petID]
+	if !ok || int64(p.UserID) != userID || p.DeletedAt != nil {
+		return 0, nil
+	}
+	if version != 0 && version != p.Version {
+		return 0, errVersionConflict
+	}
+	p.DeletedAt = &ts
+	p.Version++
+	st.pets[petID] = p
+	return 1, nil
+}
+
+//GetTrash returns the trashed pets of a user, most recently trashed first
+func (st *memPetStore) GetTrash(userID int64) ([]pet, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	pets := []pet{}
+	for _, p := range st.pets {
+		if int64(p.UserID) == userID && p.DeletedAt != nil {
+			pets = append(pets, copyPet(p))
+		}
+	}
+	sort.Slice(pets, func(i, j int) bool {
+		if !pets[i].DeletedAt.Equal(*pets[j].DeletedAt) {
+			return pets[i].DeletedAt.After(*pets[j].DeletedAt)
+		}
+		return pets[i].ID < pets[j].ID
+	})
+	return pets, nil
+}
+
+//Restore takes a pet out of the trash
+func (st *memPetStore) Restore(petID, userID int64) (int64, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	p, ok := st.pets[petID]
+	if !ok || int64(p.UserID) != userID || p.DeletedAt == nil {
+		return 0, nil
+	}
+	p.DeletedAt = nil
+	p.Version++
+	st.pets[petID] = p
+	return 1, nil
+}
+
+//GetExpired returns the pets of all users trashed before a time
+func (st *memPetStore) GetExpired(before time.Time) ([]pet, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	pets := []pet{}
+	for _, p := range st.pets {
+		if p.DeletedAt != nil && p.DeletedAt.Before(before) {
+			pets = append(pets, copyPet(p))
+		}
+	}
+	sort.Slice(pets, func(i, j int) bool { return pets[i].ID < pets[j].ID })
+	return pets, nil
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+type microchipLookup struct {
+	Microchip     string `json:"microchip" example:"985112345678903"`
+	Registered    bool   `json:"registered" example:"true"`
+	ContactHandle string `json:"contact_handle,omitempty" example:"mfrggzdfmztwq2lknnwg23tpob"`
+	ContactURL    string `json:"contact_url,omitempty" example:"http://localhost:8080/api/v1/registry/contact/mfrggzdfmztwq2lknnwg23tpob"`
+}
+
+//normalizeMicrochip strips separators from a microchip number and checks it
+//is an ISO 11784/11785 number: 15 digits starting with a country code
+//(001-899) or a manufacturer code (900-998). 999 is reserved for test chips.
+func normalizeMicrochip(chip string) (string, error) {
+	chip = strings.NewReplacer(" ", "", "-", "", ".", "").Replace(chip)
+	if len(chip) != 15 {
+		return "", errors.New("microchip must be a 15 digit ISO 11784/11785 number")
+	}
+	for _, c := range chip {
+		if c < '0' || c > '9' {
+			return "", errors.New("microchip must be a 15 digit ISO 11784/11785 number")
+		}
+	}
+	if chip[:3] == "000" || chip[:3] == "999" {
+		return "", errors.New("microchip must start with a country or manufacturer code")
+	}
+	return chip, nil
+}
+
+//validatePet checks a pet against the rules of petRequest, then normalizes
+//and checks its identification fields
+func validatePet(p *pet) error {
+	err := validate(p.request())
+	if err != nil {
+		return err
+	}
+	return validatePetIdentifiers(p)
+}
+
+//validatePetIdentifiers normalizes and checks the identification fields of a pet
+func validatePetIdentifiers(p *pet) error {
+	p.Tattoo = strings.TrimSpace(p.Tattoo)
+	p.LicenseTag = strings.TrimSpace(p.LicenseTag)
+	p.RegistrationNumber = strings.TrimSpace(p.RegistrationNumber)
+	if p.Microchip == "" {
+		return nil
+	}
+	chip, err := normalizeMicrochip(p.Microchip)
+	if err != nil {
+		return invalidField("microchip", err.Error())
+	}
+	p.Microchip = chip
+	return nil
+}
+
+//isUniqueViolation reports whether a database error is a unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		code := sqliteErr.Code()
+		return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	return false
+}
+
+//dbMicrochipLookup finds the pet registered with a microchip, giving it a
+//contact handle if it doesn't have one yet
+func (s *server) dbMicrochipLookup(chip string) (petID int64, handle string, err error) {
+	var h sql.NullString
+	err = s.db.QueryRow("SELECT id, contact_handle FROM pets WHERE microchip = $1 AND deleted_at IS NULL", chip).Scan(&petID, &h)
+	if err != nil {
+		return 0, "", err
+	}
+	if h.Valid {
+		return petID, h.String, nil
+	}
+	handle, err = generateLostSlug()
+	if err != nil {
+		return 0, "", err
+	}
+	err = s.db.QueryRow("UPDATE pets SET contact_handle = COALESCE(contact_handle, $1) WHERE id = $2 RETURNING contact_handle", handle, petID).Scan(&handle)
+	if err != nil {
+		return 0, "", err
+	}
+	return petID, handle, nil
+}
+
+//dbMicrochipLookupsCreate records a microchip lookup
+func (s *server) dbMicrochipLookupsCreate(userID int64, chip string, petID int64, ip string) error {
+	var pid sql.NullInt64
+	if petID != 0 {
+		pid = sql.NullInt64{Int64: petID, Valid: true}
+	}
+	_, err := s.db.Exec("INSERT INTO microchip_lookups(user_id, microchip, pet_id, ip, created_at) VALUES($1,$2,$3,$4,$5)",
+		userID, chip, pid, ip, time.Now())
+	return err
+}
+
+//dbContactHandleProfile returns what the relay needs to know about the pet
+//behind a contact handle
+func (s *server) dbContactHandleProfile(handle string) (lostPetProfile, error) {
+	p := lostPetProfile{slug: handle}
+	err := s.db.QueryRow("SELECT id, user_id, name FROM pets WHERE contact_handle = $1 AND deleted_at IS NULL", handle).Scan(&p.petID, &p.userID, &p.Name)
+	return p, err
+}
+
+// handlerMicrochipLookup godoc
+// @Summary Look up a microchip
+// @Description Check whether a microchip is registered. Only shelter, vet and admin users may look up chips. Owner details are never returned, only a handle to contact the owner through the relay. Every lookup is logged.
+// @Tags Registry
+// @Produce json
+// @Param Microchip path string true "Microchip number"
+// @Success 200 {object} microchipLookup
+// @Security ApiKeyAuth
+// @Router /registry/microchips/{Microchip} [get]
+func (s *server) handlerMicrochipLookup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Get user ID from the authenticated context
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Get URL params
+		chip, err := normalizeMicrochip(mux.Vars(r)["chip"])
+		if err != nil {
+			s.respondError(w, r, invalidField("chip", err.Error()), "error looking up microchip")
+			return
+		}
+
+		// Find the pet
+		res := microchipLookup{Microchip: chip}
+		petID, handle, err := s.dbMicrochipLookup(chip)
+		if err != nil && err != sql.ErrNoRows {
+			s.logger.Error().Err(err).Msg("error looking up microchip in database")
+			s.respond(w, r, nil, "error looking up microchip", http.StatusInternalServerError)
+			return
+		}
+		if err == nil {
+			res.Registered = true
+			res.ContactHandle = handle
+			res.ContactURL = s.publicURL("/api/" + version + "/registry/contact/" + handle)
+		}
+
+		// Log the lookup, refusing to answer if it can't be recorded
+		ip := s.clientIP(r)
+		s.logger.Info().Int64("user_id", userID).Str("microchip", chip).Bool("registered", res.Registered).Str("ip", ip).Msg("microchip lookup")
+		err = s.dbMicrochipLookupsCreate(userID, chip, petID, ip)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error logging microchip lookup in database")
+			s.respond(w, r, nil, "error looking up microchip", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, res, "", http.StatusOK)
+	}
+}
+
+// handlerRegistryContact godoc
+// @Summary Contact the owner of a registered pet
+// @Description Send a message to the owner of a pet found through a microchip lookup, without seeing their contact details
+// @Tags Registry
+// @Accept json
+// @Param Handle path string true "Contact handle"
+// @Param message body lostMessageRequest true "Message"
+// @Success 202 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /registry/contact/{Handle} [post]
+func (s *server) handlerRegistryContact() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := s.dbContactHandleProfile(mux.Vars(r)["handle"])
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "contact handle not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving contact handle from database")
+			s.respond(w, r, nil, "error sending message", http.StatusInternalServerError)
+			return
+		}
+
+		var m lostMessageRequest
+		err = s.decode(w, r, &m)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+
+		status, errMsg := s.relayLostMessage(r, p, m)
+		s.respond(w, r, nil, errMsg, status)
+	}
+}
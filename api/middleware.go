@@ -1,13 +1,43 @@
 package api
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"net/http"
+	"regexp"
 	"time"
 
 	"github.com/gorilla/context"
 )
 
+// requestIDPattern is what a request ID passed in by a proxy or client
+// must look like to be kept
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+//requestID tags every request with an ID, taken from the X-Request-Id
+//header when the ingress proxy set one. It is echoed back to the client and
+//recorded in the logs and the audit log.
+func (s *server) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-Id")
+		if !requestIDPattern.MatchString(id) {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		context.Set(r, "requestID", id)
+		w.Header().Set("X-Request-Id", id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+//requestIDFromRequest returns the ID the request was tagged with
+func requestIDFromRequest(r *http.Request) string {
+	id, _ := context.Get(r, "requestID").(string)
+	return id
+}
+
 func (s *server) isAuthenticated(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id, err := validateToken(r)
@@ -27,6 +57,7 @@ func (s *server) httpLogger(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 		latency := time.Since(start)
 		s.logger.Info().
+			Str("request_id", requestIDFromRequest(r)).
 			Str("method", r.Method).
 			Str("path", r.URL.Path[1:]).
 			Str("user_agent", r.UserAgent()).
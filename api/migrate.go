@@ -0,0 +1,477 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rizkybiz/petkeep-server/config"
+	"github.com/rs/zerolog"
+)
+
+// migrationFiles are the numbered schema migrations, written for cockroach
+// and translated when run against sqlite. Every version has an up and a
+// down file, e.g. 0001_users_and_pets.up.sql and 0001_users_and_pets.down.sql.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// catalogMigration is the version creating the catalog tables, pets are
+// normalized against the catalog after migrating up from it
+const catalogMigration = 10
+
+const (
+	// migrationLockLease is how long a migration lock is held without being
+	// renewed before another replica may take it over
+	migrationLockLease = 5 * time.Minute
+	// migrationLockWait is how long to wait for another replica's migration
+	// to finish
+	migrationLockWait = 10 * time.Minute
+)
+
+// errMigrationLockLost is returned when another replica took over the
+// migration lock after its lease ran out
+var errMigrationLockLost = errors.New("lost the migration lock")
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migration is one version of the schema
+type migration struct {
+	Version int
+	Name    string
+	Up      []string
+	Down    []string
+}
+
+// migrator applies migrations to a database, holding the migration lock
+// while it does
+type migrator struct {
+	db         *sql.DB
+	driver     string
+	out        io.Writer
+	holder     string
+	lease      time.Duration
+	migrations []migration
+}
+
+//loadMigrations reads the embedded migrations, ordered by version
+func loadMigrations() ([]migration, error) {
+	files, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*migration{}
+	for _, f := range files {
+		m := migrationFileName.FindStringSubmatch(f.Name())
+		if m == nil {
+			return nil, fmt.Errorf("badly named migration %s", f.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		body, err := migrationFiles.ReadFile(path.Join("migrations", f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = splitStatements(string(body))
+		} else {
+			mig.Down = splitStatements(string(body))
+		}
+	}
+
+	migrations := []migration{}
+	for _, m := range byVersion {
+		if m.Up == nil || m.Down == nil {
+			return nil, fmt.Errorf("migration %d %s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+	}
+	return migrations, nil
+}
+
+//splitStatements splits a migration file into its statements, dropping
+//comment lines
+func splitStatements(body string) []string {
+	lines := []string{}
+	for _, l := range strings.Split(body, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(l), "--") {
+			lines = append(lines, l)
+		}
+	}
+	stmts := []string{}
+	for _, stmt := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+//newMigrator sets up a migrator for a database, creating the tables that
+//track the applied migrations and the lock when needed
+func newMigrator(db *sql.DB, driver string, out io.Writer) (*migrator, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	nonce := make([]byte, 4)
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	mg := &migrator{
+		db:         db,
+		driver:     driver,
+		out:        out,
+		holder:     fmt.Sprintf("%s/%d/%s", host, os.Getpid(), hex.EncodeToString(nonce)),
+		lease:      migrationLockLease,
+		migrations: migrations,
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT NOT NULL,
+			name STRING NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (version))`,
+		`CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+			id INT NOT NULL,
+			holder STRING NOT NULL,
+			expires_at INT8 NOT NULL,
+			PRIMARY KEY (id))`,
+	} {
+		err := mg.exec(db, stmt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return mg, nil
+}
+
+//exec runs a migration statement, translated for sqlite when needed
+func (mg *migrator) exec(db interface {
+	queryer
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}, stmt string) error {
+	stmts := []string{stmt}
+	if mg.driver == driverSQLite {
+		var err error
+		stmts, err = sqliteStatements(db, stmt)
+		if err != nil {
+			return err
+		}
+	}
+	for _, s := range stmts {
+		_, err := db.Exec(s)
+		if err != nil {
+			return fmt.Errorf("%v: %s", err, s)
+		}
+	}
+	return nil
+}
+
+//lock takes the migration lock, waiting while another replica holds it.
+//Advisory locks are no-ops on cockroach, so the lock is a row only one
+//replica can insert. It expires so a replica that died while migrating
+//doesn't block the others forever.
+func (mg *migrator) lock() error {
+	deadline := time.Now().Add(migrationLockWait)
+	for {
+		now := time.Now()
+		_, err := mg.db.Exec("DELETE FROM schema_migrations_lock WHERE id = 1 AND expires_at < $1", now.Unix())
+		if err != nil {
+			return err
+		}
+		_, err = mg.db.Exec("INSERT INTO schema_migrations_lock(id, holder, expires_at) VALUES(1, $1, $2)",
+			mg.holder, now.Add(mg.lease).Unix())
+		if err == nil {
+			return nil
+		}
+		if !isUniqueViolation(err) {
+			return err
+		}
+		if now.After(deadline) {
+			var holder string
+			mg.db.QueryRow("SELECT holder FROM schema_migrations_lock WHERE id = 1").Scan(&holder)
+			return fmt.Errorf("timed out waiting for the migration lock held by %s", holder)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+//renew extends the migration lock, failing when it was lost
+func (mg *migrator) renew() error {
+	res, err := mg.db.Exec("UPDATE schema_migrations_lock SET expires_at = $1 WHERE id = 1 AND holder = $2",
+		time.Now().Add(mg.lease).Unix(), mg.holder)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errMigrationLockLost
+	}
+	return nil
+}
+
+//keepLock renews the migration lock every third of its lease until stop is
+//called, as a single migration may run for longer than the lease. When the
+//lock is lost the context is canceled, so the migration running then isn't
+//committed, and stop returns errMigrationLockLost. Other errors are retried
+//on the next tick, the lease leaves room for a few.
+func (mg *migrator) keepLock() (ctx context.Context, stop func() error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	var lost error
+	go func() {
+		defer close(done)
+		t := time.NewTicker(mg.lease / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			err := mg.renew()
+			if err == errMigrationLockLost {
+				lost = err
+				cancel()
+				return
+			}
+			if err != nil {
+				fmt.Fprintf(mg.out, "renewing the migration lock: %v\n", err)
+			}
+		}
+	}()
+	return ctx, func() error {
+		cancel()
+		<-done
+		return lost
+	}
+}
+
+//unlock releases the migration lock
+func (mg *migrator) unlock() error {
+	_, err := mg.db.Exec("DELETE FROM schema_migrations_lock WHERE id = 1 AND holder = $1", mg.holder)
+	return err
+}
+
+//applied returns when each applied migration was applied
+func (mg *migrator) applied() (map[int]time.Time, error) {
+	rows, err := mg.db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		err := rows.Scan(&version, &at)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+//current returns the highest applied version, 0 for an empty database
+func (mg *migrator) current() (int, error) {
+	applied, err := mg.applied()
+	if err != nil {
+		return 0, err
+	}
+	current := 0
+	for v := range applied {
+		if v > current {
+			current = v
+		}
+	}
+	return current, nil
+}
+
+//latest returns the version of the newest migration
+func (mg *migrator) latest() int {
+	return mg.migrations[len(mg.migrations)-1].Version
+}
+
+//run applies or reverts a single migration in a transaction, together with
+//its row in schema_migrations
+func (mg *migrator) run(ctx context.Context, m migration, up bool) error {
+	tx, err := mg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmts := m.Down
+	if up {
+		stmts = m.Up
+	}
+	for _, stmt := range stmts {
+		err := mg.exec(tx, stmt)
+		if err != nil {
+			return fmt.Errorf("migration %d %s: %v", m.Version, m.Name, err)
+		}
+	}
+	if up {
+		_, err = tx.Exec("INSERT INTO schema_migrations(version, name, applied_at) VALUES($1,$2,$3)", m.Version, m.Name, time.Now())
+	} else {
+		_, err = tx.Exec("DELETE FROM schema_migrations WHERE version = $1", m.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+//migrateTo applies or reverts migrations until the schema is at a version,
+//holding the migration lock. Replicas waiting for the lock find the work
+//done once they get it.
+func (mg *migrator) migrateTo(target int) error {
+	if target < 0 || target > mg.latest() {
+		return fmt.Errorf("version must be between 0 and %d", mg.latest())
+	}
+	err := mg.lock()
+	if err != nil {
+		return err
+	}
+	defer mg.unlock()
+	ctx, stop := mg.keepLock()
+	defer stop()
+
+	applied, err := mg.applied()
+	if err != nil {
+		return err
+	}
+	for _, m := range mg.migrations {
+		if _, ok := applied[m.Version]; ok || m.Version > target {
+			continue
+		}
+		fmt.Fprintf(mg.out, "applying %04d_%s\n", m.Version, m.Name)
+		err := mg.run(ctx, m, true)
+		if err != nil {
+			if lost := stop(); lost != nil {
+				return lost
+			}
+			return err
+		}
+	}
+	for i := len(mg.migrations) - 1; i >= 0; i-- {
+		m := mg.migrations[i]
+		if _, ok := applied[m.Version]; !ok || m.Version <= target {
+			continue
+		}
+		fmt.Fprintf(mg.out, "reverting %04d_%s\n", m.Version, m.Name)
+		err := mg.run(ctx, m, false)
+		if err != nil {
+			if lost := stop(); lost != nil {
+				return lost
+			}
+			return err
+		}
+	}
+
+	err = stop()
+	if err != nil {
+		return err
+	}
+
+	// New aliases in the catalog seed may match pets stored before
+	if target >= catalogMigration {
+		return normalizePetCatalog(mg.db)
+	}
+	return nil
+}
+
+//status writes every migration and when it was applied
+func (mg *migrator) status() error {
+	applied, err := mg.applied()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(mg.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, m := range mg.migrations {
+		at := "pending"
+		if t, ok := applied[m.Version]; ok {
+			at = t.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", m.Version, m.Name, at)
+	}
+	return w.Flush()
+}
+
+//Migrate runs the migrate subcommand: up, down, status or to N
+func Migrate(cfg config.Config, args []string) error {
+	usage := errors.New("usage: petkeep-server migrate up|down|status|to N")
+	if len(args) == 0 {
+		return usage
+	}
+
+	s := &server{logger: zerolog.New(os.Stderr).With().Timestamp().Logger().Level(zerolog.InfoLevel)}
+	err := s.openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer s.db.Close()
+	mg, err := newMigrator(s.db, cfg.DBDriver, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		return mg.migrateTo(mg.latest())
+	case args[0] == "down" && len(args) == 1:
+		current, err := mg.current()
+		if err != nil {
+			return err
+		}
+		if current == 0 {
+			fmt.Fprintln(mg.out, "nothing to revert")
+			return nil
+		}
+		return mg.migrateTo(current - 1)
+	case args[0] == "status" && len(args) == 1:
+		return mg.status()
+	case args[0] == "to" && len(args) == 2:
+		target, err := strconv.Atoi(args[1])
+		if err != nil {
+			return usage
+		}
+		return mg.migrateTo(target)
+	default:
+		return usage
+	}
+}
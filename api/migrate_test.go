@@ -0,0 +1,52 @@
+package api
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestMigratorKeepLock(t *testing.T) {
+	s := newSQLiteTestServer(t)
+	mg, err := newMigrator(s.db, driverSQLite, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mg.lease = time.Second
+	if err := mg.lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer mg.unlock()
+
+	// The lock outlives its lease while it's being kept
+	ctx, stop := mg.keepLock()
+	defer stop()
+	time.Sleep(2500 * time.Millisecond)
+	var expired int
+	err = s.db.QueryRow("SELECT count(*) FROM schema_migrations_lock WHERE expires_at < $1", time.Now().Unix()).Scan(&expired)
+	if err != nil || expired != 0 {
+		t.Fatalf("got %d expired locks, error %v, want the lock renewed", expired, err)
+	}
+
+	// Another replica taking it over cancels the migration in progress
+	_, err = s.db.Exec("UPDATE schema_migrations_lock SET holder = 'other' WHERE id = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("the context wasn't canceled after losing the lock")
+	}
+	if err := stop(); err != errMigrationLockLost {
+		t.Errorf("stopping: got %v, want %v", err, errMigrationLockLost)
+	}
+	m := mg.migrations[len(mg.migrations)-1]
+	if err := mg.run(ctx, m, false); err == nil {
+		t.Errorf("reverting %d after losing the lock: got no error", m.Version)
+	}
+	current, err := mg.current()
+	if err != nil || current != m.Version {
+		t.Errorf("got version %d, error %v, want %d still applied", current, err, m.Version)
+	}
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS pets;
+DROP TABLE IF EXISTS users;
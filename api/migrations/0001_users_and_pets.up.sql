@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS users (
+  id SERIAL NOT NULL,
+  email STRING UNIQUE,
+  password STRING,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  last_login TIMESTAMPTZ,
+  PRIMARY KEY (id));
+
+CREATE TABLE IF NOT EXISTS pets (
+  id SERIAL NOT NULL,
+  user_id int REFERENCES users (id) ON DELETE CASCADE,
+  name STRING NOT NULL,
+  type STRING,
+  gender STRING,
+  breed STRING,
+  birthday DATE,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  PRIMARY KEY (id));
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+  user_id int REFERENCES users (id) ON DELETE CASCADE,
+  token_hash STRING NOT NULL UNIQUE,
+  created_at TIMESTAMPTZ,
+  PRIMARY KEY (user_id));
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS attachments (
+  id SERIAL NOT NULL,
+  pet_id int REFERENCES pets (id) ON DELETE CASCADE,
+  user_id int REFERENCES users (id) ON DELETE CASCADE,
+  kind STRING NOT NULL,
+  filename STRING,
+  content_type STRING NOT NULL,
+  size_bytes INT NOT NULL,
+  description STRING,
+  blob_key STRING NOT NULL,
+  thumbnail_key STRING,
+  created_at TIMESTAMPTZ,
+  PRIMARY KEY (id),
+  INDEX (pet_id, kind));
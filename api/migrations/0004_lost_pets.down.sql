@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS lost_pet_messages;
+DROP TABLE IF EXISTS lost_pets;
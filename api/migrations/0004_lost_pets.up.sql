@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS lost_pets (
+  pet_id int REFERENCES pets (id) ON DELETE CASCADE,
+  user_id int REFERENCES users (id) ON DELETE CASCADE,
+  slug STRING NOT NULL UNIQUE,
+  description STRING,
+  last_seen_location STRING,
+  last_seen_latitude FLOAT,
+  last_seen_longitude FLOAT,
+  last_seen_at TIMESTAMPTZ,
+  lost_at TIMESTAMPTZ,
+  found_at TIMESTAMPTZ,
+  PRIMARY KEY (pet_id));
+
+CREATE TABLE IF NOT EXISTS lost_pet_messages (
+  id SERIAL NOT NULL,
+  pet_id int REFERENCES pets (id) ON DELETE CASCADE,
+  slug STRING NOT NULL,
+  sender_name STRING,
+  sender_contact STRING NOT NULL,
+  message STRING NOT NULL,
+  ip STRING,
+  created_at TIMESTAMPTZ,
+  PRIMARY KEY (id),
+  INDEX (slug, created_at));
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS microchip_lookups;
+ALTER TABLE pets DROP COLUMN IF EXISTS contact_handle CASCADE;
+ALTER TABLE pets DROP COLUMN IF EXISTS license_tag;
+ALTER TABLE pets DROP COLUMN IF EXISTS tattoo;
+ALTER TABLE pets DROP COLUMN IF EXISTS microchip CASCADE;
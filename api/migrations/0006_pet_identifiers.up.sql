@@ -0,0 +1,18 @@
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS microchip STRING UNIQUE;
+
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS tattoo STRING;
+
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS license_tag STRING;
+
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS contact_handle STRING UNIQUE;
+
+CREATE TABLE IF NOT EXISTS microchip_lookups (
+  id SERIAL NOT NULL,
+  user_id int REFERENCES users (id),
+  microchip STRING NOT NULL,
+  pet_id int REFERENCES pets (id) ON DELETE SET NULL,
+  ip STRING,
+  created_at TIMESTAMPTZ,
+  PRIMARY KEY (id),
+  INDEX (microchip),
+  INDEX (user_id, created_at));
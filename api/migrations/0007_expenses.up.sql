@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS expenses (
+  id SERIAL NOT NULL,
+  pet_id int REFERENCES pets (id) ON DELETE CASCADE,
+  user_id int REFERENCES users (id) ON DELETE CASCADE,
+  amount_minor INT8 NOT NULL,
+  currency STRING(3) NOT NULL,
+  category STRING NOT NULL,
+  vendor STRING,
+  spent_on DATE NOT NULL,
+  notes STRING,
+  receipt_attachment_id int REFERENCES attachments (id) ON DELETE SET NULL,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  PRIMARY KEY (id),
+  INDEX (user_id, spent_on),
+  INDEX (pet_id, spent_on));
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS medical_records (
+  id SERIAL NOT NULL,
+  pet_id int REFERENCES pets (id) ON DELETE CASCADE,
+  user_id int REFERENCES users (id) ON DELETE CASCADE,
+  kind STRING NOT NULL,
+  title STRING NOT NULL,
+  notes STRING,
+  occurred_on DATE NOT NULL,
+  due_on DATE,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  PRIMARY KEY (id),
+  INDEX (pet_id, occurred_on));
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS insurance_claim_records;
+DROP TABLE IF EXISTS insurance_claim_expenses;
+DROP TABLE IF EXISTS insurance_claims;
+DROP TABLE IF EXISTS insurance_policies;
@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS insurance_policies (
+  id SERIAL NOT NULL,
+  pet_id int REFERENCES pets (id) ON DELETE CASCADE,
+  user_id int REFERENCES users (id) ON DELETE CASCADE,
+  insurer STRING NOT NULL,
+  policy_number STRING NOT NULL,
+  coverage_start DATE NOT NULL,
+  coverage_end DATE,
+  currency STRING(3) NOT NULL,
+  deductible_minor INT8 NOT NULL,
+  reimbursement_percent INT NOT NULL,
+  annual_limit_minor INT8,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  PRIMARY KEY (id),
+  INDEX (pet_id));
+
+CREATE TABLE IF NOT EXISTS insurance_claims (
+  id SERIAL NOT NULL,
+  policy_id int REFERENCES insurance_policies (id) ON DELETE CASCADE,
+  pet_id int REFERENCES pets (id) ON DELETE CASCADE,
+  user_id int REFERENCES users (id) ON DELETE CASCADE,
+  status STRING NOT NULL,
+  incident_date DATE NOT NULL,
+  claimed_minor INT8 NOT NULL,
+  approved_minor INT8,
+  paid_minor INT8,
+  insurer_reference STRING,
+  notes STRING,
+  submitted_at TIMESTAMPTZ,
+  decided_at TIMESTAMPTZ,
+  paid_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  PRIMARY KEY (id),
+  INDEX (pet_id, incident_date),
+  INDEX (policy_id, incident_date));
+
+CREATE TABLE IF NOT EXISTS insurance_claim_expenses (
+  claim_id int REFERENCES insurance_claims (id) ON DELETE CASCADE,
+  expense_id int REFERENCES expenses (id) ON DELETE CASCADE,
+  PRIMARY KEY (claim_id, expense_id));
+
+CREATE TABLE IF NOT EXISTS insurance_claim_records (
+  claim_id int REFERENCES insurance_claims (id) ON DELETE CASCADE,
+  record_id int REFERENCES medical_records (id) ON DELETE CASCADE,
+  PRIMARY KEY (claim_id, record_id));
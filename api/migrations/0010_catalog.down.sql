@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS catalog_breeds;
+DROP TABLE IF EXISTS catalog_species;
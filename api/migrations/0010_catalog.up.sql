@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS catalog_species (
+  slug STRING NOT NULL,
+  name STRING NOT NULL UNIQUE,
+  aliases STRING[],
+  created_at TIMESTAMPTZ,
+  PRIMARY KEY (slug));
+
+CREATE TABLE IF NOT EXISTS catalog_breeds (
+  id SERIAL NOT NULL,
+  species STRING NOT NULL,
+  name STRING NOT NULL,
+  aliases STRING[],
+  created_at TIMESTAMPTZ,
+  PRIMARY KEY (id),
+  UNIQUE (species, name));
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS pet_weights;
+DROP TABLE IF EXISTS pet_notes;
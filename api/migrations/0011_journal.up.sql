@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS pet_notes (
+  id SERIAL NOT NULL,
+  pet_id int REFERENCES pets (id) ON DELETE CASCADE,
+  user_id int REFERENCES users (id) ON DELETE CASCADE,
+  title STRING,
+  body STRING NOT NULL,
+  occurred_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  PRIMARY KEY (id),
+  INDEX (pet_id, occurred_at));
+
+CREATE TABLE IF NOT EXISTS pet_weights (
+  id SERIAL NOT NULL,
+  pet_id int REFERENCES pets (id) ON DELETE CASCADE,
+  user_id int REFERENCES users (id) ON DELETE CASCADE,
+  weight_grams INT8 NOT NULL,
+  measured_at TIMESTAMPTZ NOT NULL,
+  notes STRING,
+  created_at TIMESTAMPTZ,
+  PRIMARY KEY (id),
+  INDEX (pet_id, measured_at));
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS activity_goals;
+DROP TABLE IF EXISTS activity_routes;
+DROP TABLE IF EXISTS activities;
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS activities (
+  id SERIAL NOT NULL,
+  pet_id int REFERENCES pets (id) ON DELETE CASCADE,
+  user_id int REFERENCES users (id) ON DELETE CASCADE,
+  kind STRING NOT NULL,
+  started_at TIMESTAMPTZ NOT NULL,
+  duration_seconds INT8 NOT NULL,
+  distance_meters FLOAT8 NOT NULL DEFAULT 0,
+  elevation_gain_m FLOAT8,
+  elevation_loss_m FLOAT8,
+  has_route BOOL NOT NULL DEFAULT false,
+  notes STRING,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  PRIMARY KEY (id),
+  INDEX (pet_id, started_at));
+
+CREATE TABLE IF NOT EXISTS activity_routes (
+  activity_id int REFERENCES activities (id) ON DELETE CASCADE,
+  geojson STRING NOT NULL,
+  PRIMARY KEY (activity_id));
+
+CREATE TABLE IF NOT EXISTS activity_goals (
+  pet_id int REFERENCES pets (id) ON DELETE CASCADE,
+  weekly_minutes INT8 NOT NULL DEFAULT 0,
+  weekly_distance_meters FLOAT8 NOT NULL DEFAULT 0,
+  weekly_sessions INT NOT NULL DEFAULT 0,
+  updated_at TIMESTAMPTZ,
+  PRIMARY KEY (pet_id));
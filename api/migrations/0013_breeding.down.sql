@@ -0,0 +1,5 @@
+ALTER TABLE pets DROP COLUMN IF EXISTS registration_number;
+ALTER TABLE pets DROP COLUMN IF EXISTS litter_id;
+ALTER TABLE pets DROP COLUMN IF EXISTS dam_id;
+ALTER TABLE pets DROP COLUMN IF EXISTS sire_id;
+DROP TABLE IF EXISTS litters;
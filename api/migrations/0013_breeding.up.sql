@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS litters (
+  id SERIAL NOT NULL,
+  user_id int REFERENCES users (id) ON DELETE CASCADE,
+  sire_id int REFERENCES pets (id) ON DELETE SET NULL,
+  dam_id int REFERENCES pets (id) ON DELETE SET NULL,
+  whelped_on DATE NOT NULL,
+  registration_number STRING,
+  notes STRING,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  PRIMARY KEY (id),
+  INDEX (user_id, whelped_on));
+
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS sire_id int REFERENCES pets (id) ON DELETE SET NULL;
+
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS dam_id int REFERENCES pets (id) ON DELETE SET NULL;
+
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS litter_id int REFERENCES litters (id) ON DELETE SET NULL;
+
+ALTER TABLE pets ADD COLUMN IF NOT EXISTS registration_number STRING;
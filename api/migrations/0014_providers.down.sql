@@ -0,0 +1,4 @@
+ALTER TABLE medical_records DROP COLUMN IF EXISTS provider_id;
+DROP TABLE IF EXISTS appointments;
+DROP TABLE IF EXISTS pet_providers;
+DROP TABLE IF EXISTS providers;
@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS providers (
+  id SERIAL NOT NULL,
+  user_id int REFERENCES users (id) ON DELETE CASCADE,
+  kind STRING NOT NULL,
+  name STRING NOT NULL,
+  phone STRING,
+  email STRING,
+  website STRING,
+  address STRING,
+  hours STRING,
+  notes STRING,
+  emergency BOOL NOT NULL DEFAULT false,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  PRIMARY KEY (id),
+  INDEX (user_id, kind));
+
+CREATE TABLE IF NOT EXISTS pet_providers (
+  pet_id int REFERENCES pets (id) ON DELETE CASCADE,
+  provider_id int REFERENCES providers (id) ON DELETE CASCADE,
+  role STRING,
+  linked_at TIMESTAMPTZ,
+  PRIMARY KEY (pet_id, provider_id),
+  INDEX (provider_id));
+
+CREATE TABLE IF NOT EXISTS appointments (
+  id SERIAL NOT NULL,
+  pet_id int REFERENCES pets (id) ON DELETE CASCADE,
+  user_id int REFERENCES users (id) ON DELETE CASCADE,
+  provider_id int REFERENCES providers (id) ON DELETE SET NULL,
+  title STRING NOT NULL,
+  starts_at TIMESTAMPTZ NOT NULL,
+  ends_at TIMESTAMPTZ,
+  notes STRING,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  PRIMARY KEY (id),
+  INDEX (pet_id, starts_at),
+  INDEX (user_id));
+
+ALTER TABLE medical_records ADD COLUMN IF NOT EXISTS provider_id int REFERENCES providers (id) ON DELETE SET NULL;
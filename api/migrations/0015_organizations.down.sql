@@ -0,0 +1,6 @@
+ALTER TABLE medical_records DROP COLUMN IF EXISTS created_by;
+ALTER TABLE medical_records DROP COLUMN IF EXISTS org_id;
+DROP TABLE IF EXISTS pet_access_events;
+DROP TABLE IF EXISTS pet_access;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
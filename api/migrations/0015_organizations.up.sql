@@ -0,0 +1,49 @@
+CREATE TABLE IF NOT EXISTS organizations (
+  id SERIAL NOT NULL,
+  name STRING NOT NULL,
+  phone STRING,
+  address STRING,
+  created_by int REFERENCES users (id) ON DELETE SET NULL,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  PRIMARY KEY (id));
+
+CREATE TABLE IF NOT EXISTS organization_members (
+  org_id int REFERENCES organizations (id) ON DELETE CASCADE,
+  user_id int REFERENCES users (id) ON DELETE CASCADE,
+  role STRING NOT NULL,
+  added_at TIMESTAMPTZ,
+  PRIMARY KEY (org_id, user_id),
+  INDEX (user_id));
+
+CREATE TABLE IF NOT EXISTS pet_access (
+  id SERIAL NOT NULL,
+  org_id int REFERENCES organizations (id) ON DELETE CASCADE,
+  pet_id int REFERENCES pets (id) ON DELETE CASCADE,
+  owner_id int REFERENCES users (id) ON DELETE CASCADE,
+  status STRING NOT NULL,
+  message STRING,
+  requested_by int REFERENCES users (id),
+  requested_at TIMESTAMPTZ,
+  decided_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  PRIMARY KEY (id),
+  INDEX (org_id, status),
+  INDEX (owner_id, status),
+  INDEX (pet_id));
+
+CREATE TABLE IF NOT EXISTS pet_access_events (
+  id SERIAL NOT NULL,
+  access_id int REFERENCES pet_access (id) ON DELETE CASCADE,
+  org_id int REFERENCES organizations (id) ON DELETE CASCADE,
+  pet_id int REFERENCES pets (id) ON DELETE CASCADE,
+  actor_id int REFERENCES users (id),
+  action STRING NOT NULL,
+  created_at TIMESTAMPTZ,
+  PRIMARY KEY (id),
+  INDEX (pet_id, created_at),
+  INDEX (org_id, created_at));
+
+ALTER TABLE medical_records ADD COLUMN IF NOT EXISTS org_id int REFERENCES organizations (id) ON DELETE SET NULL;
+
+ALTER TABLE medical_records ADD COLUMN IF NOT EXISTS created_by int REFERENCES users (id) ON DELETE SET NULL;
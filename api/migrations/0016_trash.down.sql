@@ -0,0 +1,11 @@
+ALTER TABLE appointments DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE activities DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE pet_weights DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE pet_notes DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE insurance_claims DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE insurance_policies DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE medical_records DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE expenses DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE lost_pets DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE attachments DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE pets DROP COLUMN IF EXISTS deleted_at CASCADE;
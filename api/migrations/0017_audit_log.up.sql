@@ -0,0 +1,16 @@
+-- The audit log outlives what it describes, so it has no foreign keys
+CREATE TABLE IF NOT EXISTS audit_log (
+  id SERIAL NOT NULL,
+  actor_id int,
+  owner_id int NOT NULL,
+  action STRING NOT NULL,
+  entity_type STRING NOT NULL,
+  entity_id int NOT NULL,
+  old_values STRING,
+  new_values STRING,
+  request_id STRING,
+  ip STRING,
+  created_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (id),
+  INDEX (owner_id, id),
+  INDEX (entity_type, entity_id, id));
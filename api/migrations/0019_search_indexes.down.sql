@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS providers@providers_search_idx;
+DROP INDEX IF EXISTS medical_records@medical_records_search_idx;
+DROP INDEX IF EXISTS pet_notes@pet_notes_search_idx;
+DROP INDEX IF EXISTS pets@pets_search_idx;
@@ -0,0 +1,6 @@
+-- Full-text indexes for search, on the same expressions the search queries
+-- use. sqlite has none and searches with the built-in index instead.
+CREATE INVERTED INDEX IF NOT EXISTS pets_search_idx ON pets ((to_tsvector('english', COALESCE(name, '') || ' ' || COALESCE(breed, ''))));
+CREATE INVERTED INDEX IF NOT EXISTS pet_notes_search_idx ON pet_notes ((to_tsvector('english', COALESCE(title, '') || ' ' || body)));
+CREATE INVERTED INDEX IF NOT EXISTS medical_records_search_idx ON medical_records ((to_tsvector('english', title || ' ' || COALESCE(notes, ''))));
+CREATE INVERTED INDEX IF NOT EXISTS providers_search_idx ON providers ((to_tsvector('english', name)));
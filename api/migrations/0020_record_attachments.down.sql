@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS attachments@attachments_claim_id_idx;
+ALTER TABLE attachments DROP COLUMN IF EXISTS claim_id;
+DROP INDEX IF EXISTS attachments@attachments_record_id_idx;
+ALTER TABLE attachments DROP COLUMN IF EXISTS record_id;
@@ -0,0 +1,9 @@
+-- Documents may belong to a medical record or an insurance claim of the
+-- pet, and go with it when it is deleted
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS record_id int REFERENCES medical_records (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS attachments_record_id_idx ON attachments (record_id);
+
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS claim_id int REFERENCES insurance_claims (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS attachments_claim_id_idx ON attachments (claim_id);
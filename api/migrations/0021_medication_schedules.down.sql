@@ -0,0 +1,3 @@
+ALTER TABLE medical_records DROP COLUMN IF EXISTS course_days;
+ALTER TABLE medical_records DROP COLUMN IF EXISTS doses_per_day;
+ALTER TABLE medical_records DROP COLUMN IF EXISTS dose_frequency;
@@ -0,0 +1,7 @@
+-- The dosing schedule of medications: daily, weekly or monthly, the doses
+-- a day of a daily one and for how many days the course runs
+ALTER TABLE medical_records ADD COLUMN IF NOT EXISTS dose_frequency STRING;
+
+ALTER TABLE medical_records ADD COLUMN IF NOT EXISTS doses_per_day INT;
+
+ALTER TABLE medical_records ADD COLUMN IF NOT EXISTS course_days INT;
@@ -17,11 +17,12 @@ type user struct {
 	CreatedAt time.Time `json:"created_at" example:"2019-11-09T21:21:46+00:00"`
 	UpdatedAt time.Time `json:"updated_at"  example:"2019-11-09T21:21:46+00:00"`
 	LastLogin time.Time `json:"last_login"  example:"2019-11-09T21:21:46+00:00"`
+	Role      string    `json:"role" example:"owner"`
 }
 
 type userRequest struct {
-	Email    string `json:"email" example:"john.doe@email.com"`
-	Password string `json:"password" example:"passw0rd"`
+	Email    string `json:"email" example:"john.doe@email.com" validate:"required,email,max=254"`
+	Password string `json:"password" example:"passw0rd" validate:"required,max=72"`
 }
 
 type userResponse struct {
@@ -30,26 +31,70 @@ type userResponse struct {
 	CreatedAt time.Time `json:"created_at" example:"2019-11-09T21:21:46+00:00"`
 	UpdatedAt time.Time `json:"updated_at"  example:"2019-11-09T21:21:46+00:00"`
 	LastLogin time.Time `json:"last_login"  example:"2019-11-09T21:21:46+00:00"`
+	Role      string    `json:"role" example:"owner"`
 }
 
 type pet struct {
-	ID        uint      `json:"pet_id"`
-	UserID    uint      `json:"user_id"`
-	Name      string    `json:"name" example:"Fido"`
-	Type      string    `json:"type" example:"Dog"`
-	Gender    string    `json:"gender" example:"Female"`
-	Breed     string    `json:"breed" example:"Lab/Terrier Mix"`
-	Birthday  time.Time `json:"birthday" example:"2019-11-09T21:21:46+00:00"`
-	CreatedAt time.Time `json:"created_at"  example:"2019-11-09T21:21:46+00:00"`
-	UpdatedAt time.Time `json:"updated_at"  example:"2019-11-09T21:21:46+00:00"`
+	ID                 uint       `json:"pet_id"`
+	UserID             uint       `json:"user_id"`
+	Name               string     `json:"name" example:"Fido"`
+	Type               string     `json:"type" example:"Dog"`
+	Gender             string     `json:"gender" example:"Female"`
+	Breed              string     `json:"breed" example:"Lab/Terrier Mix"`
+	Birthday           time.Time  `json:"birthday" example:"2019-11-09T21:21:46+00:00"`
+	Microchip          string     `json:"microchip" example:"985112345678903"`
+	Tattoo             string     `json:"tattoo" example:"ABC123"`
+	LicenseTag         string     `json:"license_tag" example:"2019-004512"`
+	SireID             *uint      `json:"sire_id" example:"2"`
+	DamID              *uint      `json:"dam_id" example:"3"`
+	LitterID           *uint      `json:"litter_id" example:"1"`
+	RegistrationNumber string     `json:"registration_number" example:"SR12345678"`
+	CreatedAt          time.Time  `json:"created_at"  example:"2019-11-09T21:21:46+00:00"`
+	UpdatedAt          time.Time  `json:"updated_at"  example:"2019-11-09T21:21:46+00:00"`
+	DeletedAt          *time.Time `json:"deleted_at,omitempty" example:"2019-11-10T08:00:00+00:00"`
+	Version            int64      `json:"version" example:"3"`
 }
 
 type petRequest struct {
-	Name     string    `json:"name" example:"Fido"`
-	Type     string    `json:"type" example:"Dog"`
-	Breed    string    `json:"breed" example:"Lab/Terrier Mix"`
-	Gender   string    `json:"gender" example:"Female"`
-	Birthday time.Time `json:"birthday" example:"2019-11-09T21:21:46+00:00"`
+	Name               string    `json:"name" example:"Fido" validate:"required,max=100"`
+	Type               string    `json:"type" example:"Dog" validate:"max=100"`
+	Breed              string    `json:"breed" example:"Lab/Terrier Mix" validate:"max=200"`
+	Gender             string    `json:"gender" example:"Female" validate:"max=100"`
+	Birthday           time.Time `json:"birthday" example:"2019-11-09T21:21:46+00:00" validate:"min=1900-01-01,max=now"`
+	Microchip          string    `json:"microchip" example:"985112345678903"`
+	Tattoo             string    `json:"tattoo" example:"ABC123" validate:"max=32"`
+	LicenseTag         string    `json:"license_tag" example:"2019-004512" validate:"max=32"`
+	SireID             *uint     `json:"sire_id" example:"2" validate:"min=1"`
+	DamID              *uint     `json:"dam_id" example:"3" validate:"min=1"`
+	LitterID           *uint     `json:"litter_id" example:"1" validate:"min=1"`
+	RegistrationNumber string    `json:"registration_number" example:"SR12345678" validate:"max=32"`
+}
+
+//checkFields checks the rules spanning the fields of a pet
+func (pr petRequest) checkFields() []fieldError {
+	errs := []fieldError{}
+	if pr.SireID != nil && pr.DamID != nil && *pr.SireID == *pr.DamID {
+		errs = append(errs, fieldError{Field: "dam_id", Code: codeInvalidField, Message: "sire and dam must be different pets"})
+	}
+	return errs
+}
+
+//request returns the fields of a pet a client sets, to be validated
+func (p pet) request() petRequest {
+	return petRequest{
+		Name:               p.Name,
+		Type:               p.Type,
+		Breed:              p.Breed,
+		Gender:             p.Gender,
+		Birthday:           p.Birthday,
+		Microchip:          p.Microchip,
+		Tattoo:             p.Tattoo,
+		LicenseTag:         p.LicenseTag,
+		SireID:             p.SireID,
+		DamID:              p.DamID,
+		LitterID:           p.LitterID,
+		RegistrationNumber: p.RegistrationNumber,
+	}
 }
 
 type emptyBody struct{}
@@ -63,11 +108,13 @@ func (u user) MarshalJSON() ([]byte, error) {
 		CreatedAt time.Time `json:"created_at"`
 		UpdatedAt time.Time `json:"updated_at"`
 		LastLogin time.Time `json:"last_login"`
+		Role      string    `json:"role"`
 	}{
 		ID:        u.ID,
 		Email:     u.Email,
 		CreatedAt: u.CreatedAt,
 		UpdatedAt: u.UpdatedAt,
 		LastLogin: u.LastLogin,
+		Role:      u.Role,
 	})
 }
@@ -0,0 +1,559 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// orgRoleAdmin manages an organization and its staff
+	orgRoleAdmin = "admin"
+	// orgRoleVet can add medical records to pets the organization may access
+	orgRoleVet = "vet"
+	// orgRoleStaff can see pets and request access to them
+	orgRoleStaff = "staff"
+)
+
+// errLastOrgAdmin is returned when removing or demoting the only admin of
+// an organization
+var errLastOrgAdmin = errors.New("an organization must keep at least one admin")
+
+type organization struct {
+	ID        uint      `json:"organization_id" example:"1"`
+	Name      string    `json:"name" example:"Riverside Animal Hospital"`
+	Phone     string    `json:"phone" example:"+1 555 0100"`
+	Address   string    `json:"address" example:"12 River Rd, Springfield"`
+	Role      string    `json:"role,omitempty" example:"admin"`
+	CreatedAt time.Time `json:"created_at" example:"2019-11-09T21:21:46+00:00"`
+	UpdatedAt time.Time `json:"updated_at" example:"2019-11-09T21:21:46+00:00"`
+}
+
+type organizationRequest struct {
+	Name    string `json:"name" example:"Riverside Animal Hospital" validate:"required,max=200"`
+	Phone   string `json:"phone" example:"+1 555 0100" validate:"max=50"`
+	Address string `json:"address" example:"12 River Rd, Springfield" validate:"max=500"`
+}
+
+type organizations []organization
+
+type orgMember struct {
+	UserID  uint      `json:"user_id" example:"4"`
+	Email   string    `json:"email" example:"dr.patel@riverside.example"`
+	Role    string    `json:"role" example:"vet"`
+	AddedAt time.Time `json:"added_at" example:"2019-11-09T21:21:46+00:00"`
+}
+
+// orgMemberRequest adds a member by the email they signed up with, or
+// changes the role of an existing one
+type orgMemberRequest struct {
+	Email string `json:"email" example:"dr.patel@riverside.example" validate:"required,email"`
+	Role  string `json:"role" example:"vet" validate:"required,oneof=admin vet staff"`
+}
+
+type orgMembers []orgMember
+
+//validateOrganization normalizes and checks an organization request
+func validateOrganization(req *organizationRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Phone = strings.TrimSpace(req.Phone)
+	req.Address = strings.TrimSpace(req.Address)
+	return validate(req)
+}
+
+//orgIDFromRequest is a helper to extract the organization ID URL param
+func orgIDFromRequest(r *http.Request) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)["orgID"], 10, 64)
+}
+
+//orgMemberFromRequest extracts the authenticated user ID and the
+//organization ID URL param, and checks the user is a member of the
+//organization with one of the given roles, or any role when none are
+//given. It responds to the client itself when they aren't.
+func (s *server) orgMemberFromRequest(w http.ResponseWriter, r *http.Request, roles ...string) (userID, orgID int64, ok bool) {
+	userID, err := userIDFromRequest(r)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+		s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+		return 0, 0, false
+	}
+	orgID, err = orgIDFromRequest(r)
+	if err != nil {
+		s.respond(w, r, nil, "must provide a valid organization id", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	role, err := s.dbOrgRole(orgID, userID)
+	if err == sql.ErrNoRows {
+		s.respond(w, r, nil, "organization not found", http.StatusNotFound)
+		return 0, 0, false
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error retrieving organization role from database")
+		s.respond(w, r, nil, "error retrieving organization", http.StatusInternalServerError)
+		return 0, 0, false
+	}
+	if len(roles) == 0 {
+		return userID, orgID, true
+	}
+	for _, a := range roles {
+		if role == a {
+			return userID, orgID, true
+		}
+	}
+	s.respond(w, r, nil, "forbidden", http.StatusForbidden)
+	return 0, 0, false
+}
+
+//dbOrgRole returns the role of a user in an organization, sql.ErrNoRows
+//when they aren't a member
+func (s *server) dbOrgRole(orgID, userID int64) (string, error) {
+	var role string
+	err := s.db.QueryRow("SELECT role FROM organization_members WHERE org_id = $1 AND user_id = $2", orgID, userID).Scan(&role)
+	return role, err
+}
+
+//dbOrgsQuery returns the organizations a user is a member of matching a
+//condition, with the user's role in each
+func (s *server) dbOrgsQuery(cond string, args ...interface{}) ([]organization, error) {
+	rows, err := s.db.Query(`SELECT o.id, o.name, o.phone, o.address, m.role, o.created_at, o.updated_at
+		FROM organizations o JOIN organization_members m ON m.org_id = o.id WHERE `+cond+` ORDER BY o.name, o.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := []organization{}
+	for rows.Next() {
+		var o organization
+		var phone, address sql.NullString
+		err := rows.Scan(&o.ID, &o.Name, &phone, &address, &o.Role, &o.CreatedAt, &o.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		o.Phone, o.Address = phone.String, address.String
+		orgs = append(orgs, o)
+	}
+	return orgs, rows.Err()
+}
+
+//dbOrgsGetAll returns the organizations a user is a member of
+func (s *server) dbOrgsGetAll(userID int64) ([]organization, error) {
+	return s.dbOrgsQuery("m.user_id = $1", userID)
+}
+
+//dbOrgsGetOne returns an organization a user is a member of
+func (s *server) dbOrgsGetOne(orgID, userID int64) (organization, error) {
+	orgs, err := s.dbOrgsQuery("o.id = $1 AND m.user_id = $2", orgID, userID)
+	if err != nil {
+		return organization{}, err
+	}
+	if len(orgs) == 0 {
+		return organization{}, sql.ErrNoRows
+	}
+	return orgs[0], nil
+}
+
+//dbOrgsCreate stores a new organization with its creator as its first admin
+func (s *server) dbOrgsCreate(o organization, userID int64) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow("INSERT INTO organizations(name, phone, address, created_by, created_at, updated_at) VALUES($1,$2,$3,$4,$5,$6) RETURNING id",
+		o.Name, nullString(o.Phone), nullString(o.Address), userID, o.CreatedAt, o.UpdatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("INSERT INTO organization_members(org_id, user_id, role, added_at) VALUES($1,$2,$3,$4)", id, userID, orgRoleAdmin, o.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+//dbOrgsUpdate updates an organization
+func (s *server) dbOrgsUpdate(o organization) (int64, error) {
+	res, err := s.db.Exec("UPDATE organizations SET name = $1, phone = $2, address = $3, updated_at = $4 WHERE id = $5",
+		o.Name, nullString(o.Phone), nullString(o.Address), o.UpdatedAt, o.ID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbOrgMembersGetAll returns the members of an organization
+func (s *server) dbOrgMembersGetAll(orgID int64) ([]orgMember, error) {
+	rows, err := s.db.Query(`SELECT m.user_id, u.email, m.role, m.added_at FROM organization_members m JOIN users u ON u.id = m.user_id
+		WHERE m.org_id = $1 ORDER BY u.email`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []orgMember{}
+	for rows.Next() {
+		var m orgMember
+		err := rows.Scan(&m.UserID, &m.Email, &m.Role, &m.AddedAt)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+//dbOrgMembersSet adds the user with an email to an organization, or
+//changes their role if they already are a member. It returns
+//sql.ErrNoRows when no user has that email.
+func (s *server) dbOrgMembersSet(orgID int64, email, role string, ts time.Time) (orgMember, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return orgMember{}, err
+	}
+	defer tx.Rollback()
+
+	m := orgMember{Role: role, AddedAt: ts}
+	err = tx.QueryRow("SELECT id, email FROM users WHERE lower(email) = lower($1)", email).Scan(&m.UserID, &m.Email)
+	if err != nil {
+		return orgMember{}, err
+	}
+	var current string
+	err = tx.QueryRow("SELECT role, added_at FROM organization_members WHERE org_id = $1 AND user_id = $2", orgID, m.UserID).Scan(&current, &m.AddedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return orgMember{}, err
+	}
+	if current == orgRoleAdmin && role != orgRoleAdmin {
+		err = checkOtherOrgAdmins(tx, orgID, int64(m.UserID))
+		if err != nil {
+			return orgMember{}, err
+		}
+	}
+	_, err = tx.Exec(`INSERT INTO organization_members(org_id, user_id, role, added_at) VALUES($1,$2,$3,$4)
+		ON CONFLICT (org_id, user_id) DO UPDATE SET role = excluded.role, added_at = excluded.added_at`, orgID, m.UserID, role, m.AddedAt)
+	if err != nil {
+		return orgMember{}, err
+	}
+	return m, tx.Commit()
+}
+
+//dbOrgMembersRemove removes a member from an organization
+func (s *server) dbOrgMembersRemove(orgID, userID int64) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	err = checkOtherOrgAdmins(tx, orgID, userID)
+	if err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec("DELETE FROM organization_members WHERE org_id = $1 AND user_id = $2", orgID, userID)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return rows, tx.Commit()
+}
+
+//checkOtherOrgAdmins returns errLastOrgAdmin when a user is the only admin
+//of an organization
+func checkOtherOrgAdmins(tx *sql.Tx, orgID, userID int64) error {
+	var others, self int
+	err := tx.QueryRow(`SELECT COALESCE(sum(CASE WHEN user_id != $2 THEN 1 ELSE 0 END), 0), COALESCE(sum(CASE WHEN user_id = $2 THEN 1 ELSE 0 END), 0)
+		FROM organization_members WHERE org_id = $1 AND role = $3`,
+		orgID, userID, orgRoleAdmin).Scan(&others, &self)
+	if err != nil {
+		return err
+	}
+	if self > 0 && others == 0 {
+		return errLastOrgAdmin
+	}
+	return nil
+}
+
+// handlerOrgsGetAll godoc
+// @Summary Get my organizations
+// @Description Get the organizations the user is a member of, with their role in each
+// @Tags Organizations
+// @Produce json
+// @Success 200 {array} organization
+// @Security ApiKeyAuth
+// @Router /organizations [get]
+func (s *server) handlerOrgsGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+		orgs, err := s.dbOrgsGetAll(userID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving organizations from database")
+			s.respond(w, r, nil, "error retrieving organizations", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, orgs, "", http.StatusOK)
+	}
+}
+
+// handlerOrgsGetOne godoc
+// @Summary Get an organization
+// @Description Get an organization the user is a member of
+// @Tags Organizations
+// @Produce json
+// @Param OrgID path int true "Organization ID"
+// @Success 200 {object} organization
+// @Security ApiKeyAuth
+// @Router /organizations/{OrgID} [get]
+func (s *server) handlerOrgsGetOne() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, orgID, ok := s.orgMemberFromRequest(w, r)
+		if !ok {
+			return
+		}
+		o, err := s.dbOrgsGetOne(orgID, userID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving organization from database")
+			s.respond(w, r, nil, "error retrieving organization", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, o, "", http.StatusOK)
+	}
+}
+
+// handlerOrgsCreate godoc
+// @Summary Create an organization
+// @Description Sign a clinic up as an organization. The user creating it becomes its first admin.
+// @Tags Organizations
+// @Accept json
+// @Produce json
+// @Param organization body organizationRequest true "Create Organization"
+// @Success 201 {object} organization
+// @Security ApiKeyAuth
+// @Router /organizations [post]
+func (s *server) handlerOrgsCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Get JSON body, decode into an organization request and validate it
+		var req organizationRequest
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateOrganization(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking organization")
+			return
+		}
+
+		// Create the organization in the db
+		o := organization{
+			Name:      req.Name,
+			Phone:     req.Phone,
+			Address:   req.Address,
+			Role:      orgRoleAdmin,
+			CreatedAt: ts,
+			UpdatedAt: ts,
+		}
+		id, err := s.dbOrgsCreate(o, userID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error creating organization in database")
+			s.respond(w, r, nil, "error creating organization", http.StatusInternalServerError)
+			return
+		}
+		o.ID = uint(id)
+		s.respond(w, r, o, "", http.StatusCreated)
+	}
+}
+
+// handlerOrgsUpdate godoc
+// @Summary Update an organization
+// @Description Update an organization. Only its admins may do this.
+// @Tags Organizations
+// @Accept json
+// @Produce json
+// @Param OrgID path int true "Organization ID"
+// @Param organization body organizationRequest true "Updated Organization"
+// @Success 200 {object} organization
+// @Security ApiKeyAuth
+// @Router /organizations/{OrgID} [put]
+func (s *server) handlerOrgsUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, orgID, ok := s.orgMemberFromRequest(w, r, orgRoleAdmin)
+		if !ok {
+			return
+		}
+		o, err := s.dbOrgsGetOne(orgID, userID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving organization from database")
+			s.respond(w, r, nil, "error updating organization", http.StatusInternalServerError)
+			return
+		}
+
+		// Get JSON body, decode into an organization request and validate it
+		var req organizationRequest
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateOrganization(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking organization")
+			return
+		}
+
+		// Update the organization in the db
+		o.Name = req.Name
+		o.Phone = req.Phone
+		o.Address = req.Address
+		o.UpdatedAt = ts
+		_, err = s.dbOrgsUpdate(o)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error updating organization in database")
+			s.respond(w, r, nil, "error updating organization", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, o, "", http.StatusOK)
+	}
+}
+
+// handlerOrgMembersGetAll godoc
+// @Summary Get the members of an organization
+// @Description Get the staff of an organization with their roles
+// @Tags Organizations
+// @Produce json
+// @Param OrgID path int true "Organization ID"
+// @Success 200 {array} orgMember
+// @Security ApiKeyAuth
+// @Router /organizations/{OrgID}/members [get]
+func (s *server) handlerOrgMembersGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, orgID, ok := s.orgMemberFromRequest(w, r)
+		if !ok {
+			return
+		}
+		members, err := s.dbOrgMembersGetAll(orgID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving organization members from database")
+			s.respond(w, r, nil, "error retrieving members", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, members, "", http.StatusOK)
+	}
+}
+
+// handlerOrgMembersSet godoc
+// @Summary Add or change a member of an organization
+// @Description Add a user to an organization by the email they signed up with, or change the role of a member. Only admins may do this.
+// @Tags Organizations
+// @Accept json
+// @Produce json
+// @Param OrgID path int true "Organization ID"
+// @Param member body orgMemberRequest true "Member (role is admin, vet or staff)"
+// @Success 200 {object} orgMember
+// @Security ApiKeyAuth
+// @Router /organizations/{OrgID}/members [put]
+func (s *server) handlerOrgMembersSet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		_, orgID, ok := s.orgMemberFromRequest(w, r, orgRoleAdmin)
+		if !ok {
+			return
+		}
+
+		// Get JSON body and decode into a member request
+		var req orgMemberRequest
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		req.Email = strings.TrimSpace(req.Email)
+		req.Role = strings.ToLower(strings.TrimSpace(req.Role))
+		err = validate(req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking member")
+			return
+		}
+
+		m, err := s.dbOrgMembersSet(orgID, req.Email, req.Role, ts)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "no user has signed up with that email", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.respondError(w, r, err, "error updating member")
+			return
+		}
+		s.logger.Info().Int64("org_id", orgID).Uint("user_id", m.UserID).Str("role", m.Role).Msg("organization member changed")
+		s.respond(w, r, m, "", http.StatusOK)
+	}
+}
+
+// handlerOrgMembersRemove godoc
+// @Summary Remove a member from an organization
+// @Description Remove a user from an organization. Admins may remove anyone, other members only themselves. The last admin can't leave.
+// @Tags Organizations
+// @Param OrgID path int true "Organization ID"
+// @Param UserID path int true "User ID"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /organizations/{OrgID}/members/{UserID} [delete]
+func (s *server) handlerOrgMembersRemove() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, orgID, ok := s.orgMemberFromRequest(w, r)
+		if !ok {
+			return
+		}
+		memberID, err := strconv.ParseInt(mux.Vars(r)["userID"], 10, 64)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid user id", http.StatusBadRequest)
+			return
+		}
+		if memberID != userID {
+			role, err := s.dbOrgRole(orgID, userID)
+			if err != nil {
+				s.logger.Error().Err(err).Msg("error retrieving organization role from database")
+				s.respond(w, r, nil, "error removing member", http.StatusInternalServerError)
+				return
+			}
+			if role != orgRoleAdmin {
+				s.respond(w, r, nil, "forbidden", http.StatusForbidden)
+				return
+			}
+		}
+		rows, err := s.dbOrgMembersRemove(orgID, memberID)
+		if err != nil {
+			s.respondError(w, r, err, "error removing member")
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "member not found", http.StatusNotFound)
+			return
+		}
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
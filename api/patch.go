@@ -0,0 +1,280 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// mediaMergePatch is a JSON Merge Patch (RFC 7396), a partial document
+	// whose null members remove fields
+	mediaMergePatch = "application/merge-patch+json"
+	// mediaJSONPatch is a JSON Patch (RFC 6902), a list of operations
+	mediaJSONPatch = "application/json-patch+json"
+)
+
+// errPatchTestFailed is returned when the test operation of a JSON Patch
+// doesn't hold, the document is left as it was
+var errPatchTestFailed = errors.New("patch test operation failed")
+
+// jsonPatchOp is one operation of a JSON Patch. Members other than those
+// of the operation are ignored, as the RFC requires.
+type jsonPatchOp map[string]interface{}
+
+//mergePatch applies a JSON Merge Patch to a document decoded from JSON
+func mergePatch(doc, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	d, ok := doc.(map[string]interface{})
+	if !ok {
+		d = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(d, k)
+		} else {
+			d[k] = mergePatch(d[k], v)
+		}
+	}
+	return d
+}
+
+//applyJSONPatch applies the operations of a JSON Patch to a document
+//decoded from JSON, stopping at the first one that fails
+func applyJSONPatch(doc interface{}, ops []jsonPatchOp) (interface{}, error) {
+	for i, op := range ops {
+		var err error
+		doc, err = op.apply(doc)
+		if err == errPatchTestFailed {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("patch operation %d: %v", i, err)
+		}
+	}
+	return doc, nil
+}
+
+//pointer reads a JSON Pointer member of an operation
+func (op jsonPatchOp) pointer(name string) ([]string, error) {
+	s, ok := op[name].(string)
+	if !ok {
+		return nil, fmt.Errorf("must have a %s", name)
+	}
+	return parsePointer(s)
+}
+
+//apply applies a single operation to a document
+func (op jsonPatchOp) apply(doc interface{}) (interface{}, error) {
+	path, err := op.pointer("path")
+	if err != nil {
+		return nil, err
+	}
+	value, hasValue := op["value"]
+	name, _ := op["op"].(string)
+	switch name {
+	case "add":
+		if !hasValue {
+			return nil, errors.New("add must have a value")
+		}
+		return pointerAdd(doc, path, value)
+
+	case "remove":
+		doc, _, err = pointerRemove(doc, path)
+		return doc, err
+
+	case "replace":
+		if !hasValue {
+			return nil, errors.New("replace must have a value")
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		doc, _, err = pointerRemove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, value)
+
+	case "move", "copy":
+		from, err := op.pointer("from")
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		if name == "move" {
+			if len(from) < len(path) && reflect.DeepEqual(from, path[:len(from)]) {
+				return nil, errors.New("can't move a value into one of its children")
+			}
+			doc, v, err = pointerRemove(doc, from)
+		} else {
+			v, err = pointerGet(doc, from)
+			if err == nil {
+				v, err = deepCopyJSON(v)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, v)
+
+	case "test":
+		if !hasValue {
+			return nil, errors.New("test must have a value")
+		}
+		v, err := pointerGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(v, value) {
+			return nil, errPatchTestFailed
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown op %q", name)
+}
+
+//parsePointer splits a JSON Pointer (RFC 6901) into its reference tokens
+func parsePointer(s string) ([]string, error) {
+	if s == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("%q is not a JSON pointer", s)
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+//arrayIndex parses a token referring to an element of an array of length
+//n. When adding, the index may be n, written as "-" too, to append.
+func arrayIndex(token string, n int, adding bool) (int, error) {
+	if adding && token == "-" {
+		return n, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%q is not an array index", token)
+	}
+	if i > n || (i == n && !adding) {
+		return 0, fmt.Errorf("array index %d is out of bounds", i)
+	}
+	return i, nil
+}
+
+//pointerGet returns the value a pointer refers to
+func pointerGet(doc interface{}, path []string) (interface{}, error) {
+	for _, t := range path {
+		switch c := doc.(type) {
+		case map[string]interface{}:
+			v, ok := c[t]
+			if !ok {
+				return nil, fmt.Errorf("%q not found", t)
+			}
+			doc = v
+		case []interface{}:
+			i, err := arrayIndex(t, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = c[i]
+		default:
+			return nil, fmt.Errorf("%q not found", t)
+		}
+	}
+	return doc, nil
+}
+
+//pointerAdd adds a value where a pointer refers to, returning the changed
+//document. Members are replaced, array elements are inserted.
+func pointerAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	t, rest := path[0], path[1:]
+	switch c := doc.(type) {
+	case map[string]interface{}:
+		if len(rest) == 0 {
+			c[t] = value
+			return c, nil
+		}
+		child, ok := c[t]
+		if !ok {
+			return nil, fmt.Errorf("%q not found", t)
+		}
+		child, err := pointerAdd(child, rest, value)
+		c[t] = child
+		return c, err
+	case []interface{}:
+		i, err := arrayIndex(t, len(c), len(rest) == 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) == 0 {
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = value
+			return c, nil
+		}
+		c[i], err = pointerAdd(c[i], rest, value)
+		return c, err
+	}
+	return nil, fmt.Errorf("%q not found", t)
+}
+
+//pointerRemove removes the value a pointer refers to, returning the
+//changed document and the value
+func pointerRemove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("can't remove the whole document")
+	}
+	t, rest := path[0], path[1:]
+	switch c := doc.(type) {
+	case map[string]interface{}:
+		child, ok := c[t]
+		if !ok {
+			return nil, nil, fmt.Errorf("%q not found", t)
+		}
+		if len(rest) == 0 {
+			delete(c, t)
+			return c, child, nil
+		}
+		child, v, err := pointerRemove(child, rest)
+		c[t] = child
+		return c, v, err
+	case []interface{}:
+		i, err := arrayIndex(t, len(c), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			v := c[i]
+			return append(c[:i], c[i+1:]...), v, nil
+		}
+		var v interface{}
+		c[i], v, err = pointerRemove(c[i], rest)
+		return c, v, err
+	}
+	return nil, nil, fmt.Errorf("%q not found", t)
+}
+
+//deepCopyJSON copies a value decoded from JSON, so a copied value doesn't
+//share maps or slices with the original
+func deepCopyJSON(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var c interface{}
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
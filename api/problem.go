@@ -0,0 +1,143 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// mediaProblem is the media type of error responses, RFC 7807 problem
+// details
+const mediaProblem = "application/problem+json"
+
+// Codes of problems more specific than their status. Codes are part of
+// the API, clients match on them, so they never change once released.
+const (
+	codeValidation      = "validation_failed"
+	codeInvalidField    = "invalid"
+	codeUnknownField    = "unknown_field"
+	codeEmailTaken      = "email_taken"
+	codeMicrochipTaken  = "microchip_taken"
+	codeLastOrgAdmin    = "last_org_admin"
+	codePatchTestFailed = "patch_test_failed"
+	codeInvalidPatch    = "invalid_patch"
+)
+
+// statusCodes are the codes of problems that have nothing more specific to
+// say than their status
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusNotAcceptable:         "not_acceptable",
+	http.StatusConflict:              "conflict",
+	http.StatusGone:                  "gone",
+	http.StatusPreconditionFailed:    "precondition_failed",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusUnprocessableEntity:   "unprocessable_entity",
+	http.StatusTooManyRequests:       "too_many_requests",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusBadGateway:            "bad_gateway",
+	http.StatusServiceUnavailable:    "service_unavailable",
+}
+
+// problem is the body of an error response. Type is always about:blank,
+// Code tells problems apart and RequestID ties the response to the logs.
+type problem struct {
+	Type      string       `json:"type" example:"about:blank"`
+	Title     string       `json:"title" example:"Bad Request"`
+	Status    int          `json:"status" example:"400"`
+	Detail    string       `json:"detail,omitempty" example:"birthday must be a date"`
+	Instance  string       `json:"instance,omitempty" example:"/api/v1/pets"`
+	Code      string       `json:"code" example:"validation_failed"`
+	RequestID string       `json:"request_id,omitempty" example:"5f0c6a3e9b1d4c2a"`
+	Errors    []fieldError `json:"errors,omitempty"`
+}
+
+// fieldError is a problem with one field of a request body or query. Row
+// is set for the rows of an import, numbered from 1.
+type fieldError struct {
+	Row     int    `json:"row,omitempty" example:"3"`
+	Field   string `json:"field" example:"birthday"`
+	Code    string `json:"code" example:"invalid"`
+	Message string `json:"message" example:"birthday must be a date"`
+}
+
+// apiError is an error that knows the response it calls for. Code is left
+// empty for the code of the status.
+type apiError struct {
+	Status int
+	Code   string
+	Detail string
+	Fields []fieldError
+}
+
+func (e *apiError) Error() string {
+	return e.Detail
+}
+
+//invalidField returns the error of a single invalid field
+func invalidField(field, msg string) *apiError {
+	return &apiError{
+		Status: http.StatusBadRequest,
+		Code:   codeValidation,
+		Detail: msg,
+		Fields: []fieldError{{Field: field, Code: codeInvalidField, Message: msg}},
+	}
+}
+
+//respondProblem writes an error as problem details
+func (s *server) respondProblem(w http.ResponseWriter, r *http.Request, e *apiError) {
+	p := problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Detail:    e.Detail,
+		Instance:  r.URL.Path,
+		Code:      e.Code,
+		RequestID: requestIDFromRequest(r),
+		Errors:    e.Fields,
+	}
+	if p.Code == "" {
+		p.Code = statusCodes[e.Status]
+	}
+	if p.Code == "" {
+		p.Code = "error"
+	}
+	w.Header().Set("Content-Type", mediaProblem)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(e.Status)
+	err := json.NewEncoder(w).Encode(p)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error encoding problem")
+	}
+}
+
+//respondError responds with the problem an error stands for. Known errors
+//get their own status and code, a missing row is 404 Not Found and a
+//unique violation 409 Conflict. Anything else is logged and answered with
+//a 500 saying msg, the error itself may give away database details.
+func (s *server) respondError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	var e *apiError
+	switch {
+	case errors.As(err, &e):
+	case err == errEmailTaken:
+		e = &apiError{Status: http.StatusConflict, Code: codeEmailTaken, Detail: err.Error()}
+	case err == errMicrochipTaken:
+		e = &apiError{Status: http.StatusConflict, Code: codeMicrochipTaken, Detail: err.Error()}
+	case err == errLastOrgAdmin:
+		e = &apiError{Status: http.StatusConflict, Code: codeLastOrgAdmin, Detail: err.Error()}
+	case errors.Is(err, sql.ErrNoRows):
+		e = &apiError{Status: http.StatusNotFound, Detail: "not found"}
+	case isUniqueViolation(err):
+		e = &apiError{Status: http.StatusConflict, Detail: "already exists"}
+	default:
+		s.logger.Error().Err(err).Str("request_id", requestIDFromRequest(r)).Msg(msg)
+		e = &apiError{Status: http.StatusInternalServerError, Detail: msg}
+	}
+	s.respondProblem(w, r, e)
+}
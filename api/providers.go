@@ -0,0 +1,650 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// providerKinds are the kinds of care provider a user can keep in their
+// directory
+var providerKinds = map[string]bool{
+	"vet":              true,
+	"emergency_clinic": true,
+	"groomer":          true,
+	"sitter":           true,
+	"walker":           true,
+	"trainer":          true,
+	"boarding":         true,
+	"other":            true,
+}
+
+// maxUpcomingAppointments is how many upcoming appointments a care team lists
+const maxUpcomingAppointments = 5
+
+type provider struct {
+	ID        uint      `json:"provider_id" example:"1"`
+	UserID    uint      `json:"user_id" example:"1"`
+	Kind      string    `json:"kind" example:"vet"`
+	Name      string    `json:"name" example:"Riverside Animal Hospital"`
+	Phone     string    `json:"phone" example:"+1 555 0100"`
+	Email     string    `json:"email" example:"front-desk@riverside.example"`
+	Website   string    `json:"website" example:"https://riverside.example"`
+	Address   string    `json:"address" example:"12 River Rd, Springfield"`
+	Hours     string    `json:"hours" example:"Mon-Fri 8:00-18:00, Sat 9:00-13:00"`
+	Notes     string    `json:"notes" example:"Ask for Dr. Patel"`
+	Emergency bool      `json:"emergency" example:"false"`
+	CreatedAt time.Time `json:"created_at" example:"2019-11-09T21:21:46+00:00"`
+	UpdatedAt time.Time `json:"updated_at" example:"2019-11-09T21:21:46+00:00"`
+}
+
+// providerRequest holds a provider. Emergency clinics are always flagged
+// as emergency contacts.
+type providerRequest struct {
+	Kind      string `json:"kind" example:"vet" validate:"required,oneof=vet emergency_clinic groomer sitter walker trainer boarding other"`
+	Name      string `json:"name" example:"Riverside Animal Hospital" validate:"required,max=200"`
+	Phone     string `json:"phone" example:"+1 555 0100" validate:"max=50"`
+	Email     string `json:"email" example:"front-desk@riverside.example" validate:"email,max=254"`
+	Website   string `json:"website" example:"https://riverside.example" validate:"max=500"`
+	Address   string `json:"address" example:"12 River Rd, Springfield" validate:"max=500"`
+	Hours     string `json:"hours" example:"Mon-Fri 8:00-18:00, Sat 9:00-13:00" validate:"max=500"`
+	Notes     string `json:"notes" example:"Ask for Dr. Patel" validate:"max=2000"`
+	Emergency bool   `json:"emergency" example:"false"`
+}
+
+//checkFields checks the website of a provider, which a tag can't express
+func (req providerRequest) checkFields() []fieldError {
+	errs := []fieldError{}
+	if req.Website != "" && !strings.HasPrefix(req.Website, "http://") && !strings.HasPrefix(req.Website, "https://") {
+		errs = append(errs, fieldError{Field: "website", Code: codeInvalidField, Message: "website must be an http or https URL"})
+	}
+	return errs
+}
+
+type providers []provider
+
+// petProvider is a provider linked to a pet, with the role it plays for it
+type petProvider struct {
+	provider
+	Role string `json:"role" example:"Primary vet"`
+}
+
+type petProviderRequest struct {
+	Role string `json:"role" example:"Primary vet"`
+}
+
+// careTeam is everything a sitter needs to know about who looks after a pet
+type careTeam struct {
+	PetID                uint          `json:"pet_id" example:"1"`
+	PetName              string        `json:"pet_name" example:"Fido"`
+	Microchip            string        `json:"microchip,omitempty" example:"985112345678903"`
+	Providers            []petProvider `json:"providers"`
+	EmergencyContacts    []provider    `json:"emergency_contacts"`
+	UpcomingAppointments []appointment `json:"upcoming_appointments"`
+}
+
+const providerColumns = "id, user_id, kind, name, phone, email, website, address, hours, notes, emergency, created_at, updated_at"
+
+//scanProvider scans a row selected with providerColumns, followed by any
+//extra columns
+func scanProvider(row interface{ Scan(...interface{}) error }, extra ...interface{}) (provider, error) {
+	var p provider
+	var phone, email, website, address, hours, notes sql.NullString
+	dest := []interface{}{&p.ID, &p.UserID, &p.Kind, &p.Name, &phone, &email, &website, &address, &hours, &notes, &p.Emergency, &p.CreatedAt, &p.UpdatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return p, err
+	}
+	p.Phone, p.Email, p.Website = phone.String, email.String, website.String
+	p.Address, p.Hours, p.Notes = address.String, hours.String, notes.String
+	return p, nil
+}
+
+//validateProvider normalizes and checks a provider request
+func validateProvider(req *providerRequest) error {
+	req.Kind = strings.ToLower(strings.TrimSpace(req.Kind))
+	for _, f := range []*string{&req.Name, &req.Phone, &req.Email, &req.Website, &req.Address, &req.Hours, &req.Notes} {
+		*f = strings.TrimSpace(*f)
+	}
+	if req.Kind == "emergency_clinic" {
+		req.Emergency = true
+	}
+	return validate(req)
+}
+
+//providerIDFromRequest is a helper to extract the provider ID URL param
+func providerIDFromRequest(r *http.Request) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)["providerID"], 10, 64)
+}
+
+//checkProvider makes sure a provider is in the user's directory
+func (s *server) checkProvider(userID int64, providerID *uint) (bool, error) {
+	if providerID == nil {
+		return true, nil
+	}
+	_, err := s.dbProvidersGetOne(userID, int64(*providerID))
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+//dbProvidersQuery returns the providers of a user matching a condition on
+//the providers table, emergency contacts first and then by name
+func (s *server) dbProvidersQuery(cond string, args ...interface{}) ([]provider, error) {
+	rows, err := s.db.Query("SELECT "+providerColumns+" FROM providers WHERE "+cond+" ORDER BY emergency DESC, name, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ps := []provider{}
+	for rows.Next() {
+		p, err := scanProvider(rows)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, p)
+	}
+	return ps, rows.Err()
+}
+
+//dbProvidersGetAll returns the providers of a user, optionally of one kind
+func (s *server) dbProvidersGetAll(userID int64, kind string) ([]provider, error) {
+	if kind != "" {
+		return s.dbProvidersQuery("user_id = $1 AND kind = $2", userID, kind)
+	}
+	return s.dbProvidersQuery("user_id = $1", userID)
+}
+
+//dbProvidersGetOne returns a single provider of a user
+func (s *server) dbProvidersGetOne(userID, providerID int64) (provider, error) {
+	row := s.db.QueryRow("SELECT "+providerColumns+" FROM providers WHERE user_id = $1 AND id = $2", userID, providerID)
+	return scanProvider(row)
+}
+
+//dbProvidersCreate stores a new provider
+func (s *server) dbProvidersCreate(p provider) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`INSERT INTO providers(user_id, kind, name, phone, email, website, address, hours, notes, emergency, created_at, updated_at)
+		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12) RETURNING id`,
+		p.UserID, p.Kind, p.Name, nullString(p.Phone), nullString(p.Email), nullString(p.Website), nullString(p.Address),
+		nullString(p.Hours), nullString(p.Notes), p.Emergency, p.CreatedAt, p.UpdatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+//dbProvidersUpdate updates a provider of a user
+func (s *server) dbProvidersUpdate(p provider) (int64, error) {
+	res, err := s.db.Exec(`UPDATE providers SET kind = $1, name = $2, phone = $3, email = $4, website = $5, address = $6, hours = $7,
+		notes = $8, emergency = $9, updated_at = $10 WHERE id = $11 AND user_id = $12`,
+		p.Kind, p.Name, nullString(p.Phone), nullString(p.Email), nullString(p.Website), nullString(p.Address),
+		nullString(p.Hours), nullString(p.Notes), p.Emergency, p.UpdatedAt, p.ID, p.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbProvidersDelete deletes a provider of a user. Its pet links go with it,
+//records and appointments keep their history without it.
+func (s *server) dbProvidersDelete(userID, providerID int64) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM providers WHERE user_id = $1 AND id = $2", userID, providerID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbPetProvidersGetAll returns the providers linked to a pet owned by a user
+func (s *server) dbPetProvidersGetAll(userID, petID int64) ([]petProvider, error) {
+	rows, err := s.db.Query("SELECT "+providerColumns+", role FROM providers JOIN pet_providers ON provider_id = id WHERE user_id = $1 AND pet_id = $2 ORDER BY emergency DESC, kind, name, id",
+		userID, petID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ps := []petProvider{}
+	for rows.Next() {
+		var role sql.NullString
+		p, err := scanProvider(rows, &role)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, petProvider{provider: p, Role: role.String})
+	}
+	return ps, rows.Err()
+}
+
+//dbPetProvidersLink links a provider to a pet, or updates the role of an
+//existing link
+func (s *server) dbPetProvidersLink(petID, providerID int64, role string) error {
+	_, err := s.db.Exec(`INSERT INTO pet_providers(pet_id, provider_id, role, linked_at) VALUES($1,$2,$3,$4)
+		ON CONFLICT (pet_id, provider_id) DO UPDATE SET role = excluded.role, linked_at = excluded.linked_at`,
+		petID, providerID, nullString(role), time.Now())
+	return err
+}
+
+//dbPetProvidersUnlink removes a provider from a pet owned by a user
+func (s *server) dbPetProvidersUnlink(userID, petID, providerID int64) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM pet_providers WHERE pet_id = $1 AND provider_id = $2 AND provider_id IN (SELECT id FROM providers WHERE user_id = $3)",
+		petID, providerID, userID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbCareTeam collects the providers, emergency contacts and upcoming
+//appointments of a pet. Emergency contacts are all of the user's, whether
+//linked to the pet or not, since any open emergency clinic will do.
+func (s *server) dbCareTeam(userID, petID int64, now time.Time) (careTeam, error) {
+	p, err := s.pets.GetOne(userID, petID)
+	if err != nil {
+		return careTeam{}, err
+	}
+	team := careTeam{PetID: p.ID, PetName: p.Name, Microchip: p.Microchip}
+	team.Providers, err = s.dbPetProvidersGetAll(userID, petID)
+	if err != nil {
+		return careTeam{}, err
+	}
+	team.EmergencyContacts, err = s.dbProvidersQuery("user_id = $1 AND emergency", userID)
+	if err != nil {
+		return careTeam{}, err
+	}
+	team.UpcomingAppointments, err = s.dbAppointmentsQuery("user_id = $1 AND pet_id = $2 AND starts_at >= $3 ORDER BY starts_at, id LIMIT $4",
+		userID, petID, now, maxUpcomingAppointments)
+	if err != nil {
+		return careTeam{}, err
+	}
+	return team, nil
+}
+
+// handlerProvidersGetAll godoc
+// @Summary Get all providers
+// @Description Get the vets, groomers, sitters and other care providers in the user's directory, emergency contacts first
+// @Tags Providers
+// @Produce json
+// @Param kind query string false "Only providers of this kind"
+// @Success 200 {array} provider
+// @Security ApiKeyAuth
+// @Router /providers [get]
+func (s *server) handlerProvidersGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+		kind := strings.ToLower(r.URL.Query().Get("kind"))
+		if kind != "" && !providerKinds[kind] {
+			s.respondProblem(w, r, invalidField("kind", "unknown provider kind"))
+			return
+		}
+		ps, err := s.dbProvidersGetAll(userID, kind)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving providers from database")
+			s.respond(w, r, nil, "error retrieving providers", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, ps, "", http.StatusOK)
+	}
+}
+
+// handlerProvidersGetOne godoc
+// @Summary Get one provider
+// @Description Get one provider in the user's directory
+// @Tags Providers
+// @Produce json
+// @Param ProviderID path int true "Provider ID"
+// @Success 200 {object} provider
+// @Security ApiKeyAuth
+// @Router /providers/{ProviderID} [get]
+func (s *server) handlerProvidersGetOne() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+		providerID, err := providerIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid provider id", http.StatusBadRequest)
+			return
+		}
+		p, err := s.dbProvidersGetOne(userID, providerID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "provider not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving provider from database")
+			s.respond(w, r, nil, "error retrieving provider", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, p, "", http.StatusOK)
+	}
+}
+
+// handlerProvidersCreate godoc
+// @Summary Create a provider
+// @Description Add a vet, emergency clinic, groomer, sitter or other care provider to the user's directory
+// @Tags Providers
+// @Accept json
+// @Produce json
+// @Param provider body providerRequest true "Create Provider"
+// @Success 201 {object} provider
+// @Security ApiKeyAuth
+// @Router /providers [post]
+func (s *server) handlerProvidersCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+
+		// Get JSON body, decode into a provider request and validate it
+		var req providerRequest
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateProvider(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking provider")
+			return
+		}
+
+		// Create the provider in the db
+		p := provider{
+			UserID:    uint(userID),
+			Kind:      req.Kind,
+			Name:      req.Name,
+			Phone:     req.Phone,
+			Email:     req.Email,
+			Website:   req.Website,
+			Address:   req.Address,
+			Hours:     req.Hours,
+			Notes:     req.Notes,
+			Emergency: req.Emergency,
+			CreatedAt: ts,
+			UpdatedAt: ts,
+		}
+		id, err := s.dbProvidersCreate(p)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error creating provider in database")
+			s.respond(w, r, nil, "error creating provider", http.StatusInternalServerError)
+			return
+		}
+		p.ID = uint(id)
+		s.respond(w, r, p, "", http.StatusCreated)
+	}
+}
+
+// handlerProvidersUpdate godoc
+// @Summary Update a provider
+// @Description Update a provider in the user's directory
+// @Tags Providers
+// @Accept json
+// @Produce json
+// @Param ProviderID path int true "Provider ID"
+// @Param provider body providerRequest true "Updated Provider"
+// @Success 200 {object} provider
+// @Security ApiKeyAuth
+// @Router /providers/{ProviderID} [put]
+func (s *server) handlerProvidersUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+		providerID, err := providerIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid provider id", http.StatusBadRequest)
+			return
+		}
+		p, err := s.dbProvidersGetOne(userID, providerID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "provider not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving provider from database")
+			s.respond(w, r, nil, "error updating provider", http.StatusInternalServerError)
+			return
+		}
+
+		// Get JSON body, decode into a provider request and validate it
+		var req providerRequest
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateProvider(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking provider")
+			return
+		}
+
+		// Update the provider in the db
+		p.Kind = req.Kind
+		p.Name = req.Name
+		p.Phone = req.Phone
+		p.Email = req.Email
+		p.Website = req.Website
+		p.Address = req.Address
+		p.Hours = req.Hours
+		p.Notes = req.Notes
+		p.Emergency = req.Emergency
+		p.UpdatedAt = ts
+		_, err = s.dbProvidersUpdate(p)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error updating provider in database")
+			s.respond(w, r, nil, "error updating provider", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, p, "", http.StatusOK)
+	}
+}
+
+// handlerProvidersDelete godoc
+// @Summary Delete a provider
+// @Description Delete a provider from the user's directory. Medical records and appointments with it are kept.
+// @Tags Providers
+// @Param ProviderID path int true "Provider ID"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /providers/{ProviderID} [delete]
+func (s *server) handlerProvidersDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+		providerID, err := providerIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid provider id", http.StatusBadRequest)
+			return
+		}
+		rows, err := s.dbProvidersDelete(userID, providerID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error deleting provider from database")
+			s.respond(w, r, nil, "error deleting provider", http.StatusInternalServerError)
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "provider not found", http.StatusNotFound)
+			return
+		}
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
+
+// handlerPetProvidersGetAll godoc
+// @Summary Get the providers of a pet
+// @Description Get the providers linked to a pet with the role each plays for it
+// @Tags Providers
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Success 200 {array} petProvider
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/providers [get]
+func (s *server) handlerPetProvidersGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok || !s.requirePet(w, r, userID, petID) {
+			return
+		}
+		ps, err := s.dbPetProvidersGetAll(userID, petID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving pet providers from database")
+			s.respond(w, r, nil, "error retrieving providers", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, ps, "", http.StatusOK)
+	}
+}
+
+// handlerPetProvidersLink godoc
+// @Summary Link a provider to a pet
+// @Description Add a provider from the user's directory to the care team of a pet, or change the role it plays
+// @Tags Providers
+// @Accept json
+// @Param PetID path int true "Pet ID"
+// @Param ProviderID path int true "Provider ID"
+// @Param link body petProviderRequest false "Role of the provider"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/providers/{ProviderID} [put]
+func (s *server) handlerPetProvidersLink() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		providerID, err := providerIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid provider id", http.StatusBadRequest)
+			return
+		}
+
+		// The role is optional, so an empty body is fine
+		var req petProviderRequest
+		if r.ContentLength != 0 {
+			err = s.decode(w, r, &req)
+			if err != nil {
+				s.logger.Error().Err(err).Msg("error decoding JSON")
+				return
+			}
+		}
+		req.Role = strings.TrimSpace(req.Role)
+		if len(req.Role) > 100 {
+			s.respondProblem(w, r, invalidField("role", "role must not be longer than 100 characters"))
+			return
+		}
+
+		if !s.requirePet(w, r, userID, petID) {
+			return
+		}
+		id := uint(providerID)
+		ok, err = s.checkProvider(userID, &id)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving provider from database")
+			s.respond(w, r, nil, "error linking provider", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			s.respond(w, r, nil, "provider not found", http.StatusNotFound)
+			return
+		}
+		err = s.dbPetProvidersLink(petID, providerID, req.Role)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error linking provider in database")
+			s.respond(w, r, nil, "error linking provider", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
+
+// handlerPetProvidersUnlink godoc
+// @Summary Unlink a provider from a pet
+// @Description Remove a provider from the care team of a pet. It stays in the user's directory.
+// @Tags Providers
+// @Param PetID path int true "Pet ID"
+// @Param ProviderID path int true "Provider ID"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/providers/{ProviderID} [delete]
+func (s *server) handlerPetProvidersUnlink() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		providerID, err := providerIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid provider id", http.StatusBadRequest)
+			return
+		}
+		rows, err := s.dbPetProvidersUnlink(userID, petID, providerID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error unlinking provider in database")
+			s.respond(w, r, nil, "error unlinking provider", http.StatusInternalServerError)
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "provider is not linked to this pet", http.StatusNotFound)
+			return
+		}
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
+
+// handlerPetsCareTeam godoc
+// @Summary Get the care team of a pet
+// @Description Get everything a sitter needs in one call: the providers looking after a pet, the user's emergency contacts and the next appointments
+// @Tags Providers
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Success 200 {object} careTeam
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/care-team [get]
+func (s *server) handlerPetsCareTeam() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		team, err := s.dbCareTeam(userID, petID, time.Now())
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "pet not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving care team from database")
+			s.respond(w, r, nil, "error retrieving care team", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, team, "", http.StatusOK)
+	}
+}
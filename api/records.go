@@ -0,0 +1,514 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type medicalRecord struct {
+	ID         uint       `json:"record_id" example:"1"`
+	PetID      uint       `json:"pet_id" example:"1"`
+	UserID     uint       `json:"user_id" example:"1"`
+	ProviderID *uint      `json:"provider_id" example:"2"`
+	Kind       string     `json:"kind" example:"vaccination"`
+	Title      string     `json:"title" example:"Rabies booster"`
+	Notes      string     `json:"notes" example:"No reaction, next booster in 3 years"`
+	OccurredOn time.Time  `json:"occurred_on" example:"2019-11-09T00:00:00Z"`
+	DueOn      *time.Time `json:"due_on,omitempty" example:"2022-11-09T00:00:00Z"`
+	// The dosing schedule of a medication, how often it's given and for how
+	// many days from occurred_on
+	DoseFrequency string `json:"dose_frequency,omitempty" example:"daily"`
+	DosesPerDay   *int   `json:"doses_per_day,omitempty" example:"2"`
+	CourseDays    *int   `json:"course_days,omitempty" example:"10"`
+	// OrgID is set when clinic staff added the record, CreatedBy is whoever did
+	OrgID     *uint     `json:"organization_id,omitempty" example:"1"`
+	CreatedBy *uint     `json:"created_by,omitempty" example:"4"`
+	CreatedAt time.Time `json:"created_at" example:"2019-11-09T21:21:46+00:00"`
+	UpdatedAt time.Time `json:"updated_at" example:"2019-11-09T21:21:46+00:00"`
+}
+
+type medicalRecordRequest struct {
+	ProviderID *uint      `json:"provider_id" example:"2"`
+	Kind       string     `json:"kind" example:"vaccination" validate:"required,oneof=visit vaccination medication procedure lab other"`
+	Title      string     `json:"title" example:"Rabies booster" validate:"required,max=200"`
+	Notes      string     `json:"notes" example:"No reaction, next booster in 3 years"`
+	OccurredOn time.Time  `json:"occurred_on" example:"2019-11-09T00:00:00Z" validate:"required"`
+	DueOn      *time.Time `json:"due_on" example:"2022-11-09T00:00:00Z"`
+	// DoseFrequency is empty for a single dose
+	DoseFrequency string `json:"dose_frequency" example:"daily" validate:"oneof=daily weekly monthly"`
+	DosesPerDay   *int   `json:"doses_per_day" example:"2" validate:"min=1,max=24"`
+	CourseDays    *int   `json:"course_days" example:"10" validate:"min=1,max=3650"`
+}
+
+//checkFields checks the rules spanning the fields of a medical record
+func (req medicalRecordRequest) checkFields() []fieldError {
+	errs := []fieldError{}
+	if req.DueOn != nil && !req.OccurredOn.IsZero() && req.DueOn.Before(req.OccurredOn) {
+		errs = append(errs, fieldError{Field: "due_on", Code: codeOutOfRange, Message: "due_on must not be before occurred_on"})
+	}
+	switch {
+	case req.Kind != "medication" && (req.DoseFrequency != "" || req.DosesPerDay != nil || req.CourseDays != nil):
+		errs = append(errs, fieldError{Field: "dose_frequency", Code: codeNotAllowed, Message: "only medications have a dosing schedule"})
+	case req.DosesPerDay != nil && req.DoseFrequency != "daily":
+		errs = append(errs, fieldError{Field: "doses_per_day", Code: codeNotAllowed, Message: "doses_per_day needs a daily dose_frequency"})
+	case req.CourseDays != nil && req.DoseFrequency == "":
+		errs = append(errs, fieldError{Field: "course_days", Code: codeNotAllowed, Message: "course_days needs a dose_frequency"})
+	}
+	return errs
+}
+
+type medicalRecords []medicalRecord
+
+const recordColumns = "id, pet_id, user_id, provider_id, kind, title, notes, occurred_on, due_on, dose_frequency, doses_per_day, course_days, org_id, created_by, created_at, updated_at"
+
+//scanRecord scans a row selected with recordColumns
+func scanRecord(row interface{ Scan(...interface{}) error }) (medicalRecord, error) {
+	var m medicalRecord
+	var notes sql.NullString
+	var due sql.NullTime
+	var freq sql.NullString
+	var perDay, courseDays sql.NullInt64
+	var providerID, orgID, createdBy sql.NullInt64
+	err := row.Scan(&m.ID, &m.PetID, &m.UserID, &providerID, &m.Kind, &m.Title, &notes, &m.OccurredOn, &due, &freq, &perDay, &courseDays, &orgID, &createdBy, &m.CreatedAt, &m.UpdatedAt)
+	if err != nil {
+		return m, err
+	}
+	m.Notes = notes.String
+	m.DoseFrequency = freq.String
+	m.DosesPerDay, m.CourseDays = nullIntPtr(perDay), nullIntPtr(courseDays)
+	m.ProviderID = nullIDPtr(providerID)
+	m.OrgID, m.CreatedBy = nullIDPtr(orgID), nullIDPtr(createdBy)
+	if due.Valid {
+		m.DueOn = &due.Time
+	}
+	return m, nil
+}
+
+//validateRecord normalizes and checks a medical record request
+func validateRecord(req *medicalRecordRequest) error {
+	req.Kind = strings.ToLower(strings.TrimSpace(req.Kind))
+	req.Title = strings.TrimSpace(req.Title)
+	req.DoseFrequency = strings.ToLower(strings.TrimSpace(req.DoseFrequency))
+	return validate(req)
+}
+
+//userAndPetFromRequest extracts the authenticated user ID and the pet ID URL
+//param, responding to the client itself when either is missing
+func (s *server) userAndPetFromRequest(w http.ResponseWriter, r *http.Request) (userID, petID int64, ok bool) {
+	userID, err := userIDFromRequest(r)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+		s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+		return 0, 0, false
+	}
+	petID, err = petIDFromRequest(r)
+	if err != nil {
+		s.respond(w, r, nil, "must provide a valid pet id", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return userID, petID, true
+}
+
+//requirePet checks that a pet exists and is the user's, responding to the
+//client itself when it isn't
+func (s *server) requirePet(w http.ResponseWriter, r *http.Request, userID, petID int64) bool {
+	ok, err := s.pets.Exists(userID, petID)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error retrieving pet from database")
+		s.respond(w, r, nil, "error retrieving pet", http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		s.respond(w, r, nil, "pet not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+//recordIDFromRequest is a helper to extract the medical record ID URL param
+func recordIDFromRequest(r *http.Request) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)["recordID"], 10, 64)
+}
+
+//dbRecordsGetAll returns the medical records of a pet owned by a user
+func (s *server) dbRecordsGetAll(userID, petID int64) ([]medicalRecord, error) {
+	rows, err := s.db.Query("SELECT "+recordColumns+" FROM medical_records WHERE user_id = $1 AND pet_id = $2 AND deleted_at IS NULL ORDER BY occurred_on DESC, id DESC", userID, petID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recs := []medicalRecord{}
+	for rows.Next() {
+		m, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, m)
+	}
+	return recs, rows.Err()
+}
+
+//dbRecordsGetOne returns a single medical record of a pet owned by a user
+func (s *server) dbRecordsGetOne(userID, petID, recordID int64) (medicalRecord, error) {
+	row := s.db.QueryRow("SELECT "+recordColumns+" FROM medical_records WHERE user_id = $1 AND pet_id = $2 AND id = $3 AND deleted_at IS NULL", userID, petID, recordID)
+	return scanRecord(row)
+}
+
+//dbRecordsCreate stores a new medical record
+func (s *server) dbRecordsCreate(m medicalRecord) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`INSERT INTO medical_records(pet_id, user_id, provider_id, kind, title, notes, occurred_on, due_on, dose_frequency, doses_per_day, course_days, org_id, created_by, created_at, updated_at)
+		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15) RETURNING id`,
+		m.PetID, m.UserID, m.ProviderID, m.Kind, m.Title, m.Notes, m.OccurredOn, m.DueOn, nullString(m.DoseFrequency), m.DosesPerDay, m.CourseDays, m.OrgID, m.CreatedBy, m.CreatedAt, m.UpdatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+//dbRecordsUpdate updates a medical record of a pet owned by a user
+func (s *server) dbRecordsUpdate(m medicalRecord) (int64, error) {
+	res, err := s.db.Exec("UPDATE medical_records SET provider_id = $1, kind = $2, title = $3, notes = $4, occurred_on = $5, due_on = $6, dose_frequency = $7, doses_per_day = $8, course_days = $9, updated_at = $10 WHERE id = $11 AND user_id = $12 AND pet_id = $13 AND deleted_at IS NULL",
+		m.ProviderID, m.Kind, m.Title, m.Notes, m.OccurredOn, m.DueOn, nullString(m.DoseFrequency), m.DosesPerDay, m.CourseDays, m.UpdatedAt, m.ID, m.UserID, m.PetID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbRecordsDelete deletes a medical record of a pet owned by a user
+func (s *server) dbRecordsDelete(userID, petID, recordID int64) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM medical_records WHERE user_id = $1 AND pet_id = $2 AND id = $3 AND deleted_at IS NULL", userID, petID, recordID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//dbRecordEvents returns the calendar events of a user's medical records:
+//the doses of medications and the due dates of vaccinations, boosters and
+//any other record that has one
+func (s *server) dbRecordEvents(userID int64) ([]calendarEvent, error) {
+	rows, err := s.db.Query(`SELECT m.id, m.kind, m.title, m.notes, m.occurred_on, m.due_on, m.dose_frequency, m.doses_per_day, m.course_days, m.created_at, m.updated_at, pets.name
+		FROM medical_records m JOIN pets ON pets.id = m.pet_id
+		WHERE m.user_id = $1 AND (m.kind = 'medication' OR m.due_on IS NOT NULL) AND m.deleted_at IS NULL AND pets.deleted_at IS NULL`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []calendarEvent
+	for rows.Next() {
+		var m medicalRecord
+		var petName string
+		var notes, freq sql.NullString
+		var due sql.NullTime
+		var perDay, courseDays sql.NullInt64
+		err := rows.Scan(&m.ID, &m.Kind, &m.Title, &notes, &m.OccurredOn, &due, &freq, &perDay, &courseDays, &m.CreatedAt, &m.UpdatedAt, &petName)
+		if err != nil {
+			return nil, err
+		}
+		m.Notes, m.DoseFrequency = notes.String, freq.String
+		m.DosesPerDay, m.CourseDays = nullIntPtr(perDay), nullIntPtr(courseDays)
+		if m.Kind == "medication" {
+			events = append(events, medicationEvent(m, petName))
+		}
+		if due.Valid {
+			events = append(events, calendarEvent{
+				UID:          fmt.Sprintf("record-%d-due@petkeep", m.ID),
+				Summary:      fmt.Sprintf("%s: %s due", petName, m.Title),
+				Description:  m.Notes,
+				Start:        due.Time,
+				AllDay:       true,
+				Created:      m.CreatedAt,
+				LastModified: m.UpdatedAt,
+			})
+		}
+	}
+	return events, rows.Err()
+}
+
+//medicationEvent builds the event of a medication, recurring on the days
+//of its dosing schedule. Doses have no time of day, so the doses of a day
+//are one all day event saying how many there are.
+func medicationEvent(m medicalRecord, petName string) calendarEvent {
+	e := calendarEvent{
+		UID:          fmt.Sprintf("record-%d@petkeep", m.ID),
+		Summary:      fmt.Sprintf("%s: %s", petName, m.Title),
+		Description:  m.Notes,
+		Start:        m.OccurredOn,
+		AllDay:       true,
+		Created:      m.CreatedAt,
+		LastModified: m.UpdatedAt,
+	}
+	if m.DoseFrequency == "" {
+		return e
+	}
+	e.RRule = "FREQ=" + strings.ToUpper(m.DoseFrequency)
+	if m.CourseDays != nil {
+		// UNTIL is inclusive, so the course ends a day short of its length
+		last := m.OccurredOn.AddDate(0, 0, *m.CourseDays-1)
+		e.RRule += ";UNTIL=" + last.Format("20060102")
+	}
+	if m.DosesPerDay != nil && *m.DosesPerDay > 1 {
+		e.Summary = fmt.Sprintf("%s: %s, %d doses", petName, m.Title, *m.DosesPerDay)
+	}
+	return e
+}
+
+// handlerRecordsGetAll godoc
+// @Summary Get all medical records of a pet
+// @Description Get all medical records of a pet, newest first
+// @Tags Medical Records
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Success 200 {array} medicalRecord
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/records [get]
+func (s *server) handlerRecordsGetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		recs, err := s.dbRecordsGetAll(userID, petID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving medical records from database")
+			s.respond(w, r, nil, "error retrieving medical records", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, recs, "", http.StatusOK)
+	}
+}
+
+// handlerRecordsGetOne godoc
+// @Summary Get one medical record
+// @Description Get one medical record of a pet
+// @Tags Medical Records
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param RecordID path int true "Record ID"
+// @Success 200 {object} medicalRecord
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/records/{RecordID} [get]
+func (s *server) handlerRecordsGetOne() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		recordID, err := recordIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid record id", http.StatusBadRequest)
+			return
+		}
+		m, err := s.dbRecordsGetOne(userID, petID, recordID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "medical record not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving medical record from database")
+			s.respond(w, r, nil, "error retrieving medical record", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, m, "", http.StatusOK)
+	}
+}
+
+// handlerRecordsCreate godoc
+// @Summary Create a medical record
+// @Description Record a vet visit, vaccination, medication, procedure or lab result for a pet, optionally with the provider from the user's directory
+// @Tags Medical Records
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param record body medicalRecordRequest true "Create Medical Record"
+// @Success 201 {object} medicalRecord
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/records [post]
+func (s *server) handlerRecordsCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok || !s.requirePet(w, r, userID, petID) {
+			return
+		}
+
+		// Get JSON body, decode into a record request and validate it
+		var req medicalRecordRequest
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateRecord(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking medical record")
+			return
+		}
+		ok, err = s.checkProvider(userID, req.ProviderID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving provider from database")
+			s.respond(w, r, nil, "error creating medical record", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			s.respondProblem(w, r, invalidField("provider_id", "provider not found"))
+			return
+		}
+
+		// Create the record in the db
+		uid := uint(userID)
+		m := medicalRecord{
+			PetID:         uint(petID),
+			UserID:        uint(userID),
+			ProviderID:    req.ProviderID,
+			CreatedBy:     &uid,
+			Kind:          req.Kind,
+			Title:         req.Title,
+			Notes:         req.Notes,
+			OccurredOn:    req.OccurredOn,
+			DueOn:         req.DueOn,
+			DoseFrequency: req.DoseFrequency,
+			DosesPerDay:   req.DosesPerDay,
+			CourseDays:    req.CourseDays,
+			CreatedAt:     ts,
+			UpdatedAt:     ts,
+		}
+		id, err := s.dbRecordsCreate(m)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error creating medical record in database")
+			s.respond(w, r, nil, "error creating medical record", http.StatusInternalServerError)
+			return
+		}
+		m.ID = uint(id)
+		s.respond(w, r, m, "", http.StatusCreated)
+	}
+}
+
+// handlerRecordsUpdate godoc
+// @Summary Update a medical record
+// @Description Update a medical record of a pet
+// @Tags Medical Records
+// @Accept json
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param RecordID path int true "Record ID"
+// @Param record body medicalRecordRequest true "Updated Medical Record"
+// @Success 200 {object} medicalRecord
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/records/{RecordID} [put]
+func (s *server) handlerRecordsUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		recordID, err := recordIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid record id", http.StatusBadRequest)
+			return
+		}
+		m, err := s.dbRecordsGetOne(userID, petID, recordID)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "medical record not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving medical record from database")
+			s.respond(w, r, nil, "error updating medical record", http.StatusInternalServerError)
+			return
+		}
+
+		// Get JSON body, decode into a record request and validate it
+		var req medicalRecordRequest
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		err = validateRecord(&req)
+		if err != nil {
+			s.respondError(w, r, err, "error checking medical record")
+			return
+		}
+		ok, err = s.checkProvider(userID, req.ProviderID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving provider from database")
+			s.respond(w, r, nil, "error updating medical record", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			s.respondProblem(w, r, invalidField("provider_id", "provider not found"))
+			return
+		}
+
+		// Update the record in the db
+		m.ProviderID = req.ProviderID
+		m.Kind = req.Kind
+		m.Title = req.Title
+		m.Notes = req.Notes
+		m.OccurredOn = req.OccurredOn
+		m.DueOn = req.DueOn
+		m.DoseFrequency, m.DosesPerDay, m.CourseDays = req.DoseFrequency, req.DosesPerDay, req.CourseDays
+		m.UpdatedAt = ts
+		_, err = s.dbRecordsUpdate(m)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error updating medical record in database")
+			s.respond(w, r, nil, "error updating medical record", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, m, "", http.StatusOK)
+	}
+}
+
+// handlerRecordsDelete godoc
+// @Summary Delete a medical record
+// @Description Delete a medical record of a pet
+// @Tags Medical Records
+// @Param PetID path int true "Pet ID"
+// @Param RecordID path int true "Record ID"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/records/{RecordID} [delete]
+func (s *server) handlerRecordsDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		recordID, err := recordIDFromRequest(r)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid record id", http.StatusBadRequest)
+			return
+		}
+		// Look up the attachments first, their blobs go with the rows
+		atts, err := s.dbAttachmentsGetRecord(userID, petID, recordID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving attachments from database")
+			s.respond(w, r, nil, "error deleting medical record", http.StatusInternalServerError)
+			return
+		}
+		rows, err := s.dbRecordsDelete(userID, petID, recordID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error deleting medical record from database")
+			s.respond(w, r, nil, "error deleting medical record", http.StatusInternalServerError)
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "medical record not found", http.StatusNotFound)
+			return
+		}
+		s.deleteBlobs(r.Context(), atts)
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// roleOwner is the default role of every user, a pet owner
+	roleOwner = "owner"
+	// roleShelter is given to animal shelter staff
+	roleShelter = "shelter"
+	// roleVet is given to veterinary staff
+	roleVet = "vet"
+	// roleAdmin is given to petkeep administrators
+	roleAdmin = "admin"
+)
+
+// validRoles are the roles a user may be given
+var validRoles = map[string]bool{
+	roleOwner:   true,
+	roleShelter: true,
+	roleVet:     true,
+	roleAdmin:   true,
+}
+
+type roleRequest struct {
+	Role string `json:"role" example:"shelter"`
+}
+
+//requireRole only lets authenticated users with one of the given roles through.
+//The role is read from the database so changes take effect immediately.
+func (s *server) requireRole(allowed ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, err := userIDFromRequest(r)
+			if err != nil {
+				s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+				s.respond(w, r, nil, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			role, err := s.users.GetRole(id)
+			if err != nil {
+				s.logger.Error().Err(err).Msg("error retrieving user role from database")
+				s.respond(w, r, nil, "forbidden", http.StatusForbidden)
+				return
+			}
+			for _, a := range allowed {
+				if role == a {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			s.respond(w, r, nil, "forbidden", http.StatusForbidden)
+		})
+	}
+}
+
+// handlerUsersRoleUpdate godoc
+// @Summary Change a user's role
+// @Description Change the role of a user. Only admins may do this.
+// @Tags Admin
+// @Accept json
+// @Param UserID path int true "User ID"
+// @Param role body roleRequest true "Role (owner, shelter, vet or admin)"
+// @Success 200 {object} emptyBody
+// @Security ApiKeyAuth
+// @Router /admin/users/{UserID}/role [put]
+func (s *server) handlerUsersRoleUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Get URL params
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			s.respond(w, r, nil, "must provide a valid user id", http.StatusBadRequest)
+			return
+		}
+
+		// Get JSON body and decode into a role request
+		var req roleRequest
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error decoding JSON")
+			return
+		}
+		if !validRoles[req.Role] {
+			s.respond(w, r, nil, "role must be one of owner, shelter, vet or admin", http.StatusBadRequest)
+			return
+		}
+
+		// Keep the role as it was for the audit log
+		oldRole, err := s.users.GetRole(id)
+		if err == sql.ErrNoRows {
+			s.respond(w, r, nil, "user not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user role from database")
+			s.respond(w, r, nil, "error updating role", http.StatusInternalServerError)
+			return
+		}
+
+		rows, err := s.users.SetRole(id, req.Role)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error updating user role in database")
+			s.respond(w, r, nil, "error updating role", http.StatusInternalServerError)
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "user not found", http.StatusNotFound)
+			return
+		}
+		s.logger.Info().Int64("user_id", id).Str("role", req.Role).Msg("user role changed")
+		s.audit(r, auditEntry{OwnerID: uint(id), Action: auditUpdate, EntityType: auditEntityUser, EntityID: uint(id)},
+			roleRequest{Role: oldRole}, req)
+		s.respond(w, r, nil, "", http.StatusOK)
+	}
+}
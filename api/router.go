@@ -19,13 +19,21 @@ import (
 // @name Authorization
 func (s *server) routes() {
 
-	// Setup the new router
+	// Setup the new router, answering unknown paths with problem details too
 	s.router = mux.NewRouter()
+	s.router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.respond(w, r, nil, "no such endpoint", http.StatusNotFound)
+	})
+	s.router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.respond(w, r, nil, "method not allowed on this endpoint", http.StatusMethodNotAllowed)
+	})
 
 	// set up default middleware on the router
+	s.router.Use(s.requestID)
 	s.router.Use(s.httpLogger)
 	s.router.Use(s.httpTiming)
 	s.router.Use(s.httpCount)
+	s.router.Use(s.invalidateSearch)
 
 	// Static folder to serve
 	staticDir := "/docs/"
@@ -35,6 +43,11 @@ func (s *server) routes() {
 	s.router.PathPrefix("/swagger/").Handler(HTTPSwagger.Handler(HTTPSwagger.URL(fmt.Sprintf("http://%s:%s/docs/swagger.json", s.serverHost, s.listenPort))))
 	s.router.Path("/api/"+version+"/login").Handler(s.handlerLogin()).Methods("POST", "OPTIONS")
 	s.router.Path("/api/" + version + "/users").Handler(s.handlerUsersCreate()).Methods("POST")
+	s.router.Path("/api/" + version + "/calendar.ics").Handler(s.handlerCalendarFeed()).Methods("GET")
+	s.router.Path("/lost/{slug}").Handler(s.handlerLostPage()).Methods("GET", "POST")
+	s.router.Path("/api/" + version + "/lost/{slug}").Handler(s.handlerLostProfile()).Methods("GET")
+	s.router.Path("/api/" + version + "/lost/{slug}/photo").Handler(s.handlerLostPhoto()).Methods("GET")
+	s.router.Path("/api/" + version + "/lost/{slug}/contact").Handler(s.handlerLostContact()).Methods("POST")
 
 	// Set up the top level api subrouter
 	api := s.router.PathPrefix("/api/" + version).Subrouter()
@@ -46,12 +59,167 @@ func (s *server) routes() {
 	users := api.PathPrefix("/users").Subrouter().StrictSlash(true)
 	users.HandleFunc("", s.handlerUsersGetOne()).Methods("GET")
 	users.HandleFunc("/reset_password", s.handlerResetPassword()).Methods("POST")
+	users.HandleFunc("/calendar_token", s.handlerCalendarTokenCreate()).Methods("POST")
+	users.HandleFunc("/calendar_token", s.handlerCalendarTokenRevoke()).Methods("DELETE")
 
 	// Set up pets paths
 	pets := api.PathPrefix("/pets").Subrouter().StrictSlash(true)
 	pets.HandleFunc("", s.handlerPetsGetAll()).Methods("GET")
+	pets.HandleFunc("/trash", s.handlerPetsTrash()).Methods("GET")
+	pets.HandleFunc("/export", s.handlerPetsExport()).Methods("GET")
+	pets.HandleFunc("/import", s.handlerPetsImport()).Methods("POST")
 	pets.HandleFunc("/{id}", s.handlerPetsGetOne()).Methods("GET")
 	pets.HandleFunc("", s.handlerPetsCreate()).Methods("POST")
 	pets.HandleFunc("/{id}", s.handlerPetsUpdate()).Methods("PUT")
+	pets.HandleFunc("/{id}", s.handlerPetsPatch()).Methods("PATCH")
 	pets.HandleFunc("/{id}", s.handlerPetsDelete()).Methods("DELETE")
+	pets.HandleFunc("/{id}/restore", s.handlerPetsRestore()).Methods("POST")
+
+	// Set up pet photo and attachment paths
+	pets.HandleFunc("/{id}/photo", s.handlerPetsPhotoGet()).Methods("GET")
+	pets.HandleFunc("/{id}/photo", s.handlerPetsPhotoUpdate()).Methods("PUT")
+	pets.HandleFunc("/{id}/photo", s.handlerPetsPhotoDelete()).Methods("DELETE")
+	pets.HandleFunc("/{id}/photo/thumbnail", s.handlerPetsPhotoThumbnail()).Methods("GET")
+	pets.HandleFunc("/{id}/attachments", s.handlerAttachmentsGetAll()).Methods("GET")
+	pets.HandleFunc("/{id}/attachments", s.handlerAttachmentsCreate()).Methods("POST")
+	pets.HandleFunc("/{id}/attachments/{attachmentID}", s.handlerAttachmentsGetOne()).Methods("GET")
+	pets.HandleFunc("/{id}/attachments/{attachmentID}", s.handlerAttachmentsDelete()).Methods("DELETE")
+	pets.HandleFunc("/{id}/attachments/{attachmentID}/thumbnail", s.handlerAttachmentsThumbnail()).Methods("GET")
+
+	// Set up lost pet paths
+	pets.HandleFunc("/{id}/lost", s.handlerLostGetOne()).Methods("GET")
+	pets.HandleFunc("/{id}/lost", s.handlerLostUpdate()).Methods("PUT")
+	pets.HandleFunc("/{id}/lost", s.handlerLostFound()).Methods("DELETE")
+	pets.HandleFunc("/{id}/lost/messages", s.handlerLostMessagesGetAll()).Methods("GET")
+
+	// Set up expense paths
+	pets.HandleFunc("/{id}/expenses", s.handlerExpensesGetAll()).Methods("GET")
+	pets.HandleFunc("/{id}/expenses", s.handlerExpensesCreate()).Methods("POST")
+	pets.HandleFunc("/{id}/expenses/{expenseID}", s.handlerExpensesGetOne()).Methods("GET")
+	pets.HandleFunc("/{id}/expenses/{expenseID}", s.handlerExpensesUpdate()).Methods("PUT")
+	pets.HandleFunc("/{id}/expenses/{expenseID}", s.handlerExpensesDelete()).Methods("DELETE")
+	api.HandleFunc("/expenses/report", s.handlerExpensesReport()).Methods("GET")
+
+	// Set up medical record paths
+	pets.HandleFunc("/{id}/records", s.handlerRecordsGetAll()).Methods("GET")
+	pets.HandleFunc("/{id}/records", s.handlerRecordsCreate()).Methods("POST")
+	pets.HandleFunc("/{id}/records/{recordID}", s.handlerRecordsGetOne()).Methods("GET")
+	pets.HandleFunc("/{id}/records/{recordID}", s.handlerRecordsUpdate()).Methods("PUT")
+	pets.HandleFunc("/{id}/records/{recordID}", s.handlerRecordsDelete()).Methods("DELETE")
+	pets.HandleFunc("/{id}/records/{recordID}/attachments", s.handlerRecordAttachmentsGetAll()).Methods("GET")
+	pets.HandleFunc("/{id}/records/{recordID}/attachments", s.handlerRecordAttachmentsCreate()).Methods("POST")
+
+	// Set up insurance paths
+	pets.HandleFunc("/{id}/insurance/policies", s.handlerPoliciesGetAll()).Methods("GET")
+	pets.HandleFunc("/{id}/insurance/policies", s.handlerPoliciesCreate()).Methods("POST")
+	pets.HandleFunc("/{id}/insurance/policies/{policyID}", s.handlerPoliciesGetOne()).Methods("GET")
+	pets.HandleFunc("/{id}/insurance/policies/{policyID}", s.handlerPoliciesUpdate()).Methods("PUT")
+	pets.HandleFunc("/{id}/insurance/policies/{policyID}", s.handlerPoliciesDelete()).Methods("DELETE")
+	pets.HandleFunc("/{id}/insurance/policies/{policyID}/summary", s.handlerPoliciesSummary()).Methods("GET")
+	pets.HandleFunc("/{id}/insurance/claims", s.handlerClaimsGetAll()).Methods("GET")
+	pets.HandleFunc("/{id}/insurance/claims", s.handlerClaimsCreate()).Methods("POST")
+	pets.HandleFunc("/{id}/insurance/claims/{claimID}", s.handlerClaimsGetOne()).Methods("GET")
+	pets.HandleFunc("/{id}/insurance/claims/{claimID}", s.handlerClaimsUpdate()).Methods("PUT")
+	pets.HandleFunc("/{id}/insurance/claims/{claimID}", s.handlerClaimsDelete()).Methods("DELETE")
+	pets.HandleFunc("/{id}/insurance/claims/{claimID}/status", s.handlerClaimsStatus()).Methods("PUT")
+	pets.HandleFunc("/{id}/insurance/claims/{claimID}/attachments", s.handlerClaimAttachmentsGetAll()).Methods("GET")
+	pets.HandleFunc("/{id}/insurance/claims/{claimID}/attachments", s.handlerClaimAttachmentsCreate()).Methods("POST")
+
+	// Set up identification registry paths, for shelters and vets only
+	registry := api.PathPrefix("/registry").Subrouter()
+	registry.Use(s.requireRole(roleShelter, roleVet, roleAdmin))
+	registry.HandleFunc("/microchips/{chip}", s.handlerMicrochipLookup()).Methods("GET")
+	registry.HandleFunc("/contact/{handle}", s.handlerRegistryContact()).Methods("POST")
+
+	// Set up journal paths
+	pets.HandleFunc("/{id}/timeline", s.handlerPetsTimeline()).Methods("GET")
+	pets.HandleFunc("/{id}/notes", s.handlerNotesGetAll()).Methods("GET")
+	pets.HandleFunc("/{id}/notes", s.handlerNotesCreate()).Methods("POST")
+	pets.HandleFunc("/{id}/notes/{noteID}", s.handlerNotesGetOne()).Methods("GET")
+	pets.HandleFunc("/{id}/notes/{noteID}", s.handlerNotesUpdate()).Methods("PUT")
+	pets.HandleFunc("/{id}/notes/{noteID}", s.handlerNotesDelete()).Methods("DELETE")
+	pets.HandleFunc("/{id}/weights", s.handlerWeightsGetAll()).Methods("GET")
+	pets.HandleFunc("/{id}/weights", s.handlerWeightsCreate()).Methods("POST")
+	pets.HandleFunc("/{id}/weights/{weightID}", s.handlerWeightsDelete()).Methods("DELETE")
+
+	// Set up activity paths
+	pets.HandleFunc("/{id}/activities", s.handlerActivitiesGetAll()).Methods("GET")
+	pets.HandleFunc("/{id}/activities", s.handlerActivitiesCreate()).Methods("POST")
+	pets.HandleFunc("/{id}/activities/import", s.handlerActivitiesImport()).Methods("POST")
+	pets.HandleFunc("/{id}/activities/goal", s.handlerActivityGoalGet()).Methods("GET")
+	pets.HandleFunc("/{id}/activities/goal", s.handlerActivityGoalUpdate()).Methods("PUT")
+	pets.HandleFunc("/{id}/activities/summary", s.handlerActivitySummary()).Methods("GET")
+	pets.HandleFunc("/{id}/activities/{activityID:[0-9]+}", s.handlerActivitiesGetOne()).Methods("GET")
+	pets.HandleFunc("/{id}/activities/{activityID:[0-9]+}", s.handlerActivitiesUpdate()).Methods("PUT")
+	pets.HandleFunc("/{id}/activities/{activityID:[0-9]+}", s.handlerActivitiesDelete()).Methods("DELETE")
+	pets.HandleFunc("/{id}/activities/{activityID:[0-9]+}/route", s.handlerActivitiesRoute()).Methods("GET")
+
+	// Set up breeding paths
+	pets.HandleFunc("/{id}/pedigree", s.handlerPetsPedigree()).Methods("GET")
+	api.HandleFunc("/breeding/coi", s.handlerBreedingCOI()).Methods("GET")
+	api.HandleFunc("/litters", s.handlerLittersGetAll()).Methods("GET")
+	api.HandleFunc("/litters", s.handlerLittersCreate()).Methods("POST")
+	api.HandleFunc("/litters/{litterID}", s.handlerLittersGetOne()).Methods("GET")
+	api.HandleFunc("/litters/{litterID}", s.handlerLittersUpdate()).Methods("PUT")
+	api.HandleFunc("/litters/{litterID}", s.handlerLittersDelete()).Methods("DELETE")
+	api.HandleFunc("/litters/{litterID}/puppies", s.handlerLittersAddPuppy()).Methods("POST")
+
+	// Set up provider and appointment paths
+	api.HandleFunc("/providers", s.handlerProvidersGetAll()).Methods("GET")
+	api.HandleFunc("/providers", s.handlerProvidersCreate()).Methods("POST")
+	api.HandleFunc("/providers/{providerID}", s.handlerProvidersGetOne()).Methods("GET")
+	api.HandleFunc("/providers/{providerID}", s.handlerProvidersUpdate()).Methods("PUT")
+	api.HandleFunc("/providers/{providerID}", s.handlerProvidersDelete()).Methods("DELETE")
+	pets.HandleFunc("/{id}/providers", s.handlerPetProvidersGetAll()).Methods("GET")
+	pets.HandleFunc("/{id}/providers/{providerID}", s.handlerPetProvidersLink()).Methods("PUT")
+	pets.HandleFunc("/{id}/providers/{providerID}", s.handlerPetProvidersUnlink()).Methods("DELETE")
+	pets.HandleFunc("/{id}/care-team", s.handlerPetsCareTeam()).Methods("GET")
+	pets.HandleFunc("/{id}/appointments", s.handlerAppointmentsGetAll()).Methods("GET")
+	pets.HandleFunc("/{id}/appointments", s.handlerAppointmentsCreate()).Methods("POST")
+	pets.HandleFunc("/{id}/appointments/{appointmentID}", s.handlerAppointmentsGetOne()).Methods("GET")
+	pets.HandleFunc("/{id}/appointments/{appointmentID}", s.handlerAppointmentsUpdate()).Methods("PUT")
+	pets.HandleFunc("/{id}/appointments/{appointmentID}", s.handlerAppointmentsDelete()).Methods("DELETE")
+
+	// Set up organization and consent paths
+	api.HandleFunc("/organizations", s.handlerOrgsGetAll()).Methods("GET")
+	api.HandleFunc("/organizations", s.handlerOrgsCreate()).Methods("POST")
+	api.HandleFunc("/organizations/{orgID}", s.handlerOrgsGetOne()).Methods("GET")
+	api.HandleFunc("/organizations/{orgID}", s.handlerOrgsUpdate()).Methods("PUT")
+	api.HandleFunc("/organizations/{orgID}/members", s.handlerOrgMembersGetAll()).Methods("GET")
+	api.HandleFunc("/organizations/{orgID}/members", s.handlerOrgMembersSet()).Methods("PUT")
+	api.HandleFunc("/organizations/{orgID}/members/{userID}", s.handlerOrgMembersRemove()).Methods("DELETE")
+	api.HandleFunc("/organizations/{orgID}/access-requests", s.handlerOrgAccessGetAll()).Methods("GET")
+	api.HandleFunc("/organizations/{orgID}/access-requests", s.handlerOrgAccessCreate()).Methods("POST")
+	api.HandleFunc("/organizations/{orgID}/access-requests/{accessID}", s.handlerOrgAccessCancel()).Methods("DELETE")
+	api.HandleFunc("/organizations/{orgID}/access-events", s.handlerOrgAccessEvents()).Methods("GET")
+	api.HandleFunc("/organizations/{orgID}/pets", s.handlerOrgPetsGetAll()).Methods("GET")
+	api.HandleFunc("/organizations/{orgID}/pets/{petID}", s.handlerOrgPetsGetOne()).Methods("GET")
+	api.HandleFunc("/organizations/{orgID}/pets/{petID}/records", s.handlerOrgRecordsGetAll()).Methods("GET")
+	api.HandleFunc("/organizations/{orgID}/pets/{petID}/records", s.handlerOrgRecordsCreate()).Methods("POST")
+	api.HandleFunc("/organizations/{orgID}/pets/{petID}/records/{recordID}", s.handlerOrgRecordsUpdate()).Methods("PUT")
+	api.HandleFunc("/access-requests", s.handlerAccessGetAll()).Methods("GET")
+	pets.HandleFunc("/{id}/access", s.handlerPetAccessGetAll()).Methods("GET")
+	pets.HandleFunc("/{id}/access/events", s.handlerPetAccessEvents()).Methods("GET")
+	pets.HandleFunc("/{id}/access/{accessID}/status", s.handlerPetAccessStatus()).Methods("PUT")
+
+	// Set up catalog paths
+	api.HandleFunc("/catalog/species", s.handlerCatalogSpecies()).Methods("GET")
+	api.HandleFunc("/catalog/species/{species}/breeds", s.handlerCatalogBreeds()).Methods("GET")
+	api.HandleFunc("/catalog/genders", s.handlerCatalogGenders()).Methods("GET")
+
+	// Set up audit log paths
+	api.HandleFunc("/audit", s.handlerAuditGetAll()).Methods("GET")
+
+	// Set up search paths
+	api.HandleFunc("/search", s.handlerSearch()).Methods("GET")
+
+	// Set up admin paths
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(s.requireRole(roleAdmin))
+	admin.HandleFunc("/users/{id}/role", s.handlerUsersRoleUpdate()).Methods("PUT")
+	admin.HandleFunc("/audit", s.handlerAdminAuditGetAll()).Methods("GET")
+	admin.HandleFunc("/catalog/species", s.handlerCatalogSpeciesCreate()).Methods("POST")
+	admin.HandleFunc("/catalog/species/{species}", s.handlerCatalogSpeciesDelete()).Methods("DELETE")
+	admin.HandleFunc("/catalog/species/{species}/breeds", s.handlerCatalogBreedsCreate()).Methods("POST")
+	admin.HandleFunc("/catalog/species/{species}/breeds/{breedID}", s.handlerCatalogBreedsDelete()).Methods("DELETE")
 }
@@ -0,0 +1,490 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	searchPet      = "pet"
+	searchNote     = "note"
+	searchRecord   = "record"
+	searchProvider = "provider"
+
+	// maxSearchLimit is the most hits a search returns
+	maxSearchLimit = 100
+	// searchSnippetWords is how many words of a document a snippet shows
+	searchSnippetWords = 24
+)
+
+// searchHit is a document matching a search. PetID is set for documents
+// about a pet and OrgID when the caller can only reach the pet through an
+// organization, whose routes have to be used to get the document.
+type searchHit struct {
+	Type    string  `json:"type" example:"record"`
+	ID      uint    `json:"id" example:"12"`
+	PetID   *uint   `json:"pet_id" example:"1"`
+	OrgID   *uint   `json:"organization_id,omitempty" example:"3"`
+	Title   string  `json:"title" example:"Rabies vaccination"`
+	Snippet string  `json:"snippet" example:"Booster for <mark>rabies</mark>, next one due in three years"`
+	Score   float64 `json:"score" example:"0.42"`
+	text    string
+}
+
+// SearchIndex finds the documents a user can access that match a query,
+// most relevant first
+type SearchIndex interface {
+	Search(userID int64, query string, limit int) ([]searchHit, error)
+}
+
+// searchSource is a kind of document that can be searched. Columns selects
+// the ID, pet ID, organization ID, title and text of its documents From
+// the tables, limited to those the user in $1 can access. Text has to stay
+// as it is, the inverted index of the source is on the same expression.
+type searchSource struct {
+	Type    string
+	Columns string
+	Text    string
+	From    string
+}
+
+// searchAccessiblePet is the condition for a pet p the user in $1 owns or
+// an organization of theirs was granted access to
+var searchAccessiblePet = fmt.Sprintf(`p.deleted_at IS NULL AND (p.user_id = $1 OR p.id IN (
+	SELECT a.pet_id FROM pet_access a JOIN organization_members m ON m.org_id = a.org_id WHERE m.user_id = $1 AND a.status = '%s'))`, accessGranted)
+
+// searchPetOrg is the organization through which the user in $1 reaches a
+// pet p they don't own
+var searchPetOrg = fmt.Sprintf(`CASE WHEN p.user_id = $1 THEN NULL ELSE (
+	SELECT min(a.org_id) FROM pet_access a JOIN organization_members m ON m.org_id = a.org_id WHERE m.user_id = $1 AND a.status = '%s' AND a.pet_id = p.id) END`, accessGranted)
+
+// searchSources are the documents searches look through
+var searchSources = []searchSource{
+	{
+		Type:    searchPet,
+		Columns: "p.id, p.id, " + searchPetOrg + ", p.name",
+		Text:    "COALESCE(p.name, '') || ' ' || COALESCE(p.breed, '')",
+		From:    "pets p WHERE " + searchAccessiblePet,
+	},
+	{
+		// Journal notes aren't shared with organizations
+		Type:    searchNote,
+		Columns: "n.id, n.pet_id, NULL, COALESCE(n.title, '')",
+		Text:    "COALESCE(n.title, '') || ' ' || n.body",
+		From:    "pet_notes n JOIN pets p ON p.id = n.pet_id WHERE n.deleted_at IS NULL AND p.deleted_at IS NULL AND p.user_id = $1",
+	},
+	{
+		Type:    searchRecord,
+		Columns: "r.id, r.pet_id, " + searchPetOrg + ", r.title",
+		Text:    "r.title || ' ' || COALESCE(r.notes, '')",
+		From:    "medical_records r JOIN pets p ON p.id = r.pet_id WHERE r.deleted_at IS NULL AND " + searchAccessiblePet,
+	},
+	{
+		Type:    searchProvider,
+		Columns: "v.id, NULL, NULL, v.name",
+		Text:    "v.name",
+		From:    "providers v WHERE v.user_id = $1",
+	},
+}
+
+//searchTerms splits a query or a document into lowercase words
+func searchTerms(s string) []string {
+	return strings.Fields(catalogKey(s))
+}
+
+// searchSuffixes are the inflections cut off words so that, for instance,
+// "vaccinations" finds "vaccinated"
+var searchSuffixes = []string{"ations", "ation", "ings", "ing", "edly", "ed", "es", "s", "ly"}
+
+//searchStem cuts the inflection off a word, roughly. Words starting with
+//the stem of a query term match it.
+func searchStem(term string) string {
+	for _, suffix := range searchSuffixes {
+		if stem := strings.TrimSuffix(term, suffix); stem != term && len(stem) >= 3 {
+			return stem
+		}
+	}
+	return term
+}
+
+//searchSnippet cuts the part of a document around the first word matching
+//the query out of it, with the matching words in <mark> tags and the rest
+//escaped for HTML
+func searchSnippet(text string, stems []string) string {
+	words := strings.Fields(text)
+	matches := func(w string) bool {
+		for _, t := range searchTerms(w) {
+			for _, stem := range stems {
+				if strings.HasPrefix(t, stem) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	start := 0
+	for i, w := range words {
+		if matches(w) {
+			// Show a few words of context before the match
+			start = i - searchSnippetWords/3
+			break
+		}
+	}
+	if start+searchSnippetWords > len(words) {
+		start = len(words) - searchSnippetWords
+	}
+	if start < 0 {
+		start = 0
+	}
+	end := start + searchSnippetWords
+	if end > len(words) {
+		end = len(words)
+	}
+
+	parts := []string{}
+	if start > 0 {
+		parts = append(parts, "…")
+	}
+	for _, w := range words[start:end] {
+		if matches(w) {
+			parts = append(parts, "<mark>"+html.EscapeString(w)+"</mark>")
+		} else {
+			parts = append(parts, html.EscapeString(w))
+		}
+	}
+	if end < len(words) {
+		parts = append(parts, "…")
+	}
+	return strings.Join(parts, " ")
+}
+
+//scanSearchHit reads the columns of a search source
+func scanSearchHit(rows *sql.Rows, dest ...interface{}) (searchHit, error) {
+	var h searchHit
+	var id int64
+	var petID, orgID sql.NullInt64
+	err := rows.Scan(append([]interface{}{&id, &petID, &orgID, &h.Title, &h.text}, dest...)...)
+	h.ID, h.PetID, h.OrgID = uint(id), nullIDPtr(petID), nullIDPtr(orgID)
+	return h, err
+}
+
+//rankSearchHits sorts hits by score, cuts them to the limit and fills in
+//their snippets
+func rankSearchHits(hits []searchHit, terms []string, limit int) []searchHit {
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	stems := make([]string, len(terms))
+	for i, t := range terms {
+		stems[i] = searchStem(t)
+	}
+	for i := range hits {
+		hits[i].Snippet = searchSnippet(hits[i].text, stems)
+	}
+	return hits
+}
+
+// sqlSearchIndex searches with the full-text inverted indexes of the
+// cockroach database, ranked by ts_rank
+type sqlSearchIndex struct {
+	db *sql.DB
+}
+
+//Search finds the documents matching all words of a query
+func (ix *sqlSearchIndex) Search(userID int64, query string, limit int) ([]searchHit, error) {
+	terms := searchTerms(query)
+	hits := []searchHit{}
+	for _, src := range searchSources {
+		vector := "to_tsvector('english', " + src.Text + ")"
+		rows, err := ix.db.Query("SELECT "+src.Columns+", "+src.Text+", ts_rank("+vector+", plainto_tsquery('english', $2)) AS rank FROM "+src.From+
+			" AND "+vector+" @@ plainto_tsquery('english', $2) ORDER BY rank DESC LIMIT $3", userID, strings.Join(terms, " "), limit)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var rank float64
+			h, err := scanSearchHit(rows, &rank)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			h.Type, h.Score = src.Type, math.Round(rank*1000)/1000
+			hits = append(hits, h)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rankSearchHits(hits, terms, limit), nil
+}
+
+// builtinSearchIndex searches databases without full-text indexes. It
+// reads the documents a user can access and builds an inverted index of
+// them in memory, ranked with BM25. The index is kept for the user's next
+// searches until invalidate is called after a write. Any write drops every
+// index kept, as pets shared with organizations are searched by more users
+// than their owners.
+type builtinSearchIndex struct {
+	db *sql.DB
+
+	mu         sync.Mutex
+	corpora    map[int64]*searchCorpus
+	generation int
+}
+
+// BM25 parameters, the usual ones
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// maxSearchCorpora is how many users' indexes are kept between searches
+const maxSearchCorpora = 100
+
+// searchPosting is a document a term occurs in, and how often
+type searchPosting struct {
+	doc   int
+	count int
+}
+
+// searchCorpus is the inverted index of the documents a user can access,
+// with their lengths for BM25
+type searchCorpus struct {
+	docs       []searchHit
+	postings   map[string][]searchPosting
+	dictionary []string
+	lengths    []int
+	avgLength  float64
+	used       time.Time
+}
+
+//newSearchCorpus indexes the words of documents
+func newSearchCorpus(docs []searchHit) *searchCorpus {
+	c := &searchCorpus{docs: docs, postings: map[string][]searchPosting{}, lengths: make([]int, len(docs))}
+	total := 0
+	for i, d := range docs {
+		counts := map[string]int{}
+		for _, t := range searchTerms(d.text) {
+			counts[t]++
+			c.lengths[i]++
+		}
+		total += c.lengths[i]
+		for t, n := range counts {
+			c.postings[t] = append(c.postings[t], searchPosting{doc: i, count: n})
+		}
+	}
+	c.dictionary = make([]string, 0, len(c.postings))
+	for t := range c.postings {
+		c.dictionary = append(c.dictionary, t)
+	}
+	sort.Strings(c.dictionary)
+	if len(docs) > 0 {
+		c.avgLength = float64(total) / float64(len(docs))
+	}
+	return c
+}
+
+//search finds the documents matching all terms, ranked with BM25
+func (c *searchCorpus) search(terms []string, limit int) []searchHit {
+	if len(c.docs) == 0 {
+		return []searchHit{}
+	}
+
+	// Score the documents matching each term, a term matches every word
+	// starting with its stem
+	scores := map[int]float64{}
+	matched := map[int]int{}
+	for _, term := range terms {
+		stem := searchStem(term)
+		counts := map[int]int{}
+		for i := sort.SearchStrings(c.dictionary, stem); i < len(c.dictionary) && strings.HasPrefix(c.dictionary[i], stem); i++ {
+			for _, p := range c.postings[c.dictionary[i]] {
+				counts[p.doc] += p.count
+			}
+		}
+		idf := math.Log(1 + (float64(len(c.docs))-float64(len(counts))+0.5)/(float64(len(counts))+0.5))
+		for doc, n := range counts {
+			tf := float64(n)
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(c.lengths[doc])/c.avgLength)
+			scores[doc] += idf * tf * (bm25K1 + 1) / (tf + norm)
+			matched[doc]++
+		}
+	}
+
+	hits := []searchHit{}
+	for doc, score := range scores {
+		if matched[doc] == len(terms) {
+			h := c.docs[doc]
+			h.Score = math.Round(score*1000) / 1000
+			hits = append(hits, h)
+		}
+	}
+	// Documents come out of a map, order ties the same way every time
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Type != hits[j].Type {
+			return hits[i].Type < hits[j].Type
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	return rankSearchHits(hits, terms, limit)
+}
+
+//Search finds the documents matching all words of a query
+func (ix *builtinSearchIndex) Search(userID int64, query string, limit int) ([]searchHit, error) {
+	c, err := ix.corpus(userID)
+	if err != nil {
+		return nil, err
+	}
+	return c.search(searchTerms(query), limit), nil
+}
+
+//corpus returns the index of the documents a user can access, building it
+//when none is kept from an earlier search
+func (ix *builtinSearchIndex) corpus(userID int64) (*searchCorpus, error) {
+	ix.mu.Lock()
+	c, ok := ix.corpora[userID]
+	if ok {
+		c.used = time.Now()
+	}
+	generation := ix.generation
+	ix.mu.Unlock()
+	if ok {
+		return c, nil
+	}
+
+	docs := []searchHit{}
+	for _, src := range searchSources {
+		rows, err := ix.db.Query("SELECT "+src.Columns+", "+src.Text+" FROM "+src.From, userID)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			h, err := scanSearchHit(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			h.Type = src.Type
+			docs = append(docs, h)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	c = newSearchCorpus(docs)
+
+	// A write while the documents were read may not be in the index, it's
+	// only kept when there was none
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.generation != generation {
+		return c, nil
+	}
+	if ix.corpora == nil {
+		ix.corpora = map[int64]*searchCorpus{}
+	}
+	if len(ix.corpora) >= maxSearchCorpora {
+		// Make room by dropping the index searched least recently
+		oldest := int64(-1)
+		for id, kept := range ix.corpora {
+			if oldest < 0 || kept.used.Before(ix.corpora[oldest].used) {
+				oldest = id
+			}
+		}
+		delete(ix.corpora, oldest)
+	}
+	c.used = time.Now()
+	ix.corpora[userID] = c
+	return c, nil
+}
+
+//invalidate drops the indexes kept, the documents of any user may have
+//changed
+func (ix *builtinSearchIndex) invalidate() {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.corpora = nil
+	ix.generation++
+}
+
+// searchCache is a SearchIndex that keeps documents between searches
+type searchCache interface {
+	invalidate()
+}
+
+//invalidateSearch has the search index drop the documents it keeps after
+//every request that may have written to them
+func (s *server) invalidateSearch(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		if c, ok := s.search.(searchCache); ok && r.Method != "GET" && r.Method != "HEAD" && r.Method != "OPTIONS" {
+			c.invalidate()
+		}
+	})
+}
+
+// handlerSearch godoc
+// @Summary Search
+// @Description Search the names and breeds of pets, journal notes, medical records and providers the user can access for all words of a query, most relevant first. Pets shared with an organization of the user are searched too. Snippets are HTML with the matching words in mark tags.
+// @Tags Search
+// @Produce json
+// @Param q query string true "Words to search for"
+// @Param limit query int false "Most hits to return, 20 by default and at most 100"
+// @Success 200 {array} searchHit
+// @Security ApiKeyAuth
+// @Router /search [get]
+func (s *server) handlerSearch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+		query, limit, err := searchParams(r)
+		if err != nil {
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+		hits, err := s.search.Search(userID, query, limit)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error searching database")
+			s.respond(w, r, nil, "error searching", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, hits, "", http.StatusOK)
+	}
+}
+
+//searchParams reads the q and limit query params
+func searchParams(r *http.Request) (string, int, error) {
+	query := r.URL.Query().Get("q")
+	if len(searchTerms(query)) == 0 {
+		return "", 0, invalidField("q", "q must contain a word")
+	}
+	if len(query) > 256 {
+		return "", 0, invalidField("q", "q must be at most 256 characters")
+	}
+	limit := 20
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			return "", 0, invalidField("limit", fmt.Sprintf("limit must be between 1 and %d", maxSearchLimit))
+		}
+		limit = n
+	}
+	return query, limit, nil
+}
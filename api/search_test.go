@@ -0,0 +1,119 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSearchCorpusBM25(t *testing.T) {
+	c := newSearchCorpus([]searchHit{
+		{Type: searchRecord, ID: 1, text: "Rabies vaccination booster"},
+		{Type: searchRecord, ID: 2, text: "rabies"},
+		{Type: searchNote, ID: 3, text: "Walked to the park"},
+	})
+
+	// Worked out by hand: three documents of 3, 1 and 4 words, an idf of
+	// ln(1 + 1.5/2.5) for a term in two of them and ln(1 + 2.5/1.5) in one.
+	// The one word document is the better match for rabies.
+	for _, tc := range []struct {
+		query string
+		want  []float64
+		ids   []uint
+	}{
+		{"rabies", []float64{0.631, 0.447}, []uint{2, 1}},
+		{"vaccinations", []float64{0.933}, []uint{1}},
+		{"rabies booster", []float64{1.38}, []uint{1}},
+		{"rabies park", nil, nil},
+		{"zebra", nil, nil},
+	} {
+		hits := c.search(searchTerms(tc.query), 10)
+		if len(hits) != len(tc.ids) {
+			t.Errorf("%q: got %d hits %v, want %d", tc.query, len(hits), hits, len(tc.ids))
+			continue
+		}
+		for i, h := range hits {
+			if h.ID != tc.ids[i] || h.Score != tc.want[i] {
+				t.Errorf("%q hit %d: got %d scored %v, want %d scored %v", tc.query, i, h.ID, h.Score, tc.ids[i], tc.want[i])
+			}
+		}
+	}
+	if hits := c.search(searchTerms("rabies"), 1); len(hits) != 1 || hits[0].ID != 2 {
+		t.Errorf("limited to one hit: got %v", hits)
+	}
+	if hits := newSearchCorpus(nil).search(searchTerms("rabies"), 10); hits == nil || len(hits) != 0 {
+		t.Errorf("searching no documents: got %#v, want no hits", hits)
+	}
+}
+
+func TestSearchSnippet(t *testing.T) {
+	words := make([]string, 40)
+	for i := range words {
+		words[i] = fmt.Sprintf("w%d", i)
+	}
+	words[19] = "Vaccinated,"
+	words[21] = "<b>"
+	for _, c := range []struct {
+		name, text, query, want string
+	}{
+		{"a match in the middle", strings.Join(words, " "), "vaccinations",
+			"… w11 w12 w13 w14 w15 w16 w17 w18 <mark>Vaccinated,</mark> w20 &lt;b&gt; w22 w23 w24 w25 w26 w27 w28 w29 w30 w31 w32 w33 w34 …"},
+		{"a match at the end", strings.Join(words[:30], " ") + " rabies", "rabies",
+			"… w7 w8 w9 w10 w11 w12 w13 w14 w15 w16 w17 w18 Vaccinated, w20 &lt;b&gt; w22 w23 w24 w25 w26 w27 w28 w29 <mark>rabies</mark>"},
+		{"a short text", "Rabies & distemper booster", "booster rabies", "<mark>Rabies</mark> &amp; distemper <mark>booster</mark>"},
+		{"no match", "Walked to the park", "rabies", "Walked to the park"},
+	} {
+		stems := []string{}
+		for _, t := range searchTerms(c.query) {
+			stems = append(stems, searchStem(t))
+		}
+		if got := searchSnippet(c.text, stems); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestBuiltinSearchIndexCache(t *testing.T) {
+	h := newSQLiteHandlerTest(t)
+	ix, ok := h.s.search.(*builtinSearchIndex)
+	if !ok {
+		t.Fatalf("got a %T search index, want the built-in one", h.s.search)
+	}
+	var fido pet
+	if w := h.do("POST", "/pets", petRequest{Name: "Fido"}, nil, &fido); w.Code != http.StatusCreated {
+		t.Fatalf("creating a pet: got %d %s", w.Code, w.Body.String())
+	}
+	search := func(query string) []searchHit {
+		t.Helper()
+		hits, err := ix.Search(int64(fido.UserID), query, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hits
+	}
+	if hits := search("fido"); len(hits) != 1 {
+		t.Fatalf("searching fido: got %v", hits)
+	}
+
+	// The index is kept between searches, so a change behind the API's
+	// back isn't seen until it's invalidated
+	if _, err := h.s.db.Exec("UPDATE pets SET name = 'Rex' WHERE id = $1", fido.ID); err != nil {
+		t.Fatal(err)
+	}
+	if hits := search("fido"); len(hits) != 1 {
+		t.Errorf("searching fido again: got %v, want the kept index", hits)
+	}
+	ix.invalidate()
+	if hits := search("rex"); len(hits) != 1 {
+		t.Errorf("searching rex after invalidating: got %v", hits)
+	}
+
+	// Writes through the API invalidate it
+	if w := h.do("POST", "/pets", petRequest{Name: "Biscuit"}, nil, nil); w.Code != http.StatusCreated {
+		t.Fatalf("creating a pet: got %d %s", w.Code, w.Body.String())
+	}
+	if hits := search("biscuit"); len(hits) != 1 {
+		t.Errorf("searching biscuit after creating it: got %v", hits)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"time"
 
 	// GorillaHandlers "github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -26,10 +28,26 @@ type server struct {
 	statsd     *statsd.Client
 	listenPort string
 	serverHost string
+
+	blobs          BlobStore
+	maxUploadBytes int64
+	mailer         mailer
+	contactLimiter *rateLimiter
+	trustedProxies []*net.IPNet
+	catalog        *catalog
+	users          UserStore
+	pets           PetStore
+	search         SearchIndex
+	auditLog       auditLog
 }
 
 func newServer(serverHost, listenPort string) *server {
-	s := &server{serverHost: serverHost, listenPort: listenPort}
+	s := &server{
+		serverHost:     serverHost,
+		listenPort:     listenPort,
+		contactLimiter: newRateLimiter(5, time.Hour),
+		catalog:        newCatalog(),
+	}
 	s.routes()
 	return s
 }
@@ -54,13 +72,55 @@ func StartServer(cfg config.Config) error {
 	// Initialize the logger
 	srv.logger = zerolog.New(os.Stdout).With().Timestamp().Logger()
 
-	// Connect to the cockroach database
-	err := srv.connectDB(cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.CertPath, cfg.DBName, cfg.DBInsecure)
+	// Only proxies in front of the server may tell the client address
+	var err error
+	srv.trustedProxies, err = parseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return err
+	}
+
+	// Connect to the cockroach database, or open the embedded one
+	err = srv.openDB(cfg)
 	if err != nil {
 		return err
 	}
 	defer srv.db.Close()
 
+	// Bring the schema up to date, waiting for other replicas doing the same
+	if cfg.AutoMigrate {
+		mg, err := newMigrator(srv.db, cfg.DBDriver, os.Stdout)
+		if err != nil {
+			return err
+		}
+		err = mg.migrateTo(mg.latest())
+		if err != nil {
+			return err
+		}
+	}
+
+	// Load the species and breeds admins added to the catalog
+	err = srv.reloadCatalog()
+	if err != nil {
+		return err
+	}
+
+	// Set up the blob store for uploads
+	err = srv.newBlobStore(cfg)
+	if err != nil {
+		return err
+	}
+	srv.maxUploadBytes = cfg.MaxUploadBytes
+
+	// Purge pets that have been in the trash for longer than the retention
+	if cfg.TrashRetention > 0 {
+		go srv.runTrashPurge(cfg.TrashRetention)
+	}
+
+	// Set up outgoing email
+	if cfg.SMTPHost != "" {
+		srv.mailer = newSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword, cfg.SMTPFrom)
+	}
+
 	if cfg.StatsdHost != "" {
 		err := srv.newStatsdClient(cfg.StatsdHost, cfg.StatsdPort)
 		if err != nil {
@@ -86,6 +146,18 @@ func StartServer(cfg config.Config) error {
 	return nil
 }
 
+//openDB connects to the database of the driver selected in the config
+func (s *server) openDB(cfg config.Config) error {
+	switch cfg.DBDriver {
+	case driverCockroach:
+		return s.connectDB(cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.CertPath, cfg.DBName, cfg.DBInsecure)
+	case driverSQLite:
+		return s.connectSQLite(cfg.DBPath)
+	default:
+		return fmt.Errorf("unknown database driver %q", cfg.DBDriver)
+	}
+}
+
 func (s *server) newStatsdClient(addr, port string) error {
 	c, err := statsd.New(
 		statsd.Address(fmt.Sprintf("%s:%s", addr, port)),
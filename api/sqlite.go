@@ -0,0 +1,146 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	_ "modernc.org/sqlite" //embedded sqlite driver, no cgo needed
+)
+
+const (
+	driverCockroach = "cockroach"
+	driverSQLite    = "sqlite"
+)
+
+// sqliteTypes maps the cockroach column types used by the migrations to
+// their sqlite equivalents. Arrays are kept as text, pq.Array reads and
+// writes them in the same format either way.
+var sqliteTypes = strings.NewReplacer(
+	"SERIAL", "INTEGER",
+	"STRING[]", "TEXT",
+	"STRING", "TEXT",
+	"TIMESTAMPTZ", "TIMESTAMP",
+	"INT8", "INTEGER",
+	"FLOAT8", "REAL",
+)
+
+var (
+	sqliteCreateTable = regexp.MustCompile(`^\s*CREATE TABLE IF NOT EXISTS (\w+)`)
+	sqliteInlineIndex = regexp.MustCompile(`,\s*INDEX \(([^)]*)\)`)
+	sqliteAddColumn   = regexp.MustCompile(`^\s*ALTER TABLE (\w+) ADD COLUMN IF NOT EXISTS (\w+) (.*)$`)
+	sqliteDropColumn  = regexp.MustCompile(`^\s*ALTER TABLE (\w+) DROP COLUMN IF EXISTS (\w+)( CASCADE)?$`)
+	sqliteInverted    = regexp.MustCompile(`^\s*CREATE INVERTED INDEX`)
+	sqliteDropIndex   = regexp.MustCompile(`^\s*DROP INDEX IF EXISTS \w+@(\w+)`)
+)
+
+// queryer runs a query returning a single row, in or outside a transaction
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+//connectSQLite opens an embedded sqlite database, creating the file when it
+//doesn't exist yet. The database runs in WAL mode so reads don't wait for
+//writes, and writers wait up to 5 seconds for each other instead of failing
+//with SQLITE_BUSY.
+func (s *server) connectSQLite(path string) error {
+	if path == "" {
+		return errors.New("must provide a database path")
+	}
+
+	// Transactions take the write lock up front, a deferred transaction
+	// upgrading its lock later fails at once rather than waiting
+	connString := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_pragma=synchronous(NORMAL)&_txlock=immediate"
+	s.logger.Debug().Msg(fmt.Sprintf("connection string: %s", connString))
+	conn, err := sql.Open("sqlite", connString)
+	if err != nil {
+		return err
+	}
+	err = conn.Ping()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	s.logger.Info().Str("path", path).Msg("Successfully opened sqlite database")
+	s.db = conn
+	s.users = &sqlUserStore{db: conn}
+	s.pets = &sqlPetStore{db: conn}
+	s.search = &builtinSearchIndex{db: conn}
+	s.auditLog = &sqlAuditLog{db: conn}
+	return nil
+}
+
+//sqliteStatements translates a cockroach migration statement to sqlite
+//statements. Inline indexes become CREATE INDEX statements and, as sqlite
+//has no IF EXISTS for columns, columns are checked for first. Indexes on a
+//dropped column are dropped with it, as cockroach does. sqlite has no
+//inverted indexes, they are left out and search uses the built-in index.
+func sqliteStatements(q queryer, stmt string) ([]string, error) {
+	if sqliteInverted.MatchString(stmt) {
+		return nil, nil
+	}
+	if m := sqliteDropIndex.FindStringSubmatch(stmt); m != nil {
+		return []string{"DROP INDEX IF EXISTS " + m[1]}, nil
+	}
+	if m := sqliteAddColumn.FindStringSubmatch(stmt); m != nil {
+		table, column, def := m[1], m[2], sqliteTypes.Replace(m[3])
+		exists, err := sqliteColumnExists(q, table, column)
+		if err != nil || exists {
+			return nil, err
+		}
+
+		// sqlite can't add a UNIQUE column, the index does the same job
+		stmts := []string{}
+		if strings.Contains(def, " UNIQUE") || strings.HasPrefix(def, "UNIQUE") {
+			def = strings.TrimSpace(strings.Replace(def, "UNIQUE", "", 1))
+			stmts = append(stmts, fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s_%s_key ON %s (%s)", table, column, table, column))
+		}
+		return append([]string{fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, def)}, stmts...), nil
+	}
+
+	if m := sqliteDropColumn.FindStringSubmatch(stmt); m != nil {
+		table, column := m[1], m[2]
+		exists, err := sqliteColumnExists(q, table, column)
+		if err != nil || !exists {
+			return nil, err
+		}
+		var indexes sql.NullString
+		err = q.QueryRow(`SELECT group_concat(l.name) FROM pragma_index_list($1) l, pragma_index_info(l.name) i
+			WHERE l.origin = 'c' AND i.name = $2`, table, column).Scan(&indexes)
+		if err != nil {
+			return nil, err
+		}
+		stmts := []string{}
+		if indexes.Valid {
+			for _, idx := range strings.Split(indexes.String, ",") {
+				stmts = append(stmts, "DROP INDEX IF EXISTS "+idx)
+			}
+		}
+		return append(stmts, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, column)), nil
+	}
+
+	m := sqliteCreateTable.FindStringSubmatch(stmt)
+	if m == nil {
+		return []string{sqliteTypes.Replace(stmt)}, nil
+	}
+	table := m[1]
+	stmts := []string{sqliteTypes.Replace(sqliteInlineIndex.ReplaceAllString(stmt, ""))}
+	for _, idx := range sqliteInlineIndex.FindAllStringSubmatch(stmt, -1) {
+		cols := strings.Split(idx[1], ",")
+		names := make([]string, len(cols))
+		for i, c := range cols {
+			names[i] = strings.Fields(c)[0]
+		}
+		stmts = append(stmts, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_%s_idx ON %s (%s)", table, strings.Join(names, "_"), table, idx[1]))
+	}
+	return stmts, nil
+}
+
+//sqliteColumnExists checks whether a table has a column
+func sqliteColumnExists(q queryer, table, column string) (bool, error) {
+	var exists bool
+	err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM pragma_table_info($1) WHERE name = $2)", table, column).Scan(&exists)
+	return exists, err
+}
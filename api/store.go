@@ -0,0 +1,418 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// errEmailTaken is returned by a UserStore when another user signed up
+	// with the email
+	errEmailTaken = errors.New("email is already registered")
+	// errMicrochipTaken is returned by a PetStore when another pet is
+	// registered with the microchip
+	errMicrochipTaken = errors.New("microchip is already registered")
+	// errVersionConflict is returned by a PetStore when a pet was changed
+	// since the version an update was based on
+	errVersionConflict = errors.New("pet was changed by someone else")
+)
+
+// UserStore persists user accounts. Lookups of users that don't exist
+// return sql.ErrNoRows, whatever the backend.
+type UserStore interface {
+	Create(u user) (int64, error)
+	GetOne(id int64) (user, error)
+	// GetByEmail returns the user with its password hash
+	GetByEmail(email string) (user, error)
+	SetLastLogin(id int64, ts time.Time) error
+	GetRole(id int64) (string, error)
+	SetRole(id int64, role string) (int64, error)
+}
+
+// PetStore persists pets. Every method is scoped to the pet's owner, pets
+// of other users behave as if they don't exist. So do pets in the trash,
+// except for the trash methods. Lookups of pets that don't exist return
+// sql.ErrNoRows, whatever the backend.
+type PetStore interface {
+	// GetAll returns the pets of a user ordered by ID
+	GetAll(userID int64) ([]pet, error)
+	// List returns a page of the pets of a user, one more than the limit
+	// when there is a next page, and their total count when asked for
+	List(userID int64, q listQuery) ([]pet, int64, error)
+	GetOne(userID, petID int64) (pet, error)
+	Exists(userID, petID int64) (bool, error)
+	Create(p pet, userID int64) (int64, error)
+	// CreateMany inserts pets all at once, or none of them when one fails
+	CreateMany(ps []pet, userID int64) ([]int64, error)
+	// TakenMicrochips returns which of the microchips are registered to a
+	// pet already, trashed or not
+	TakenMicrochips(chips []string) ([]string, error)
+	// Each calls fn with every pet of a user ordered by ID, reading them as
+	// it goes, and stops at the first error
+	Each(userID int64, fn func(p pet) error) error
+	// Update saves a pet and returns its new version, 0 when the pet
+	// doesn't exist. When p.Version is set the pet is only saved if it is
+	// still at that version.
+	Update(p pet, userID int64) (int64, error)
+	// Delete removes a pet for good, trashed or not
+	Delete(petID, userID int64) (int64, error)
+
+	// Trash moves a pet and its records to the trash. When version is set
+	// the pet is only trashed if it is still at that version.
+	Trash(petID, userID, version int64, ts time.Time) (int64, error)
+	// GetTrash returns the trashed pets of a user, most recently trashed first
+	GetTrash(userID int64) ([]pet, error)
+	// Restore takes a pet and its records out of the trash
+	Restore(petID, userID int64) (int64, error)
+	// GetExpired returns the pets of all users trashed before a time
+	GetExpired(before time.Time) ([]pet, error)
+}
+
+// petRecordTables are the tables of a pet's records, which go to the trash
+// and come back with it
+var petRecordTables = []string{
+	"attachments",
+	"lost_pets",
+	"expenses",
+	"medical_records",
+	"insurance_policies",
+	"insurance_claims",
+	"pet_notes",
+	"pet_weights",
+	"activities",
+	"appointments",
+}
+
+// sqlUserStore keeps users in the cockroach database
+type sqlUserStore struct {
+	db *sql.DB
+}
+
+// sqlPetStore keeps pets in the cockroach database
+type sqlPetStore struct {
+	db *sql.DB
+}
+
+//Create inserts a user, its password already hashed
+func (st *sqlUserStore) Create(u user) (int64, error) {
+	var id int64
+	err := st.db.QueryRow("INSERT INTO users(email, password, created_at, updated_at) VALUES($1,$2,$3,$4) RETURNING id", u.Email, u.Password, u.CreatedAt, u.UpdatedAt).Scan(&id)
+	if isUniqueViolation(err) {
+		return 0, errEmailTaken
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+//GetOne returns a user by ID, without its password
+func (st *sqlUserStore) GetOne(id int64) (user, error) {
+	var u user
+	var lastLogin sql.NullTime
+	row := st.db.QueryRow("SELECT id, email, created_at, updated_at, last_login, role FROM users WHERE id = $1", id)
+	err := row.Scan(&u.ID, &u.Email, &u.CreatedAt, &u.UpdatedAt, &lastLogin, &u.Role)
+	u.LastLogin = lastLogin.Time
+	return u, err
+}
+
+//GetByEmail returns a user by email, with its password hash
+func (st *sqlUserStore) GetByEmail(email string) (user, error) {
+	var u user
+	var lastLogin sql.NullTime
+	row := st.db.QueryRow("SELECT id, email, password, created_at, updated_at, last_login, role FROM users WHERE email = $1", email)
+	err := row.Scan(&u.ID, &u.Email, &u.Password, &u.CreatedAt, &u.UpdatedAt, &lastLogin, &u.Role)
+	u.LastLogin = lastLogin.Time
+	return u, err
+}
+
+//SetLastLogin records when a user last logged in
+func (st *sqlUserStore) SetLastLogin(id int64, ts time.Time) error {
+	_, err := st.db.Exec("UPDATE users SET last_login = $1 WHERE id = $2", ts, id)
+	return err
+}
+
+//GetRole returns the role of a user
+func (st *sqlUserStore) GetRole(id int64) (string, error) {
+	var role string
+	err := st.db.QueryRow("SELECT role FROM users WHERE id = $1", id).Scan(&role)
+	if err != nil {
+		return "", err
+	}
+	return role, nil
+}
+
+//SetRole changes the role of a user
+func (st *sqlUserStore) SetRole(id int64, role string) (int64, error) {
+	res, err := st.db.Exec("UPDATE users SET role = $1 WHERE id = $2", role, id)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//query returns the pets matching a condition
+func (st *sqlPetStore) query(cond string, args ...interface{}) ([]pet, error) {
+	rows, err := st.db.Query("SELECT "+petColumns+" FROM pets WHERE "+cond, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pets := []pet{}
+	for rows.Next() {
+		pet, err := scanPet(rows)
+		if err != nil {
+			return nil, err
+		}
+		pets = append(pets, pet)
+	}
+	return pets, rows.Err()
+}
+
+//GetAll returns all pets owned by a user by ID
+func (st *sqlPetStore) GetAll(userID int64) ([]pet, error) {
+	return st.query("user_id = $1 AND deleted_at IS NULL ORDER BY id", userID)
+}
+
+//List returns a page of the pets owned by a user
+func (st *sqlPetStore) List(userID int64, q listQuery) ([]pet, int64, error) {
+	args := []interface{}{userID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	cond := "user_id = $1 AND deleted_at IS NULL"
+
+	var total int64
+	if q.Count {
+		// The total ignores the cursor, it counts every page
+		filters := q
+		filters.After = nil
+		conds := strings.Join(append([]string{cond}, filters.where(arg)...), " AND ")
+		err := st.db.QueryRow("SELECT count(*) FROM pets WHERE "+conds, args...).Scan(&total)
+		if err != nil {
+			return nil, 0, err
+		}
+		args = args[:1]
+	}
+
+	conds := strings.Join(append([]string{cond}, q.where(arg)...), " AND ")
+	pets, err := st.query(conds+q.orderBy(arg), args...)
+	return pets, total, err
+}
+
+//GetOne returns a single pet by ID owned by a user by ID
+func (st *sqlPetStore) GetOne(userID, petID int64) (pet, error) {
+	row := st.db.QueryRow("SELECT "+petColumns+" FROM pets WHERE user_id = $1 AND id = $2 AND deleted_at IS NULL", userID, petID)
+	return scanPet(row)
+}
+
+//Exists checks whether a pet exists and is owned by a user
+func (st *sqlPetStore) Exists(userID, petID int64) (bool, error) {
+	var exists bool
+	err := st.db.QueryRow("SELECT EXISTS (SELECT 1 FROM pets WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)", petID, userID).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+//Create inserts a pet for a user
+func (st *sqlPetStore) Create(p pet, userID int64) (int64, error) {
+	return insertPet(st.db, p, userID)
+}
+
+//insertPet inserts a pet for a user, in or outside a transaction
+func insertPet(q queryer, p pet, userID int64) (int64, error) {
+	var id int64
+	err := q.QueryRow("INSERT INTO pets(user_id, name, type, gender, breed, birthday, microchip, tattoo, license_tag, sire_id, dam_id, litter_id, registration_number, created_at, updated_at) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15) RETURNING id",
+		userID, p.Name, p.Type, p.Gender, p.Breed, p.Birthday, nullString(p.Microchip), nullString(p.Tattoo), nullString(p.LicenseTag), p.SireID, p.DamID, p.LitterID, nullString(p.RegistrationNumber), p.CreatedAt, p.UpdatedAt).Scan(&id)
+	if isUniqueViolation(err) {
+		return 0, errMicrochipTaken
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+//CreateMany inserts pets for a user in a single transaction
+func (st *sqlPetStore) CreateMany(ps []pet, userID int64) ([]int64, error) {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ids := make([]int64, len(ps))
+	for i, p := range ps {
+		ids[i], err = insertPet(tx, p, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ids, tx.Commit()
+}
+
+// microchipBatch is how many microchips TakenMicrochips looks up per query
+const microchipBatch = 500
+
+//TakenMicrochips returns which of the microchips are registered already
+func (st *sqlPetStore) TakenMicrochips(chips []string) ([]string, error) {
+	taken := []string{}
+	for start := 0; start < len(chips); start += microchipBatch {
+		batch := chips[start:]
+		if len(batch) > microchipBatch {
+			batch = batch[:microchipBatch]
+		}
+		args := make([]interface{}, len(batch))
+		params := make([]string, len(batch))
+		for i, chip := range batch {
+			args[i], params[i] = chip, "$"+strconv.Itoa(i+1)
+		}
+		rows, err := st.db.Query("SELECT microchip FROM pets WHERE microchip IN ("+strings.Join(params, ", ")+")", args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var chip string
+			if err := rows.Scan(&chip); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			taken = append(taken, chip)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return taken, nil
+}
+
+//Each calls fn with every pet of a user, one row at a time
+func (st *sqlPetStore) Each(userID int64, fn func(p pet) error) error {
+	rows, err := st.db.Query("SELECT "+petColumns+" FROM pets WHERE user_id = $1 AND deleted_at IS NULL ORDER BY id", userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		p, err := scanPet(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+//Update saves the changes to a pet owned by a user, bumping its version
+func (st *sqlPetStore) Update(p pet, userID int64) (int64, error) {
+	var version int64
+	err := st.db.QueryRow("UPDATE pets SET name = $1, type = $2, gender = $3, breed = $4, birthday = $5, microchip = $6, tattoo = $7, license_tag = $8, sire_id = $9, dam_id = $10, litter_id = $11, registration_number = $12, updated_at = $13, version = version + 1 WHERE id = $14 AND user_id = $15 AND deleted_at IS NULL AND ($16 = 0 OR version = $16) RETURNING version",
+		p.Name, p.Type, p.Gender, p.Breed, p.Birthday, nullString(p.Microchip), nullString(p.Tattoo), nullString(p.LicenseTag), p.SireID, p.DamID, p.LitterID, nullString(p.RegistrationNumber), p.UpdatedAt, p.ID, userID, p.Version).Scan(&version)
+	if isUniqueViolation(err) {
+		return 0, errMicrochipTaken
+	}
+	if err == sql.ErrNoRows {
+		// Tell a pet at another version apart from a missing one
+		exists, err := st.Exists(userID, int64(p.ID))
+		if err != nil || !exists {
+			return 0, err
+		}
+		return 0, errVersionConflict
+	}
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+//Delete removes a pet owned by a user for good, returning the number of
+//pets removed. Its records go with it.
+func (st *sqlPetStore) Delete(petID, userID int64) (int64, error) {
+	res, err := st.db.Exec("DELETE FROM pets WHERE id = $1 AND user_id = $2", petID, userID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+//Trash moves a pet and its records to the trash, stamping them with the
+//same time
+func (st *sqlPetStore) Trash(petID, userID, version int64, ts time.Time) (int64, error) {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE pets SET deleted_at = $1, version = version + 1 WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL AND ($4 = 0 OR version = $4)",
+		ts, petID, userID, version)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if rows == 0 {
+		// Tell a pet at another version apart from a missing one
+		exists, err := st.Exists(userID, petID)
+		if err != nil || !exists || version == 0 {
+			return 0, err
+		}
+		return 0, errVersionConflict
+	}
+	for _, table := range petRecordTables {
+		_, err := tx.Exec("UPDATE "+table+" SET deleted_at = $1 WHERE pet_id = $2 AND deleted_at IS NULL", ts, petID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return rows, tx.Commit()
+}
+
+//GetTrash returns the trashed pets of a user, most recently trashed first
+func (st *sqlPetStore) GetTrash(userID int64) ([]pet, error) {
+	return st.query("user_id = $1 AND deleted_at IS NOT NULL ORDER BY deleted_at DESC, id", userID)
+}
+
+//Restore takes a pet and its records out of the trash. Records deleted on
+//their own are gone for good, so everything trashed belongs to the pet.
+func (st *sqlPetStore) Restore(petID, userID int64) (int64, error) {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE pets SET deleted_at = NULL, version = version + 1 WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL", petID, userID)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil || rows == 0 {
+		return 0, err
+	}
+	for _, table := range petRecordTables {
+		_, err := tx.Exec("UPDATE "+table+" SET deleted_at = NULL WHERE pet_id = $1", petID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return rows, tx.Commit()
+}
+
+//GetExpired returns the pets of all users trashed before a time
+func (st *sqlPetStore) GetExpired(before time.Time) ([]pet, error) {
+	return st.query("deleted_at < $1 ORDER BY id", before)
+}
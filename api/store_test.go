@@ -0,0 +1,391 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestMemStoreConformance(t *testing.T) {
+	checkStoreConformance(t, func() (UserStore, PetStore) {
+		pets := newMemPetStore()
+		pets.nextID = bigPetID
+		return newMemUserStore(), pets
+	})
+}
+
+func TestSQLiteStoreConformance(t *testing.T) {
+	checkStoreConformance(t, func() (UserStore, PetStore) {
+		s := newSQLiteTestServer(t)
+		// A pet of nobody's at bigPetID makes the next ones count on
+		// from it
+		_, err := s.db.Exec(`INSERT INTO users(id, email, password, created_at, updated_at) VALUES($1, 'seed@example.com', 'hash', $2, $2)`, bigPetID, time.Now())
+		if err == nil {
+			_, err = s.db.Exec("INSERT INTO pets(id, user_id, name, created_at, updated_at) VALUES($1, $1, 'Seed', $2, $2)", bigPetID, time.Now())
+		}
+		if err != nil {
+			t.Fatalf("seeding the pet ids: %v", err)
+		}
+		return s.users, s.pets
+	})
+}
+
+// bigPetID is where the ids of pets start in the conformance runs, past
+// what a float64 holds exactly, like the ids CockroachDB hands out
+const bigPetID = 1 << 53
+
+// TestCockroachStoreConformance runs the suite against a CockroachDB
+// cluster when PETKEEP_TEST_COCKROACH_URL is set, for instance to
+// postgresql://root@localhost:26257/defaultdb?sslmode=disable. Every run
+// gets a database of its own, dropped afterwards.
+func TestCockroachStoreConformance(t *testing.T) {
+	connString := os.Getenv("PETKEEP_TEST_COCKROACH_URL")
+	if connString == "" {
+		t.Skip("PETKEEP_TEST_COCKROACH_URL is not set")
+	}
+	checkStoreConformance(t, func() (UserStore, PetStore) {
+		s := newCockroachTestServer(t, connString)
+		return s.users, s.pets
+	})
+}
+
+//newCockroachTestServer returns a server on a fresh, migrated database of
+//a CockroachDB cluster
+func newCockroachTestServer(t *testing.T, connString string) *server {
+	t.Helper()
+	admin, err := sql.Open("postgres", connString)
+	if err != nil {
+		t.Fatalf("connecting to cockroach: %v", err)
+	}
+	defer admin.Close()
+	name := fmt.Sprintf("petkeep_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE DATABASE " + name); err != nil {
+		t.Fatalf("creating a database: %v", err)
+	}
+	t.Cleanup(func() {
+		admin, err := sql.Open("postgres", connString)
+		if err != nil {
+			return
+		}
+		defer admin.Close()
+		admin.Exec("DROP DATABASE " + name + " CASCADE")
+	})
+
+	u, err := url.Parse(connString)
+	if err != nil {
+		t.Fatalf("parsing PETKEEP_TEST_COCKROACH_URL: %v", err)
+	}
+	u.Path = "/" + name
+	conn, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatalf("connecting to cockroach: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	s := &server{logger: zerolog.New(io.Discard), db: conn, users: &sqlUserStore{db: conn}, pets: &sqlPetStore{db: conn}}
+	mg, err := newMigrator(conn, driverCockroach, io.Discard)
+	if err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	err = mg.migrateTo(mg.latest())
+	if err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	return s
+}
+
+//newSQLiteTestServer returns a server on a fresh, migrated sqlite database
+func newSQLiteTestServer(t *testing.T) *server {
+	t.Helper()
+	s := &server{logger: zerolog.New(io.Discard)}
+	err := s.connectSQLite(filepath.Join(t.TempDir(), "petkeep.db"))
+	if err != nil {
+		t.Fatalf("opening sqlite: %v", err)
+	}
+	t.Cleanup(func() { s.db.Close() })
+	mg, err := newMigrator(s.db, driverSQLite, io.Discard)
+	if err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	err = mg.migrateTo(mg.latest())
+	if err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	return s
+}
+
+// handlerTest is a server on the memory stores with a signed in user
+type handlerTest struct {
+	t     *testing.T
+	s     *server
+	token string
+}
+
+func newHandlerTest(t *testing.T) *handlerTest {
+	t.Helper()
+	jwtSigningKey = "test-signing-key"
+	s := newServer("localhost", "8080")
+	s.logger = zerolog.New(io.Discard)
+	s.users = newMemUserStore()
+	s.pets = newMemPetStore()
+	s.auditLog = discardAuditLog{}
+	ts := time.Now()
+	id, err := s.users.Create(user{Email: "jane@example.com", Password: "hash", CreatedAt: ts, UpdatedAt: ts})
+	if err != nil {
+		t.Fatalf("creating the user: %v", err)
+	}
+	token, err := generateToken(uint(id), ts.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("signing a token: %v", err)
+	}
+	return &handlerTest{t: t, s: s, token: token}
+}
+
+// discardAuditLog drops audit entries, there's no audit_log table to keep
+// them in with the memory stores
+type discardAuditLog struct{}
+
+func (discardAuditLog) Append(e auditEntry) error {
+	return nil
+}
+
+//do sends a request as the signed in user and decodes the JSON response
+//into out when it is set. A body that is a reader is sent as is, anything
+//else as JSON.
+func (h *handlerTest) do(method, path string, body interface{}, header http.Header, out interface{}) *httptest.ResponseRecorder {
+	h.t.Helper()
+	var b io.Reader
+	switch body := body.(type) {
+	case nil:
+	case io.Reader:
+		b = body
+	default:
+		j, err := json.Marshal(body)
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		b = bytes.NewReader(j)
+	}
+	req := httptest.NewRequest(method, "/api/"+version+path, b)
+	req.Header.Set("Authorization", "Bearer "+h.token)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	h.s.router.ServeHTTP(w, req)
+	if out != nil && w.Code < 300 {
+		err := json.Unmarshal(w.Body.Bytes(), out)
+		if err != nil {
+			h.t.Fatalf("%s %s: decoding %q: %v", method, path, w.Body.String(), err)
+		}
+	}
+	return w
+}
+
+func TestHandlerPetsCreateAndGet(t *testing.T) {
+	h := newHandlerTest(t)
+
+	var created pet
+	w := h.do("POST", "/pets", petRequest{Name: "Fido", Type: "dog", Birthday: time.Date(2019, 11, 9, 0, 0, 0, 0, time.UTC)}, nil, &created)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("creating a pet: got %d %s", w.Code, w.Body.String())
+	}
+	if created.ID == 0 || created.Type != "Dog" || created.Version != 1 {
+		t.Errorf("creating a pet: got %+v", created)
+	}
+	if w.Header().Get("ETag") != versionETag(1) {
+		t.Errorf("creating a pet: got ETag %q", w.Header().Get("ETag"))
+	}
+
+	var got pet
+	w = h.do("GET", "/pets/1", nil, nil, &got)
+	if w.Code != http.StatusOK || got.Name != "Fido" {
+		t.Errorf("getting a pet: got %d %+v", w.Code, got)
+	}
+	w = h.do("GET", "/pets/fido", nil, nil, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("getting a pet with an invalid id: got %d, want 400", w.Code)
+	}
+	w = h.do("GET", "/pets/1", nil, http.Header{"If-None-Match": {versionETag(1)}}, nil)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("getting an unchanged pet: got %d, want 304", w.Code)
+	}
+
+	var all []pet
+	w = h.do("GET", "/pets", nil, nil, &all)
+	if w.Code != http.StatusOK || len(all) != 1 {
+		t.Errorf("getting all pets: got %d %v", w.Code, all)
+	}
+}
+
+func TestHandlerPetsListInvalidQuery(t *testing.T) {
+	h := newHandlerTest(t)
+
+	for param, path := range map[string]string{
+		"limit":  "/pets?limit=0",
+		"sort":   "/pets?sort=color",
+		"cursor": "/pets?cursor=nope",
+	} {
+		w := h.do("GET", path, nil, nil, nil)
+		var p problem
+		json.Unmarshal(w.Body.Bytes(), &p)
+		if w.Code != http.StatusBadRequest || p.Code != codeValidation || len(p.Errors) != 1 || p.Errors[0].Field != param {
+			t.Errorf("GET %s: got %d %+v, want a validation error on %s", path, w.Code, p, param)
+		}
+	}
+}
+
+func TestHandlerPetsCreateInvalid(t *testing.T) {
+	h := newHandlerTest(t)
+
+	w := h.do("POST", "/pets", map[string]interface{}{}, nil, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("creating an empty pet: got %d %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != mediaProblem {
+		t.Errorf("creating an empty pet: got Content-Type %q, want %q", ct, mediaProblem)
+	}
+	var p problem
+	json.Unmarshal(w.Body.Bytes(), &p)
+	if p.Code != codeValidation || len(p.Errors) != 1 || p.Errors[0].Field != "name" {
+		t.Errorf("creating an empty pet: got %+v, want name required", p)
+	}
+
+	w = h.do("POST", "/pets", petRequest{Name: "Stray", Breed: "Beagle"}, nil, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("creating a pet with a breed but no type: got %d", w.Code)
+	}
+	w = h.do("POST", "/pets", petRequest{Name: "Stray"}, nil, nil)
+	if w.Code != http.StatusCreated {
+		t.Errorf("creating a pet without a type: got %d %s", w.Code, w.Body.String())
+	}
+
+	w = h.do("POST", "/pets", map[string]interface{}{"name": "Fido", "type": "Dog", "color": "brown"}, nil, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("creating a pet with an unknown field: got %d", w.Code)
+	}
+	w = h.do("POST", "/pets", petRequest{Name: "Fido", Type: "Dog", Microchip: "123"}, nil, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("creating a pet with an invalid microchip: got %d", w.Code)
+	}
+	w = h.do("POST", "/pets", petRequest{Name: "Fido", Type: "Dog", Microchip: "985112345678903"}, nil, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("creating a chipped pet: got %d %s", w.Code, w.Body.String())
+	}
+	w = h.do("POST", "/pets", petRequest{Name: "Rex", Type: "Dog", Microchip: "985 112 345 678 903"}, nil, nil)
+	if w.Code != http.StatusConflict {
+		t.Errorf("creating a pet with a taken microchip: got %d, want 409", w.Code)
+	}
+}
+
+func TestHandlerPetsUpdate(t *testing.T) {
+	h := newHandlerTest(t)
+	h.do("POST", "/pets", petRequest{Name: "Fido", Type: "Dog"}, nil, nil)
+
+	var updated pet
+	w := h.do("PUT", "/pets/1", pet{Name: "Fido II", Type: "Dog"}, http.Header{"If-Match": {versionETag(1)}}, &updated)
+	if w.Code != http.StatusOK || updated.Name != "Fido II" || updated.Version != 2 {
+		t.Fatalf("updating a pet: got %d %+v", w.Code, updated)
+	}
+	w = h.do("PUT", "/pets/1", pet{Name: "Fido III", Type: "Dog"}, http.Header{"If-Match": {versionETag(1)}}, nil)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("updating a stale pet: got %d, want 412", w.Code)
+	}
+	w = h.do("PUT", "/pets/2", pet{Name: "Nobody", Type: "Dog"}, nil, nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("updating a missing pet: got %d, want 404", w.Code)
+	}
+}
+
+func TestHandlerPetsOtherUser(t *testing.T) {
+	h := newHandlerTest(t)
+	h.do("POST", "/pets", petRequest{Name: "Fido", Type: "Dog"}, nil, nil)
+
+	// Another user sees nothing of the first one's pets
+	id, _ := h.s.users.Create(user{Email: "other@example.com", Password: "hash"})
+	h.token, _ = generateToken(uint(id), time.Now().Add(time.Hour))
+	w := h.do("GET", "/pets/1", nil, nil, nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("getting the pet of another user: got %d, want 404", w.Code)
+	}
+	var all []pet
+	h.do("GET", "/pets", nil, nil, &all)
+	if len(all) != 0 {
+		t.Errorf("getting all pets: got the pets of another user %v", all)
+	}
+
+	h.token = "not a token"
+	w = h.do("GET", "/pets", nil, nil, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("getting pets without a valid token: got %d, want 401", w.Code)
+	}
+}
+
+func TestHandlerPetsDelete(t *testing.T) {
+	h := newHandlerTest(t)
+	h.do("POST", "/pets", petRequest{Name: "Fido", Type: "Dog"}, nil, nil)
+	h.do("PUT", "/pets/1", pet{Name: "Fido II", Type: "Dog"}, nil, nil)
+
+	w := h.do("DELETE", "/pets/1", nil, http.Header{"If-Match": {versionETag(1)}}, nil)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("deleting a stale pet: got %d, want 412", w.Code)
+	}
+	w = h.do("DELETE", "/pets/1", nil, http.Header{"If-Match": {versionETag(2)}}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("deleting a pet: got %d %s", w.Code, w.Body.String())
+	}
+	w = h.do("GET", "/pets/1", nil, nil, nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("getting a deleted pet: got %d, want 404", w.Code)
+	}
+	w = h.do("DELETE", "/pets/1", nil, nil, nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("deleting a deleted pet: got %d, want 404", w.Code)
+	}
+}
+
+func TestHandlerPetsImportInvalid(t *testing.T) {
+	h := newHandlerTest(t)
+	rows := []map[string]string{
+		{"name": "Fido", "type": "Dog", "microchip": "985112345678903"},
+		{"name": "Rex", "type": "Dog", "birthday": "yesterday"},
+		{"name": "Bella", "type": "Dog", "microchip": "985112345678903"},
+	}
+	w := h.do("POST", "/pets/import", rows, http.Header{"Content-Type": {"application/json"}}, nil)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("importing invalid rows: got %d %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != mediaProblem {
+		t.Errorf("importing invalid rows: got Content-Type %q, want %q", ct, mediaProblem)
+	}
+	var p problem
+	json.Unmarshal(w.Body.Bytes(), &p)
+	want := []fieldError{
+		{Row: 2, Field: "birthday", Code: codeInvalidField, Message: "birthday must be a date (YYYY-MM-DD)"},
+		{Row: 3, Field: "microchip", Code: codeMicrochipTaken, Message: "microchip is on row 1 too"},
+	}
+	if p.Code != codeValidation || len(p.Errors) != len(want) {
+		t.Fatalf("importing invalid rows: got %+v", p)
+	}
+	for i := range want {
+		if p.Errors[i] != want[i] {
+			t.Errorf("importing invalid rows: got error %+v, want %+v", p.Errors[i], want[i])
+		}
+	}
+
+	var all []pet
+	h.do("GET", "/pets", nil, nil, &all)
+	if len(all) != 0 {
+		t.Errorf("importing invalid rows: imported %v", all)
+	}
+}
@@ -0,0 +1,499 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//checkStoreConformance runs the behaviour every UserStore and PetStore has
+//to share against a backend. newStores must return empty stores.
+func checkStoreConformance(t *testing.T, newStores func() (UserStore, PetStore)) {
+	t.Helper()
+	checkUserStore(t, newStores)
+	checkPetStore(t, newStores)
+	checkPetTrash(t, newStores)
+	checkPetList(t, newStores)
+	checkPetBulk(t, newStores)
+}
+
+func checkUserStore(t *testing.T, newStores func() (UserStore, PetStore)) {
+	t.Helper()
+	users, _ := newStores()
+	ts := time.Now().UTC().Truncate(time.Second)
+
+	id, err := users.Create(user{Email: "jane@example.com", Password: "hash", CreatedAt: ts, UpdatedAt: ts})
+	if err != nil {
+		t.Fatalf("creating a user: %v", err)
+	}
+	_, err = users.Create(user{Email: "jane@example.com", Password: "other", CreatedAt: ts, UpdatedAt: ts})
+	if err != errEmailTaken {
+		t.Errorf("creating a user with a taken email: got %v, want errEmailTaken", err)
+	}
+
+	u, err := users.GetOne(id)
+	if err != nil {
+		t.Fatalf("getting a user: %v", err)
+	}
+	if int64(u.ID) != id || u.Email != "jane@example.com" || !u.CreatedAt.Equal(ts) {
+		t.Errorf("getting a user: got %+v", u)
+	}
+	if u.Password != "" {
+		t.Errorf("getting a user by ID returned its password")
+	}
+	if u.Role != roleOwner {
+		t.Errorf("new users: got role %q, want %q", u.Role, roleOwner)
+	}
+	_, err = users.GetOne(id + 100)
+	if err != sql.ErrNoRows {
+		t.Errorf("getting a missing user: got %v, want sql.ErrNoRows", err)
+	}
+
+	u, err = users.GetByEmail("jane@example.com")
+	if err != nil || int64(u.ID) != id || u.Password != "hash" {
+		t.Errorf("getting a user by email: got %+v, %v", u, err)
+	}
+	_, err = users.GetByEmail("nobody@example.com")
+	if err != sql.ErrNoRows {
+		t.Errorf("getting a missing user by email: got %v, want sql.ErrNoRows", err)
+	}
+
+	login := ts.Add(time.Hour)
+	err = users.SetLastLogin(id, login)
+	if err != nil {
+		t.Fatalf("setting the last login: %v", err)
+	}
+	u, _ = users.GetOne(id)
+	if !u.LastLogin.Equal(login) {
+		t.Errorf("last login: got %v, want %v", u.LastLogin, login)
+	}
+
+	rows, err := users.SetRole(id, roleVet)
+	if err != nil || rows != 1 {
+		t.Errorf("setting a role: got %d, %v", rows, err)
+	}
+	role, err := users.GetRole(id)
+	if err != nil || role != roleVet {
+		t.Errorf("getting a role: got %q, %v", role, err)
+	}
+	rows, err = users.SetRole(id+100, roleVet)
+	if err != nil || rows != 0 {
+		t.Errorf("setting the role of a missing user: got %d, %v", rows, err)
+	}
+	_, err = users.GetRole(id + 100)
+	if err != sql.ErrNoRows {
+		t.Errorf("getting the role of a missing user: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func checkPetStore(t *testing.T, newStores func() (UserStore, PetStore)) {
+	t.Helper()
+	users, pets := newStores()
+	ts := time.Now().UTC().Truncate(time.Second)
+	birthday := time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	owner, err := users.Create(user{Email: "owner@example.com", Password: "hash", CreatedAt: ts, UpdatedAt: ts})
+	if err != nil {
+		t.Fatalf("creating the owner: %v", err)
+	}
+	other, err := users.Create(user{Email: "other@example.com", Password: "hash", CreatedAt: ts, UpdatedAt: ts})
+	if err != nil {
+		t.Fatalf("creating another user: %v", err)
+	}
+
+	all, err := pets.GetAll(owner)
+	if err != nil || all == nil || len(all) != 0 {
+		t.Errorf("getting the pets of a user without any: got %v, %v, want an empty list", all, err)
+	}
+
+	dam := pet{Name: "Bella", Type: "Dog", Gender: "Female", Birthday: birthday, Microchip: "985112345678903", CreatedAt: ts, UpdatedAt: ts}
+	damID, err := pets.Create(dam, owner)
+	if err != nil {
+		t.Fatalf("creating a pet: %v", err)
+	}
+	damRef := uint(damID)
+	pup := pet{Name: "Fido", Type: "Dog", Gender: "Male", Breed: "Labrador Retriever", Birthday: birthday.AddDate(1, 0, 0), DamID: &damRef, CreatedAt: ts, UpdatedAt: ts}
+	pupID, err := pets.Create(pup, owner)
+	if err != nil {
+		t.Fatalf("creating a second pet: %v", err)
+	}
+	_, err = pets.Create(pet{Name: "Rex", Type: "Dog", Microchip: dam.Microchip, CreatedAt: ts, UpdatedAt: ts}, other)
+	if err != errMicrochipTaken {
+		t.Errorf("creating a pet with a taken microchip: got %v, want errMicrochipTaken", err)
+	}
+	otherID, err := pets.Create(pet{Name: "Rex", Type: "Dog", CreatedAt: ts, UpdatedAt: ts}, other)
+	if err != nil {
+		t.Fatalf("creating a pet for another user: %v", err)
+	}
+
+	damRef = 0
+	p, err := pets.GetOne(owner, pupID)
+	if err != nil {
+		t.Fatalf("getting a pet: %v", err)
+	}
+	if int64(p.ID) != pupID || int64(p.UserID) != owner || p.Name != "Fido" || p.Breed != "Labrador Retriever" || !p.Birthday.Equal(pup.Birthday) || p.Version != 1 {
+		t.Errorf("getting a pet: got %+v", p)
+	}
+	if p.DamID == nil || int64(*p.DamID) != damID || p.SireID != nil || p.LitterID != nil {
+		t.Errorf("getting a pet: got parents %v, %v and litter %v", p.DamID, p.SireID, p.LitterID)
+	}
+	_, err = pets.GetOne(other, pupID)
+	if err != sql.ErrNoRows {
+		t.Errorf("getting a pet of another user: got %v, want sql.ErrNoRows", err)
+	}
+
+	ok, err := pets.Exists(owner, damID)
+	if err != nil || !ok {
+		t.Errorf("checking a pet exists: got %v, %v", ok, err)
+	}
+	ok, err = pets.Exists(other, damID)
+	if err != nil || ok {
+		t.Errorf("checking a pet of another user exists: got %v, %v", ok, err)
+	}
+
+	all, err = pets.GetAll(owner)
+	if err != nil || len(all) != 2 || int64(all[0].ID) != damID || int64(all[1].ID) != pupID {
+		t.Errorf("getting the pets of a user: got %+v, %v", all, err)
+	}
+
+	p.Name = "Fido Jr"
+	p.Microchip = dam.Microchip
+	p.UpdatedAt = ts.Add(time.Minute)
+	_, err = pets.Update(p, owner)
+	if err != errMicrochipTaken {
+		t.Errorf("updating a pet to a taken microchip: got %v, want errMicrochipTaken", err)
+	}
+	p.Microchip = "985112345678904"
+	p.DamID = nil
+	version, err := pets.Update(p, owner)
+	if err != nil || version != 2 {
+		t.Fatalf("updating a pet: got version %d, %v", version, err)
+	}
+	p, _ = pets.GetOne(owner, pupID)
+	if p.Name != "Fido Jr" || p.Microchip != "985112345678904" || p.DamID != nil || !p.UpdatedAt.Equal(ts.Add(time.Minute)) || !p.CreatedAt.Equal(ts) || p.Version != 2 {
+		t.Errorf("getting an updated pet: got %+v", p)
+	}
+	p.Version = 1
+	version, err = pets.Update(p, owner)
+	if err != errVersionConflict || version != 0 {
+		t.Errorf("updating a pet at an old version: got %d, %v, want errVersionConflict", version, err)
+	}
+	p.Version = 2
+	version, err = pets.Update(p, owner)
+	if err != nil || version != 3 {
+		t.Errorf("updating a pet at its version: got %d, %v", version, err)
+	}
+	p.Name = "Stolen"
+	version, err = pets.Update(p, other)
+	if err != nil || version != 0 {
+		t.Errorf("updating a pet of another user: got %d, %v", version, err)
+	}
+	p, _ = pets.GetOne(owner, pupID)
+	if p.Name != "Fido Jr" {
+		t.Errorf("another user changed a pet's name to %q", p.Name)
+	}
+
+	rows, err := pets.Delete(pupID, other)
+	if err != nil || rows != 0 {
+		t.Errorf("deleting a pet of another user: got %d, %v", rows, err)
+	}
+	rows, err = pets.Delete(pupID, owner)
+	if err != nil || rows != 1 {
+		t.Errorf("deleting a pet: got %d, %v", rows, err)
+	}
+	_, err = pets.GetOne(owner, pupID)
+	if err != sql.ErrNoRows {
+		t.Errorf("getting a deleted pet: got %v, want sql.ErrNoRows", err)
+	}
+	all, _ = pets.GetAll(other)
+	if len(all) != 1 || int64(all[0].ID) != otherID {
+		t.Errorf("pets of another user after a delete: got %+v", all)
+	}
+}
+
+func checkPetTrash(t *testing.T, newStores func() (UserStore, PetStore)) {
+	t.Helper()
+	users, pets := newStores()
+	ts := time.Now().UTC().Truncate(time.Second)
+
+	owner, err := users.Create(user{Email: "owner@example.com", Password: "hash", CreatedAt: ts, UpdatedAt: ts})
+	if err != nil {
+		t.Fatalf("creating the owner: %v", err)
+	}
+	other, err := users.Create(user{Email: "other@example.com", Password: "hash", CreatedAt: ts, UpdatedAt: ts})
+	if err != nil {
+		t.Fatalf("creating another user: %v", err)
+	}
+	ids := []int64{}
+	for i, name := range []string{"Bella", "Fido", "Rex"} {
+		chip := []string{"985112345678901", "985112345678902", "985112345678903"}[i]
+		id, err := pets.Create(pet{Name: name, Type: "Dog", Microchip: chip, CreatedAt: ts, UpdatedAt: ts}, owner)
+		if err != nil {
+			t.Fatalf("creating a pet: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	rows, err := pets.Trash(ids[0], other, 0, ts)
+	if err != nil || rows != 0 {
+		t.Errorf("trashing a pet of another user: got %d, %v", rows, err)
+	}
+	rows, err = pets.Trash(ids[0], owner, 0, ts.Add(-48*time.Hour))
+	if err != nil || rows != 1 {
+		t.Errorf("trashing a pet: got %d, %v", rows, err)
+	}
+	rows, err = pets.Trash(ids[0], owner, 0, ts)
+	if err != nil || rows != 0 {
+		t.Errorf("trashing a trashed pet: got %d, %v", rows, err)
+	}
+	rows, err = pets.Trash(ids[1], owner, 2, ts)
+	if err != errVersionConflict || rows != 0 {
+		t.Errorf("trashing a pet at another version: got %d, %v, want errVersionConflict", rows, err)
+	}
+	rows, err = pets.Trash(ids[1], owner, 1, ts)
+	if err != nil || rows != 1 {
+		t.Errorf("trashing a second pet: got %d, %v", rows, err)
+	}
+
+	// Trashed pets are hidden from everything but the trash
+	all, _ := pets.GetAll(owner)
+	if len(all) != 1 || int64(all[0].ID) != ids[2] {
+		t.Errorf("getting the pets of a user with trashed pets: got %+v", all)
+	}
+	_, err = pets.GetOne(owner, ids[0])
+	if err != sql.ErrNoRows {
+		t.Errorf("getting a trashed pet: got %v, want sql.ErrNoRows", err)
+	}
+	ok, _ := pets.Exists(owner, ids[0])
+	if ok {
+		t.Errorf("a trashed pet exists")
+	}
+	_, err = pets.Create(pet{Name: "Copy", Type: "Dog", Microchip: "985112345678901", CreatedAt: ts, UpdatedAt: ts}, other)
+	if err != errMicrochipTaken {
+		t.Errorf("creating a pet with the microchip of a trashed pet: got %v, want errMicrochipTaken", err)
+	}
+
+	trash, err := pets.GetTrash(owner)
+	if err != nil || len(trash) != 2 || int64(trash[0].ID) != ids[1] || int64(trash[1].ID) != ids[0] {
+		t.Fatalf("getting the trash: got %+v, %v", trash, err)
+	}
+	if trash[0].DeletedAt == nil || !trash[0].DeletedAt.Equal(ts) {
+		t.Errorf("trashed pet: got deleted_at %v, want %v", trash[0].DeletedAt, ts)
+	}
+	trash, _ = pets.GetTrash(other)
+	if len(trash) != 0 {
+		t.Errorf("getting the trash of another user: got %+v", trash)
+	}
+
+	expired, err := pets.GetExpired(ts.Add(-24 * time.Hour))
+	if err != nil || len(expired) != 1 || int64(expired[0].ID) != ids[0] {
+		t.Errorf("getting expired pets: got %+v, %v", expired, err)
+	}
+
+	rows, err = pets.Restore(ids[1], other)
+	if err != nil || rows != 0 {
+		t.Errorf("restoring a pet of another user: got %d, %v", rows, err)
+	}
+	rows, err = pets.Restore(ids[1], owner)
+	if err != nil || rows != 1 {
+		t.Errorf("restoring a pet: got %d, %v", rows, err)
+	}
+	rows, err = pets.Restore(ids[2], owner)
+	if err != nil || rows != 0 {
+		t.Errorf("restoring a pet that isn't trashed: got %d, %v", rows, err)
+	}
+	p, err := pets.GetOne(owner, ids[1])
+	if err != nil || p.DeletedAt != nil || p.Version != 3 {
+		t.Errorf("getting a restored pet: got %+v, %v", p, err)
+	}
+
+	rows, err = pets.Delete(ids[0], owner)
+	if err != nil || rows != 1 {
+		t.Errorf("deleting a trashed pet for good: got %d, %v", rows, err)
+	}
+	trash, _ = pets.GetTrash(owner)
+	if len(trash) != 0 {
+		t.Errorf("trash after deleting for good: got %+v", trash)
+	}
+}
+
+func checkPetList(t *testing.T, newStores func() (UserStore, PetStore)) {
+	t.Helper()
+	users, pets := newStores()
+	ts := time.Now().UTC().Truncate(time.Second)
+
+	owner, err := users.Create(user{Email: "owner@example.com", Password: "hash", CreatedAt: ts, UpdatedAt: ts})
+	if err != nil {
+		t.Fatalf("creating the owner: %v", err)
+	}
+	other, err := users.Create(user{Email: "other@example.com", Password: "hash", CreatedAt: ts, UpdatedAt: ts})
+	if err != nil {
+		t.Fatalf("creating another user: %v", err)
+	}
+	born := func(year int) time.Time { return time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC) }
+	for _, p := range []pet{
+		{Name: "Rex", Type: "Dog", Breed: "Beagle", Birthday: born(2015)},
+		{Name: "Bella", Type: "Dog", Breed: "Poodle", Birthday: born(2018)},
+		{Name: "Tom", Type: "Cat", Breed: "Siamese", Birthday: born(2018)},
+		{Name: "Fido", Type: "Dog", Breed: "Beagle", Birthday: born(2020)},
+		{Name: "Kit", Type: "Cat"},
+	} {
+		p.CreatedAt, p.UpdatedAt = ts, ts
+		if _, err := pets.Create(p, owner); err != nil {
+			t.Fatalf("creating a pet: %v", err)
+		}
+	}
+	if _, err := pets.Create(pet{Name: "Max", Type: "Dog", Birthday: born(2018), CreatedAt: ts, UpdatedAt: ts}, other); err != nil {
+		t.Fatalf("creating a pet of another user: %v", err)
+	}
+
+	// Walk every page of a filtered list sorted by birthday, youngest
+	// first, then by name
+	q := listQuery{
+		schema:  petListSchema,
+		Filters: []listFilter{{Field: "type", Op: "=", Value: "dog"}, {Field: "birthday", Op: "<", Value: born(2020)}},
+		Sort:    []listSort{{Field: "birthday", Desc: true}, {Field: "name"}, {Field: "id"}},
+		Limit:   1,
+		Count:   true,
+	}
+	names := []string{}
+	for page := 0; page < 5; page++ {
+		got, total, err := pets.List(owner, q)
+		if err != nil || total != 2 {
+			t.Fatalf("listing pets: got total %d, %v, want 2", total, err)
+		}
+		if len(got) == 0 {
+			break
+		}
+		names = append(names, got[0].Name)
+		if len(got) <= q.Limit {
+			break
+		}
+		q = nextListPage(t, q, "sort=-birthday,name", got[q.Limit-1])
+	}
+	if strings.Join(names, ",") != "Bella,Rex" {
+		t.Errorf("listing pets page by page: got %v, want Bella, Rex", names)
+	}
+
+	// Cursors carry ids exactly, however large the database makes them
+	q = listQuery{schema: petListSchema, Sort: []listSort{{Field: "id"}}, Limit: 1}
+	seen := map[uint]bool{}
+	for page := 0; page < 6; page++ {
+		got, _, err := pets.List(owner, q)
+		if err != nil {
+			t.Fatalf("listing pets by id: %v", err)
+		}
+		if len(got) == 0 {
+			break
+		}
+		if seen[got[0].ID] {
+			t.Fatalf("listing pets by id: got pet %d twice", got[0].ID)
+		}
+		seen[got[0].ID] = true
+		if len(got) <= q.Limit {
+			break
+		}
+		q = nextListPage(t, q, "", got[q.Limit-1])
+	}
+	if len(seen) != 5 {
+		t.Errorf("listing pets by id page by page: got %d pets, want 5", len(seen))
+	}
+
+	// A pet without a birthday is the oldest
+	q = listQuery{schema: petListSchema, Sort: []listSort{{Field: "birthday"}, {Field: "id"}}, Limit: 1}
+	names = []string{}
+	for page := 0; page < 6; page++ {
+		got, _, err := pets.List(owner, q)
+		if err != nil {
+			t.Fatalf("listing pets by birthday: %v", err)
+		}
+		if len(got) == 0 {
+			break
+		}
+		names = append(names, got[0].Name)
+		if len(got) <= q.Limit {
+			break
+		}
+		q = nextListPage(t, q, "sort=birthday", got[q.Limit-1])
+	}
+	if strings.Join(names, ",") != "Kit,Rex,Bella,Tom,Fido" {
+		t.Errorf("listing pets by birthday page by page: got %v, want Kit, Rex, Bella, Tom, Fido", names)
+	}
+
+	q = listQuery{schema: petListSchema, Sort: []listSort{{Field: "name", Desc: true}, {Field: "id"}}, Limit: 10}
+	got, total, err := pets.List(owner, q)
+	if err != nil || len(got) != 5 || got[0].Name != "Tom" || got[4].Name != "Bella" || total != 0 {
+		t.Errorf("listing pets sorted by name: got %+v, %d, %v", got, total, err)
+	}
+}
+
+//nextListPage returns the query of the page after an item, going through
+//the cursor a client would be handed with the given query params
+func nextListPage(t *testing.T, q listQuery, params string, last pet) listQuery {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/pets?"+params+"&cursor="+q.cursorAfter(last), nil)
+	next, err := petListSchema.listQueryFromRequest(r)
+	if err != nil {
+		t.Fatalf("reading the cursor after pet %d: %v", last.ID, err)
+	}
+	q.After = next.After
+	return q
+}
+
+func checkPetBulk(t *testing.T, newStores func() (UserStore, PetStore)) {
+	t.Helper()
+	users, pets := newStores()
+	ts := time.Now().UTC().Truncate(time.Second)
+
+	owner, err := users.Create(user{Email: "owner@example.com", Password: "hash", CreatedAt: ts, UpdatedAt: ts})
+	if err != nil {
+		t.Fatalf("creating the owner: %v", err)
+	}
+	_, err = pets.Create(pet{Name: "Rex", Microchip: "985112345678901", CreatedAt: ts, UpdatedAt: ts}, owner)
+	if err != nil {
+		t.Fatalf("creating a pet: %v", err)
+	}
+
+	taken, err := pets.TakenMicrochips([]string{"985112345678901", "985112345678902"})
+	if err != nil || len(taken) != 1 || taken[0] != "985112345678901" {
+		t.Errorf("looking up taken microchips: got %v, %v", taken, err)
+	}
+
+	// A taken microchip fails the whole batch
+	_, err = pets.CreateMany([]pet{
+		{Name: "Bella", Microchip: "985112345678902", CreatedAt: ts, UpdatedAt: ts},
+		{Name: "Copy", Microchip: "985112345678901", CreatedAt: ts, UpdatedAt: ts},
+	}, owner)
+	if err != errMicrochipTaken {
+		t.Errorf("creating pets with a taken microchip: got %v, want errMicrochipTaken", err)
+	}
+	all, _ := pets.GetAll(owner)
+	if len(all) != 1 {
+		t.Errorf("pets after a failed batch: got %+v", all)
+	}
+
+	ids, err := pets.CreateMany([]pet{
+		{Name: "Bella", Microchip: "985112345678902", CreatedAt: ts, UpdatedAt: ts},
+		{Name: "Fido", CreatedAt: ts, UpdatedAt: ts},
+	}, owner)
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("creating pets: got %v, %v", ids, err)
+	}
+
+	names := []string{}
+	err = pets.Each(owner, func(p pet) error {
+		names = append(names, p.Name)
+		return nil
+	})
+	if err != nil || strings.Join(names, ",") != "Rex,Bella,Fido" {
+		t.Errorf("going through the pets: got %v, %v", names, err)
+	}
+	stop := errors.New("stop")
+	err = pets.Each(owner, func(p pet) error { return stop })
+	if err != stop {
+		t.Errorf("stopping going through the pets: got %v, want the error of fn", err)
+	}
+}
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timelineKinds are the kinds of event a timeline can be filtered by.
+// Medical records keep their own kind, with "record" for other records.
+var timelineKinds = map[string]bool{
+	"created":     true,
+	"weight":      true,
+	"visit":       true,
+	"vaccination": true,
+	"medication":  true,
+	"procedure":   true,
+	"lab":         true,
+	"record":      true,
+	"note":        true,
+	"photo":       true,
+	"document":    true,
+	"activity":    true,
+}
+
+// defaultTimelineLimit and maxTimelineLimit bound a page of timeline events
+const (
+	defaultTimelineLimit = 50
+	maxTimelineLimit     = 200
+)
+
+// timelineSource is a table the events of a timeline come from, with the
+// SQL expressions giving the fields of an event. Source says which kind of
+// resource an event links back to.
+type timelineSource struct {
+	Table      string
+	PetColumn  string
+	Kind       string
+	OccurredAt string
+	Title      string
+	Detail     string
+	Amount     string
+	Source     string
+}
+
+// timelineSources are everything that happens to a pet. Medical records
+// keep their own kind, with "record" for other records.
+var timelineSources = []timelineSource{
+	{Table: "pets", PetColumn: "id", Kind: "'created'", OccurredAt: "created_at", Title: "name", Detail: "NULL", Amount: "NULL", Source: "'pet'"},
+	{Table: "pet_weights", PetColumn: "pet_id", Kind: "'weight'", OccurredAt: "measured_at", Title: "NULL", Detail: "notes", Amount: "weight_grams", Source: "'weight'"},
+	{Table: "medical_records", PetColumn: "pet_id", Kind: "CASE WHEN kind = 'other' THEN 'record' ELSE kind END", OccurredAt: "occurred_on", Title: "title", Detail: "notes", Amount: "NULL", Source: "'record'"},
+	{Table: "pet_notes", PetColumn: "pet_id", Kind: "'note'", OccurredAt: "occurred_at", Title: "title", Detail: "body", Amount: "NULL", Source: "'note'"},
+	{Table: "attachments", PetColumn: "pet_id", Kind: "CASE WHEN content_type LIKE 'image/%' THEN 'photo' ELSE 'document' END", OccurredAt: "created_at", Title: "filename", Detail: "description", Amount: "NULL", Source: "kind"},
+	{Table: "activities", PetColumn: "pet_id", Kind: "'activity'", OccurredAt: "started_at", Title: "kind", Detail: "notes", Amount: "CAST(round(distance_meters) AS BIGINT)", Source: "'activity'"},
+}
+
+type timelineEvent struct {
+	Kind       string    `json:"kind" example:"vaccination"`
+	ID         uint      `json:"id" example:"4"`
+	OccurredAt time.Time `json:"occurred_at" example:"2019-11-09T00:00:00Z"`
+	Title      string    `json:"title" example:"Rabies booster"`
+	Detail     string    `json:"detail,omitempty" example:"No reaction, next booster in 3 years"`
+	DetailHTML string    `json:"detail_html,omitempty" example:"<p>Loved the <strong>waves</strong></p>"`
+	URL        string    `json:"url" example:"/api/v1/pets/1/records/4"`
+}
+
+type timelinePage struct {
+	Events     []timelineEvent `json:"events"`
+	NextCursor string          `json:"next_cursor,omitempty" example:"eyJ0IjoiMjAxOS0xMS0wOVQwMDowMDowMFoiLCJrIjoidmFjY2luYXRpb24iLCJpIjo0fQ"`
+}
+
+// timelineCursor is the position of the last event on a page. It is handed
+// to clients as opaque base64 JSON.
+type timelineCursor struct {
+	OccurredAt time.Time `json:"t"`
+	Kind       string    `json:"k"`
+	ID         uint      `json:"i"`
+}
+
+// timelineFilter narrows down the events of a timeline
+type timelineFilter struct {
+	Kinds  []string
+	From   time.Time
+	To     time.Time
+	Cursor *timelineCursor
+	Limit  int
+}
+
+//encode returns the opaque form of a cursor
+func (c timelineCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+//decodeTimelineCursor parses a cursor from a previous page
+func decodeTimelineCursor(s string) (*timelineCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c timelineCursor
+	err = json.Unmarshal(b, &c)
+	if err != nil || c.OccurredAt.IsZero() || !timelineKinds[c.Kind] {
+		return nil, errors.New("invalid cursor")
+	}
+	return &c, nil
+}
+
+//timelineFilterFromRequest reads the kind, from, to, cursor and limit
+//query params
+func timelineFilterFromRequest(r *http.Request) (timelineFilter, error) {
+	f := timelineFilter{Limit: defaultTimelineLimit}
+	var err error
+	for _, k := range strings.Split(r.URL.Query().Get("kind"), ",") {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		if !timelineKinds[k] {
+			return f, invalidField("kind", fmt.Sprintf("unknown event kind %q", k))
+		}
+		f.Kinds = append(f.Kinds, k)
+	}
+	f.From, err = parseDateParam(r, "from")
+	if err != nil {
+		return f, err
+	}
+	f.To, err = parseDateParam(r, "to")
+	if err != nil {
+		return f, err
+	}
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		f.Cursor, err = decodeTimelineCursor(c)
+		if err != nil {
+			return f, invalidField("cursor", "invalid cursor")
+		}
+	}
+	if l := r.URL.Query().Get("limit"); l != "" {
+		f.Limit, err = strconv.Atoi(l)
+		if err != nil || f.Limit < 1 || f.Limit > maxTimelineLimit {
+			return f, invalidField("limit", fmt.Sprintf("limit must be between 1 and %d", maxTimelineLimit))
+		}
+	}
+	return f, nil
+}
+
+//timelineURL links an event back to the API resource it came from
+func timelineURL(petID int64, source string, id uint) string {
+	base := fmt.Sprintf("/api/%s/pets/%d", version, petID)
+	switch source {
+	case "weight":
+		return base + "/weights"
+	case "record":
+		return fmt.Sprintf("%s/records/%d", base, id)
+	case "note":
+		return fmt.Sprintf("%s/notes/%d", base, id)
+	case "activity":
+		return fmt.Sprintf("%s/activities/%d", base, id)
+	case attachmentKindAvatar:
+		return base + "/photo"
+	case attachmentKindDocument:
+		return fmt.Sprintf("%s/attachments/%d", base, id)
+	}
+	return base
+}
+
+//dbTimeline returns a page of the events of a pet, newest first. Each
+//source is asked for one more event than the limit, the newest of them
+//all make the page and one more tells whether there is a next page.
+func (s *server) dbTimeline(petID int64, f timelineFilter) (timelinePage, error) {
+	page := timelinePage{Events: []timelineEvent{}}
+	for _, src := range timelineSources {
+		events, err := s.dbTimelineSource(petID, src, f)
+		if err != nil {
+			return timelinePage{}, err
+		}
+		page.Events = append(page.Events, events...)
+	}
+	sort.Slice(page.Events, func(i, j int) bool {
+		a, b := page.Events[i], page.Events[j]
+		if !a.OccurredAt.Equal(b.OccurredAt) {
+			return a.OccurredAt.After(b.OccurredAt)
+		}
+		if a.Kind != b.Kind {
+			return a.Kind > b.Kind
+		}
+		return a.ID > b.ID
+	})
+
+	if len(page.Events) > f.Limit {
+		page.Events = page.Events[:f.Limit]
+		last := page.Events[f.Limit-1]
+		page.NextCursor = timelineCursor{OccurredAt: last.OccurredAt, Kind: last.Kind, ID: last.ID}.encode()
+	}
+	return page, nil
+}
+
+//dbTimelineSource returns the newest events of a pet from one source that
+//match a filter, at most one more than the limit
+func (s *server) dbTimelineSource(petID int64, src timelineSource, f timelineFilter) ([]timelineEvent, error) {
+	where := []string{src.PetColumn + " = $1", "deleted_at IS NULL"}
+	args := []interface{}{petID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if len(f.Kinds) > 0 {
+		kinds := make([]string, len(f.Kinds))
+		for i, k := range f.Kinds {
+			kinds[i] = arg(k)
+		}
+		where = append(where, src.Kind+" IN ("+strings.Join(kinds, ",")+")")
+	}
+	if !f.From.IsZero() {
+		where = append(where, src.OccurredAt+" >= "+arg(f.From))
+	}
+	if !f.To.IsZero() {
+		where = append(where, src.OccurredAt+" < "+arg(f.To))
+	}
+	if f.Cursor != nil {
+		t, k, id := arg(f.Cursor.OccurredAt), arg(f.Cursor.Kind), arg(int64(f.Cursor.ID))
+		where = append(where, fmt.Sprintf("(%[1]s < %[4]s OR (%[1]s = %[4]s AND (%[2]s < %[5]s OR (%[2]s = %[5]s AND %[3]s < %[6]s))))",
+			src.OccurredAt, src.Kind, "id", t, k, id))
+	}
+	q := fmt.Sprintf("SELECT %s, id, %s, %s, %s, %s, %s FROM %s WHERE %s ORDER BY %s DESC, %s DESC, id DESC LIMIT %s",
+		src.Kind, src.OccurredAt, src.Title, src.Detail, src.Amount, src.Source, src.Table,
+		strings.Join(where, " AND "), src.OccurredAt, src.Kind, arg(f.Limit+1))
+
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []timelineEvent{}
+	for rows.Next() {
+		var e timelineEvent
+		var title, detail sql.NullString
+		var amount sql.NullInt64
+		var source string
+		err := rows.Scan(&e.Kind, &e.ID, &e.OccurredAt, &title, &detail, &amount, &source)
+		if err != nil {
+			return nil, err
+		}
+		e.Title = title.String
+		e.Detail = detail.String
+		e.URL = timelineURL(petID, source, e.ID)
+		switch e.Kind {
+		case "weight":
+			e.Title = formatWeight(amount.Int64)
+		case "created":
+			e.Title = e.Title + " joined"
+		case "note":
+			e.DetailHTML = renderMarkdown(e.Detail)
+		case "activity":
+			if e.Title != "" {
+				e.Title = strings.ToUpper(e.Title[:1]) + e.Title[1:]
+			}
+			if amount.Int64 > 0 {
+				e.Title += ", " + formatDistance(float64(amount.Int64))
+			}
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// handlerPetsTimeline godoc
+// @Summary Get the timeline of a pet
+// @Description Get everything that happened to a pet in one list, newest first: when it was added, weights, medical records, journal notes, photos, documents and activities. Pass next_cursor back as cursor to get the next page.
+// @Tags Journal
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Param kind query string false "Comma separated event kinds: created, weight, visit, vaccination, medication, procedure, lab, record, note, photo, document, activity"
+// @Param from query string false "Earliest date, YYYY-MM-DD"
+// @Param to query string false "Date before which to stop, YYYY-MM-DD"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "Events per page, 1 to 200, defaults to 50"
+// @Success 200 {object} timelinePage
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/timeline [get]
+func (s *server) handlerPetsTimeline() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		f, err := timelineFilterFromRequest(r)
+		if err != nil {
+			s.respondError(w, r, err, "error reading query")
+			return
+		}
+		if !s.requirePet(w, r, userID, petID) {
+			return
+		}
+		page, err := s.dbTimeline(petID, f)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving timeline from database")
+			s.respond(w, r, nil, "error retrieving timeline", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, page, "", http.StatusOK)
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// trashPurgeInterval is how often trashed pets are checked for being past
+// the retention period
+const trashPurgeInterval = time.Hour
+
+// handlerPetsTrash godoc
+// @Summary Get the trash
+// @Description Get the deleted pets of the user that can still be restored, most recently deleted first
+// @Tags Pets
+// @Produce json
+// @Success 200 {array} pet
+// @Security ApiKeyAuth
+// @Router /pets/trash [get]
+func (s *server) handlerPetsTrash() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving user ID from context")
+			s.respond(w, r, nil, "error retrieving user", http.StatusUnauthorized)
+			return
+		}
+		pets, err := s.pets.GetTrash(userID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving trash from database")
+			s.respond(w, r, nil, "error retrieving trash", http.StatusInternalServerError)
+			return
+		}
+		s.respond(w, r, pets, "", http.StatusOK)
+	}
+}
+
+// handlerPetsRestore godoc
+// @Summary Restore a pet
+// @Description Take a deleted pet and its records out of the trash
+// @Tags Pets
+// @Produce json
+// @Param PetID path int true "Pet ID"
+// @Success 200 {object} pet
+// @Security ApiKeyAuth
+// @Router /pets/{PetID}/restore [post]
+func (s *server) handlerPetsRestore() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, petID, ok := s.userAndPetFromRequest(w, r)
+		if !ok {
+			return
+		}
+		// Find the pet in the trash, the audit log records when it was trashed
+		trash, err := s.pets.GetTrash(userID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving trash from database")
+			s.respond(w, r, nil, "error restoring pet", http.StatusInternalServerError)
+			return
+		}
+		var trashed *pet
+		for i := range trash {
+			if int64(trash[i].ID) == petID {
+				trashed = &trash[i]
+			}
+		}
+		if trashed == nil {
+			s.respond(w, r, nil, "pet not found in trash", http.StatusNotFound)
+			return
+		}
+
+		rows, err := s.pets.Restore(petID, userID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error restoring pet in database")
+			s.respond(w, r, nil, "error restoring pet", http.StatusInternalServerError)
+			return
+		}
+		if rows == 0 {
+			s.respond(w, r, nil, "pet not found in trash", http.StatusNotFound)
+			return
+		}
+		p, err := s.pets.GetOne(userID, petID)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error retrieving pet from database")
+			s.respond(w, r, nil, "error retrieving pet", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("ETag", versionETag(p.Version))
+		s.audit(r, auditEntry{OwnerID: p.UserID, Action: auditRestore, EntityType: auditEntityPet, EntityID: p.ID}, *trashed, p)
+		s.respond(w, r, p, "", http.StatusOK)
+	}
+}
+
+//purgeTrash removes the pets trashed before a time for good, with their
+//records and files
+func (s *server) purgeTrash(ctx context.Context, before time.Time) error {
+	pets, err := s.pets.GetExpired(before)
+	if err != nil {
+		return err
+	}
+	for _, p := range pets {
+		// Collect the pet's files, the database cascade won't remove them
+		atts, err := s.dbAttachmentsGetPet(int64(p.UserID), int64(p.ID))
+		if err != nil {
+			return err
+		}
+		rows, err := s.pets.Delete(int64(p.ID), int64(p.UserID))
+		if err != nil {
+			return err
+		}
+
+		// Another replica may have purged the pet first
+		if rows > 0 {
+			s.audit(nil, auditEntry{OwnerID: p.UserID, Action: auditPurge, EntityType: auditEntityPet, EntityID: p.ID}, p, nil)
+			s.deleteBlobs(ctx, atts)
+			s.logger.Info().Uint("pet_id", p.ID).Msg("purged pet from trash")
+		}
+	}
+	return nil
+}
+
+//runTrashPurge purges the trash every trashPurgeInterval, keeping trashed
+//pets for the retention period
+func (s *server) runTrashPurge(retention time.Duration) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+	for {
+		err := s.purgeTrash(context.Background(), time.Now().Add(-retention))
+		if err != nil {
+			s.logger.Error().Err(err).Msg("error purging trash")
+		}
+		<-ticker.C
+	}
+}
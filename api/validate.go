@@ -0,0 +1,318 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/badoux/checkmail"
+)
+
+// Codes of field errors found by validate
+const (
+	codeRequired   = "required"
+	codeTooShort   = "too_short"
+	codeTooLong    = "too_long"
+	codeOutOfRange = "out_of_range"
+	codeNotAllowed = "not_allowed"
+)
+
+// A validate tag lists the rules of a field of a request, separated by
+// commas:
+//
+//	required        the field must be set, strings must not be blank
+//	min=n, max=n    length of strings, value of numbers
+//	min=d, max=d    bounds of times, d is a date (YYYY-MM-DD) or now
+//	oneof=a b c     the field must be one of the values, ignoring case
+//	email           the field must be an email address
+//
+// Fields that aren't set are only checked by required. Rules spanning
+// fields are made by giving the request a checkFields method. Tags are
+// parsed once per request type, those of the API's requests when the
+// package is initialized, so a bad tag never fails a request.
+type validationRule struct {
+	Name  string
+	Param string
+	// Bound is the param of a min or max rule parsed, a number or, on a
+	// time, a date unless Now is set
+	Bound float64
+	Date  time.Time
+	Now   bool
+}
+
+// fieldChecker is a request with rules spanning several fields
+type fieldChecker interface {
+	checkFields() []fieldError
+}
+
+// validatedField is a field of a request type that has rules
+type validatedField struct {
+	Index []int
+	Name  string
+	Rules []validationRule
+}
+
+// validatedFields caches the fields with rules of each request type
+var validatedFields sync.Map
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func init() {
+	mustParseTags(userRequest{}, petRequest{}, expenseRequest{}, medicalRecordRequest{}, providerRequest{},
+		appointmentRequest{}, policyRequest{}, claimRequest{}, noteRequest{}, weightRequest{}, activityRequest{},
+		litterRequest{}, catalogEntryRequest{}, organizationRequest{}, orgMemberRequest{}, petAccessRequest{}, activityGoal{})
+}
+
+//validate checks a request against the rules in its validate tags and
+//those of its checkFields method. All the violations are returned at once
+//as an apiError, nil when there are none.
+func validate(req interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(req))
+	fields, err := fieldsOf(v.Type())
+	if err != nil {
+		return err
+	}
+	errs := []fieldError{}
+	for _, f := range fields {
+		errs = append(errs, checkRules(f, v.FieldByIndex(f.Index))...)
+	}
+	if fc, ok := req.(fieldChecker); ok {
+		errs = append(errs, fc.checkFields()...)
+	}
+	return validationError(errs)
+}
+
+//mustParseTags parses the validate tags of request types up front,
+//panicking on an invalid one so it stops the server from starting
+func mustParseTags(reqs ...interface{}) {
+	for _, req := range reqs {
+		if _, err := fieldsOf(reflect.TypeOf(req)); err != nil {
+			panic(err)
+		}
+	}
+}
+
+//validationError returns the apiError reporting field errors, nil when
+//there are none
+func validationError(errs []fieldError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	detail := errs[0].Message
+	if len(errs) > 1 {
+		detail = fmt.Sprintf("%s (and %d more)", detail, len(errs)-1)
+	}
+	return &apiError{Status: http.StatusBadRequest, Code: codeValidation, Detail: detail, Fields: errs}
+}
+
+//fieldsOf returns the fields with rules of a struct type, named like in
+//JSON, or the error of an invalid tag. The fields of nested structs are
+//named parent.field.
+func fieldsOf(t reflect.Type) ([]validatedField, error) {
+	if fields, ok := validatedFields.Load(t); ok {
+		return fields.([]validatedField), nil
+	}
+	fields := []validatedField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if tag := f.Tag.Get("validate"); tag != "" {
+			rules, err := parseRules(tag, ft)
+			if err != nil {
+				return nil, fmt.Errorf("validate tag of %s.%s: %w", t.Name(), f.Name, err)
+			}
+			fields = append(fields, validatedField{Index: f.Index, Name: name, Rules: rules})
+		}
+		if ft.Kind() == reflect.Struct && ft != timeType {
+			nested, err := fieldsOf(ft)
+			if err != nil {
+				return nil, err
+			}
+			for _, n := range nested {
+				n.Index = append([]int{i}, n.Index...)
+				if !f.Anonymous {
+					n.Name = name + "." + n.Name
+				}
+				fields = append(fields, n)
+			}
+		}
+	}
+	validatedFields.Store(t, fields)
+	return fields, nil
+}
+
+//parseRules parses the validate tag of a field of type t, checking that
+//its rules exist and their params suit the field
+func parseRules(tag string, t reflect.Type) ([]validationRule, error) {
+	rules := []validationRule{}
+	for _, r := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(r), "=")
+		rule := validationRule{Name: name, Param: param}
+		switch name {
+		case "required", "email":
+		case "oneof":
+			if len(strings.Fields(param)) == 0 {
+				return nil, errors.New("oneof must list values")
+			}
+		case "min", "max":
+			if err := parseBound(&rule, t); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+//parseBound parses the param of a min or max rule on a field of type t
+func parseBound(rule *validationRule, t reflect.Type) error {
+	if t == timeType {
+		if rule.Param == "now" {
+			rule.Now = true
+			return nil
+		}
+		d, err := time.Parse("2006-01-02", rule.Param)
+		if err != nil {
+			return fmt.Errorf("%s of a time must be a date (YYYY-MM-DD) or now, not %q", rule.Name, rule.Param)
+		}
+		rule.Date = d
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+	default:
+		return fmt.Errorf("can't bound a %s", t.Kind())
+	}
+	n, err := strconv.ParseFloat(rule.Param, 64)
+	if err != nil {
+		return fmt.Errorf("%s must be a number, not %q", rule.Name, rule.Param)
+	}
+	rule.Bound = n
+	return nil
+}
+
+//checkRules checks the value of a field against its rules
+func checkRules(f validatedField, v reflect.Value) []fieldError {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v = reflect.Value{}
+		} else {
+			v = v.Elem()
+		}
+	}
+	set := v.IsValid() && !v.IsZero()
+	if set && v.Kind() == reflect.String {
+		set = strings.TrimSpace(v.String()) != ""
+	}
+	errs := []fieldError{}
+	for _, rule := range f.Rules {
+		if rule.Name == "required" {
+			if !set {
+				errs = append(errs, fieldError{Field: f.Name, Code: codeRequired, Message: f.Name + " is required"})
+			}
+			continue
+		}
+		if !set {
+			continue
+		}
+		if code, msg := checkRule(rule, v); code != "" {
+			errs = append(errs, fieldError{Field: f.Name, Code: code, Message: f.Name + " " + msg})
+		}
+	}
+	return errs
+}
+
+//checkRule checks a value set against one rule, returning the code and
+//message of the violation or empty strings
+func checkRule(rule validationRule, v reflect.Value) (code, msg string) {
+	switch rule.Name {
+	case "min", "max":
+		return checkBound(rule, v)
+	case "oneof":
+		allowed := strings.Fields(rule.Param)
+		for _, a := range allowed {
+			if strings.EqualFold(strings.TrimSpace(fmt.Sprint(v.Interface())), a) {
+				return "", ""
+			}
+		}
+		return codeNotAllowed, "must be one of " + strings.Join(allowed, ", ")
+	case "email":
+		if checkmail.ValidateFormat(strings.TrimSpace(v.String())) != nil {
+			return codeInvalidField, "format is invalid"
+		}
+	}
+	return "", ""
+}
+
+//checkBound checks a min or max rule. Strings are bound by their length
+//in characters, times by a date or now.
+func checkBound(rule validationRule, v reflect.Value) (code, msg string) {
+	min := rule.Name == "min"
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if rule.Now {
+			switch {
+			case min && t.Before(time.Now()):
+				return codeOutOfRange, "must not be in the past"
+			case !min && t.After(time.Now()):
+				return codeOutOfRange, "must not be in the future"
+			}
+			return "", ""
+		}
+		switch {
+		case min && t.Before(rule.Date):
+			return codeOutOfRange, "must not be before " + rule.Param
+		case !min && t.After(rule.Date):
+			return codeOutOfRange, "must not be after " + rule.Param
+		}
+		return "", ""
+	}
+
+	var x float64
+	switch v.Kind() {
+	case reflect.String:
+		x = float64(utf8.RuneCountInString(strings.TrimSpace(v.String())))
+		switch {
+		case min && x < rule.Bound:
+			return codeTooShort, fmt.Sprintf("must be at least %s characters long", rule.Param)
+		case !min && x > rule.Bound:
+			return codeTooLong, fmt.Sprintf("must not be longer than %s characters", rule.Param)
+		}
+		return "", ""
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		x = v.Float()
+	}
+	switch {
+	case min && x < rule.Bound:
+		return codeOutOfRange, "must be at least " + rule.Param
+	case !min && x > rule.Bound:
+		return codeOutOfRange, "must be at most " + rule.Param
+	}
+	return "", ""
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRulesInvalid(t *testing.T) {
+	for _, c := range []struct {
+		tag string
+		typ interface{}
+	}{
+		{"required,maybe", ""},
+		{"max=ten", ""},
+		{"min=yesterday", time.Time{}},
+		{"min=1", true},
+		{"oneof=", ""},
+	} {
+		if _, err := parseRules(c.tag, reflect.TypeOf(c.typ)); err == nil {
+			t.Errorf("parsing %q on a %T: got no error", c.tag, c.typ)
+		}
+	}
+
+	type badRequest struct {
+		Name string `json:"name" validate:"required,max=lots"`
+	}
+	if err := validate(badRequest{Name: "Fido"}); err == nil {
+		t.Error("validating a request with an invalid tag: got no error")
+	}
+}
+
+func TestOneofTagsMatchKinds(t *testing.T) {
+	for _, c := range []struct {
+		req   interface{}
+		field string
+		kinds map[string]bool
+	}{
+		{expenseRequest{}, "Category", expenseCategories},
+		{providerRequest{}, "Kind", providerKinds},
+	} {
+		f, _ := reflect.TypeOf(c.req).FieldByName(c.field)
+		rules, err := parseRules(f.Tag.Get("validate"), f.Type)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var oneof []string
+		for _, r := range rules {
+			if r.Name == "oneof" {
+				oneof = strings.Fields(r.Param)
+			}
+		}
+		if len(oneof) != len(c.kinds) {
+			t.Errorf("%T.%s: oneof lists %d values, want the %d filters accept", c.req, c.field, len(oneof), len(c.kinds))
+		}
+		for _, k := range oneof {
+			if !c.kinds[k] {
+				t.Errorf("%T.%s: %q is accepted but can't be filtered on", c.req, c.field, k)
+			}
+		}
+	}
+}
+
+func TestValidateExpenseFields(t *testing.T) {
+	req := expenseRequest{AmountMinor: -5, Currency: "usd", Category: "Toys", Vendor: strings.Repeat("x", 201)}
+	err := validateExpense(&req)
+	var e *apiError
+	if !errors.As(err, &e) {
+		t.Fatalf("got %v, want an apiError", err)
+	}
+	got := map[string]string{}
+	for _, f := range e.Fields {
+		got[f.Field] = f.Code
+	}
+	want := map[string]string{
+		"amount_minor": codeOutOfRange,
+		"category":     codeNotAllowed,
+		"vendor":       codeTooLong,
+		"date":         codeRequired,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got field errors %v, want %v", got, want)
+	}
+	if req.Currency != "USD" || req.Category != "toys" {
+		t.Errorf("got currency %q and category %q, want them normalized", req.Currency, req.Category)
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	type rulesRequest struct {
+		Name     string     `json:"name" validate:"required,min=2,max=4"`
+		Count    int        `json:"count" validate:"min=1,max=10"`
+		Ratio    float64    `json:"ratio" validate:"min=0,max=1"`
+		ParentID *uint      `json:"parent_id" validate:"min=2"`
+		Born     time.Time  `json:"born" validate:"min=1900-01-01,max=now"`
+		Due      *time.Time `json:"due" validate:"min=now"`
+		Kind     string     `json:"kind" validate:"oneof=cat dog"`
+		Email    string     `json:"email" validate:"email"`
+	}
+	one, two := uint(1), uint(2)
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	valid := rulesRequest{Name: "Rex"}
+
+	for _, c := range []struct {
+		name  string
+		edit  func(r *rulesRequest)
+		field string
+		code  string
+	}{
+		{"valid", func(r *rulesRequest) {}, "", ""},
+		{"required missing", func(r *rulesRequest) { r.Name = "" }, "name", codeRequired},
+		{"required blank", func(r *rulesRequest) { r.Name = "   " }, "name", codeRequired},
+		{"min length", func(r *rulesRequest) { r.Name = "R" }, "name", codeTooShort},
+		{"max length", func(r *rulesRequest) { r.Name = "Rexie" }, "name", codeTooLong},
+		{"max length in characters", func(r *rulesRequest) { r.Name = "Zoë" }, "", ""},
+		{"min number", func(r *rulesRequest) { r.Count = -1 }, "count", codeOutOfRange},
+		{"max number", func(r *rulesRequest) { r.Count = 11 }, "count", codeOutOfRange},
+		{"unset number", func(r *rulesRequest) { r.Count = 0 }, "", ""},
+		{"max float", func(r *rulesRequest) { r.Ratio = 1.5 }, "ratio", codeOutOfRange},
+		{"min float", func(r *rulesRequest) { r.Ratio = -0.5 }, "ratio", codeOutOfRange},
+		{"min pointer", func(r *rulesRequest) { r.ParentID = &one }, "parent_id", codeOutOfRange},
+		{"pointer in range", func(r *rulesRequest) { r.ParentID = &two }, "", ""},
+		{"min date", func(r *rulesRequest) { r.Born = time.Date(1899, 12, 31, 0, 0, 0, 0, time.UTC) }, "born", codeOutOfRange},
+		{"max now", func(r *rulesRequest) { r.Born = future }, "born", codeOutOfRange},
+		{"before now", func(r *rulesRequest) { r.Born = past }, "", ""},
+		{"min now", func(r *rulesRequest) { r.Due = &past }, "due", codeOutOfRange},
+		{"after now", func(r *rulesRequest) { r.Due = &future }, "", ""},
+		{"oneof", func(r *rulesRequest) { r.Kind = "bird" }, "kind", codeNotAllowed},
+		{"oneof ignores case", func(r *rulesRequest) { r.Kind = "Dog" }, "", ""},
+		{"email", func(r *rulesRequest) { r.Email = "rex.example.com" }, "email", codeInvalidField},
+		{"email valid", func(r *rulesRequest) { r.Email = "rex@example.com" }, "", ""},
+	} {
+		req := valid
+		c.edit(&req)
+		err := validate(req)
+		if c.field == "" {
+			if err != nil {
+				t.Errorf("%s: got %v, want no error", c.name, err)
+			}
+			continue
+		}
+		var e *apiError
+		if !errors.As(err, &e) || len(e.Fields) != 1 {
+			t.Errorf("%s: got %v, want one field error", c.name, err)
+			continue
+		}
+		if f := e.Fields[0]; f.Field != c.field || f.Code != c.code {
+			t.Errorf("%s: got %s on %s, want %s on %s", c.name, f.Code, f.Field, c.code, c.field)
+		}
+	}
+}
+
+func TestValidateCheckFields(t *testing.T) {
+	three := uint(3)
+	err := validate(petRequest{Name: "Fido", SireID: &three, DamID: &three})
+	var e *apiError
+	if !errors.As(err, &e) || len(e.Fields) != 1 || e.Fields[0].Field != "dam_id" {
+		t.Errorf("got %v, want an error on dam_id", err)
+	}
+
+	start := time.Date(2019, 11, 20, 9, 30, 0, 0, time.UTC)
+	err = validate(appointmentRequest{Title: "Checkup", StartsAt: start, EndsAt: &start})
+	if !errors.As(err, &e) || len(e.Fields) != 1 || e.Fields[0].Field != "ends_at" {
+		t.Errorf("got %v, want an error on ends_at", err)
+	}
+
+	// Field rules and checkFields are reported together
+	err = validate(petRequest{SireID: &three, DamID: &three})
+	if !errors.As(err, &e) || len(e.Fields) != 2 {
+		t.Errorf("got %v, want errors on name and dam_id", err)
+	}
+	if err := validate(petRequest{Name: "Fido"}); err != nil {
+		t.Errorf("a pet without a type: got %v, want no error", err)
+	}
+}
+
+func TestValidateRecordDoses(t *testing.T) {
+	day := time.Date(2019, 11, 9, 0, 0, 0, 0, time.UTC)
+	two, ten := 2, 10
+	for _, c := range []struct {
+		name  string
+		req   medicalRecordRequest
+		field string
+	}{
+		{"a single dose", medicalRecordRequest{Kind: "medication"}, ""},
+		{"a daily course", medicalRecordRequest{Kind: "medication", DoseFrequency: "daily", DosesPerDay: &two, CourseDays: &ten}, ""},
+		{"an ongoing monthly dose", medicalRecordRequest{Kind: "medication", DoseFrequency: "monthly"}, ""},
+		{"an unknown frequency", medicalRecordRequest{Kind: "medication", DoseFrequency: "hourly"}, "dose_frequency"},
+		{"doses of a weekly medication", medicalRecordRequest{Kind: "medication", DoseFrequency: "weekly", DosesPerDay: &two}, "doses_per_day"},
+		{"a course without a frequency", medicalRecordRequest{Kind: "medication", CourseDays: &ten}, "course_days"},
+		{"a schedule of a vaccination", medicalRecordRequest{Kind: "vaccination", DoseFrequency: "daily"}, "dose_frequency"},
+	} {
+		c.req.Title, c.req.OccurredOn = "Amoxicillin", day
+		err := validate(c.req)
+		var e *apiError
+		switch {
+		case c.field == "" && err != nil:
+			t.Errorf("%s: got %v, want no error", c.name, err)
+		case c.field != "" && (!errors.As(err, &e) || len(e.Fields) != 1 || e.Fields[0].Field != c.field):
+			t.Errorf("%s: got %v, want an error on %s", c.name, err, c.field)
+		}
+	}
+}
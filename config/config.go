@@ -1,27 +1,52 @@
 package config
 
-import "github.com/namsral/flag"
+import (
+	"time"
+
+	"github.com/namsral/flag"
+)
 
 //Config is a struct for configuring the API server
 type Config struct {
-	APIPort       string
-	DBHost        string
-	DBPort        string
-	DBUser        string
-	DBInsecure    bool
-	CertPath      string
-	DBName        string
-	JWTSigningKey string
-	LogLevel      string
-	StatsdHost    string
-	StatsdPort    string
-	ServerHost    string
+	APIPort        string
+	DBDriver       string
+	DBPath         string
+	AutoMigrate    bool
+	DBHost         string
+	DBPort         string
+	DBUser         string
+	DBInsecure     bool
+	CertPath       string
+	DBName         string
+	JWTSigningKey  string
+	LogLevel       string
+	StatsdHost     string
+	StatsdPort     string
+	ServerHost     string
+	TrustedProxies string
+	BlobStore      string
+	BlobPath       string
+	S3Endpoint     string
+	S3Region       string
+	S3Bucket       string
+	S3AccessKey    string
+	S3SecretKey    string
+	MaxUploadBytes int64
+	TrashRetention time.Duration
+	SMTPHost       string
+	SMTPPort       string
+	SMTPUser       string
+	SMTPPassword   string
+	SMTPFrom       string
 }
 
 //Generate returns a new config from ENV, file, or flags
 func Generate() Config {
 	cfg := Config{}
 	flag.StringVar(&cfg.APIPort, "api-port", "8080", "port of the petkeep API server")
+	flag.StringVar(&cfg.DBDriver, "api-database-driver", "cockroach", "database backend. cockroach, or sqlite for an embedded database file")
+	flag.StringVar(&cfg.DBPath, "api-database-path", "petkeep.db", "path of the database file when the sqlite driver is used")
+	flag.BoolVar(&cfg.AutoMigrate, "api-auto-migrate", false, "apply pending schema migrations on startup, otherwise run petkeep-server migrate up")
 	flag.StringVar(&cfg.DBHost, "api-database-host", "", "hostname or IP address of the cockroachdb server")
 	flag.StringVar(&cfg.DBPort, "api-database-port", "3306", "port of the cockroachdb server")
 	flag.StringVar(&cfg.DBUser, "api-database-user", "", "username for accessing the MYSQL database server")
@@ -33,6 +58,21 @@ func Generate() Config {
 	flag.StringVar(&cfg.StatsdHost, "api-statsd-host", "", "hostname or IP address for statsd server")
 	flag.StringVar(&cfg.StatsdPort, "api-statsd-port", "8125", "port for statsd server")
 	flag.StringVar(&cfg.ServerHost, "server-host", "localhost", "hostname to access the server")
+	flag.StringVar(&cfg.TrustedProxies, "api-trusted-proxies", "", "comma separated IPs or CIDR ranges of proxies whose X-Real-Ip header gives the client address")
+	flag.StringVar(&cfg.BlobStore, "api-blob-store", "local", "where uploaded files are stored. local or s3")
+	flag.StringVar(&cfg.BlobPath, "api-blob-path", "blobs/", "directory where uploaded files are stored by the local blob store")
+	flag.StringVar(&cfg.S3Endpoint, "api-s3-endpoint", "", "URL of the S3 compatible endpoint, e.g. https://s3.us-east-1.amazonaws.com")
+	flag.StringVar(&cfg.S3Region, "api-s3-region", "us-east-1", "region of the S3 bucket")
+	flag.StringVar(&cfg.S3Bucket, "api-s3-bucket", "", "name of the S3 bucket for uploaded files")
+	flag.StringVar(&cfg.S3AccessKey, "api-s3-access-key", "", "access key for the S3 bucket")
+	flag.StringVar(&cfg.S3SecretKey, "api-s3-secret-key", "", "secret key for the S3 bucket (KEEP SECRET!)")
+	flag.Int64Var(&cfg.MaxUploadBytes, "api-upload-max-bytes", 10485760, "maximum size of an uploaded file in bytes")
+	flag.DurationVar(&cfg.TrashRetention, "api-trash-retention", 720*time.Hour, "how long deleted pets stay in the trash before they are purged, 0 keeps them forever")
+	flag.StringVar(&cfg.SMTPHost, "api-smtp-host", "", "hostname of the SMTP server for outgoing email, leave empty to disable email")
+	flag.StringVar(&cfg.SMTPPort, "api-smtp-port", "587", "port of the SMTP server")
+	flag.StringVar(&cfg.SMTPUser, "api-smtp-user", "", "username for the SMTP server")
+	flag.StringVar(&cfg.SMTPPassword, "api-smtp-password", "", "password for the SMTP server (KEEP SECRET!)")
+	flag.StringVar(&cfg.SMTPFrom, "api-smtp-from", "Petkeep <no-reply@petkeep.com>", "sender address of outgoing email")
 	flag.Parse()
 	return cfg
 }
@@ -24,71 +24,131 @@ var doc = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/login": {
-            "post": {
-                "description": "Login a user",
-                "consumes": [
-                    "application/json"
+        "/access-requests": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Get the requests organizations have made to access the user's pets, newest first",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Login a user",
+                "tags": [
+                    "Consent"
+                ],
+                "summary": "Get access requests for my pets",
                 "parameters": [
                     {
-                        "description": "Login User",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.userRequest"
-                        }
+                        "type": "string",
+                        "description": "Only requests with this status: pending, granted, denied, revoked or cancelled",
+                        "name": "status",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.token"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.petAccess"
+                            }
                         }
                     }
                 }
             }
         },
-        "/pets": {
+        "/admin/audit": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get all pets",
+                "description": "Get the changes made to any account or pet, newest first. Only admins may do this. Pass next_cursor back as cursor to get the next page.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Pets"
+                    "Admin"
+                ],
+                "summary": "Get the audit log of all users",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Only changes to this user's account and pets",
+                        "name": "owner_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only changes made by this user",
+                        "name": "actor_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "pet or user",
+                        "name": "entity_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only changes to this entity",
+                        "name": "entity_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "create, update, delete, restore or purge",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest date, YYYY-MM-DD",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date before which to stop, YYYY-MM-DD",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Entries per page, 1 to 200, defaults to 50",
+                        "name": "limit",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get all pets",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/api.pet"
-                            }
+                            "$ref": "#/definitions/api.auditPage"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/admin/catalog/species": {
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Create a pet",
+                "description": "Add a species to the catalog. Only admins may do this.",
                 "consumes": [
                     "application/json"
                 ],
@@ -96,50 +156,47 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Pets"
+                    "Admin"
                 ],
-                "summary": "Create a pet",
+                "summary": "Add a species",
                 "parameters": [
                     {
-                        "description": "Create Pet",
-                        "name": "pet",
+                        "description": "Species",
+                        "name": "species",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.petRequest"
+                            "$ref": "#/definitions/api.catalogEntryRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/api.pet"
+                            "$ref": "#/definitions/api.catalogSpecies"
                         }
                     }
                 }
             }
         },
-        "/pets/{PetID}": {
-            "get": {
+        "/admin/catalog/species/{Species}": {
+            "delete": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get one pet",
-                "produces": [
-                    "application/json"
-                ],
+                "description": "Remove a species an admin added, along with its breeds. Built-in species can't be removed. Pets keep their type but must pick a new one when next updated.",
                 "tags": [
-                    "Pets"
+                    "Admin"
                 ],
-                "summary": "Get one pet",
+                "summary": "Remove a species",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Get Pet",
-                        "name": "PetID",
+                        "type": "string",
+                        "description": "Species slug",
+                        "name": "Species",
                         "in": "path",
                         "required": true
                     }
@@ -148,18 +205,20 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.pet"
+                            "$ref": "#/definitions/api.emptyBody"
                         }
                     }
                 }
-            },
-            "put": {
+            }
+        },
+        "/admin/catalog/species/{Species}/breeds": {
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Update a pet",
+                "description": "Add a breed to a species in the catalog. Only admins may do this.",
                 "consumes": [
                     "application/json"
                 ],
@@ -167,54 +226,106 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Pets"
+                    "Admin"
                 ],
-                "summary": "Update a pet",
+                "summary": "Add a breed",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Pet ID",
-                        "name": "PetID",
+                        "type": "string",
+                        "description": "Species slug, name or alias",
+                        "name": "Species",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Updated Pet",
-                        "name": "pet",
+                        "description": "Breed",
+                        "name": "breed",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.pet"
+                            "$ref": "#/definitions/api.catalogEntryRequest"
                         }
                     }
                 ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.catalogBreed"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/catalog/species/{Species}/breeds/{BreedID}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Remove a breed an admin added. Built-in breeds can't be removed.",
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Remove a breed",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Species slug",
+                        "name": "Species",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Breed ID",
+                        "name": "BreedID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.pet"
+                            "$ref": "#/definitions/api.emptyBody"
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/admin/users/{UserID}/role": {
+            "put": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Delete a pet",
+                "description": "Change the role of a user. Only admins may do this.",
+                "consumes": [
+                    "application/json"
+                ],
                 "tags": [
-                    "Pets"
+                    "Admin"
                 ],
-                "summary": "Delete a pet",
+                "summary": "Change a user's role",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Deleted Pet",
-                        "name": "PetID",
+                        "description": "User ID",
+                        "name": "UserID",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Role (owner, shelter, vet or admin)",
+                        "name": "role",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.roleRequest"
+                        }
                     }
                 ],
                 "responses": {
@@ -227,109 +338,7067 @@ var doc = `{
                 }
             }
         },
-        "/users": {
+        "/audit": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get a user",
+                "description": "Get the changes made to the user's own account and pets, by anyone, newest first. Pass next_cursor back as cursor to get the next page.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Users"
+                    "Audit"
+                ],
+                "summary": "Get the audit log",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Only changes made by this user",
+                        "name": "actor_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "pet or user",
+                        "name": "entity_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only changes to this entity",
+                        "name": "entity_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "create, update, delete, restore or purge",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest date, YYYY-MM-DD",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date before which to stop, YYYY-MM-DD",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Entries per page, 1 to 200, defaults to 50",
+                        "name": "limit",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get a user",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.userResponse"
+                            "$ref": "#/definitions/api.auditPage"
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Create a user",
-                "consumes": [
-                    "application/json"
+            }
+        },
+        "/breeding/coi": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Compute the coefficient of inbreeding (Wright) the offspring of a sire and a dam would have, from their pedigrees going back a number of generations. Ancestors past that are treated as unrelated.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Users"
+                    "Breeding"
                 ],
-                "summary": "Create a user",
+                "summary": "Get the coefficient of inbreeding of a pairing",
                 "parameters": [
                     {
-                        "description": "Create User",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
+                        "type": "integer",
+                        "description": "Sire pet ID",
+                        "name": "sire_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Dam pet ID",
+                        "name": "dam_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Generations, 1 to 10, defaults to 5",
+                        "name": "generations",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.userRequest"
+                            "$ref": "#/definitions/api.coiResult"
                         }
                     }
+                }
+            }
+        },
+        "/calendar.ics": {
+            "get": {
+                "description": "Get an iCalendar feed of a user's pet events, authenticated by a calendar feed token",
+                "produces": [
+                    "text/calendar"
+                ],
+                "tags": [
+                    "Calendar"
+                ],
+                "summary": "Get the calendar feed",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Calendar feed token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.userResponse"
+                            "type": "string"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "api.emptyBody": {
-            "type": "object"
         },
-        "api.pet": {
-            "type": "object",
-            "properties": {
-                "birthday": {
-                    "type": "string",
-                    "example": "2019-11-09T21:21:46+00:00"
-                },
-                "breed": {
-                    "type": "string",
-                    "example": "Lab/Terrier Mix"
-                },
-                "created_at": {
-                    "type": "string",
-                    "example": "2019-11-09T21:21:46+00:00"
-                },
-                "gender": {
-                    "type": "string",
-                    "example": "Female"
-                },
-                "name": {
-                    "type": "string",
-                    "example": "Fido"
-                },
-                "pet_id": {
-                    "type": "integer"
-                },
-                "type": {
-                    "type": "string",
-                    "example": "Dog"
-                },
-                "updated_at": {
+        "/catalog/genders": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "List the genders a pet may have, with the aliases accepted for each",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Catalog"
+                ],
+                "summary": "List genders",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.catalogGender"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/catalog/species": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "List the species pets may be, best matches first when searching by name or alias",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Catalog"
+                ],
+                "summary": "List species",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Name or alias prefix",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of results, 1 to 100, defaults to 10 when searching",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.catalogSpecies"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/catalog/species/{Species}/breeds": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "List the breeds of a species, best matches first when searching by name or alias. Mixes are written as breeds separated by a slash, e.g. \"Labrador Retriever/Terrier Mix\", or \"Mixed Breed\" when unknown.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Catalog"
+                ],
+                "summary": "Autocomplete breeds",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Species slug, name or alias",
+                        "name": "Species",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name or alias prefix",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of results, 1 to 100, defaults to 10",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.catalogBreed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/expenses/report": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Sum a user's expenses grouped by month, category and/or pet, and always by currency. Optionally as CSV.",
+                "produces": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "tags": [
+                    "Expenses"
+                ],
+                "summary": "Get an expense report",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma separated dimensions: month, category, pet (default month)",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest date, YYYY-MM-DD",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date before which to stop, YYYY-MM-DD",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Category",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "pet_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Set to csv for CSV output",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.expenseReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/litters": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all litters of the user, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Breeding"
+                ],
+                "summary": "Get all litters",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.litter"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Record a litter with its sire, dam and whelping date. Add puppies to it afterwards.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Breeding"
+                ],
+                "summary": "Create a litter",
+                "parameters": [
+                    {
+                        "description": "Create Litter",
+                        "name": "litter",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.litterRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.litter"
+                        }
+                    }
+                }
+            }
+        },
+        "/litters/{LitterID}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get one litter of the user with its puppies",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Breeding"
+                ],
+                "summary": "Get one litter",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Litter ID",
+                        "name": "LitterID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.litter"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update a litter of the user. Puppies already added keep their parents and birthday.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Breeding"
+                ],
+                "summary": "Update a litter",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Litter ID",
+                        "name": "LitterID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated Litter",
+                        "name": "litter",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.litterRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.litter"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete a litter of the user. Its puppies are kept.",
+                "tags": [
+                    "Breeding"
+                ],
+                "summary": "Delete a litter",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Litter ID",
+                        "name": "LitterID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/litters/{LitterID}/puppies": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a pet born in a litter. Its sire, dam, birthday and litter come from the litter, its type from the dam and its breed from the parents when they share one.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Breeding"
+                ],
+                "summary": "Add a puppy to a litter",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Litter ID",
+                        "name": "LitterID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Create Puppy",
+                        "name": "pet",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.petRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.pet"
+                        }
+                    }
+                }
+            }
+        },
+        "/login": {
+            "post": {
+                "description": "Login a user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Login a user",
+                "parameters": [
+                    {
+                        "description": "Login User",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.userRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.token"
+                        }
+                    }
+                }
+            }
+        },
+        "/lost/{Slug}": {
+            "get": {
+                "description": "Get the public profile of a lost pet. No authentication is needed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Lost Pets"
+                ],
+                "summary": "Get a lost pet profile",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Lost pet slug",
+                        "name": "Slug",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.lostPetProfile"
+                        }
+                    }
+                }
+            }
+        },
+        "/lost/{Slug}/contact": {
+            "post": {
+                "description": "Send a message to the owner of a lost pet without seeing their contact details. No authentication is needed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Lost Pets"
+                ],
+                "summary": "Contact the owner of a lost pet",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Lost pet slug",
+                        "name": "Slug",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Message",
+                        "name": "message",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.lostMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/lost/{Slug}/photo": {
+            "get": {
+                "description": "Get the photo of a lost pet. No authentication is needed.",
+                "produces": [
+                    "image/jpeg",
+                    "image/png",
+                    "image/gif",
+                    "image/webp"
+                ],
+                "tags": [
+                    "Lost Pets"
+                ],
+                "summary": "Get a lost pet photo",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Lost pet slug",
+                        "name": "Slug",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    }
+                }
+            }
+        },
+        "/organizations": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the organizations the user is a member of, with their role in each",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Organizations"
+                ],
+                "summary": "Get my organizations",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.organization"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Sign a clinic up as an organization. The user creating it becomes its first admin.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Organizations"
+                ],
+                "summary": "Create an organization",
+                "parameters": [
+                    {
+                        "description": "Create Organization",
+                        "name": "organization",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.organizationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.organization"
+                        }
+                    }
+                }
+            }
+        },
+        "/organizations/{OrgID}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get an organization the user is a member of",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Organizations"
+                ],
+                "summary": "Get an organization",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "OrgID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.organization"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update an organization. Only its admins may do this.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Organizations"
+                ],
+                "summary": "Update an organization",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "OrgID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated Organization",
+                        "name": "organization",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.organizationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.organization"
+                        }
+                    }
+                }
+            }
+        },
+        "/organizations/{OrgID}/access-events": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get every access request, grant, denial, revocation and cancellation involving an organization, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Consent"
+                ],
+                "summary": "Get the consent audit trail of an organization",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "OrgID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.accessEvent"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/organizations/{OrgID}/access-requests": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the requests an organization has made to access pets, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Consent"
+                ],
+                "summary": "Get the access requests of an organization",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "OrgID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only requests with this status: pending, granted, denied, revoked or cancelled",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.petAccess"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Ask the owner of a pet for access to it. The owner is identified by the email they signed up with and has to grant the request before the organization can see the pet.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Consent"
+                ],
+                "summary": "Request access to a client's pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "OrgID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Access Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.petAccessRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.petAccess"
+                        }
+                    }
+                }
+            }
+        },
+        "/organizations/{OrgID}/access-requests/{AccessID}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Withdraw a pending access request, or give up access an owner granted",
+                "tags": [
+                    "Consent"
+                ],
+                "summary": "Cancel an access request",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "OrgID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Access request ID",
+                        "name": "AccessID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/organizations/{OrgID}/members": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the staff of an organization with their roles",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Organizations"
+                ],
+                "summary": "Get the members of an organization",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "OrgID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.orgMember"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Add a user to an organization by the email they signed up with, or change the role of a member. Only admins may do this.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Organizations"
+                ],
+                "summary": "Add or change a member of an organization",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "OrgID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Member (role is admin, vet or staff)",
+                        "name": "member",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.orgMemberRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.orgMember"
+                        }
+                    }
+                }
+            }
+        },
+        "/organizations/{OrgID}/members/{UserID}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Remove a user from an organization. Admins may remove anyone, other members only themselves. The last admin can't leave.",
+                "tags": [
+                    "Organizations"
+                ],
+                "summary": "Remove a member from an organization",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "OrgID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "UserID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/organizations/{OrgID}/pets": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the pets whose owners have granted the organization access",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Consent"
+                ],
+                "summary": "Get the pets an organization may access",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "OrgID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.pet"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/organizations/{OrgID}/pets/{PetID}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a pet whose owner has granted the organization access",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Consent"
+                ],
+                "summary": "Get a pet an organization may access",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "OrgID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.pet"
+                        }
+                    }
+                }
+            }
+        },
+        "/organizations/{OrgID}/pets/{PetID}/records": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all medical records of a pet whose owner has granted the organization access, including those the owner added",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Consent"
+                ],
+                "summary": "Get the medical records of a client's pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "OrgID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.medicalRecord"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Add a medical record to a pet whose owner has granted the organization access. It shows up in the owner's account. Only admins and vets of the organization may do this.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Consent"
+                ],
+                "summary": "Add a medical record to a client's pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "OrgID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Create Medical Record",
+                        "name": "record",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.medicalRecordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.medicalRecord"
+                        }
+                    }
+                }
+            }
+        },
+        "/organizations/{OrgID}/pets/{PetID}/records/{RecordID}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update a medical record the organization added to a pet it still has access to. Only admins and vets of the organization may do this.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Consent"
+                ],
+                "summary": "Update a medical record of a client's pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "OrgID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Record ID",
+                        "name": "RecordID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated Medical Record",
+                        "name": "record",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.medicalRecordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.medicalRecord"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a page of the pets, oldest first unless sorted otherwise. The Link header points to the next page when there is one. Send Accept: text/csv for the page as CSV.",
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "application/msgpack",
+                    "application/cbor"
+                ],
+                "tags": [
+                    "Pets"
+                ],
+                "summary": "Get all pets",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only pets of this type, ignoring case",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only pets of this breed, ignoring case",
+                        "name": "breed",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only pets of this gender, ignoring case",
+                        "name": "gender",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only pets born on or after this day (YYYY-MM-DD)",
+                        "name": "birthday_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only pets born before this day (YYYY-MM-DD)",
+                        "name": "birthday_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated fields to sort by, prefixed with - for descending: name, type, breed, gender, birthday, created_at",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Pets per page, 100 by default and at most 500",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor of the page, from the Link header of the previous one",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return the total number of matching pets in X-Total-Count",
+                        "name": "count",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the list the client has",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.pet"
+                            }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Changes whenever a pet on the page is added, removed or changed"
+                            },
+                            "Link": {
+                                "type": "string",
+                                "description": "URL of the next page as rel=\\\"next\\"
+                            },
+                            "X-Total-Count": {
+                                "type": "int",
+                                "description": "Number of matching pets on all pages, when asked for"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a pet",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pets"
+                ],
+                "summary": "Create a pet",
+                "parameters": [
+                    {
+                        "description": "Create Pet",
+                        "name": "pet",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.petRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.pet"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/export": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Download all pets as a CSV file, a JSON array or newline delimited JSON. The export is streamed as it is read from the database.",
+                "produces": [
+                    "text/csv",
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "Pets"
+                ],
+                "summary": "Export pets",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "csv, json (the default) or ndjson",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.pet"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/import": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create pets from a CSV file, whose first line names the columns, or a JSON array of objects. Columns are named after the fields of a pet, other names can be mapped to them with map, for instance map=Pet Name:name or map=Notes:- to skip a column. The columns of an export are accepted as they are. All rows are checked first and, when any has a problem, nothing is imported and a validation_failed problem with status 422 lists the problems, each with its row. With dry_run=true the rows are only checked.",
+                "consumes": [
+                    "text/csv",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pets"
+                ],
+                "summary": "Import pets",
+                "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Source column and the pet field it fills, separated by a colon",
+                        "name": "map",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only check the rows",
+                        "name": "dry_run",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.importResult"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.importResult"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/trash": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the deleted pets of the user that can still be restored, most recently deleted first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pets"
+                ],
+                "summary": "Get the trash",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.pet"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get one pet",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pets"
+                ],
+                "summary": "Get one pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Get Pet",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version the client has",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.pet"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the pet"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update a pet. Pass the ETag of the pet as If-Match to only update it if nobody changed it since.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pets"
+                ],
+                "summary": "Update a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version the update is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Updated Pet",
+                        "name": "pet",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.pet"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.pet"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "New version of the pet"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Move a pet and its records to the trash, they are purged for good once the retention period is over. Pass the ETag of the pet as If-Match to only delete it if nobody changed it since.",
+                "tags": [
+                    "Pets"
+                ],
+                "summary": "Delete a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Deleted Pet",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version the client means to delete",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Change some fields of a pet with a JSON Merge Patch (application/merge-patch+json) or a JSON Patch (application/json-patch+json). pet_id, user_id, created_at, updated_at and version can't be changed. Pass the ETag of the pet as If-Match to only patch it if nobody changed it since.",
+                "consumes": [
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pets"
+                ],
+                "summary": "Patch a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version the patch is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Merge patch or JSON Patch operations",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.pet"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "New version of the pet"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/access": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the requests organizations have made to access a pet, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Consent"
+                ],
+                "summary": "Get access requests for a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.petAccess"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/access/events": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get every access request, grant, denial, revocation and cancellation for a pet, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Consent"
+                ],
+                "summary": "Get the consent audit trail of a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.accessEvent"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/access/{AccessID}/status": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Decide on an organization's request to access a pet. Pending requests can be granted or denied, granted ones revoked. Every decision is kept in the consent audit trail.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Consent"
+                ],
+                "summary": "Grant, deny or revoke access to a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Access request ID",
+                        "name": "AccessID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New status (granted, denied or revoked)",
+                        "name": "status",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.petAccessStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.petAccess"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/activities": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the walks, runs, play and training sessions of a pet, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Activities"
+                ],
+                "summary": "Get all activities of a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest date, YYYY-MM-DD",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date before which to stop, YYYY-MM-DD",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.activity"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Log a walk, run, play or training session of a pet without a route",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Activities"
+                ],
+                "summary": "Log an activity",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Create Activity",
+                        "name": "activity",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.activityRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.activity"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/activities/goal": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the weekly activity goal of a pet. Targets of zero are not set.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Activities"
+                ],
+                "summary": "Get the weekly activity goal of a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.activityGoal"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Set how many minutes, meters and sessions of activity a pet should get each week. Set a target to zero to drop it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Activities"
+                ],
+                "summary": "Set the weekly activity goal of a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Weekly Goal",
+                        "name": "goal",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.activityGoal"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.activityGoal"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/activities/import": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Import a route recorded by a phone or tracker as a GPX or GeoJSON file. Distance, elevation gain and loss, duration and pace are computed from the route. Routes without timestamps need started_at and duration_seconds form fields.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Activities"
+                ],
+                "summary": "Import an activity from a GPX or GeoJSON file",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "GPX or GeoJSON file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "walk, run, play or training, defaults to walk",
+                        "name": "kind",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Notes",
+                        "name": "notes",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start time, RFC 3339, when the route has no timestamps",
+                        "name": "started_at",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Duration, when the route has no timestamps",
+                        "name": "duration_seconds",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.activity"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/activities/summary": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the sessions, minutes and distance of a pet per week, Monday to Sunday in UTC, newest week first, measured against its weekly goal",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Activities"
+                ],
+                "summary": "Get weekly activity summaries of a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of weeks including the current one, 1 to 52, defaults to 4",
+                        "name": "weeks",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.activitySummary"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/activities/{ActivityID}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get one activity of a pet",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Activities"
+                ],
+                "summary": "Get one activity",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Activity ID",
+                        "name": "ActivityID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.activity"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update an activity of a pet. The route of an imported activity is kept as is.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Activities"
+                ],
+                "summary": "Update an activity",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Activity ID",
+                        "name": "ActivityID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated Activity",
+                        "name": "activity",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.activityRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.activity"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete an activity of a pet along with its route",
+                "tags": [
+                    "Activities"
+                ],
+                "summary": "Delete an activity",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Activity ID",
+                        "name": "ActivityID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/activities/{ActivityID}/route": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the route of an imported activity as a GeoJSON LineString feature",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Activities"
+                ],
+                "summary": "Get the route of an activity",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Activity ID",
+                        "name": "ActivityID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/appointments": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the appointments of a pet, soonest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Appointments"
+                ],
+                "summary": "Get all appointments of a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest date, YYYY-MM-DD",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date before which to stop, YYYY-MM-DD",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.appointment"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Book an appointment for a pet, optionally with a provider from the user's directory",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Appointments"
+                ],
+                "summary": "Create an appointment",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Create Appointment",
+                        "name": "appointment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.appointmentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.appointment"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/appointments/{AppointmentID}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get one appointment of a pet",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Appointments"
+                ],
+                "summary": "Get one appointment",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Appointment ID",
+                        "name": "AppointmentID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.appointment"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update an appointment of a pet",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Appointments"
+                ],
+                "summary": "Update an appointment",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Appointment ID",
+                        "name": "AppointmentID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated Appointment",
+                        "name": "appointment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.appointmentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.appointment"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete an appointment of a pet",
+                "tags": [
+                    "Appointments"
+                ],
+                "summary": "Delete an appointment",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Appointment ID",
+                        "name": "AppointmentID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/attachments": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the metadata of all documents attached to a pet, including those of its medical records and claims",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Attachments"
+                ],
+                "summary": "Get all attachments of a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.attachment"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Upload a document such as a vet invoice or lab result as multipart/form-data",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Attachments"
+                ],
+                "summary": "Attach a document to a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "Document (PDF, plain text or image)",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Description",
+                        "name": "description",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.attachment"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/attachments/{AttachmentID}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Download a document attached to a pet, one of its medical records or claims",
+                "produces": [
+                    "application/pdf",
+                    "text/plain",
+                    "image/jpeg",
+                    "image/png",
+                    "image/gif",
+                    "image/webp"
+                ],
+                "tags": [
+                    "Attachments"
+                ],
+                "summary": "Download an attachment",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Attachment ID",
+                        "name": "AttachmentID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete a document attached to a pet, one of its medical records or claims",
+                "tags": [
+                    "Attachments"
+                ],
+                "summary": "Delete an attachment",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Attachment ID",
+                        "name": "AttachmentID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/attachments/{AttachmentID}/thumbnail": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Download the thumbnail of an image attached to a pet",
+                "produces": [
+                    "image/jpeg"
+                ],
+                "tags": [
+                    "Attachments"
+                ],
+                "summary": "Get an attachment thumbnail",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Attachment ID",
+                        "name": "AttachmentID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/care-team": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get everything a sitter needs in one call: the providers looking after a pet, the user's emergency contacts and the next appointments",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Providers"
+                ],
+                "summary": "Get the care team of a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.careTeam"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/expenses": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all expenses of a pet, newest first, optionally as CSV",
+                "produces": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "tags": [
+                    "Expenses"
+                ],
+                "summary": "Get all expenses of a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest date, YYYY-MM-DD",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date before which to stop, YYYY-MM-DD",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Category",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Set to csv for CSV output",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.expense"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Record an expense for a pet. Amounts are integer minor units of the currency, e.g. cents.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Expenses"
+                ],
+                "summary": "Create an expense",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Create Expense",
+                        "name": "expense",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.expenseRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.expense"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/expenses/{ExpenseID}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get one expense of a pet",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Expenses"
+                ],
+                "summary": "Get one expense",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Expense ID",
+                        "name": "ExpenseID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.expense"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update an expense of a pet",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Expenses"
+                ],
+                "summary": "Update an expense",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Expense ID",
+                        "name": "ExpenseID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated Expense",
+                        "name": "expense",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.expenseRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.expense"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete an expense of a pet",
+                "tags": [
+                    "Expenses"
+                ],
+                "summary": "Delete an expense",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Expense ID",
+                        "name": "ExpenseID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/insurance/claims": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all insurance claims of a pet, newest incident first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Insurance"
+                ],
+                "summary": "Get all insurance claims of a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.insuranceClaim"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Draft an insurance claim against a policy of the pet, linked to its expenses and medical records",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Insurance"
+                ],
+                "summary": "Create an insurance claim",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Create Claim",
+                        "name": "claim",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.claimRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.insuranceClaim"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/insurance/claims/{ClaimID}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get one insurance claim of a pet",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Insurance"
+                ],
+                "summary": "Get one insurance claim",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Claim ID",
+                        "name": "ClaimID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.insuranceClaim"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update an insurance claim of a pet. Only draft claims can be edited.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Insurance"
+                ],
+                "summary": "Update a draft insurance claim",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Claim ID",
+                        "name": "ClaimID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated Claim",
+                        "name": "claim",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.claimRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.insuranceClaim"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete an insurance claim of a pet. Only draft claims can be deleted.",
+                "tags": [
+                    "Insurance"
+                ],
+                "summary": "Delete a draft insurance claim",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Claim ID",
+                        "name": "ClaimID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/insurance/claims/{ClaimID}/attachments": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the metadata of the documents attached to an insurance claim",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Attachments"
+                ],
+                "summary": "Get all attachments of an insurance claim",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Claim ID",
+                        "name": "ClaimID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.attachment"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Upload a document such as a claim form or an explanation of benefits for an insurance claim as multipart/form-data. It is downloaded and deleted like the other attachments of the pet.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Attachments"
+                ],
+                "summary": "Attach a document to an insurance claim",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Claim ID",
+                        "name": "ClaimID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "Document (PDF, plain text or image)",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Description",
+                        "name": "description",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.attachment"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/insurance/claims/{ClaimID}/status": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Change the status of a claim. Claims go from draft to submitted, then approved or denied, and approved claims to paid. Denied claims may be resubmitted. approved_minor is required to approve and paid_minor to mark paid.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Insurance"
+                ],
+                "summary": "Move an insurance claim along its workflow",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Claim ID",
+                        "name": "ClaimID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New Status",
+                        "name": "status",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.claimStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.insuranceClaim"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/insurance/policies": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all insurance policies of a pet, newest coverage first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Insurance"
+                ],
+                "summary": "Get all insurance policies of a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.insurancePolicy"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Add an insurance policy to a pet. Amounts are in integer minor units of the currency.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Insurance"
+                ],
+                "summary": "Create an insurance policy",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Create Policy",
+                        "name": "policy",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.policyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.insurancePolicy"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/insurance/policies/{PolicyID}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get one insurance policy of a pet",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Insurance"
+                ],
+                "summary": "Get one insurance policy",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Policy ID",
+                        "name": "PolicyID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.insurancePolicy"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update an insurance policy of a pet",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Insurance"
+                ],
+                "summary": "Update an insurance policy",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Policy ID",
+                        "name": "PolicyID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated Policy",
+                        "name": "policy",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.policyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.insurancePolicy"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete an insurance policy of a pet along with all of its claims",
+                "tags": [
+                    "Insurance"
+                ],
+                "summary": "Delete an insurance policy",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Policy ID",
+                        "name": "PolicyID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/insurance/policies/{PolicyID}/summary": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get how much of the deductible and annual limit are used and remaining in a policy year. Approved claims count with their expected reimbursement, paid claims with the amount paid, and submitted claims are shown as pending.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Insurance"
+                ],
+                "summary": "Get the deductible and limit left on a policy",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Policy ID",
+                        "name": "PolicyID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "A date in the policy year, YYYY-MM-DD, defaults to today",
+                        "name": "date",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.policyYearSummary"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/lost": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the active lost report of a pet",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Lost Pets"
+                ],
+                "summary": "Get a lost report",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.lostReport"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Mark a pet as lost, publishing its profile at an unguessable URL, or update an active report",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Lost Pets"
+                ],
+                "summary": "Mark a pet as lost",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Lost Report",
+                        "name": "report",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.lostReportRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.lostReport"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Mark a lost pet as found, making its public profile private again",
+                "tags": [
+                    "Lost Pets"
+                ],
+                "summary": "Mark a pet as found",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/lost/messages": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the messages finders sent through a pet's lost pet page",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Lost Pets"
+                ],
+                "summary": "Get lost pet messages",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.lostMessage"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/notes": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all journal notes of a pet, newest first, with their markdown rendered to HTML",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Journal"
+                ],
+                "summary": "Get all journal notes of a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.journalNote"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Write a free-text note about a pet. The body is markdown, raw HTML in it is not rendered.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Journal"
+                ],
+                "summary": "Create a journal note",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Create Note",
+                        "name": "note",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.noteRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.journalNote"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/notes/{NoteID}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get one journal note of a pet, with its markdown rendered to HTML",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Journal"
+                ],
+                "summary": "Get one journal note",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Note ID",
+                        "name": "NoteID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.journalNote"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update a journal note of a pet",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Journal"
+                ],
+                "summary": "Update a journal note",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Note ID",
+                        "name": "NoteID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated Note",
+                        "name": "note",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.noteRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.journalNote"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete a journal note of a pet",
+                "tags": [
+                    "Journal"
+                ],
+                "summary": "Delete a journal note",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Note ID",
+                        "name": "NoteID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/pedigree": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the ancestors of a pet as a tree of sires and dams, going back a number of generations",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Breeding"
+                ],
+                "summary": "Get the pedigree of a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Generations, 1 to 10, defaults to 4",
+                        "name": "generations",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.pedigreeNode"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/photo": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Download a pet's profile photo",
+                "produces": [
+                    "image/jpeg",
+                    "image/png",
+                    "image/gif",
+                    "image/webp"
+                ],
+                "tags": [
+                    "Attachments"
+                ],
+                "summary": "Get a pet photo",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Upload a pet's profile photo as multipart/form-data, replacing any existing one",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Attachments"
+                ],
+                "summary": "Upload a pet photo",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "Photo (JPEG, PNG or GIF)",
+                        "name": "photo",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.attachment"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete a pet's profile photo",
+                "tags": [
+                    "Attachments"
+                ],
+                "summary": "Delete a pet photo",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/photo/thumbnail": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Download a thumbnail of a pet's profile photo",
+                "produces": [
+                    "image/jpeg"
+                ],
+                "tags": [
+                    "Attachments"
+                ],
+                "summary": "Get a pet photo thumbnail",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/providers": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the providers linked to a pet with the role each plays for it",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Providers"
+                ],
+                "summary": "Get the providers of a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.petProvider"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/providers/{ProviderID}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Add a provider from the user's directory to the care team of a pet, or change the role it plays",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Providers"
+                ],
+                "summary": "Link a provider to a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Provider ID",
+                        "name": "ProviderID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Role of the provider",
+                        "name": "link",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.petProviderRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Remove a provider from the care team of a pet. It stays in the user's directory.",
+                "tags": [
+                    "Providers"
+                ],
+                "summary": "Unlink a provider from a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Provider ID",
+                        "name": "ProviderID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/records": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all medical records of a pet, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Medical Records"
+                ],
+                "summary": "Get all medical records of a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.medicalRecord"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Record a vet visit, vaccination, medication, procedure or lab result for a pet, optionally with the provider from the user's directory",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Medical Records"
+                ],
+                "summary": "Create a medical record",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Create Medical Record",
+                        "name": "record",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.medicalRecordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.medicalRecord"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/records/{RecordID}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get one medical record of a pet",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Medical Records"
+                ],
+                "summary": "Get one medical record",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Record ID",
+                        "name": "RecordID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.medicalRecord"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update a medical record of a pet",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Medical Records"
+                ],
+                "summary": "Update a medical record",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Record ID",
+                        "name": "RecordID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated Medical Record",
+                        "name": "record",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.medicalRecordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.medicalRecord"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete a medical record of a pet",
+                "tags": [
+                    "Medical Records"
+                ],
+                "summary": "Delete a medical record",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Record ID",
+                        "name": "RecordID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/records/{RecordID}/attachments": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the metadata of the documents attached to a medical record",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Attachments"
+                ],
+                "summary": "Get all attachments of a medical record",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Record ID",
+                        "name": "RecordID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.attachment"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Upload a document such as a lab result or vaccination certificate for a medical record as multipart/form-data. It is downloaded and deleted like the other attachments of the pet.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Attachments"
+                ],
+                "summary": "Attach a document to a medical record",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Record ID",
+                        "name": "RecordID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "Document (PDF, plain text or image)",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Description",
+                        "name": "description",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.attachment"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Take a deleted pet and its records out of the trash",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pets"
+                ],
+                "summary": "Restore a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.pet"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/timeline": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get everything that happened to a pet in one list, newest first: when it was added, weights, medical records, journal notes, photos, documents and activities. Pass next_cursor back as cursor to get the next page.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Journal"
+                ],
+                "summary": "Get the timeline of a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated event kinds: created, weight, visit, vaccination, medication, procedure, lab, record, note, photo, document, activity",
+                        "name": "kind",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest date, YYYY-MM-DD",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date before which to stop, YYYY-MM-DD",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Events per page, 1 to 200, defaults to 50",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.timelinePage"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/weights": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all weight entries of a pet, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Journal"
+                ],
+                "summary": "Get the weight log of a pet",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.weightEntry"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Log the weight of a pet in whole grams",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Journal"
+                ],
+                "summary": "Log a weight",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Create Weight Entry",
+                        "name": "weight",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.weightRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.weightEntry"
+                        }
+                    }
+                }
+            }
+        },
+        "/pets/{PetID}/weights/{WeightID}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete a weight entry of a pet",
+                "tags": [
+                    "Journal"
+                ],
+                "summary": "Delete a weight entry",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pet ID",
+                        "name": "PetID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Weight ID",
+                        "name": "WeightID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/providers": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the vets, groomers, sitters and other care providers in the user's directory, emergency contacts first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Providers"
+                ],
+                "summary": "Get all providers",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only providers of this kind",
+                        "name": "kind",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.provider"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Add a vet, emergency clinic, groomer, sitter or other care provider to the user's directory",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Providers"
+                ],
+                "summary": "Create a provider",
+                "parameters": [
+                    {
+                        "description": "Create Provider",
+                        "name": "provider",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.providerRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.provider"
+                        }
+                    }
+                }
+            }
+        },
+        "/providers/{ProviderID}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get one provider in the user's directory",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Providers"
+                ],
+                "summary": "Get one provider",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Provider ID",
+                        "name": "ProviderID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.provider"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update a provider in the user's directory",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Providers"
+                ],
+                "summary": "Update a provider",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Provider ID",
+                        "name": "ProviderID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated Provider",
+                        "name": "provider",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.providerRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.provider"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete a provider from the user's directory. Medical records and appointments with it are kept.",
+                "tags": [
+                    "Providers"
+                ],
+                "summary": "Delete a provider",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Provider ID",
+                        "name": "ProviderID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/registry/contact/{Handle}": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Send a message to the owner of a pet found through a microchip lookup, without seeing their contact details",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Registry"
+                ],
+                "summary": "Contact the owner of a registered pet",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Contact handle",
+                        "name": "Handle",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Message",
+                        "name": "message",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.lostMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/registry/microchips/{Microchip}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Check whether a microchip is registered. Only shelter, vet and admin users may look up chips. Owner details are never returned, only a handle to contact the owner through the relay. Every lookup is logged.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Registry"
+                ],
+                "summary": "Look up a microchip",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Microchip number",
+                        "name": "Microchip",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.microchipLookup"
+                        }
+                    }
+                }
+            }
+        },
+        "/search": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Search the names and breeds of pets, journal notes, medical records and providers the user can access for all words of a query, most relevant first. Pets shared with an organization of the user are searched too. Snippets are HTML with the matching words in mark tags.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Search"
+                ],
+                "summary": "Search",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Words to search for",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Most hits to return, 20 by default and at most 100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.searchHit"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/users": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a user",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Get a user",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.userResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Create a user",
+                "parameters": [
+                    {
+                        "description": "Create User",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.userRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.userResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/calendar_token": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a calendar feed token, replacing and revoking any existing one",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Calendar"
+                ],
+                "summary": "Create a calendar feed token",
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.calendarTokenResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Revoke the calendar feed token, disabling the feed URL",
+                "tags": [
+                    "Calendar"
+                ],
+                "summary": "Revoke the calendar feed token",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.emptyBody"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.accessEvent": {
+            "type": "object",
+            "properties": {
+                "access_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "action": {
+                    "type": "string",
+                    "example": "granted"
+                },
+                "actor_email": {
+                    "type": "string",
+                    "example": "jane@example.com"
+                },
+                "actor_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2019-11-10T08:00:00+00:00"
+                },
+                "event_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "organization_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "api.activity": {
+            "type": "object",
+            "properties": {
+                "activity_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "distance_meters": {
+                    "type": "number",
+                    "example": 3200
+                },
+                "duration_seconds": {
+                    "type": "integer",
+                    "example": 2700
+                },
+                "elevation_gain_m": {
+                    "type": "number",
+                    "example": 42
+                },
+                "elevation_loss_m": {
+                    "type": "number",
+                    "example": 40
+                },
+                "has_route": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "walk"
+                },
+                "notes": {
+                    "type": "string",
+                    "example": "Morning loop around the park"
+                },
+                "pace_seconds_per_km": {
+                    "type": "integer",
+                    "example": 843
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "started_at": {
+                    "type": "string",
+                    "example": "2019-11-09T07:30:00Z"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "api.activityGoal": {
+            "type": "object",
+            "properties": {
+                "weekly_distance_meters": {
+                    "type": "number",
+                    "example": 20000
+                },
+                "weekly_minutes": {
+                    "type": "integer",
+                    "example": 210
+                },
+                "weekly_sessions": {
+                    "type": "integer",
+                    "example": 7
+                }
+            }
+        },
+        "api.activityRequest": {
+            "type": "object",
+            "required": [
+                "duration_seconds",
+                "kind",
+                "started_at"
+            ],
+            "properties": {
+                "distance_meters": {
+                    "type": "number",
+                    "example": 3200
+                },
+                "duration_seconds": {
+                    "type": "integer",
+                    "example": 2700
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "walk"
+                },
+                "notes": {
+                    "type": "string",
+                    "example": "Morning loop around the park"
+                },
+                "started_at": {
+                    "type": "string",
+                    "example": "2019-11-09T07:30:00Z"
+                }
+            }
+        },
+        "api.activitySummary": {
+            "type": "object",
+            "properties": {
+                "goal": {
+                    "$ref": "#/definitions/api.activityGoal"
+                },
+                "weeks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.activityWeek"
+                    }
+                }
+            }
+        },
+        "api.activityWeek": {
+            "type": "object",
+            "properties": {
+                "by_kind": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "distance_goal_percent": {
+                    "type": "integer",
+                    "example": 77
+                },
+                "distance_meters": {
+                    "type": "number",
+                    "example": 15400
+                },
+                "goal_met": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "minutes": {
+                    "type": "integer",
+                    "example": 180
+                },
+                "minutes_goal_percent": {
+                    "type": "integer",
+                    "example": 86
+                },
+                "sessions": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "sessions_goal_percent": {
+                    "type": "integer",
+                    "example": 71
+                },
+                "week_start": {
+                    "type": "string",
+                    "example": "2019-11-04"
+                }
+            }
+        },
+        "api.appointment": {
+            "type": "object",
+            "properties": {
+                "appointment_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "ends_at": {
+                    "type": "string",
+                    "example": "2019-11-20T10:00:00Z"
+                },
+                "notes": {
+                    "type": "string",
+                    "example": "Bring the vaccination booklet"
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "provider_id": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "provider_name": {
+                    "type": "string",
+                    "example": "Riverside Animal Hospital"
+                },
+                "starts_at": {
+                    "type": "string",
+                    "example": "2019-11-20T09:30:00Z"
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Annual checkup"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "api.appointmentRequest": {
+            "type": "object",
+            "required": [
+                "starts_at",
+                "title"
+            ],
+            "properties": {
+                "ends_at": {
+                    "type": "string",
+                    "example": "2019-11-20T10:00:00Z"
+                },
+                "notes": {
+                    "type": "string",
+                    "example": "Bring the vaccination booklet"
+                },
+                "provider_id": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "starts_at": {
+                    "type": "string",
+                    "example": "2019-11-20T09:30:00Z"
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Annual checkup"
+                }
+            }
+        },
+        "api.attachment": {
+            "type": "object",
+            "properties": {
+                "attachment_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "claim_id": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "content_type": {
+                    "type": "string",
+                    "example": "application/pdf"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "Annual checkup invoice"
+                },
+                "filename": {
+                    "type": "string",
+                    "example": "invoice.pdf"
+                },
+                "has_thumbnail": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "document"
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "record_id": {
+                    "type": "integer",
+                    "example": 4
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 48213
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "api.auditEntry": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "example": "update"
+                },
+                "actor_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "after": {
+                    "type": "object"
+                },
+                "audit_id": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "before": {
+                    "type": "object"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "entity_id": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "entity_type": {
+                    "type": "string",
+                    "example": "pet"
+                },
+                "ip": {
+                    "type": "string",
+                    "example": "203.0.113.7"
+                },
+                "owner_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "request_id": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015"
+                }
+            }
+        },
+        "api.auditPage": {
+            "type": "object",
+            "properties": {
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.auditEntry"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string",
+                    "example": "eyJpIjoxMn0"
+                }
+            }
+        },
+        "api.calendarTokenResponse": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string",
+                    "example": "4f9c1f0c8b7d4e2a9d1c3b5a7e9f0d2c4b6a8e0f1d3c5b7a9e1f3d5c7b9a1e3f"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "http://localhost:8080/api/v1/calendar.ics?token=4f9c1f0c8b7d4e2a9d1c3b5a7e9f0d2c4b6a8e0f1d3c5b7a9e1f3d5c7b9a1e3f"
+                }
+            }
+        },
+        "api.careTeam": {
+            "type": "object",
+            "properties": {
+                "emergency_contacts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.provider"
+                    }
+                },
+                "microchip": {
+                    "type": "string",
+                    "example": "985112345678903"
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "pet_name": {
+                    "type": "string",
+                    "example": "Fido"
+                },
+                "providers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.petProvider"
+                    }
+                },
+                "upcoming_appointments": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.appointment"
+                    }
+                }
+            }
+        },
+        "api.catalogBreed": {
+            "type": "object",
+            "properties": {
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "lab",
+                        "labrador"
+                    ]
+                },
+                "breed_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "custom": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Labrador Retriever"
+                }
+            }
+        },
+        "api.catalogEntryRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "mexican walking fish"
+                    ]
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Axolotl"
+                }
+            }
+        },
+        "api.catalogGender": {
+            "type": "object",
+            "properties": {
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "f",
+                        "girl"
+                    ]
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Female"
+                }
+            }
+        },
+        "api.catalogSpecies": {
+            "type": "object",
+            "properties": {
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "canine",
+                        "puppy"
+                    ]
+                },
+                "custom": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Dog"
+                },
+                "slug": {
+                    "type": "string",
+                    "example": "dog"
+                }
+            }
+        },
+        "api.claimRequest": {
+            "type": "object",
+            "required": [
+                "incident_date",
+                "policy_id"
+            ],
+            "properties": {
+                "claimed_minor": {
+                    "type": "integer",
+                    "example": 12550
+                },
+                "expense_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        1,
+                        2
+                    ]
+                },
+                "incident_date": {
+                    "type": "string",
+                    "example": "2019-11-09T00:00:00Z"
+                },
+                "notes": {
+                    "type": "string",
+                    "example": "Ear infection"
+                },
+                "policy_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "record_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        4
+                    ]
+                }
+            }
+        },
+        "api.claimStatusRequest": {
+            "type": "object",
+            "properties": {
+                "approved_minor": {
+                    "type": "integer",
+                    "example": 12000
+                },
+                "insurer_reference": {
+                    "type": "string",
+                    "example": "CLM-998877"
+                },
+                "paid_minor": {
+                    "type": "integer",
+                    "example": 9600
+                },
+                "status": {
+                    "type": "string",
+                    "example": "approved"
+                }
+            }
+        },
+        "api.coiResult": {
+            "type": "object",
+            "properties": {
+                "coefficient": {
+                    "type": "number",
+                    "example": 0.0625
+                },
+                "common_ancestors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.pedigreeAncestor"
+                    }
+                },
+                "dam_id": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "generations": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "percent": {
+                    "type": "number",
+                    "example": 6.25
+                },
+                "sire_id": {
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "api.emptyBody": {
+            "type": "object"
+        },
+        "api.expense": {
+            "type": "object",
+            "properties": {
+                "amount_minor": {
+                    "type": "integer",
+                    "example": 12550
+                },
+                "category": {
+                    "type": "string",
+                    "example": "vet"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "date": {
+                    "type": "string",
+                    "example": "2019-11-09T00:00:00Z"
+                },
+                "expense_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "notes": {
+                    "type": "string",
+                    "example": "Annual checkup and rabies booster"
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "receipt_attachment_id": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "vendor": {
+                    "type": "string",
+                    "example": "Riverside Animal Hospital"
+                }
+            }
+        },
+        "api.expenseReport": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string",
+                    "example": "2019-01-01"
+                },
+                "group_by": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "month",
+                        "category"
+                    ]
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.expenseReportRow"
+                    }
+                },
+                "to": {
+                    "type": "string",
+                    "example": "2020-01-01"
+                }
+            }
+        },
+        "api.expenseReportRow": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string",
+                    "example": "vet"
+                },
+                "count": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "month": {
+                    "type": "string",
+                    "example": "2019-11"
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "pet_name": {
+                    "type": "string",
+                    "example": "Fido"
+                },
+                "total": {
+                    "type": "string",
+                    "example": "125.50"
+                },
+                "total_minor": {
+                    "type": "integer",
+                    "example": 12550
+                }
+            }
+        },
+        "api.expenseRequest": {
+            "type": "object",
+            "required": [
+                "amount_minor",
+                "category",
+                "currency",
+                "date"
+            ],
+            "properties": {
+                "amount_minor": {
+                    "type": "integer",
+                    "example": 12550
+                },
+                "category": {
+                    "type": "string",
+                    "example": "vet"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "date": {
+                    "type": "string",
+                    "example": "2019-11-09T00:00:00Z"
+                },
+                "notes": {
+                    "type": "string",
+                    "example": "Annual checkup and rabies booster"
+                },
+                "receipt_attachment_id": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "vendor": {
+                    "type": "string",
+                    "example": "Riverside Animal Hospital"
+                }
+            }
+        },
+        "api.importResult": {
+            "type": "object",
+            "properties": {
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "pet_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "rows": {
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "api.insuranceClaim": {
+            "type": "object",
+            "properties": {
+                "approved_minor": {
+                    "type": "integer",
+                    "example": 12000
+                },
+                "claim_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "claimed_minor": {
+                    "type": "integer",
+                    "example": 12550
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "decided_at": {
+                    "type": "string",
+                    "example": "2019-11-20T09:00:00+00:00"
+                },
+                "expense_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        1,
+                        2
+                    ]
+                },
+                "incident_date": {
+                    "type": "string",
+                    "example": "2019-11-09T00:00:00Z"
+                },
+                "insurer_reference": {
+                    "type": "string",
+                    "example": "CLM-998877"
+                },
+                "notes": {
+                    "type": "string",
+                    "example": "Ear infection"
+                },
+                "paid_at": {
+                    "type": "string",
+                    "example": "2019-11-25T09:00:00+00:00"
+                },
+                "paid_minor": {
+                    "type": "integer",
+                    "example": 9600
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "policy_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "record_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        4
+                    ]
+                },
+                "status": {
+                    "type": "string",
+                    "example": "submitted"
+                },
+                "submitted_at": {
+                    "type": "string",
+                    "example": "2019-11-10T09:00:00+00:00"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "api.insurancePolicy": {
+            "type": "object",
+            "properties": {
+                "annual_limit_minor": {
+                    "type": "integer",
+                    "example": 500000
+                },
+                "coverage_end": {
+                    "type": "string",
+                    "example": "2022-06-01T00:00:00Z"
+                },
+                "coverage_start": {
+                    "type": "string",
+                    "example": "2019-06-01T00:00:00Z"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "deductible_minor": {
+                    "type": "integer",
+                    "example": 25000
+                },
+                "insurer": {
+                    "type": "string",
+                    "example": "Healthy Paws"
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "policy_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "policy_number": {
+                    "type": "string",
+                    "example": "HP-1234567"
+                },
+                "reimbursement_percent": {
+                    "type": "integer",
+                    "example": 80
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "api.journalNote": {
+            "type": "object",
+            "properties": {
+                "body": {
+                    "type": "string",
+                    "example": "Loved the **waves**, hated the sand."
+                },
+                "body_html": {
+                    "type": "string",
+                    "example": "\u003cp\u003eLoved the \u003cstrong\u003ewaves\u003c/strong\u003e, hated the sand.\u003c/p\u003e"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "note_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "occurred_at": {
+                    "type": "string",
+                    "example": "2019-11-09T15:00:00Z"
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "title": {
+                    "type": "string",
+                    "example": "First day at the beach"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "api.litter": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "dam_id": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "litter_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "notes": {
+                    "type": "string",
+                    "example": "Six puppies, all healthy"
+                },
+                "puppies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.pet"
+                    }
+                },
+                "puppy_count": {
+                    "type": "integer",
+                    "example": 6
+                },
+                "registration_number": {
+                    "type": "string",
+                    "example": "LR0012345"
+                },
+                "sire_id": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "whelped_on": {
+                    "type": "string",
+                    "example": "2019-11-09T00:00:00Z"
+                }
+            }
+        },
+        "api.litterRequest": {
+            "type": "object",
+            "required": [
+                "dam_id",
+                "whelped_on"
+            ],
+            "properties": {
+                "dam_id": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "notes": {
+                    "type": "string",
+                    "example": "Six puppies, all healthy"
+                },
+                "registration_number": {
+                    "type": "string",
+                    "example": "LR0012345"
+                },
+                "sire_id": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "whelped_on": {
+                    "type": "string",
+                    "example": "2019-11-09T00:00:00Z"
+                }
+            }
+        },
+        "api.lostMessage": {
+            "type": "object",
+            "properties": {
+                "contact": {
+                    "type": "string",
+                    "example": "jane@email.com"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "I think I saw your dog near the bakery on 5th street."
+                },
+                "message_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Jane"
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "api.lostMessageRequest": {
+            "type": "object",
+            "properties": {
+                "contact": {
+                    "type": "string",
+                    "example": "jane@email.com"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "I think I saw your dog near the bakery on 5th street."
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Jane"
+                },
+                "website": {
+                    "description": "Website is a honeypot, people never see the field but bots fill it in",
+                    "type": "string"
+                }
+            }
+        },
+        "api.lostPetProfile": {
+            "type": "object",
+            "properties": {
+                "breed": {
+                    "type": "string",
+                    "example": "Lab/Terrier Mix"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "Black lab with a red collar, very friendly"
+                },
+                "gender": {
+                    "type": "string",
+                    "example": "Female"
+                },
+                "last_seen_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "last_seen_latitude": {
+                    "type": "number",
+                    "example": 40.801
+                },
+                "last_seen_location": {
+                    "type": "string",
+                    "example": "Riverside Park near the north entrance"
+                },
+                "last_seen_longitude": {
+                    "type": "number",
+                    "example": -73.9723
+                },
+                "lost_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Fido"
+                },
+                "photo_url": {
+                    "type": "string",
+                    "example": "http://localhost:8080/api/v1/lost/mfrggzdfmztwq2lknnwg23tpob/photo"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "Dog"
+                }
+            }
+        },
+        "api.lostReport": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "Black lab with a red collar, very friendly"
+                },
+                "last_seen_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "last_seen_latitude": {
+                    "type": "number",
+                    "example": 40.801
+                },
+                "last_seen_location": {
+                    "type": "string",
+                    "example": "Riverside Park near the north entrance"
+                },
+                "last_seen_longitude": {
+                    "type": "number",
+                    "example": -73.9723
+                },
+                "lost_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "public_url": {
+                    "type": "string",
+                    "example": "http://localhost:8080/lost/mfrggzdfmztwq2lknnwg23tpob"
+                },
+                "slug": {
+                    "type": "string",
+                    "example": "mfrggzdfmztwq2lknnwg23tpob"
+                }
+            }
+        },
+        "api.lostReportRequest": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "Black lab with a red collar, very friendly"
+                },
+                "last_seen_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "last_seen_latitude": {
+                    "type": "number",
+                    "example": 40.801
+                },
+                "last_seen_location": {
+                    "type": "string",
+                    "example": "Riverside Park near the north entrance"
+                },
+                "last_seen_longitude": {
+                    "type": "number",
+                    "example": -73.9723
+                }
+            }
+        },
+        "api.medicalRecord": {
+            "type": "object",
+            "properties": {
+                "course_days": {
+                    "type": "integer",
+                    "example": 10
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "created_by": {
+                    "type": "integer",
+                    "example": 4
+                },
+                "dose_frequency": {
+                    "description": "The dosing schedule of a medication, how often it's given and for how\nmany days from occurred_on",
+                    "type": "string",
+                    "example": "daily"
+                },
+                "doses_per_day": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "due_on": {
+                    "type": "string",
+                    "example": "2022-11-09T00:00:00Z"
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "vaccination"
+                },
+                "notes": {
+                    "type": "string",
+                    "example": "No reaction, next booster in 3 years"
+                },
+                "occurred_on": {
+                    "type": "string",
+                    "example": "2019-11-09T00:00:00Z"
+                },
+                "organization_id": {
+                    "description": "OrgID is set when clinic staff added the record, CreatedBy is whoever did",
+                    "type": "integer",
+                    "example": 1
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "provider_id": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "record_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Rabies booster"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "api.medicalRecordRequest": {
+            "type": "object",
+            "required": [
+                "kind",
+                "occurred_on",
+                "title"
+            ],
+            "properties": {
+                "course_days": {
+                    "type": "integer",
+                    "example": 10
+                },
+                "dose_frequency": {
+                    "description": "DoseFrequency is empty for a single dose",
+                    "type": "string",
+                    "example": "daily"
+                },
+                "doses_per_day": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "due_on": {
+                    "type": "string",
+                    "example": "2022-11-09T00:00:00Z"
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "vaccination"
+                },
+                "notes": {
+                    "type": "string",
+                    "example": "No reaction, next booster in 3 years"
+                },
+                "occurred_on": {
+                    "type": "string",
+                    "example": "2019-11-09T00:00:00Z"
+                },
+                "provider_id": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Rabies booster"
+                }
+            }
+        },
+        "api.microchipLookup": {
+            "type": "object",
+            "properties": {
+                "contact_handle": {
+                    "type": "string",
+                    "example": "mfrggzdfmztwq2lknnwg23tpob"
+                },
+                "contact_url": {
+                    "type": "string",
+                    "example": "http://localhost:8080/api/v1/registry/contact/mfrggzdfmztwq2lknnwg23tpob"
+                },
+                "microchip": {
+                    "type": "string",
+                    "example": "985112345678903"
+                },
+                "registered": {
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "api.noteRequest": {
+            "type": "object",
+            "required": [
+                "body"
+            ],
+            "properties": {
+                "body": {
+                    "type": "string",
+                    "example": "Loved the **waves**, hated the sand."
+                },
+                "occurred_at": {
+                    "type": "string",
+                    "example": "2019-11-09T15:00:00Z"
+                },
+                "title": {
+                    "type": "string",
+                    "example": "First day at the beach"
+                }
+            }
+        },
+        "api.orgMember": {
+            "type": "object",
+            "properties": {
+                "added_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "email": {
+                    "type": "string",
+                    "example": "dr.patel@riverside.example"
+                },
+                "role": {
+                    "type": "string",
+                    "example": "vet"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 4
+                }
+            }
+        },
+        "api.orgMemberRequest": {
+            "type": "object",
+            "required": [
+                "email",
+                "role"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "dr.patel@riverside.example"
+                },
+                "role": {
+                    "type": "string",
+                    "example": "vet"
+                }
+            }
+        },
+        "api.organization": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "string",
+                    "example": "12 River Rd, Springfield"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Riverside Animal Hospital"
+                },
+                "organization_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "+1 555 0100"
+                },
+                "role": {
+                    "type": "string",
+                    "example": "admin"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                }
+            }
+        },
+        "api.organizationRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "address": {
+                    "type": "string",
+                    "example": "12 River Rd, Springfield"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Riverside Animal Hospital"
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "+1 555 0100"
+                }
+            }
+        },
+        "api.pedigreeAncestor": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "Rex"
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 7
+                }
+            }
+        },
+        "api.pedigreeNode": {
+            "type": "object",
+            "properties": {
+                "birthday": {
+                    "type": "string",
+                    "example": "2019-11-09T00:00:00Z"
+                },
+                "breed": {
+                    "type": "string",
+                    "example": "Labrador Retriever"
+                },
+                "dam": {
+                    "$ref": "#/definitions/api.pedigreeNode"
+                },
+                "gender": {
+                    "type": "string",
+                    "example": "Male"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Fido"
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "registration_number": {
+                    "type": "string",
+                    "example": "SR12345678"
+                },
+                "sire": {
+                    "$ref": "#/definitions/api.pedigreeNode"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "Dog"
+                }
+            }
+        },
+        "api.pet": {
+            "type": "object",
+            "properties": {
+                "birthday": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "breed": {
+                    "type": "string",
+                    "example": "Lab/Terrier Mix"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "dam_id": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "deleted_at": {
+                    "type": "string",
+                    "example": "2019-11-10T08:00:00+00:00"
+                },
+                "gender": {
+                    "type": "string",
+                    "example": "Female"
+                },
+                "license_tag": {
+                    "type": "string",
+                    "example": "2019-004512"
+                },
+                "litter_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "microchip": {
+                    "type": "string",
+                    "example": "985112345678903"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Fido"
+                },
+                "pet_id": {
+                    "type": "integer"
+                },
+                "registration_number": {
+                    "type": "string",
+                    "example": "SR12345678"
+                },
+                "sire_id": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "tattoo": {
+                    "type": "string",
+                    "example": "ABC123"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "Dog"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "user_id": {
+                    "type": "integer"
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "api.petAccess": {
+            "type": "object",
+            "properties": {
+                "access_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "decided_at": {
+                    "type": "string",
+                    "example": "2019-11-10T08:00:00+00:00"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Fido is booked in for surgery on Friday"
+                },
+                "organization_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "organization_name": {
+                    "type": "string",
+                    "example": "Riverside Animal Hospital"
+                },
+                "owner_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "pet_name": {
+                    "type": "string",
+                    "example": "Fido"
+                },
+                "requested_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "requested_by": {
+                    "type": "integer",
+                    "example": 4
+                },
+                "status": {
+                    "type": "string",
+                    "example": "pending"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2019-11-10T08:00:00+00:00"
+                }
+            }
+        },
+        "api.petAccessRequest": {
+            "type": "object",
+            "required": [
+                "owner_email",
+                "pet_id"
+            ],
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "Fido is booked in for surgery on Friday"
+                },
+                "owner_email": {
+                    "type": "string",
+                    "example": "jane@example.com"
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "api.petAccessStatusRequest": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string",
+                    "example": "granted"
+                }
+            }
+        },
+        "api.petProvider": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "string",
+                    "example": "12 River Rd, Springfield"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "email": {
+                    "type": "string",
+                    "example": "front-desk@riverside.example"
+                },
+                "emergency": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "hours": {
+                    "type": "string",
+                    "example": "Mon-Fri 8:00-18:00, Sat 9:00-13:00"
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "vet"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Riverside Animal Hospital"
+                },
+                "notes": {
+                    "type": "string",
+                    "example": "Ask for Dr. Patel"
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "+1 555 0100"
+                },
+                "provider_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "role": {
+                    "type": "string",
+                    "example": "Primary vet"
+                },
+                "updated_at": {
                     "type": "string",
                     "example": "2019-11-09T21:21:46+00:00"
                 },
                 "user_id": {
-                    "type": "integer"
+                    "type": "integer",
+                    "example": 1
+                },
+                "website": {
+                    "type": "string",
+                    "example": "https://riverside.example"
+                }
+            }
+        },
+        "api.petProviderRequest": {
+            "type": "object",
+            "properties": {
+                "role": {
+                    "type": "string",
+                    "example": "Primary vet"
                 }
             }
         },
         "api.petRequest": {
             "type": "object",
+            "required": [
+                "name"
+            ],
             "properties": {
                 "birthday": {
                     "type": "string",
@@ -339,20 +7408,337 @@ var doc = `{
                     "type": "string",
                     "example": "Lab/Terrier Mix"
                 },
+                "dam_id": {
+                    "type": "integer",
+                    "example": 3
+                },
                 "gender": {
                     "type": "string",
                     "example": "Female"
                 },
+                "license_tag": {
+                    "type": "string",
+                    "example": "2019-004512"
+                },
+                "litter_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "microchip": {
+                    "type": "string",
+                    "example": "985112345678903"
+                },
                 "name": {
                     "type": "string",
                     "example": "Fido"
                 },
+                "registration_number": {
+                    "type": "string",
+                    "example": "SR12345678"
+                },
+                "sire_id": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "tattoo": {
+                    "type": "string",
+                    "example": "ABC123"
+                },
                 "type": {
                     "type": "string",
                     "example": "Dog"
                 }
             }
         },
+        "api.policyRequest": {
+            "type": "object",
+            "required": [
+                "coverage_start",
+                "currency",
+                "insurer",
+                "policy_number",
+                "reimbursement_percent"
+            ],
+            "properties": {
+                "annual_limit_minor": {
+                    "type": "integer",
+                    "example": 500000
+                },
+                "coverage_end": {
+                    "type": "string",
+                    "example": "2022-06-01T00:00:00Z"
+                },
+                "coverage_start": {
+                    "type": "string",
+                    "example": "2019-06-01T00:00:00Z"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "deductible_minor": {
+                    "type": "integer",
+                    "example": 25000
+                },
+                "insurer": {
+                    "type": "string",
+                    "example": "Healthy Paws"
+                },
+                "policy_number": {
+                    "type": "string",
+                    "example": "HP-1234567"
+                },
+                "reimbursement_percent": {
+                    "type": "integer",
+                    "example": 80
+                }
+            }
+        },
+        "api.policyYearSummary": {
+            "type": "object",
+            "properties": {
+                "annual_limit_minor": {
+                    "type": "integer",
+                    "example": 500000
+                },
+                "claims": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "deductible_met_minor": {
+                    "type": "integer",
+                    "example": 12000
+                },
+                "deductible_minor": {
+                    "type": "integer",
+                    "example": 25000
+                },
+                "deductible_remaining_minor": {
+                    "type": "integer",
+                    "example": 13000
+                },
+                "limit_remaining_minor": {
+                    "type": "integer",
+                    "example": 500000
+                },
+                "pending_minor": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "policy_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "reimbursed_minor": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "year_end": {
+                    "type": "string",
+                    "example": "2020-06-01"
+                },
+                "year_start": {
+                    "type": "string",
+                    "example": "2019-06-01"
+                }
+            }
+        },
+        "api.provider": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "string",
+                    "example": "12 River Rd, Springfield"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "email": {
+                    "type": "string",
+                    "example": "front-desk@riverside.example"
+                },
+                "emergency": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "hours": {
+                    "type": "string",
+                    "example": "Mon-Fri 8:00-18:00, Sat 9:00-13:00"
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "vet"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Riverside Animal Hospital"
+                },
+                "notes": {
+                    "type": "string",
+                    "example": "Ask for Dr. Patel"
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "+1 555 0100"
+                },
+                "provider_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "website": {
+                    "type": "string",
+                    "example": "https://riverside.example"
+                }
+            }
+        },
+        "api.providerRequest": {
+            "type": "object",
+            "required": [
+                "kind",
+                "name"
+            ],
+            "properties": {
+                "address": {
+                    "type": "string",
+                    "example": "12 River Rd, Springfield"
+                },
+                "email": {
+                    "type": "string",
+                    "example": "front-desk@riverside.example"
+                },
+                "emergency": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "hours": {
+                    "type": "string",
+                    "example": "Mon-Fri 8:00-18:00, Sat 9:00-13:00"
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "vet"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Riverside Animal Hospital"
+                },
+                "notes": {
+                    "type": "string",
+                    "example": "Ask for Dr. Patel"
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "+1 555 0100"
+                },
+                "website": {
+                    "type": "string",
+                    "example": "https://riverside.example"
+                }
+            }
+        },
+        "api.roleRequest": {
+            "type": "object",
+            "properties": {
+                "role": {
+                    "type": "string",
+                    "example": "shelter"
+                }
+            }
+        },
+        "api.searchHit": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "organization_id": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "score": {
+                    "type": "number",
+                    "example": 0.42
+                },
+                "snippet": {
+                    "type": "string",
+                    "example": "Booster for \u003cmark\u003erabies\u003c/mark\u003e, next one due in three years"
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Rabies vaccination"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "record"
+                }
+            }
+        },
+        "api.timelineEvent": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string",
+                    "example": "No reaction, next booster in 3 years"
+                },
+                "detail_html": {
+                    "type": "string",
+                    "example": "\u003cp\u003eLoved the \u003cstrong\u003ewaves\u003c/strong\u003e\u003c/p\u003e"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 4
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "vaccination"
+                },
+                "occurred_at": {
+                    "type": "string",
+                    "example": "2019-11-09T00:00:00Z"
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Rabies booster"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "/api/v1/pets/1/records/4"
+                }
+            }
+        },
+        "api.timelinePage": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.timelineEvent"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string",
+                    "example": "eyJ0IjoiMjAxOS0xMS0wOVQwMDowMDowMFoiLCJrIjoidmFjY2luYXRpb24iLCJpIjo0fQ"
+                }
+            }
+        },
         "api.token": {
             "type": "object",
             "properties": {
@@ -368,6 +7754,10 @@ var doc = `{
         },
         "api.userRequest": {
             "type": "object",
+            "required": [
+                "email",
+                "password"
+            ],
             "properties": {
                 "email": {
                     "type": "string",
@@ -394,6 +7784,10 @@ var doc = `{
                     "type": "string",
                     "example": "2019-11-09T21:21:46+00:00"
                 },
+                "role": {
+                    "type": "string",
+                    "example": "owner"
+                },
                 "updated_at": {
                     "type": "string",
                     "example": "2019-11-09T21:21:46+00:00"
@@ -403,6 +7797,59 @@ var doc = `{
                     "example": 1
                 }
             }
+        },
+        "api.weightEntry": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string",
+                    "example": "2019-11-09T21:21:46+00:00"
+                },
+                "measured_at": {
+                    "type": "string",
+                    "example": "2019-11-09T09:00:00Z"
+                },
+                "notes": {
+                    "type": "string",
+                    "example": "Weighed at the vet"
+                },
+                "pet_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "weight_grams": {
+                    "type": "integer",
+                    "example": 12500
+                },
+                "weight_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "api.weightRequest": {
+            "type": "object",
+            "required": [
+                "weight_grams"
+            ],
+            "properties": {
+                "measured_at": {
+                    "type": "string",
+                    "example": "2019-11-09T09:00:00Z"
+                },
+                "notes": {
+                    "type": "string",
+                    "example": "Weighed at the vet"
+                },
+                "weight_grams": {
+                    "type": "integer",
+                    "example": 12500
+                }
+            }
         }
     },
     "securityDefinitions": {
@@ -3,12 +3,23 @@ package main
 import (
 	"log"
 
+	"github.com/namsral/flag"
 	"github.com/rizkybiz/petkeep-server/api"
 	"github.com/rizkybiz/petkeep-server/config"
 )
 
 func main() {
 	cfg := config.Generate()
+
+	// Flags come before the subcommand, e.g. petkeep-server -api-database-driver=sqlite migrate up
+	if args := flag.Args(); len(args) > 0 && args[0] == "migrate" {
+		err := api.Migrate(cfg, args[1:])
+		if err != nil {
+			log.Fatalf("Could not migrate the database: %s", err)
+		}
+		return
+	}
+
 	err := api.StartServer(cfg)
 	if err != nil {
 		log.Fatalf("Could not start the API server: %s", err)